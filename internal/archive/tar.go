@@ -0,0 +1,156 @@
+// Package archive creates and extracts tar archives.
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/simplesurance/baur/v5/internal/fs"
+)
+
+// modTime is the modification time of all archive entries. Using a fixed
+// time makes archives of the same files reproducible.
+var modTime = time.Unix(0, 0).UTC()
+
+// CreateTar creates a tar archive at dst containing files.
+// files are paths relative to baseDir, they are stored with their relative
+// path in the archive.
+// Archives of the same files are byte-identical: entries are sorted by path,
+// the modification time is set to a fixed value, owner information is omitted
+// and only the permission bits of the file mode are stored.
+func CreateTar(dst, baseDir string, files []string) error {
+	files = slices.Clone(files)
+	slices.Sort(files)
+
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(f)
+
+	for _, relPath := range files {
+		if err := addFile(tw, baseDir, relPath); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing %s failed: %w", dst, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing %s failed: %w", dst, err)
+	}
+
+	return nil
+}
+
+func addFile(tw *tar.Writer, baseDir, relPath string) error {
+	absPath := filepath.Join(baseDir, relPath)
+
+	fi, err := os.Stat(absPath)
+	if err != nil {
+		return err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", absPath)
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     filepath.ToSlash(relPath),
+		Mode:     int64(fi.Mode().Perm()),
+		Size:     fi.Size(),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return fmt.Errorf("writing tar header for %s failed: %w", absPath, err)
+	}
+
+	f, err := os.Open(absPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("adding %s to archive failed: %w", absPath, err)
+	}
+
+	return nil
+}
+
+// ExtractTar extracts the tar archive src into destDir.
+// Existing files are overwritten. Only regular files and directories are
+// supported, entries with paths that are absolute or refer to locations
+// outside of destDir cause an error.
+func ExtractTar(src, destDir string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("reading %s failed: %w", src, err)
+		}
+
+		relPath := filepath.FromSlash(path.Clean(hdr.Name))
+		if !filepath.IsLocal(relPath) {
+			return fmt.Errorf("archive contains invalid path %q", hdr.Name)
+		}
+		dest := filepath.Join(destDir, relPath)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := fs.Mkdir(dest); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := extractFile(tr, dest, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("archive entry %q has unsupported type %q", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+func extractFile(r io.Reader, dest string, perm os.FileMode) error {
+	if err := fs.Mkdir(filepath.Dir(dest)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("extracting %s failed: %w", dest, err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,82 @@
+package archive
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), perm))
+}
+
+func TestCreateTarIsReproducible(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "b", "2.txt"), "2", 0o644)
+	writeFile(t, filepath.Join(dir, "a.txt"), "1", 0o755)
+
+	tar1 := filepath.Join(t.TempDir(), "1.tar")
+	require.NoError(t, CreateTar(tar1, dir, []string{"b/2.txt", "a.txt"}))
+
+	mtime := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "a.txt"), mtime, mtime))
+
+	tar2 := filepath.Join(t.TempDir(), "2.tar")
+	require.NoError(t, CreateTar(tar2, dir, []string{"a.txt", "b/2.txt"}))
+
+	content1, err := os.ReadFile(tar1)
+	require.NoError(t, err)
+	content2, err := os.ReadFile(tar2)
+	require.NoError(t, err)
+	assert.Equal(t, content1, content2)
+}
+
+func TestExtractTarRestoresFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "dist", "index.html"), "<html>", 0o644)
+	writeFile(t, filepath.Join(dir, "dist", "bin", "run.sh"), "#!/bin/sh", 0o755)
+
+	archive := filepath.Join(t.TempDir(), "dist.tar")
+	require.NoError(t, CreateTar(archive, dir, []string{"dist/index.html", "dist/bin/run.sh"}))
+
+	destDir := t.TempDir()
+	require.NoError(t, ExtractTar(archive, destDir))
+
+	content, err := os.ReadFile(filepath.Join(destDir, "dist", "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "<html>", string(content))
+
+	fi, err := os.Stat(filepath.Join(destDir, "dist", "bin", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), fi.Mode().Perm())
+}
+
+func TestExtractTarRejectsPathsOutsideDestDir(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.tar")
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+
+	tw := tar.NewWriter(f)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "../evil",
+		Mode:     0o644,
+		Size:     1,
+	}))
+	_, err = tw.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+
+	destDir := filepath.Join(t.TempDir(), "dest")
+	require.ErrorContains(t, ExtractTar(archive, destDir), "invalid path")
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(destDir), "evil"))
+}
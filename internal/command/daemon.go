@@ -0,0 +1,96 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur/v5/internal/command/term"
+	"github.com/simplesurance/baur/v5/internal/daemon"
+	"github.com/simplesurance/baur/v5/internal/fswatch"
+)
+
+var daemonLongHelp = fmt.Sprintf(`
+Start a daemon that resolves the file inputs of tasks for other baur commands.
+
+The daemon keeps resolved inputs, file digests and the object IDs of the files
+tracked in the git repository cached between commands.
+It watches the directories that contain the inputs of the resolved tasks and
+discards the cached information about files that change. When the git
+repository changes, e.g. because files are staged or a commit is checked out,
+all cached information are discarded.
+
+When a daemon is running for the repository, other baur commands resolve file
+inputs via it. Otherwise, or when the daemon fails to serve a request, they are
+resolved in-process.
+GolangSources inputs are resolved with the environment variables of the
+command that sends the request.
+
+The daemon listens on a unix domain socket in a baur-<UID> directory in
+%s, or in the directory for temporary files if it is unset. The
+directory is only accessible by the user. Commands do not use the daemon if
+the directory or the daemon process are not owned by the user.
+The daemon runs until it is terminated.
+
+Running the daemon is only supported on linux.
+`,
+	term.Highlight("$XDG_RUNTIME_DIR"),
+)
+
+func init() {
+	rootCmd.AddCommand(&newDaemonCmd().Command)
+}
+
+type daemonCmd struct {
+	cobra.Command
+}
+
+func newDaemonCmd() *daemonCmd {
+	cmd := daemonCmd{
+		Command: cobra.Command{
+			Use:   "daemon",
+			Short: "run a daemon that caches resolved inputs",
+			Long:  strings.TrimSpace(daemonLongHelp),
+			Args:  cobra.NoArgs,
+		},
+	}
+
+	cmd.Run = cmd.run
+
+	return &cmd
+}
+
+func (c *daemonCmd) run(*cobra.Command, []string) {
+	if !fswatch.Supported() {
+		stderr.Printf("running the daemon is not supported on %s\n", runtime.GOOS)
+		exitFunc(exitCodeError)
+	}
+
+	repo := mustFindRepository()
+	socketPath := daemon.SocketPath(repo.Path)
+
+	err := daemon.NewClient(socketPath, repo.Path, true).Ping(ctx)
+	if !errors.Is(err, daemon.ErrNotRunning) && !errors.Is(err, daemon.ErrUntrusted) {
+		fatalf("a baur daemon is already listening on %s\n", socketPath)
+	}
+
+	srv, err := daemon.NewServer(ctx, repo.Path)
+	exitOnErr(err)
+	defer srv.Close()
+
+	ln, err := daemon.Listen(socketPath)
+	exitOnErr(err)
+
+	daemonCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	stdout.Printf("listening on %s\n", term.Highlight(socketPath))
+
+	exitOnErr(srv.Serve(daemonCtx, ln))
+}
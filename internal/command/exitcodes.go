@@ -6,4 +6,5 @@ const (
 	exitCodeAlreadyExist     = 2
 	exitCodeTaskRunIsPending = 3
 	exitCodeNotExist         = 4
+	exitCodeInterrupted      = 5
 )
@@ -0,0 +1,190 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur/v5/internal/command/term"
+	"github.com/simplesurance/baur/v5/internal/log"
+	"github.com/simplesurance/baur/v5/internal/output/docker"
+	"github.com/simplesurance/baur/v5/internal/output/filecopy"
+	"github.com/simplesurance/baur/v5/internal/output/s3"
+	"github.com/simplesurance/baur/v5/pkg/baur"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+const fetchExample = `
+baur fetch calc.build		download the outputs of the recorded run of the build task of the calc application
+baur fetch *.build		download the outputs of recorded runs of all tasks named build
+`
+
+var fetchLongHelp = fmt.Sprintf(`
+Download the outputs of recorded task runs.
+
+For each task with status %s, the outputs of the run that matches the
+current inputs are downloaded to the locations declared in the task
+configuration.
+File outputs are downloaded from their filecopy or S3 destination, docker
+images are pulled from the registry and their image ID is written to the
+declared IDFile.
+After the download, the digest of each output is verified against the
+recorded one.
+
+Arguments:
+%s
+
+Exit Codes:
+  %d - Success
+  %d - Error
+  %d - No recorded run exists for a task
+
+The following Environment Variables are supported:
+    %s
+
+  S3 Download:
+    %s
+    %s
+    %s
+
+  Docker Registry Download:
+    %s
+    %s
+    %s
+    %s
+`,
+	term.ColoredTaskStatus(baur.TaskStatusRunExist),
+	targetHelp,
+	exitCodeSuccess,
+	exitCodeError,
+	exitCodeNotExist,
+
+	term.Highlight(envVarPSQLURL),
+
+	term.Highlight("AWS_REGION"),
+	term.Highlight("AWS_ACCESS_KEY_ID"),
+	term.Highlight("AWS_SECRET_ACCESS_KEY"),
+
+	term.Highlight("DOCKER_HOST"),
+	term.Highlight("DOCKER_API_VERSION"),
+	term.Highlight("DOCKER_CERT_PATH"),
+	term.Highlight("DOCKER_TLS_VERIFY"))
+
+func init() {
+	rootCmd.AddCommand(&newFetchCmd().Command)
+}
+
+type fetchCmd struct {
+	cobra.Command
+
+	inputStr       []string
+	lookupInputStr string
+}
+
+func newFetchCmd() *fetchCmd {
+	cmd := fetchCmd{
+		Command: cobra.Command{
+			Use:               "fetch [TARGET|APP_DIR]...",
+			Short:             "download outputs of recorded task runs",
+			Long:              strings.TrimSpace(fetchLongHelp),
+			Example:           strings.TrimSpace(fetchExample),
+			ValidArgsFunction: newCompleteTargetFunc(completeTargetFuncOpts{}),
+		},
+	}
+
+	cmd.Run = cmd.run
+
+	cmd.Flags().StringArrayVar(&cmd.inputStr, "input-str", nil,
+		"include a string as input, can be specified multiple times")
+	cmd.Flags().StringVar(&cmd.lookupInputStr, "lookup-input-str", "",
+		"if a run can not be found, try to find a run with this value as input-string")
+
+	return &cmd
+}
+
+func (c *fetchCmd) run(_ *cobra.Command, args []string) {
+	repo := mustFindRepository()
+	vcsState := mustGetRepoState(repo.Path)
+
+	storageClt := mustNewCompatibleStorageRepo(repo)
+	defer storageClt.Close()
+
+	loader, err := baur.NewLoader(repo.Cfg, vcsState.CommitID, log.StdLogger)
+	exitOnErr(err)
+
+	tasks, err := loader.LoadTasks(args...)
+	exitOnErr(err)
+
+	baur.SortTasksByID(tasks)
+
+	statusEvaluator := baur.NewTaskStatusEvaluator(
+		repo.Path,
+		storageClt,
+		newInputResolver(
+			vcsState,
+			repo.Path,
+			baur.AsInputStrings(c.inputStr...),
+			true,
+		),
+		c.lookupInputStr,
+	)
+
+	restorer := mustNewOutputRestorer()
+
+	var runsMissing bool
+	for _, task := range tasks {
+		status, _, run, err := statusEvaluator.Status(ctx, task)
+		exitOnErrf(err, "%s: evaluating task status failed", task)
+
+		if status != baur.TaskStatusRunExist {
+			stderr.Printf("%s: task has status %s, no recorded run exists\n",
+				term.Highlight(task), term.ColoredTaskStatus(status))
+			runsMissing = true
+			continue
+		}
+
+		exitOnErr(restoreOutputs(storageClt, restorer, task, run.ID))
+	}
+
+	if runsMissing {
+		exitFunc(exitCodeNotExist)
+	}
+}
+
+func mustNewOutputRestorer() *baur.OutputRestorer {
+	dockerClient, err := docker.NewClient(log.StdLogger.Debugf)
+	exitOnErr(err)
+
+	s3Client, err := s3.NewClient(ctx, log.StdLogger)
+	exitOnErr(err)
+
+	return baur.NewOutputRestorer(s3Client, dockerClient, filecopy.New(log.Debugf))
+}
+
+// restoreOutputs downloads the outputs of the task run with ID runID to the
+// locations declared in the configuration of task.
+func restoreOutputs(storageClt storage.Storer, restorer *baur.OutputRestorer, task *baur.Task, runID int) error {
+	outputs, err := storageClt.Outputs(ctx, runID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			stdout.TaskPrintf(task, "run %s has no recorded outputs\n", term.Highlight(runID))
+			return nil
+		}
+
+		return fmt.Errorf("%s: querying outputs of run %d failed: %w", task, runID, err)
+	}
+
+	err = restorer.Restore(ctx, task, outputs, func(_ *storage.Output, uri, dest string) {
+		stdout.TaskPrintf(task, "downloading %s -> %s\n", uri, dest)
+	})
+	if err != nil {
+		return err
+	}
+
+	stdout.TaskPrintf(task, "outputs of run %s restored %s\n",
+		term.Highlight(runID), term.GreenHighlight("successfully"))
+
+	return nil
+}
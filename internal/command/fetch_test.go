@@ -0,0 +1,165 @@
+//go:build dbtest
+
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/testutils/repotest"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+func createFetchTestApp(t *testing.T, r *repotest.Repo) {
+	t.Helper()
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"bash", "-c", "mkdir -p dist && echo built > dist/out.txt"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+				Output: cfg.Output{
+					File: []cfg.FileOutput{
+						{
+							Path:     "dist/out.txt",
+							FileCopy: []cfg.FileCopy{{Path: r.FilecopyArtifactDir}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+}
+
+func TestFetchRestoresOutputs(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	createFetchTestApp(t, r)
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	require.NoError(t, runCmdTest.Execute())
+
+	outputPath := filepath.Join(r.Dir, "dist", "out.txt")
+	require.NoError(t, os.RemoveAll(filepath.Join(r.Dir, "dist")))
+
+	fetchCmd := newFetchCmd()
+	fetchCmd.SetArgs([]string{"testapp.build"})
+	stdout, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, fetchCmd.Execute())
+	require.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+	assert.Contains(t, stdout.String(), "testapp.build: downloading "+filepath.Join(r.FilecopyArtifactDir, "out.txt"))
+
+	content, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "built\n", string(content))
+}
+
+func TestFetchFailsWhenNoRunExists(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	createFetchTestApp(t, r)
+	doInitDb(t)
+
+	fetchCmd := newFetchCmd()
+	_, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, fetchCmd.Execute())
+	assert.Equal(t, exitCodeNotExist, exitCode)
+	assert.Contains(t, stderr.String(), "testapp.build: task has status")
+}
+
+func TestRunRestoreOutputsDownloadsOutputsOfExistingRuns(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	createFetchTestApp(t, r)
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	require.NoError(t, runCmdTest.Execute())
+
+	require.NoError(t, os.RemoveAll(filepath.Join(r.Dir, "dist")))
+
+	runCmdTest = newRunCmd()
+	runCmdTest.SetArgs([]string{"--restore-outputs"})
+	stdout, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, runCmdTest.Execute())
+	require.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+	assert.Contains(t, stdout.String(), "testapp.build: outputs of run 1 restored")
+	assert.FileExists(t, filepath.Join(r.Dir, "dist", "out.txt"))
+}
+
+func TestFetchRestoresDirectoryOutputs(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"bash", "-c", "mkdir -p site/css && echo index > site/index.html && echo css > site/css/main.css"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+				Output: cfg.Output{
+					File: []cfg.FileOutput{
+						{
+							Path:     "site",
+							FileCopy: []cfg.FileCopy{{Path: r.FilecopyArtifactDir}},
+						},
+					},
+				},
+			},
+		},
+	}
+	require.NoError(t, appCfg.ToFile(filepath.Join(r.Dir, ".app.toml")))
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	stdout, _ := interceptCmdOutput(t)
+	require.NoError(t, runCmdTest.Execute())
+	assert.Contains(t, stdout.String(), "files: site uploaded to "+filepath.Join(r.FilecopyArtifactDir, "site.tar"))
+
+	require.NoError(t, os.RemoveAll(filepath.Join(r.Dir, "site")))
+
+	fetchCmd := newFetchCmd()
+	_, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, fetchCmd.Execute())
+	require.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+
+	content, err := os.ReadFile(filepath.Join(r.Dir, "site", "css", "main.css"))
+	require.NoError(t, err)
+	assert.Equal(t, "css\n", string(content))
+	assert.FileExists(t, filepath.Join(r.Dir, "site", "index.html"))
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"sync"
+
+	"github.com/simplesurance/baur/v5/internal/digest/digestcache"
+	"github.com/simplesurance/baur/v5/internal/digest/gitobjectid"
+	"github.com/simplesurance/baur/v5/internal/log"
+	"github.com/simplesurance/baur/v5/pkg/baur"
+)
+
+var (
+	fileDigestCachesMu sync.Mutex
+	// fileDigestCaches contains the digest caches of the used
+	// repositories, the key is the repository directory.
+	fileDigestCaches = map[string]*digestcache.Cache{}
+)
+
+// withFileDigestCache returns an InputResolverOpt that configures the
+// InputResolver to look up the digests of files that are untracked or modified
+// in git in the persistent digest cache of the repository.
+// If the cache directory can not be determined, the digests are not cached.
+func withFileDigestCache(repositoryDir string) baur.InputResolverOpt {
+	fileDigestCachesMu.Lock()
+	defer fileDigestCachesMu.Unlock()
+
+	if c, exists := fileDigestCaches[repositoryDir]; exists {
+		return baur.WithFileHashFn(c.File)
+	}
+
+	cacheDir, err := digestcache.DefaultDir()
+	if err != nil {
+		log.Debugf("not caching file digests, determining cache directory failed: %s\n", err)
+		return func(*baur.InputResolver) {}
+	}
+
+	c := digestcache.New(
+		digestcache.FilePath(cacheDir, repositoryDir),
+		gitobjectid.New(repositoryDir, log.Debugf).File,
+		log.Debugf,
+	)
+	fileDigestCaches[repositoryDir] = c
+
+	return baur.WithFileHashFn(c.File)
+}
+
+// saveFileDigestCaches writes the digest caches that were used to their files.
+// It is called when a command finished or exits via exitFunc. Long running
+// commands can call it additionally to persist the digests earlier.
+// Errors are only logged, the caches are an optimization.
+func saveFileDigestCaches() {
+	fileDigestCachesMu.Lock()
+	defer fileDigestCachesMu.Unlock()
+
+	for _, c := range fileDigestCaches {
+		if err := c.Save(); err != nil {
+			log.Debugf("storing file digest cache failed: %s\n", err)
+		}
+	}
+}
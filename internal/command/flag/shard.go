@@ -0,0 +1,65 @@
+package flag
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ShardFormatDescr describes the format of the shard flag.
+const ShardFormatDescr = "K/N"
+
+// Shard is a flag that selects the K-th of N shards.
+type Shard struct {
+	// Index is the 1-based number of the selected shard.
+	Index int
+	// Count is the number of shards, it is 0 when the flag was not set.
+	Count int
+}
+
+// String returns the default value in the usage output
+func (s *Shard) String() string {
+	return ""
+}
+
+// Set parses the passed string in the format K/N, K must be between 1 and N.
+func (s *Shard) Set(val string) error {
+	kStr, nStr, found := strings.Cut(val, "/")
+	if !found {
+		return fmt.Errorf("shard must be in the format %s", ShardFormatDescr)
+	}
+
+	k, err := strconv.Atoi(kStr)
+	if err != nil {
+		return fmt.Errorf("shard number %q is not a number", kStr)
+	}
+
+	n, err := strconv.Atoi(nStr)
+	if err != nil {
+		return fmt.Errorf("shard count %q is not a number", nStr)
+	}
+
+	if n < 1 {
+		return errors.New("shard count must be greater than 0")
+	}
+
+	if k < 1 || k > n {
+		return fmt.Errorf("shard number must be between 1 and %d", n)
+	}
+
+	s.Index = k
+	s.Count = n
+
+	return nil
+}
+
+// Type returns the format description of the flag
+func (*Shard) Type() string {
+	return ShardFormatDescr
+}
+
+// IsSet returns true if the flag was set.
+func (s *Shard) IsSet() bool {
+	return s.Count > 0
+}
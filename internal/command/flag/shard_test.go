@@ -0,0 +1,32 @@
+package flag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardSet(t *testing.T) {
+	var s Shard
+	assert.False(t, s.IsSet())
+
+	require.NoError(t, s.Set("3/8"))
+	assert.Equal(t, 3, s.Index)
+	assert.Equal(t, 8, s.Count)
+	assert.True(t, s.IsSet())
+
+	require.NoError(t, s.Set("1/1"))
+	assert.Equal(t, 1, s.Index)
+	assert.Equal(t, 1, s.Count)
+}
+
+func TestShardSetInvalid(t *testing.T) {
+	for _, val := range []string{"", "3", "a/8", "3/b", "0/8", "9/8", "1/0", "-1/2"} {
+		t.Run(val, func(t *testing.T) {
+			var s Shard
+			assert.Error(t, s.Set(val))
+			assert.False(t, s.IsSet())
+		})
+	}
+}
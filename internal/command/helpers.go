@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/simplesurance/baur/v5/internal/command/flag"
 	"github.com/simplesurance/baur/v5/internal/command/term"
+	"github.com/simplesurance/baur/v5/internal/daemon"
 	"github.com/simplesurance/baur/v5/internal/format/csv"
 	"github.com/simplesurance/baur/v5/internal/format/json"
 	"github.com/simplesurance/baur/v5/internal/format/table"
@@ -18,6 +20,7 @@ import (
 	"github.com/simplesurance/baur/v5/pkg/cfg"
 	"github.com/simplesurance/baur/v5/pkg/storage"
 	"github.com/simplesurance/baur/v5/pkg/storage/postgres"
+	"github.com/simplesurance/baur/v5/pkg/storage/sqlite"
 )
 
 type Formatter interface {
@@ -111,7 +114,9 @@ func mustArgToApp(repo *baur.Repository, arg string) *baur.App {
 	return apps[0]
 }
 
-// newStorageClient creates a new postgresql storage client.
+// newStorageClient creates a new storage client. If the uri starts with
+// [sqlite.URLPrefix] an SQLite client is created, otherwise a postgresql
+// client.
 // If the environment variable BAUR_PSQL_URI is set, this uri is used instead
 // of the configuration specified in the baur.Repository object
 func newStorageClient(psqlURI string) (storage.Storer, error) {
@@ -121,6 +126,15 @@ func newStorageClient(psqlURI string) (storage.Storer, error) {
 		uri = envURI
 	}
 
+	if strings.HasPrefix(uri, sqlite.URLPrefix) {
+		var logger sqlite.Logger
+		if verboseFlag {
+			logger = log.StdLogger
+		}
+
+		return sqlite.New(ctx, uri, logger)
+	}
+
 	var logger postgres.Logger
 	if verboseFlag {
 		logger = log.StdLogger
@@ -195,12 +209,12 @@ func mustNewCompatibleStorageRepo(r *baur.Repository) storage.Storer {
 
 func mustNewCompatibleStorage(uri string) storage.Storer {
 	clt, err := newStorageClient(uri)
-	exitOnErr(err, "creating postgresql storage client failed")
+	exitOnErr(err, "creating storage client failed")
 
 	if err := clt.IsCompatible(ctx); err != nil {
 		if errors.Is(err, storage.ErrNotExist) {
-			fatal("baur postgresql database not found\n" +
-				" - ensure that the postgresql URL is correct,\n" +
+			fatal("baur database not found\n" +
+				" - ensure that the database URL is correct,\n" +
 				" - run 'baur init db' to create the database and schema")
 		}
 		clt.Close()
@@ -210,6 +224,36 @@ func mustNewCompatibleStorage(uri string) storage.Storer {
 	return clt
 }
 
+// newInputResolver returns a baur.InputResolver.
+// If a baur daemon is running for the repository, the file inputs are resolved
+// by it, otherwise they are resolved in-process. When the daemon fails to
+// serve a request, the inputs are also resolved in-process.
+func newInputResolver(gitRepo baur.GitUntrackedFilesResolver, repositoryDir string, fixedInputs []baur.Input, hashGitUntrackedFiles bool) *baur.InputResolver {
+	inProcessResolver := baur.NewInputResolver(gitRepo, repositoryDir, fixedInputs, hashGitUntrackedFiles, withFileDigestCache(repositoryDir))
+
+	clt := daemon.NewClient(daemon.SocketPath(repositoryDir), repositoryDir, hashGitUntrackedFiles)
+	if err := clt.Ping(ctx); err != nil {
+		if !errors.Is(err, daemon.ErrNotRunning) {
+			stderr.Printf("%s, resolving inputs without it\n", err)
+		}
+
+		log.Debugf("resolving inputs in-process: %s\n", err)
+		return inProcessResolver
+	}
+
+	log.Debugf("resolving file inputs via baur daemon\n")
+	return baur.NewInputResolver(
+		gitRepo,
+		repositoryDir,
+		fixedInputs,
+		hashGitUntrackedFiles,
+		withFileDigestCache(repositoryDir),
+		baur.WithFileInputResolver(daemon.NewFallbackResolver(clt, inProcessResolver, func(err error) {
+			stderr.Printf("%s, resolving inputs without it\n", err)
+		})),
+	)
+}
+
 func mustGetRepoState(dir string) *git.Repository {
 	repo, err := git.NewRepositoryWithCheck(dir)
 	if err != nil {
@@ -344,6 +388,31 @@ func mustUntrackedFilesNotExist(requireCleanGitWorktree bool, gitRepo *git.Repos
 	}
 }
 
+// mustWorktreeBeClean exits with an error if the git repository contains
+// modified or untracked files that are not ignored.
+func mustWorktreeBeClean(gitRepo *git.Repository, flagName string) {
+	dirty, err := gitRepo.WorktreeIsDirty()
+	exitOnErr(err)
+
+	if dirty {
+		fatalf("%s was specified but the git repository contains modified or untracked files, they must be committed first\n", flagName)
+	}
+}
+
+// mustFilterAffectedTasks returns the elements of tasks that are affected by
+// files that were changed in the git repository between ref and HEAD.
+func mustFilterAffectedTasks(repoPath string, inputResolver *baur.InputResolver, tasks []*baur.Task, ref string) []*baur.Task {
+	changedFiles, err := git.ChangedFiles(ctx, repoPath, ref)
+	exitOnErrf(err, "--%s: listing changed files failed", flagNameChangedSince)
+
+	log.Debugf("files changed since %s: %s", ref, strings.Join(changedFiles, ", "))
+
+	affected, err := baur.NewAffectedTaskFinder(repoPath, inputResolver).Affected(ctx, tasks, changedFiles)
+	exitOnErrf(err, "--%s: determining affected tasks failed", flagNameChangedSince)
+
+	return affected
+}
+
 func untrackedFilesExistErrMsg(untrackedFiles []string) string {
 	return fmt.Sprintf("%s was specified, expecting only tracked unmodified files but found the following untracked or modified files:\n%s",
 		term.Highlight("--"+flagNameRequireCleanGitWorktree), term.Highlight(prettyprint.TruncatedStrSlice(untrackedFiles, 10)))
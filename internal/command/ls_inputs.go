@@ -95,7 +95,7 @@ func (c *lsInputsCmd) mustGetTaskInputs(taskSpec string) *baur.Inputs {
 	repo := mustFindRepository()
 	vcsState := mustGetRepoState(repo.Path)
 	task := mustArgToTask(repo, vcsState, taskSpec)
-	inputResolver := baur.NewInputResolver(
+	inputResolver := newInputResolver(
 		vcsState,
 		repo.Path,
 		baur.AsInputStrings(c.inputStr...),
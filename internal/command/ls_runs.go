@@ -33,7 +33,10 @@ baur ls runs --limit=1 calc		list a single task run of the calc
 					application
 baur ls runs --has-input=string:master calc
 					list task runs of the calc application
-					that have a 'string:master' input`
+					that have a 'string:master' input
+baur ls runs --result=failure calc.check
+					list failed runs of the check task of
+					the calc application`
 
 func init() {
 	lsCmd.AddCommand(&newLsRunsCmd().Command)
@@ -43,6 +46,7 @@ type lsRunsCmd struct {
 	cobra.Command
 
 	format *flag.OneOf
+	result *flag.OneOf
 	after  flag.DateTimeFlagValue
 	before flag.DateTimeFlagValue
 	input  string
@@ -79,6 +83,12 @@ func newLsRunsCmd() *lsRunsCmd {
 			"output format",
 			flag.FormatCSV, flag.FormatPlain,
 		),
+		result: flag.NewOneOfFlag(
+			"result",
+			"",
+			"only show runs with the given result",
+			string(storage.ResultSuccess), string(storage.ResultFailure),
+		),
 	}
 
 	cmd.Run = cmd.run
@@ -101,6 +111,9 @@ func newLsRunsCmd() *lsRunsCmd {
 	cmd.Flags().VarP(&cmd.before, "before", "b",
 		fmt.Sprintf("only show runs that were started before this datetime.\nFormat: %s", term.Highlight(flag.DateTimeFormatDescr)))
 
+	cmd.Flags().Var(cmd.result, "result", cmd.result.Usage(term.Highlight))
+	_ = cmd.result.RegisterFlagCompletion(&cmd.Command)
+
 	cmd.Flags().StringVar(&cmd.input, "has-input", "",
 		fmt.Sprintf(
 			"only show runs that have the given input,\n"+
@@ -194,6 +207,7 @@ func printHeader(formatter Formatter) {
 		"Start Time",
 		"Duration",
 		"Input Digest",
+		"Attempts",
 	)
 }
 
@@ -213,6 +227,7 @@ func (c *lsRunsCmd) printTaskRun(formatter Formatter, taskRun *storage.TaskRunWi
 			term.FormatBaseWithoutUnitName(c.format.Val == flag.FormatCSV),
 		),
 		taskRun.TotalInputDigest,
+		strconv.Itoa(taskRun.Attempts),
 	)
 }
 
@@ -251,6 +266,14 @@ func (c *lsRunsCmd) getFilters() []*storage.Filter {
 		})
 	}
 
+	if c.result.Val != "" {
+		filters = append(filters, &storage.Filter{
+			Field:    storage.FieldResult,
+			Operator: storage.OpEQ,
+			Value:    c.result.Val,
+		})
+	}
+
 	if c.input != "" {
 		if strings.HasPrefix(c.input, "string:") {
 			filters = append(filters, &storage.Filter{
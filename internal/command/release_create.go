@@ -165,7 +165,7 @@ func (c *releaseCreateCmd) mustFetchTaskIDs(
 	statusMgr := baur.NewTaskStatusEvaluator(
 		repo.Path,
 		storageClt,
-		baur.NewInputResolver(
+		newInputResolver(
 			mustGetRepoState(repo.Path),
 			repo.Path,
 			nil,
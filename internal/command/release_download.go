@@ -21,6 +21,8 @@ The command downloads one instance of each uploaded output of task runs that
 belong to the release.
 Only outputs that have been uploaded to S3 are downloaded, others are ignored.
 The downloaded outputs are stored at the path: %s.
+Archives of directory and glob outputs are extracted into
+%s.
 
 The command can be run without access to the baur repository by specifying the
 PostgreSQL URI via the environment variable %s.
@@ -35,6 +37,7 @@ Exit Codes:
   %d - Release does not exist
 `,
 	term.Highlight("DEST-DIR/TASK-ID/OUTPUT-NAME"),
+	term.Highlight("DEST-DIR/TASK-ID/"),
 	term.Highlight(envVarPSQLURL),
 	term.Highlight("--tasks"),
 	exitCodeSuccess,
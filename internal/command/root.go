@@ -17,9 +17,10 @@ import (
 )
 
 var rootCmd = &cobra.Command{
-	Use:              "baur",
-	Short:            "baur is a task runner for mono repositories.",
-	PersistentPreRun: initSb,
+	Use:               "baur",
+	Short:             "baur is a task runner for mono repositories.",
+	PersistentPreRun:  initSb,
+	PersistentPostRun: func(*cobra.Command, []string) { saveFileDigestCaches() },
 }
 
 var (
@@ -38,7 +39,10 @@ var (
 	stderr = term.NewStream(os.Stderr)
 )
 
-var exitFunc = func(code int) { os.Exit(code) }
+var exitFunc = func(code int) {
+	saveFileDigestCaches()
+	os.Exit(code)
+}
 
 func initSb(_ *cobra.Command, _ []string) {
 	if verboseFlag {
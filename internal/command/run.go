@@ -1,25 +1,38 @@
 package command
 
 import (
+	"cmp"
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/simplesurance/baur/v5/internal/command/flag"
 	"github.com/simplesurance/baur/v5/internal/command/term"
+	"github.com/simplesurance/baur/v5/internal/events"
 	"github.com/simplesurance/baur/v5/internal/exec"
 	"github.com/simplesurance/baur/v5/internal/log"
 	"github.com/simplesurance/baur/v5/internal/output/docker"
 	"github.com/simplesurance/baur/v5/internal/output/filecopy"
 	"github.com/simplesurance/baur/v5/internal/output/s3"
+	"github.com/simplesurance/baur/v5/internal/report"
 	"github.com/simplesurance/baur/v5/internal/routines"
+	"github.com/simplesurance/baur/v5/internal/set"
 	"github.com/simplesurance/baur/v5/internal/vcs/git"
 	"github.com/simplesurance/baur/v5/pkg/baur"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
 	"github.com/simplesurance/baur/v5/pkg/storage"
 )
 
@@ -28,18 +41,136 @@ baur run auth				run all tasks of the auth application, upload the produced outp
 baur run calc.check			run the check task of the calc application and upload the produced outputs
 baur run *.build			run all tasks named build of the all applications and upload the produced outputs
 baur run --force			run and upload all tasks of applications, independent of their status
+baur run --timeout=30m			run all pending tasks, terminate running and skip queued tasks after 30min
+baur run --upload-retries=3		run all pending tasks, retry failed uploads up to 3 times
+baur run -p 4 --parallel-uploads=4	run and upload up to 4 tasks in parallel
+baur run --restore-outputs		run all pending tasks, download the outputs of tasks with status run-exist
+baur run --sandbox			run all pending tasks in sandboxes that only contain their input files
+baur run --audit-inputs calc.build	run the build task of calc, report undeclared file reads and unread inputs
+baur run --warn-untracked-env		run all pending tasks, warn about environment variables that are not tracked as inputs
+baur run --events=- | jq .			run all pending tasks, write events as JSON to stdout
+baur run --report-junit=report.xml	run all pending tasks, write a JUnit XML report to report.xml
+baur run --shard=$CI_NODE_INDEX/$CI_NODE_TOTAL	run the pending tasks of one of the CI jobs
+baur run --enqueue			run all pending tasks on the machines running baur worker
+baur run --plan --plan-format=json	print the tasks that would be run and where their outputs would be uploaded to as JSON
+baur run --changed-since=origin/main	run pending tasks that are affected by changes since the branch diverged from origin/main
 `
 
-const flagNameRequireCleanGitWorktree = "require-clean-git-worktree"
+const (
+	flagNameRequireCleanGitWorktree = "require-clean-git-worktree"
+	flagNameChangedSince            = "changed-since"
+)
+
+// taskDurationRuns is the number of previous runs of a task whose average
+// duration is used to balance shards and to order the task executions.
+const taskDurationRuns = 10
+
+// shardRunsMinAge is how long before the commit time of HEAD runs must have
+// stopped to be considered when balancing shards.
+const shardRunsMinAge = time.Hour
 
 var runLongHelp = fmt.Sprintf(`
 Execute tasks of applications.
 
 If no argument is specified all tasks of all applications with status %s are run.
 
+When %s is passed, the outputs of tasks with status %s are
+downloaded to the locations declared in the task configuration, as done by
+%s.
+
+When %s is passed, only tasks are considered that are affected
+by files changed since the merge-base of REF and HEAD, as listed by
+%s. Uncommitted changes and untracked files in the worktree
+are included.
+
+When %s is passed, the tasks are partitioned into N shards and only
+the tasks of the K-th shard are considered. The shards are balanced by the
+average duration of the previous runs of the tasks that stopped at least 1h
+before the commit time of HEAD. Tasks that are connected via TaskInfo inputs are assigned
+to the same shard. Running baur with the same arguments in N CI jobs, with
+K from 1 to N, runs each task in exactly one job.
+
+When %s is passed, the file accesses of task commands are traced.
+Files in the repository that were read but are not inputs and input files that
+were not read are reported. With %s, a %s
+section listing the undeclared files is printed. Tracing is only supported on
+linux/amd64.
+
+When %s is passed, the command of a task is executed in a temporary
+directory that mirrors the repository layout and only contains the input files
+of the task. Declared outputs in the task directory are replaced with the ones
+created in the sandbox, tasks fail when a declared output was not created.
+Tasks that read files that are not tracked as inputs via relative paths fail.
+Reads via absolute paths, for example into the repository, are not prevented.
+
+The environment variables that are passed to task commands are configured in
+the %s section of a task. When %s is passed, a warning
+is printed for tasks whose commands receive environment variables that are not
+tracked as inputs.
+
+%s sets the number of slots that are available to run tasks
+in parallel. A task occupies the number of slots configured by its %s
+setting, by default 1. Tasks with the same %s are never run in
+parallel.
+Tasks on the longest paths are started first. The path of a task is the task
+itself and the longest chain of pending tasks that depend on it via TaskInfo
+inputs, its duration is estimated from the average durations of the previous
+runs of the tasks. Tasks without previous runs are started last, in the order
+of their IDs.
+
+%s sets the number of tasks whose outputs are uploaded in
+parallel. The uploads of an output to its destinations always run in parallel.
+A task run is only recorded when all uploads of the task succeeded.
+
+Task commands that fail or exceed their timeout are rerun as configured by the
+%s and %s settings of the task. Failed uploads are retried
+as configured by %s and %s.
+
+When %s is passed, events about evaluated task statuses, task
+executions, created outputs, uploads and stored runs are written as
+newline-delimited JSON objects to FILE. With %s, the events are
+written to stdout and all other messages to stderr.
+
+%s and %s write a report of the task results
+when the run finished. Each task is a test case, tasks whose runs exist are
+reported as skipped in the JUnit report and as cache hits in the Markdown
+report. Failed tasks include the output of their command.
+
+Before a task is executed, a lease for its inputs is acquired in the database.
+If another baur process is running the task with the same inputs, baur waits
+until it finished. If the other process recorded a successful run, the task is
+not executed. Leases of baur processes that terminated unexpectedly expire after
+the duration passed via %s. Leases are not used when %s or
+%s is passed.
+
+When %s is passed, the pending tasks are added to a job queue in the
+database instead of being executed. Processes running %s claim the
+jobs, run the tasks at the same git commit, upload their outputs and record the
+runs. baur waits until all jobs finished and reports their results. Tasks that
+reference other pending tasks as TaskInfo inputs are enqueued when the runs of
+the referenced tasks were recorded. The git worktree must be clean.
+When the jobs did not finish within the duration passed via %s,
+the jobs that were not claimed by a worker are canceled and all unfinished
+jobs are reported as failed.
+
+When %s is passed, the status of the tasks is evaluated but no task is run.
+The tasks that would be run are printed in the order in that they would be
+started, with their total input digests, the tasks they reference as TaskInfo
+inputs, the outputs they would produce and the destinations the outputs would
+be uploaded to. %s prints the plan as JSON to stdout, all
+other messages are written to stderr.
+
+When SIGINT or SIGTERM is received, running tasks are terminated and queued
+tasks are skipped.
+
 Arguments:
 %s
 
+Exit Codes:
+  %d - Success
+  %d - Error
+  %d - Interrupted by a signal
+
 The following Environment Variables are supported:
     %s
 
@@ -55,7 +186,42 @@ The following Environment Variables are supported:
     %s
 `,
 	term.ColoredTaskStatus(baur.TaskStatusExecutionPending),
+	term.Highlight("--restore-outputs"),
+	term.ColoredTaskStatus(baur.TaskStatusRunExist),
+	term.Highlight("baur fetch"),
+	term.Highlight("--"+flagNameChangedSince+"=REF"),
+	term.Highlight("baur status --"+flagNameChangedSince+"=REF"),
+	term.Highlight("--shard=K/N"),
+	term.Highlight("--audit-inputs"),
+	term.Highlight("--audit-inputs-suggest"),
+	term.Highlight("Task.Input.Files"),
+	term.Highlight("--sandbox"),
+	term.Highlight("Task.Environment"),
+	term.Highlight("--warn-untracked-env"),
+	term.Highlight("--parallel-runs"),
+	term.Highlight("weight"),
+	term.Highlight("mutex_group"),
+	term.Highlight("--parallel-uploads"),
+	term.Highlight("retries"),
+	term.Highlight("retry_delay"),
+	term.Highlight("--upload-retries"),
+	term.Highlight("--upload-retry-delay"),
+	term.Highlight("--events=FILE"),
+	term.Highlight("--events=-"),
+	term.Highlight("--report-junit=FILE"),
+	term.Highlight("--report-markdown=FILE"),
+	term.Highlight("--run-lease-ttl"),
+	term.Highlight("--force"),
+	term.Highlight("--skip-upload"),
+	term.Highlight("--enqueue"),
+	term.Highlight("baur worker"),
+	term.Highlight("--enqueue-timeout"),
+	term.Highlight("--plan"),
+	term.Highlight("--plan-format=json"),
 	targetHelp,
+	exitCodeSuccess,
+	exitCodeError,
+	exitCodeInterrupted,
 
 	term.Highlight(envVarPSQLURL),
 
@@ -68,6 +234,11 @@ The following Environment Variables are supported:
 	term.Highlight("DOCKER_CERT_PATH"),
 	term.Highlight("DOCKER_TLS_VERIFY"))
 
+var (
+	errInterrupted = errors.New("interrupted by signal")
+	errRunTimeout  = errors.New("run timeout exceeded")
+)
+
 var (
 	statusStrSuccess = term.GreenHighlight("successful")
 	statusStrSkipped = term.YellowHighlight("skipped")
@@ -90,17 +261,60 @@ type runCmd struct {
 	taskRunnerGoRoutines    uint
 	showOutput              bool
 	requireCleanGitWorktree bool
+	timeout                 time.Duration
+	restoreOutputs          bool
+	changedSince            string
+	warnUntrackedEnv        bool
+	sandbox                 bool
+	auditInputs             bool
+	auditInputsSuggest      bool
+	uploadRetries           uint
+	parallelUploads         uint
+	eventsPath              string
+	reportJUnitPath         string
+	reportMarkdownPath      string
+	shard                   flag.Shard
+	uploadRetryDelay        time.Duration
+	runLeaseTTL             time.Duration
+	enqueue                 bool
+	enqueueTimeout          time.Duration
+	plan                    bool
+	planFormat              *flag.OneOf
 
 	// other fields
 	storage      storage.Storer
 	repoRootPath string
+	taskLogDest  *cfg.TaskLog
 	dockerClient *docker.Client
 	uploader     *baur.Uploader
 	gitRepo      *git.Repository
+	// planOut is the writer that the plan is written to when --plan is
+	// passed.
+	planOut io.Writer
 
+	events                *events.Writer
+	report                *report.Report
 	uploadRoutinePool     *routines.Pool
 	taskRunnerRoutinePool *routines.Pool
 	taskRunner            *baur.TaskRunner
+	restorer              *baur.OutputRestorer
+	runLeaser             *baur.RunLeaser
+
+	// existingRuns are the runs of tasks with status run-exist, their
+	// outputs are downloaded when restoreOutputs is set
+	existingRuns []*existingRun
+
+	// execCtx is used to run tasks and upload their outputs. It is
+	// canceled when a termination signal is received or the run timeout
+	// is exceeded.
+	execCtx context.Context
+
+	// scheduler releases pending tasks for execution when all tasks
+	// that they reference as TaskInfo input were run, their outputs
+	// uploaded and the runs recorded.
+	scheduler      *baur.TaskScheduler
+	pendingTasks   map[string]*pendingTask
+	pendingTasksWg sync.WaitGroup
 
 	skipAllScheduledTaskRunsOnce sync.Once
 	errorHappened                bool
@@ -109,6 +323,14 @@ type runCmd struct {
 type pendingTask struct {
 	task   *baur.Task
 	inputs *baur.Inputs
+	// lease is the acquired run lease of the task, it is nil when run
+	// leases are disabled.
+	lease *baur.RunLease
+}
+
+type existingRun struct {
+	task  *baur.Task
+	runID int
 }
 
 func newRunCmd() *runCmd {
@@ -120,6 +342,12 @@ func newRunCmd() *runCmd {
 			Example:           strings.TrimSpace(runExample),
 			ValidArgsFunction: newCompleteTargetFunc(completeTargetFuncOpts{}),
 		},
+		planFormat: flag.NewOneOfFlag(
+			"plan-format",
+			flag.FormatPlain,
+			"format of the execution plan printed by --plan",
+			flag.FormatJSON, flag.FormatPlain,
+		),
 	}
 
 	cmd.Run = cmd.run
@@ -135,13 +363,68 @@ func newRunCmd() *runCmd {
 	cmd.Flags().StringVar(&cmd.lookupInputStr, "lookup-input-str", "",
 		"if a run can not be found, try to find a run with this value as input-string")
 	cmd.Flags().UintVarP(&cmd.taskRunnerGoRoutines, "parallel-runs", "p", 1,
-		"specifies the max. number of tasks to run in parallel")
+		"specifies the max. number of tasks to run in parallel,\n"+
+			"a task with a weight occupies that many of the slots")
 	cmd.Flags().BoolVarP(&cmd.showOutput, "show-task-output", "o", false,
 		"show the output of tasks, if disabled the output is only shown "+
 			"when task execution fails",
 	)
 	cmd.Flags().BoolVarP(&cmd.requireCleanGitWorktree, flagNameRequireCleanGitWorktree, "c", false,
 		"fail if the git repository contains modified or untracked files")
+	cmd.Flags().DurationVar(&cmd.timeout, "timeout", 0,
+		"terminate running tasks and skip queued ones when executing the tasks takes longer than the duration,\n"+
+			"0 disables the timeout")
+	cmd.Flags().BoolVar(&cmd.restoreOutputs, "restore-outputs", false,
+		"download the outputs of tasks with status run-exist")
+	cmd.MarkFlagsMutuallyExclusive("force", "restore-outputs")
+	cmd.Flags().StringVar(&cmd.changedSince, flagNameChangedSince, "",
+		"only run tasks affected by files changed since the merge-base of REF and HEAD,\n"+
+			"including uncommitted changes")
+	cmd.Flags().BoolVar(&cmd.sandbox, "sandbox", false,
+		"run task commands in a temporary directory that only contains the input files of the task")
+	cmd.Flags().BoolVar(&cmd.auditInputs, "audit-inputs", false,
+		"trace the file accesses of task commands and report files that were read but are not inputs\n"+
+			"and input files that were not read, only supported on linux/amd64")
+	cmd.Flags().BoolVar(&cmd.auditInputsSuggest, "audit-inputs-suggest", false,
+		"print a Task.Input.Files section for files that were read but are not inputs, requires --audit-inputs")
+	cmd.MarkFlagsMutuallyExclusive("sandbox", "audit-inputs")
+	cmd.Flags().UintVar(&cmd.parallelUploads, "parallel-uploads", 1,
+		"specifies the max. number of tasks whose outputs are uploaded in parallel,\n"+
+			"the destinations of an output are always uploaded to in parallel")
+	cmd.Flags().UintVar(&cmd.uploadRetries, "upload-retries", 0,
+		"number of times a failed upload is retried")
+	cmd.Flags().DurationVar(&cmd.uploadRetryDelay, "upload-retry-delay", 5*time.Second,
+		"duration to wait before a failed upload is retried")
+	cmd.Flags().StringVar(&cmd.eventsPath, "events", "",
+		"write newline-delimited JSON events to FILE, '-' writes them to stdout")
+	cmd.Flags().StringVar(&cmd.reportJUnitPath, "report-junit", "",
+		"write a JUnit XML report of the task results to FILE")
+	cmd.Flags().StringVar(&cmd.reportMarkdownPath, "report-markdown", "",
+		"write a Markdown report of the task results to FILE")
+	cmd.Flags().Var(&cmd.shard, "shard",
+		"only run the tasks of the K-th of N partitions, e.g. 2/8")
+	cmd.Flags().DurationVar(&cmd.runLeaseTTL, "run-lease-ttl", time.Minute,
+		"duration after that the run lease of a terminated baur process expires,\n"+
+			"0 disables waiting for other baur processes running the same tasks")
+	cmd.Flags().BoolVar(&cmd.enqueue, "enqueue", false,
+		"add pending tasks to the job queue in the database instead of running them,\n"+
+			"wait until 'baur worker' processes ran them and report the results")
+	cmd.Flags().DurationVar(&cmd.enqueueTimeout, "enqueue-timeout", time.Hour,
+		"max. duration to wait for enqueued jobs to finish, 0 disables the timeout")
+	for _, f := range []string{"force", "skip-upload", "input-str", "sandbox", "audit-inputs"} {
+		cmd.MarkFlagsMutuallyExclusive("enqueue", f)
+	}
+	cmd.Flags().BoolVar(&cmd.plan, "plan", false,
+		"evaluate the status of tasks and print the tasks that would be run, their outputs and\n"+
+			"upload destinations, without executing them")
+	cmd.Flags().Var(cmd.planFormat, "plan-format", cmd.planFormat.Usage(term.Highlight))
+	_ = cmd.planFormat.RegisterFlagCompletion(&cmd.Command)
+	for _, f := range []string{"enqueue", "skip-upload", "restore-outputs", "events", "report-junit", "report-markdown"} {
+		cmd.MarkFlagsMutuallyExclusive("plan", f)
+	}
+	cmd.Flags().BoolVar(&cmd.warnUntrackedEnv, "warn-untracked-env", false,
+		"print a warning listing the environment variables that are passed to\n"+
+			"a task command but are not tracked as inputs")
 
 	return &cmd
 }
@@ -154,19 +437,59 @@ func (c *runCmd) run(_ *cobra.Command, args []string) {
 		exitFunc(exitCodeError)
 	}
 
+	if c.parallelUploads == 0 {
+		stderr.Printf("--parallel-uploads must be greater than 0\n")
+		exitFunc(exitCodeError)
+	}
+
+	if c.auditInputsSuggest && !c.auditInputs {
+		stderr.Printf("--audit-inputs-suggest can only be used together with --audit-inputs\n")
+		exitFunc(exitCodeError)
+	}
+
+	if c.auditInputs && !exec.TracingSupported() {
+		stderr.Printf("--audit-inputs is not supported on %s/%s\n", runtime.GOOS, runtime.GOARCH)
+		exitFunc(exitCodeError)
+	}
+
 	startTime := time.Now()
 
+	if c.plan {
+		c.planOut = stdout
+		if c.planFormat.Val == flag.FormatJSON {
+			// only the plan is written to stdout, to keep it
+			// parseable
+			origStdout := stdout
+			stdout = stderr
+			defer func() { stdout = origStdout }()
+		}
+	}
+
+	if c.eventsPath != "" {
+		closeEventsFn := c.mustOpenEventStream()
+		defer closeEventsFn()
+	}
+
+	if c.reportJUnitPath != "" || c.reportMarkdownPath != "" {
+		c.report = report.New()
+	}
+
 	repo := mustFindRepository()
 	c.repoRootPath = repo.Path
+	c.taskLogDest = &repo.Cfg.TaskLog
 
 	c.gitRepo = mustGetRepoState(repo.Path)
 
+	if c.enqueue {
+		mustWorktreeBeClean(c.gitRepo, "--enqueue")
+	}
+
 	mustUntrackedFilesNotExist(c.requireCleanGitWorktree, c.gitRepo)
 
 	c.storage = mustNewCompatibleStorageRepo(repo)
 	defer c.storage.Close()
 
-	inputResolver := baur.NewInputResolver(
+	inputResolver := newInputResolver(
 		mustGetRepoState(c.repoRootPath),
 		c.repoRootPath,
 		baur.AsInputStrings(c.inputStr...),
@@ -180,7 +503,7 @@ func (c *runCmd) run(_ *cobra.Command, args []string) {
 	)
 
 	if !c.skipUpload {
-		c.uploadRoutinePool = routines.NewPool(1) // run 1 upload in parallel with builds
+		c.uploadRoutinePool = routines.NewPool(c.parallelUploads)
 	}
 
 	c.taskRunnerRoutinePool = routines.NewPool(c.taskRunnerGoRoutines)
@@ -197,12 +520,29 @@ func (c *runCmd) run(_ *cobra.Command, args []string) {
 		c.taskRunner.GitUntrackedFilesFn = git.UntrackedFiles
 	}
 
+	c.taskRunner.TraceFileAccesses = c.auditInputs
+	c.taskRunner.RetryFn = printTaskRetry
+
 	c.dockerClient, err = docker.NewClient(log.StdLogger.Debugf)
 	exitOnErr(err)
 
 	s3Client, err := s3.NewClient(ctx, log.StdLogger)
 	exitOnErr(err)
 	c.uploader = baur.NewUploader(c.dockerClient, s3Client, filecopy.New(log.Debugf))
+	c.uploader.Retries = int(c.uploadRetries)
+	c.uploader.RetryDelay = c.uploadRetryDelay
+	c.restorer = baur.NewOutputRestorer(s3Client, c.dockerClient, filecopy.New(log.Debugf))
+
+	if c.runLeaseTTL > 0 && !c.force && !c.skipUpload {
+		c.runLeaser = baur.NewRunLeaser(c.storage, processIdentifier())
+		c.runLeaser.TTL = c.runLeaseTTL
+		c.runLeaser.WaitFn = func(task *baur.Task) {
+			stdout.TaskPrintf(task, "waiting for another baur process that runs the task with the same inputs\n")
+		}
+		c.runLeaser.RenewErrFn = func(task *baur.Task, err error) {
+			stderr.ErrPrintf(err, "%s: renewing run lease failed", task)
+		}
+	}
 
 	if c.skipUpload {
 		stdout.Printf("--skip-upload was passed, outputs won't be uploaded and task runs not recorded\n\n")
@@ -214,8 +554,19 @@ func (c *runCmd) run(_ *cobra.Command, args []string) {
 	tasks, err := loader.LoadTasks(args...)
 	exitOnErr(err)
 
+	if c.changedSince != "" {
+		affectedTasks := mustFilterAffectedTasks(c.repoRootPath, inputResolver, tasks, c.changedSince)
+		stdout.Printf("%d/%d task(s) affected by changes since %s\n\n",
+			len(affectedTasks), len(tasks), term.Highlight(c.changedSince))
+		tasks = affectedTasks
+	}
+
 	baur.SortTasksByID(tasks)
 
+	if c.shard.IsSet() {
+		tasks = c.mustSelectShard(tasks)
+	}
+
 	// TODO: move taskStatusEvaluator to the cmd struct?
 	pendingTasks, err := c.filterPendingTasks(taskStatusEvaluator, tasks)
 	exitOnErr(err)
@@ -229,7 +580,21 @@ func (c *runCmd) run(_ *cobra.Command, args []string) {
 
 	stdout.PrintSep()
 
-	if c.force {
+	if c.restoreOutputs {
+		c.restoreExistingRunOutputs()
+	}
+
+	c.sortByCriticalPath(pendingTasks)
+
+	if c.plan {
+		c.mustPrintPlan(pendingTasks, len(tasks))
+		return
+	}
+
+	if c.enqueue {
+		stdout.Printf("Enqueuing %d/%d task(s) with status %s\n\n",
+			len(pendingTasks), len(tasks), term.ColoredTaskStatus(baur.TaskStatusExecutionPending))
+	} else if c.force {
 		stdout.Printf("Running %d/%d task(s) with status %s, %s\n\n",
 			len(pendingTasks), len(tasks), term.ColoredTaskStatus(baur.TaskStatusExecutionPending), term.ColoredTaskStatus(baur.TaskStatusRunExist))
 	} else {
@@ -237,67 +602,618 @@ func (c *runCmd) run(_ *cobra.Command, args []string) {
 			len(pendingTasks), len(tasks), term.ColoredTaskStatus(baur.TaskStatusExecutionPending))
 	}
 
-	for _, pt := range pendingTasks {
-		// copy the iteration variable, to prevent that its value
-		// changes in the closure to 't' of the next iteration before
-		// the closure is executed
-		pendingTaskCopy := pt
-
-		c.taskRunnerRoutinePool.Queue(func() {
-			task := pendingTaskCopy.task
-			runResult, err := c.runTask(task)
-			if err != nil {
-				// error is printed in runTask()
-				c.skipAllScheduledTaskRuns()
-				return
-			}
+	var cancelExecCtx func()
+	c.execCtx, cancelExecCtx = c.newExecContext()
+	defer cancelExecCtx()
 
-			outputs, err := baur.OutputsFromTask(c.dockerClient, task)
-			if err != nil {
-				stderr.ErrPrintln(err, task.ID)
-				c.skipAllScheduledTaskRuns()
-				return
-			}
+	if c.enqueue {
+		c.enqueueAndWait(pendingTasks)
+	} else {
+		c.runPendingTasks(pendingTasks)
+	}
 
-			if !declaredOutputsExist(task, outputs) {
-				// error is printed in declaredOutputsExist()
-				c.skipAllScheduledTaskRuns()
-				return
-			}
+	c.writeReports()
 
-			if c.skipUpload {
-				return
-			}
+	stdout.PrintSep()
+	stdout.Printf("finished in: %s\n",
+		term.FormatDuration(
+			time.Since(startTime),
+		),
+	)
 
-			c.uploadRoutinePool.Queue(func() {
-				err := c.uploadAndRecord(ctx, pendingTaskCopy, outputs, runResult)
-				if err != nil {
-					// error is printed in uploadAndRecord()
-					c.skipAllScheduledTaskRuns()
-				}
-			})
-		})
+	cause := context.Cause(c.execCtx)
+	if errors.Is(cause, errInterrupted) {
+		stderr.Printf("run was %s\n", term.RedHighlight("interrupted"))
+		exitFunc(exitCodeInterrupted)
+		return
 	}
 
+	if errors.Is(cause, errRunTimeout) {
+		stderr.Printf("%s, run was terminated after %s\n", cause, term.FormatDuration(c.timeout))
+		exitFunc(exitCodeError)
+		return
+	}
+
+	if c.errorHappened {
+		exitFunc(exitCodeError)
+	}
+}
+
+// runPendingTasks executes the tasks, uploads their outputs and records the
+// runs. It returns when all tasks were processed.
+func (c *runCmd) runPendingTasks(pendingTasks []*pendingTask) {
+	var err error
+
+	c.scheduler, err = baur.NewTaskScheduler(pendingTasksToTasks(pendingTasks))
+	exitOnErr(err)
+
+	c.pendingTasks = make(map[string]*pendingTask, len(pendingTasks))
+	for _, pt := range pendingTasks {
+		c.pendingTasks[pt.task.ID] = pt
+	}
+
+	c.pendingTasksWg.Add(len(pendingTasks))
+	c.queueTaskRuns(c.scheduler.Start())
+	c.pendingTasksWg.Wait()
+
 	c.taskRunnerRoutinePool.Wait()
 
 	if !c.skipUpload {
-		stdout.Println("task execution finished, waiting for uploads to finish...")
 		c.uploadRoutinePool.Wait()
 	}
+}
 
-	stdout.PrintSep()
-	stdout.Printf("finished in: %s\n",
-		term.FormatDuration(
-			time.Since(startTime),
-		),
+// newExecContext returns a context that is canceled when SIGINT or SIGTERM
+// is received or the run timeout is exceeded.
+// After the first signal was received, the signal handler is removed, further
+// signals terminate baur immediately.
+// The returned function must be called to release the resources.
+func (c *runCmd) newExecContext() (context.Context, func()) {
+	execCtx, cancel := context.WithCancelCause(ctx)
+
+	cancelTimeout := func() {}
+	if c.timeout > 0 {
+		execCtx, cancelTimeout = context.WithTimeoutCause(execCtx, c.timeout, errRunTimeout)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-sigCh:
+			signal.Stop(sigCh)
+			stderr.Printf("received %s signal, %s running tasks, %s queued task runs\n",
+				sig,
+				term.RedHighlight("terminating"),
+				term.YellowHighlight("skipping"),
+			)
+			cancel(fmt.Errorf("%w %s", errInterrupted, sig))
+
+		case <-execCtx.Done():
+		}
+	}()
+
+	return execCtx, func() {
+		signal.Stop(sigCh)
+		cancelTimeout()
+		cancel(nil)
+	}
+}
+
+// restoreExistingRunOutputs downloads the outputs of c.existingRuns.
+// Failed downloads are printed and cause the command to exit with an error
+// after all tasks were run.
+func (c *runCmd) restoreExistingRunOutputs() {
+	if len(c.existingRuns) == 0 {
+		return
+	}
+
+	stdout.Printf("Restoring outputs of %d task(s) with status %s\n\n",
+		len(c.existingRuns), term.ColoredTaskStatus(baur.TaskStatusRunExist))
+
+	for _, r := range c.existingRuns {
+		if err := restoreOutputs(c.storage, c.restorer, r.task, r.runID); err != nil {
+			stderr.ErrPrintln(err)
+			c.errorHappened = true
+		}
+	}
+
+	stdout.Println()
+}
+
+func pendingTasksToTasks(pendingTasks []*pendingTask) []*baur.Task {
+	result := make([]*baur.Task, 0, len(pendingTasks))
+	for _, pt := range pendingTasks {
+		result = append(result, pt.task)
+	}
+
+	return result
+}
+
+// queueTaskRuns queues the execution of tasks in the taskRunnerRoutinePool.
+func (c *runCmd) queueTaskRuns(tasks []*baur.Task) {
+	for _, task := range tasks {
+		pt := c.pendingTasks[task.ID]
+
+		c.taskRunnerRoutinePool.Queue(
+			func() { c.runAndUpload(pt) },
+			routines.WithWeight(task.Weight),
+			routines.WithMutexGroup(task.MutexGroup),
+		)
+	}
+}
+
+func (c *runCmd) runAndUpload(pt *pendingTask) {
+	task := pt.task
+
+	if c.runLeaser != nil && !c.acquireRunLease(pt) {
+		return
+	}
+
+	if c.warnUntrackedEnv {
+		printUntrackedEnvVars(pt)
+	}
+
+	c.emitEvent(events.TypeTaskStarted, task, nil)
+	runResult, err := c.runTask(pt)
+	c.emitTaskFinished(task, runResult, err)
+	if c.auditInputs && runResult != nil {
+		c.printInputAudit(pt, runResult)
+	}
+	if err != nil {
+		// error is printed in runTask()
+		if errors.Is(err, baur.ErrTaskRunSkipped) {
+			c.addToReport(task, report.StatusSkipped, runResult, err)
+		} else {
+			c.addToReport(task, report.StatusFailure, runResult, err)
+		}
+
+		var ee *exec.ExitCodeError
+		if errors.As(err, &ee) && !c.skipUpload {
+			c.recordFailedRun(pt, runResult)
+		}
+
+		deleteTaskLog(task, runResult)
+		c.taskFailed(task)
+		return
+	}
+
+	outputs, err := baur.OutputsFromTask(c.dockerClient, task)
+	if err != nil {
+		stderr.ErrPrintln(err, task.ID)
+		deleteTaskLog(task, runResult)
+		c.addToReport(task, report.StatusFailure, runResult, err)
+		c.taskFailed(task)
+		return
+	}
+
+	if !declaredOutputsExist(task, outputs) {
+		// error is printed in declaredOutputsExist()
+		removeOutputArchives(task, outputs)
+		deleteTaskLog(task, runResult)
+		c.addToReport(task, report.StatusFailure, runResult, errors.New("declared outputs do not exist"))
+		c.taskFailed(task)
+		return
+	}
+
+	c.emitOutputsCreated(task, outputs)
+
+	if c.skipUpload {
+		removeOutputArchives(task, outputs)
+		deleteTaskLog(task, runResult)
+		c.addToReport(task, report.StatusSuccess, runResult, nil)
+		c.taskCompleted(task)
+		return
+	}
+
+	c.uploadRoutinePool.Queue(func() {
+		err := c.uploadAndRecord(pt, outputs, runResult)
+		removeOutputArchives(task, outputs)
+		deleteTaskLog(task, runResult)
+		if err != nil {
+			// error is printed in uploadAndRecord()
+			c.addToReport(task, report.StatusFailure, runResult, err)
+			c.taskFailed(task)
+			return
+		}
+
+		c.addToReport(task, report.StatusSuccess, runResult, nil)
+		c.taskCompleted(task)
+	})
+}
+
+// printInputAudit prints the files that were read by the command of the task
+// but are not inputs and the input files that were not read.
+func (c *runCmd) printInputAudit(pt *pendingTask, runResult *baur.RunResult) {
+	audit := baur.AuditInputs(pt.task, pt.inputs, runResult.FileAccesses)
+
+	var sb strings.Builder
+	if len(audit.UndeclaredReads) == 0 {
+		sb.WriteString("input audit: all read files are inputs\n")
+	} else {
+		fmt.Fprintf(&sb, "input audit: %s file(s) were read that are not inputs:\n",
+			term.YellowHighlight(len(audit.UndeclaredReads)))
+		for _, p := range audit.UndeclaredReads {
+			sb.WriteString("  " + p + "\n")
+		}
+	}
+
+	if len(audit.UnreadInputs) > 0 {
+		fmt.Fprintf(&sb, "input audit: %s input file(s) were not read:\n",
+			term.Highlight(len(audit.UnreadInputs)))
+		for _, p := range audit.UnreadInputs {
+			sb.WriteString("  " + p + "\n")
+		}
+	}
+
+	if c.auditInputsSuggest && len(audit.UndeclaredReads) > 0 {
+		sb.WriteString("input audit: suggested input configuration:\n")
+		sb.WriteString(audit.SuggestedFileInputs())
+	}
+
+	stdout.TaskPrintf(pt.task, "%s", sb.String())
+}
+
+// printUntrackedEnvVars prints a warning when environment variables are passed
+// to the command of the task that are not tracked as inputs.
+func printUntrackedEnvVars(pt *pendingTask) {
+	env, err := baur.CommandEnv(pt.task, os.Environ())
+	if err != nil {
+		stderr.ErrPrintln(err, pt.task.ID)
+		return
+	}
+
+	untracked := baur.UntrackedEnvVars(env, pt.inputs)
+	if len(untracked) == 0 {
+		return
+	}
+
+	stderr.TaskPrintf(pt.task, "%s: the following environment variables are passed to the command but are not tracked as inputs: %s\n",
+		term.YellowHighlight("warning"), strings.Join(untracked, ", "))
+}
+
+// printTaskRetry prints that an attempt to run the task failed and the
+// command is rerun.
+func printTaskRetry(task *baur.Task, attempt int, err error) {
+	var ee *exec.ExitCodeError
+	if errors.As(err, &ee) {
+		err = errors.New(ee.ColoredError(term.Highlight, term.RedHighlight, false))
+	}
+
+	stderr.Printf("%s: attempt %d/%d %s: %s, %s in %s\n",
+		term.Highlight(task),
+		attempt, task.Retries+1,
+		statusStrFailed,
+		err,
+		term.YellowHighlight("retrying"),
+		term.FormatDuration(task.RetryDelay),
 	)
+}
 
-	if c.errorHappened {
-		exitFunc(exitCodeError)
+// mustOpenEventStream opens the event stream c.eventsPath. If it is "-",
+// events are written to stdout and the other messages of the command to
+// stderr. The returned function must be called to close the stream.
+func (c *runCmd) mustOpenEventStream() func() {
+	if c.eventsPath == "-" {
+		origStdout := stdout
+		c.events = events.NewWriter(origStdout)
+		stdout = stderr
+
+		return func() { stdout = origStdout }
+	}
+
+	f, err := os.Create(c.eventsPath)
+	exitOnErrf(err, "creating events file failed")
+	c.events = events.NewWriter(f)
+
+	return func() {
+		if err := f.Close(); err != nil {
+			stderr.ErrPrintln(err, "closing events file failed")
+		}
+	}
+}
+
+// emitEvent writes an event to the event stream, if it is enabled.
+// task can be nil.
+func (c *runCmd) emitEvent(typ events.Type, task *baur.Task, data any) {
+	if c.events == nil {
+		return
+	}
+
+	var taskID string
+	if task != nil {
+		taskID = task.ID
+	}
+
+	if err := c.events.Emit(typ, taskID, data); err != nil {
+		stderr.ErrPrintln(err, "writing event failed")
+	}
+}
+
+// emitTaskFinished emits a TypeTaskFinished event for the result of
+// runTask().
+func (c *runCmd) emitTaskFinished(task *baur.Task, result *baur.RunResult, err error) {
+	var ev events.TaskFinished
+	if result != nil {
+		ev.ExitCode = result.ExitCode
+		ev.DurationMs = result.StopTime.Sub(result.StartTime).Milliseconds()
+		ev.Attempts = result.Attempts
+	}
+
+	var ee *exec.ExitCodeError
+	switch {
+	case err == nil:
+		ev.Result = events.ResultSuccess
+	case errors.Is(err, baur.ErrTaskRunSkipped):
+		ev.Result = events.ResultSkipped
+		ev.Error = err.Error()
+	case errors.As(err, &ee):
+		ev.Result = events.ResultFailure
+		ev.Error = ee.ColoredError(fmt.Sprint, fmt.Sprint, false)
+	default:
+		ev.Result = events.ResultError
+		ev.Error = err.Error()
+	}
+
+	c.emitEvent(events.TypeTaskFinished, task, &ev)
+}
+
+// emitOutputsCreated emits a TypeOutputCreated event for each output.
+func (c *runCmd) emitOutputsCreated(task *baur.Task, outputs []baur.Output) {
+	if c.events == nil {
+		return
+	}
+
+	for _, o := range outputs {
+		size, err := o.SizeBytes()
+		if err != nil {
+			stderr.ErrPrintln(err, task.ID)
+			continue
+		}
+
+		d, err := o.Digest()
+		if err != nil {
+			stderr.ErrPrintln(err, task.ID)
+			continue
+		}
+
+		c.emitEvent(events.TypeOutputCreated, task, &events.OutputCreated{
+			Output:    o.Name(),
+			Type:      o.Type().String(),
+			SizeBytes: size,
+			Digest:    d.String(),
+		})
 	}
 }
 
+// mustSelectShard partitions tasks into the number of shards passed via
+// --shard and returns the tasks of the selected shard.
+// The shards are balanced by the average durations of previous runs. All CI
+// jobs of a pipeline must compute the same partitions, therefore only runs are
+// considered that stopped at least shardRunsMinAge before the commit time of
+// HEAD. Runs are recorded shortly after they stopped, the set of those runs
+// does not change anymore when the CI jobs start.
+// This does not hold when a run is recorded more than shardRunsMinAge after it
+// stopped or the clocks of the hosts differ by more than shardRunsMinAge, the
+// CI jobs can then compute different partitions and run some tasks in
+// multiple or in no job.
+func (c *runCmd) mustSelectShard(tasks []*baur.Task) []*baur.Task {
+	commitTime, err := git.CommitTime(ctx, c.repoRootPath)
+	exitOnErrf(err, "--shard: determining commit time of HEAD failed")
+
+	durations, err := baur.AvgTaskRunDurations(ctx, c.storage, tasks, commitTime.Add(-shardRunsMinAge), taskDurationRuns)
+	exitOnErrf(err, "--shard: querying durations of previous task runs failed")
+
+	shards := baur.ShardTasks(tasks, c.shard.Count, durations)
+	result := shards[c.shard.Index-1]
+
+	stdout.Printf("%d/%d task(s) assigned to shard %s\n\n",
+		len(result), len(tasks),
+		term.Highlight(fmt.Sprintf("%d/%d", c.shard.Index, c.shard.Count)),
+	)
+
+	return result
+}
+
+// sortByCriticalPath sorts pendingTasks by the expected durations of their
+// critical paths, which are estimated from the durations of previous runs.
+// If the durations can not be queried, an error is printed and the order is
+// kept.
+func (c *runCmd) sortByCriticalPath(pendingTasks []*pendingTask) {
+	if len(pendingTasks) < 2 {
+		return
+	}
+
+	tasks := pendingTasksToTasks(pendingTasks)
+
+	durations, err := baur.AvgTaskRunDurations(ctx, c.storage, tasks, time.Now(), taskDurationRuns)
+	if err != nil {
+		stderr.ErrPrintln(err, "querying durations of previous task runs failed, tasks are run in the order of their IDs")
+		return
+	}
+
+	baur.SortTasksByCriticalPath(tasks, durations)
+
+	order := make(map[string]int, len(tasks))
+	for i, t := range tasks {
+		order[t.ID] = i
+	}
+
+	slices.SortFunc(pendingTasks, func(a, b *pendingTask) int {
+		return cmp.Compare(order[a.task.ID], order[b.task.ID])
+	})
+}
+
+// addToReport adds the result of a task to the run report, if it is enabled.
+// runResult and err can be nil.
+func (c *runCmd) addToReport(task *baur.Task, status report.Status, runResult *baur.RunResult, err error) {
+	if c.report == nil {
+		return
+	}
+
+	res := report.TaskResult{
+		AppName:  task.AppName,
+		TaskName: task.Name,
+		Status:   status,
+	}
+
+	if runResult != nil {
+		res.Duration = runResult.StopTime.Sub(runResult.StartTime)
+		if status == report.StatusFailure {
+			res.Output = string(runResult.Output)
+		}
+	}
+
+	if err != nil {
+		var ee *exec.ExitCodeError
+		if errors.As(err, &ee) {
+			res.Message = ee.ColoredError(fmt.Sprint, fmt.Sprint, false)
+		} else {
+			res.Message = err.Error()
+		}
+	}
+
+	c.report.Add(&res)
+}
+
+// writeReports writes the run report to the files passed via
+// --report-junit and --report-markdown.
+// If writing a report fails, the command exits with an error code.
+func (c *runCmd) writeReports() {
+	if c.reportJUnitPath != "" && !writeReportFile(c.reportJUnitPath, c.report.WriteJUnit) {
+		c.errorHappened = true
+	}
+
+	if c.reportMarkdownPath != "" && !writeReportFile(c.reportMarkdownPath, c.report.WriteMarkdown) {
+		c.errorHappened = true
+	}
+}
+
+// writeReportFile creates the file at path and writes a report to it via
+// writeFn. On errors, the error is printed and false is returned.
+func writeReportFile(path string, writeFn func(io.Writer) error) bool {
+	f, err := os.Create(path)
+	if err != nil {
+		stderr.ErrPrintf(err, "creating report file failed")
+		return false
+	}
+
+	err = writeFn(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		stderr.ErrPrintf(err, "writing report %s failed", path)
+		return false
+	}
+
+	stdout.Printf("report written to %s\n", term.Highlight(path))
+
+	return true
+}
+
+// processIdentifier returns an identifier for this baur process, that is
+// stored in the database e.g. as holder of run leases.
+func processIdentifier() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), rand.Text())
+}
+
+// acquireRunLease acquires the run lease of the task. If another baur
+// process holds the lease, it waits until it was released. If the other
+// process recorded a run of the task with the same inputs, the task is
+// completed without executing it.
+// If false is returned, the task must not be executed, taskCompleted() or
+// taskFailed() was already called.
+func (c *runCmd) acquireRunLease(pt *pendingTask) bool {
+	task := pt.task
+
+	totalDigest, err := pt.inputs.Digest()
+	if err != nil {
+		stderr.ErrPrintln(err, task.ID)
+		c.addToReport(task, report.StatusFailure, nil, err)
+		c.taskFailed(task)
+		return false
+	}
+
+	lease, run, err := c.runLeaser.Acquire(c.execCtx, task, totalDigest.String())
+	if err != nil {
+		stderr.ErrPrintln(err, task.ID)
+		c.addToReport(task, report.StatusFailure, nil, err)
+		c.taskFailed(task)
+		return false
+	}
+
+	if run != nil {
+		stdout.TaskPrintf(task, "execution %s, a run with the same inputs was recorded by another baur process with ID %s\n",
+			statusStrSkipped, term.Highlight(run.ID),
+		)
+		c.emitEvent(events.TypeStatusEvaluated, task, &events.StatusEvaluated{
+			Status: baur.TaskStatusRunExist.String(),
+			RunID:  run.ID,
+		})
+		c.addToReport(task, report.StatusCached, nil, nil)
+		c.taskCompleted(task)
+		return false
+	}
+
+	pt.lease = lease
+
+	return true
+}
+
+// releaseRunLease releases the run lease of the task, if one was acquired.
+func (c *runCmd) releaseRunLease(task *baur.Task) {
+	pt := c.pendingTasks[task.ID]
+	if pt == nil || pt.lease == nil {
+		return
+	}
+
+	err := pt.lease.Release(ctx)
+	pt.lease = nil
+	if err != nil {
+		stderr.ErrPrintln(err, task.ID)
+	}
+}
+
+// taskCompleted must be called when a task was run, it's outputs uploaded
+// and the run recorded successfully. Tasks that were waiting for it to finish
+// are queued for execution.
+func (c *runCmd) taskCompleted(task *baur.Task) {
+	c.releaseRunLease(task)
+	c.queueTaskRuns(c.scheduler.Completed(task))
+	c.pendingTasksWg.Done()
+}
+
+// taskFailed must be called when running a task, uploading it's outputs or
+// recording it failed. Tasks that depend on it are skipped.
+func (c *runCmd) taskFailed(task *baur.Task) {
+	c.releaseRunLease(task)
+	c.skipAllScheduledTaskRuns()
+
+	for _, skipped := range c.scheduler.Failed(task) {
+		stderr.Printf("%s: execution %s, TaskInfo dependency %s %s\n",
+			term.Highlight(skipped),
+			statusStrSkipped,
+			term.Highlight(task),
+			statusStrFailed,
+		)
+		c.emitEvent(events.TypeTaskFinished, skipped, &events.TaskFinished{
+			Result: events.ResultSkipped,
+			Error:  fmt.Sprintf("TaskInfo dependency %s failed", task),
+		})
+		c.addToReport(skipped, report.StatusSkipped, nil, fmt.Errorf("dependency %s failed", task))
+		c.pendingTasksWg.Done()
+	}
+
+	c.pendingTasksWg.Done()
+}
+
 func (c *runCmd) skipAllScheduledTaskRuns() {
 	c.skipAllScheduledTaskRunsOnce.Do(func() {
 		c.taskRunner.SkipRuns(true)
@@ -313,13 +1229,36 @@ func (c *runCmd) skipAllScheduledTaskRuns() {
 	})
 }
 
-func (c *runCmd) runTask(task *baur.Task) (*baur.RunResult, error) {
-	result, err := c.taskRunner.Run(task)
+// runTask executes the task and prints the result.
+// If the command exits with a non-zero code, the RunResult is returned
+// together with an *exec.ExitCodeError.
+func (c *runCmd) runTask(pt *pendingTask) (*baur.RunResult, error) {
+	task := pt.task
+
+	var result *baur.RunResult
+	var err error
+	if c.sandbox {
+		result, err = c.taskRunner.RunInSandbox(c.execCtx, task, pt.inputs)
+	} else {
+		result, err = c.taskRunner.Run(c.execCtx, task)
+	}
 	if err == nil {
 		err = result.ExpectSuccess()
 	}
 
 	if err == nil {
+		if result.Attempts > 1 {
+			stdout.TaskPrintf(task, "execution %s after %s attempts (%s)\n",
+				statusStrSuccess,
+				term.Highlight(result.Attempts),
+				term.FormatDuration(
+					result.StopTime.Sub(result.StartTime),
+				),
+			)
+
+			return result, nil
+		}
+
 		stdout.TaskPrintf(task, "execution %s (%s)\n",
 			statusStrSuccess,
 			term.FormatDuration(
@@ -344,6 +1283,24 @@ func (c *runCmd) runTask(task *baur.Task) (*baur.RunResult, error) {
 			term.Highlight(task),
 			ee.ColoredError(term.Highlight, term.RedHighlight, !c.showOutput && !verboseFlag),
 		)
+		return result, err
+	}
+
+	if errors.Is(err, baur.ErrTaskRunTimeout) {
+		stderr.Printf("%s: execution %s, %s\n",
+			term.Highlight(task),
+			statusStrFailed,
+			err,
+		)
+		return nil, err
+	}
+
+	if c.execCtx.Err() != nil {
+		stderr.Printf("%s: execution %s, %s\n",
+			term.Highlight(task),
+			term.RedHighlight("terminated"),
+			context.Cause(c.execCtx),
+		)
 		return nil, err
 	}
 
@@ -362,39 +1319,136 @@ func (c *runCmd) runTask(task *baur.Task) (*baur.RunResult, error) {
 	return nil, err
 }
 
+// uploadAndRecord uploads the outputs and records the run in the database.
+// The run is also recorded when the execution was interrupted after the
+// uploads finished.
 func (c *runCmd) uploadAndRecord(
-	ctx context.Context,
 	pt *pendingTask,
 	outputs []baur.Output,
 	runResult *baur.RunResult,
 ) error {
-	var uploadResults []*baur.UploadResult
 	task := pt.task
 	inputs := pt.inputs
 
+	uploadResults, err := uploadOutputs(c.execCtx, c.uploader, task, outputs, c.emitEvent)
+	if err != nil {
+		// error is printed in uploadOutputs()
+		return err
+	}
+
+	uploadTaskLog(c.execCtx, c.uploader, c.taskLogDest, task, runResult)
+
+	id, err := baur.StoreRun(ctx, c.storage, c.gitRepo, task, inputs, runResult, uploadResults)
+	if err != nil {
+		stderr.Printf("%s: recording build result in database %s, %s\n",
+			term.Highlight(task),
+			statusStrFailed,
+			err,
+		)
+		return err
+	}
+
+	stdout.TaskPrintf(task, "run stored in database with ID %s\n", term.Highlight(id))
+	c.emitEvent(events.TypeRunStored, task, &events.RunStored{RunID: id, Result: string(storage.ResultSuccess)})
+
+	return nil
+}
+
+// recordFailedRun stores the run of a task that exited with a non-zero exit
+// code in the database.
+func (c *runCmd) recordFailedRun(pt *pendingTask, runResult *baur.RunResult) {
+	uploadTaskLog(c.execCtx, c.uploader, c.taskLogDest, pt.task, runResult)
+
+	id, err := baur.StoreRun(ctx, c.storage, c.gitRepo, pt.task, pt.inputs, runResult, nil)
+	if err != nil {
+		stderr.Printf("%s: recording failed run in database %s, %s\n",
+			term.Highlight(pt.task),
+			statusStrFailed,
+			err,
+		)
+		return
+	}
+
+	stdout.TaskPrintf(pt.task, "failed run stored in database with ID %s\n", term.Highlight(id))
+	c.emitEvent(events.TypeRunStored, pt.task, &events.RunStored{RunID: id, Result: string(storage.ResultFailure)})
+}
+
+// uploadOutputs uploads the outputs of task to their destinations, prints the
+// progress and returns the results of the uploads.
+// emitEvent is called for the events of the uploads, it can be nil.
+func uploadOutputs(
+	ctx context.Context,
+	uploader *baur.Uploader,
+	task *baur.Task,
+	outputs []baur.Output,
+	emitEvent func(events.Type, *baur.Task, any),
+) ([]*baur.UploadResult, error) {
+	if emitEvent == nil {
+		emitEvent = func(events.Type, *baur.Task, any) {}
+	}
+
+	// the uploads to the destinations of an output run concurrently,
+	// the callbacks are called from multiple goroutines
+	var mu sync.Mutex
+	var uploadResults []*baur.UploadResult
+	var uploadedBytes uint64
+	var sizeErr error
+
+	startTime := time.Now()
+
 	for _, output := range outputs {
-		err := c.uploader.Upload(
+		err := uploader.Upload(
+			ctx,
 			output,
-			func(_ baur.Output, info baur.UploadInfo) {
+			func(o baur.Output, info baur.UploadInfo, url string) {
 				log.Debugf("%s: uploading output %s to %s\n",
-					task, output, info)
+					task, output, url)
+				emitEvent(events.TypeUploadStarted, task, &events.UploadStarted{
+					Output:      o.Name(),
+					Destination: info.String(),
+					URL:         url,
+				})
 			},
 			func(o baur.Output, result *baur.UploadResult) {
 				size, err := o.SizeBytes()
 				if err != nil {
 					stderr.ErrPrintf(err, "%s: %s", task, output)
-					c.skipAllScheduledTaskRuns()
+					mu.Lock()
+					sizeErr = err
+					mu.Unlock()
 					return
 				}
 
 				bps := uint64(math.Round(float64(size) / result.Stop.Sub(result.Start).Seconds()))
 
+				mu.Lock()
+				uploadResults = append(uploadResults, result)
+				uploadedBytes += size
+				mu.Unlock()
+
 				stdout.TaskPrintf(task, "%s uploaded to %s (%s/s)\n",
 					output, result.URL,
 					term.FormatSize(bps),
 				)
-
-				uploadResults = append(uploadResults, result)
+				emitEvent(events.TypeUploadFinished, task, &events.UploadFinished{
+					Output:         o.Name(),
+					URL:            result.URL,
+					Method:         result.Method.String(),
+					DurationMs:     result.Stop.Sub(result.Start).Milliseconds(),
+					BytesPerSecond: bps,
+				})
+			},
+			func(o baur.Output, info baur.UploadInfo, attempt int, err error) {
+				stderr.Printf("%s: %s: upload to %s attempt %d/%d %s: %s, %s in %s\n",
+					term.Highlight(task),
+					o,
+					info,
+					attempt, uploader.Retries+1,
+					statusStrFailed,
+					err,
+					term.YellowHighlight("retrying"),
+					term.FormatDuration(uploader.RetryDelay),
+				)
 			},
 		)
 		if err != nil {
@@ -404,23 +1458,70 @@ func (c *runCmd) uploadAndRecord(
 				statusStrFailed,
 				err,
 			)
-			return err
+			emitEvent(events.TypeUploadFailed, task, &events.UploadFailed{
+				Output: output.Name(),
+				Error:  err.Error(),
+			})
+			return nil, err
+		}
+
+		if sizeErr != nil {
+			return nil, sizeErr
 		}
 	}
 
-	id, err := baur.StoreRun(ctx, c.storage, c.gitRepo, task, inputs, runResult, uploadResults)
-	if err != nil {
-		stderr.Printf("%s: recording build result in database %s, %s\n",
-			term.Highlight(task),
-			statusStrFailed,
-			err,
+	if len(uploadResults) > 0 {
+		stdout.TaskPrintf(task, "%d output(s) uploaded to %d destination(s) (%s in %s)\n",
+			len(outputs), len(uploadResults),
+			term.FormatSize(uploadedBytes),
+			term.FormatDuration(time.Since(startTime)),
 		)
-		return err
 	}
 
-	stdout.TaskPrintf(task, "run stored in database with ID %s\n", term.Highlight(id))
+	return uploadResults, nil
+}
 
-	return nil
+// uploadTaskLog uploads the complete output of the task command, if it is too
+// large to be stored in the database and a destination is configured in dest.
+// When the upload fails, an error is printed and the output is stored in the
+// database.
+func uploadTaskLog(ctx context.Context, uploader *baur.Uploader, dest *cfg.TaskLog, task *baur.Task, runResult *baur.RunResult) {
+	err := uploader.UploadLog(ctx, task, runResult, dest)
+	if err != nil {
+		stderr.Printf("%s: %s, storing it in the database\n", term.Highlight(task), err)
+		return
+	}
+
+	if runResult.LogURL != "" {
+		stdout.TaskPrintf(task, "log uploaded to %s\n", runResult.LogURL)
+	}
+}
+
+// deleteTaskLog deletes the temporary file that contains the complete output
+// of the task command.
+func deleteTaskLog(task *baur.Task, runResult *baur.RunResult) {
+	if runResult == nil {
+		return
+	}
+
+	if err := runResult.Close(); err != nil {
+		stderr.ErrPrintf(err, "%s: deleting temporary file of the task output failed", task)
+	}
+}
+
+// removeOutputArchives removes the temporary tar archives of file tree
+// outputs.
+func removeOutputArchives(task *baur.Task, outputs []baur.Output) {
+	for _, output := range outputs {
+		ft, ok := output.(*baur.OutputFileTree)
+		if !ok {
+			continue
+		}
+
+		if err := ft.RemoveArchive(); err != nil {
+			log.Debugf("%s: removing archive of %s failed: %s\n", task, output, err)
+		}
+	}
 }
 
 func declaredOutputsExist(task *baur.Task, outputs []baur.Output) bool {
@@ -486,11 +1587,21 @@ func (c *runCmd) filterPendingTasks(taskStatusEvaluator *baur.TaskStatusEvaluato
 			return nil, fmt.Errorf("%s: evaluating task status failed: %w", task, err)
 		}
 
+		statusEv := events.StatusEvaluated{Status: status.String()}
+		if run != nil {
+			statusEv.RunID = run.ID
+		}
+		c.emitEvent(events.TypeStatusEvaluated, task, &statusEv)
+
 		if status == baur.TaskStatusRunExist {
 			stdout.Printf("%-*s%s%s (%s)\n",
 				taskIDColLen, task, sep, term.ColoredTaskStatus(status), term.GreenHighlight(run.ID))
 
 			if !c.force {
+				c.addToReport(task, report.StatusCached, nil, nil)
+				if c.restoreOutputs {
+					c.existingRuns = append(c.existingRuns, &existingRun{task: task, runID: run.ID})
+				}
 				continue
 			}
 		} else {
@@ -500,5 +1611,71 @@ func (c *runCmd) filterPendingTasks(taskStatusEvaluator *baur.TaskStatusEvaluato
 		result = append(result, &pendingTask{task: task, inputs: inputs})
 	}
 
+	deps, err := c.pendingTaskInfoDependencies(taskStatusEvaluator, tasks, result, taskIDColLen)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(result, deps...), nil
+}
+
+// pendingTaskInfoDependencies returns the pending tasks that are referenced
+// directly or indirectly as TaskInfo inputs by pendingTasks and are not part of
+// tasks. They must be run before the tasks that reference them, otherwise
+// the TaskInfo files would reference outputs that do not exist.
+func (c *runCmd) pendingTaskInfoDependencies(
+	taskStatusEvaluator *baur.TaskStatusEvaluator,
+	tasks []*baur.Task,
+	pendingTasks []*pendingTask,
+	taskIDColLen int,
+) ([]*pendingTask, error) {
+	const sep = " => "
+
+	evaluated := make(set.Set[string], len(tasks))
+	for _, task := range tasks {
+		evaluated.Add(task.ID)
+	}
+
+	queue := make([]*baur.Task, 0, len(pendingTasks))
+	for _, pt := range pendingTasks {
+		queue = append(queue, pt.task)
+	}
+
+	var result []*pendingTask
+	for len(queue) > 0 {
+		task := queue[0]
+		queue = queue[1:]
+
+		for _, ti := range task.TaskInfoDependencies {
+			dep := ti.Task
+			if evaluated.Contains(dep.ID) {
+				continue
+			}
+			evaluated.Add(dep.ID)
+
+			status, inputs, run, err := taskStatusEvaluator.Status(ctx, dep)
+			if err != nil {
+				return nil, fmt.Errorf("%s: evaluating task status failed: %w", dep, err)
+			}
+
+			statusEv := events.StatusEvaluated{Status: status.String()}
+			if run != nil {
+				statusEv.RunID = run.ID
+			}
+			c.emitEvent(events.TypeStatusEvaluated, dep, &statusEv)
+
+			if status == baur.TaskStatusRunExist {
+				log.Debugf("%s: TaskInfo input %s has status %s (%d)\n", task, dep, status, run.ID)
+				continue
+			}
+
+			stdout.Printf("%-*s%s%s (TaskInfo input of %s)\n",
+				taskIDColLen, dep, sep, term.ColoredTaskStatus(status), term.Highlight(task))
+
+			result = append(result, &pendingTask{task: dep, inputs: inputs})
+			queue = append(queue, dep)
+		}
+	}
+
 	return result, nil
 }
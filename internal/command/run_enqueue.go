@@ -0,0 +1,213 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/simplesurance/baur/v5/internal/command/term"
+	"github.com/simplesurance/baur/v5/internal/events"
+	"github.com/simplesurance/baur/v5/internal/report"
+	"github.com/simplesurance/baur/v5/pkg/baur"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+// enqueuePollInterval is the interval in that the states of enqueued jobs
+// are queried.
+const enqueuePollInterval = 2 * time.Second
+
+// enqueueAndWait adds the pending tasks as jobs to the queue in the database
+// and waits until workers processed them.
+// Tasks are only enqueued when the runs of the pending tasks that they
+// reference as TaskInfo inputs were recorded.
+// When c.execCtx is canceled or the jobs did not finish within
+// c.enqueueTimeout, the jobs that were not claimed by a worker yet are
+// canceled.
+func (c *runCmd) enqueueAndWait(pendingTasks []*pendingTask) {
+	commitID, err := c.gitRepo.CommitID()
+	exitOnErr(err)
+
+	scheduler, err := baur.NewTaskScheduler(pendingTasksToTasks(pendingTasks))
+	exitOnErr(err)
+
+	pendingTasksByID := make(map[string]*pendingTask, len(pendingTasks))
+	for _, pt := range pendingTasks {
+		pendingTasksByID[pt.task.ID] = pt
+	}
+
+	// jobs contains the pending tasks of enqueued jobs that did not finish
+	jobs := map[int]*pendingTask{}
+
+	enqueue := func(tasks []*baur.Task) {
+		err := c.enqueueJobs(commitID, pendingTasksByID, tasks, jobs)
+		if err == nil {
+			return
+		}
+
+		stderr.ErrPrintln(err, "enqueuing jobs failed")
+		c.errorHappened = true
+		for _, task := range tasks {
+			c.addToReport(task, report.StatusFailure, nil, err)
+			c.reportSkippedDependents(scheduler.Failed(task), task)
+		}
+	}
+
+	var timeout <-chan time.Time
+	if c.enqueueTimeout > 0 {
+		timer := time.NewTimer(c.enqueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	enqueue(scheduler.Start())
+
+	for len(jobs) > 0 {
+		select {
+		case <-c.execCtx.Done():
+			c.cancelJobs(jobs)
+			return
+		case <-timeout:
+			c.cancelJobs(jobs)
+			c.reportUnfinishedJobs(scheduler, jobs)
+			return
+		case <-time.After(enqueuePollInterval):
+		}
+
+		states, err := c.storage.TaskRunJobs(ctx, slices.Sorted(maps.Keys(jobs)))
+		if err != nil {
+			stderr.ErrPrintln(err, "querying job states failed")
+			continue
+		}
+
+		for _, job := range states {
+			if !job.State.IsFinished() {
+				continue
+			}
+
+			pt := jobs[job.ID]
+			delete(jobs, job.ID)
+
+			if job.State == storage.JobStateSuccess {
+				stdout.TaskPrintf(pt.task, "job %d %s, run by worker %s stored with ID %s\n",
+					job.ID, statusStrSuccess, job.Worker, term.Highlight(job.TaskRunID),
+				)
+				c.emitEvent(events.TypeRunStored, pt.task, &events.RunStored{
+					RunID:  job.TaskRunID,
+					Result: string(storage.ResultSuccess),
+				})
+				c.addToReport(pt.task, report.StatusSuccess, nil, nil)
+				enqueue(scheduler.Completed(pt.task))
+				continue
+			}
+
+			jobErr := jobError(job)
+			stderr.Printf("%s: job %d %s, %s\n",
+				term.Highlight(pt.task), job.ID, statusStrFailed, jobErr,
+			)
+			c.errorHappened = true
+			c.addToReport(pt.task, report.StatusFailure, nil, jobErr)
+			c.reportSkippedDependents(scheduler.Failed(pt.task), pt.task)
+		}
+	}
+}
+
+// enqueueJobs adds jobs for tasks to the queue and adds them to jobs.
+func (c *runCmd) enqueueJobs(commitID string, pendingTasks map[string]*pendingTask, tasks []*baur.Task, jobs map[int]*pendingTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	newJobs := make([]*storage.TaskRunJob, 0, len(tasks))
+	for _, task := range tasks {
+		totalDigest, err := pendingTasks[task.ID].inputs.Digest()
+		if err != nil {
+			return fmt.Errorf("%s: calculating total input digest failed: %w", task, err)
+		}
+
+		newJobs = append(newJobs, &storage.TaskRunJob{
+			ApplicationName:  task.AppName,
+			TaskName:         task.Name,
+			VCSRevision:      commitID,
+			TotalInputDigest: totalDigest.String(),
+		})
+	}
+
+	ids, err := c.storage.EnqueueTaskRunJobs(ctx, newJobs)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		jobs[id] = pendingTasks[tasks[i].ID]
+		stdout.TaskPrintf(tasks[i], "enqueued as job %s\n", term.Highlight(id))
+	}
+
+	return nil
+}
+
+// reportSkippedDependents prints that the tasks in skipped are not enqueued
+// because failedTask failed.
+func (c *runCmd) reportSkippedDependents(skipped []*baur.Task, failedTask *baur.Task) {
+	for _, task := range skipped {
+		stderr.Printf("%s: execution %s, TaskInfo dependency %s %s\n",
+			term.Highlight(task),
+			statusStrSkipped,
+			term.Highlight(failedTask),
+			statusStrFailed,
+		)
+		c.addToReport(task, report.StatusSkipped, nil, fmt.Errorf("dependency %s failed", failedTask))
+	}
+}
+
+// reportUnfinishedJobs reports the jobs as failed because they did not finish
+// within c.enqueueTimeout.
+func (c *runCmd) reportUnfinishedJobs(scheduler *baur.TaskScheduler, jobs map[int]*pendingTask) {
+	c.errorHappened = true
+
+	for _, id := range slices.Sorted(maps.Keys(jobs)) {
+		pt := jobs[id]
+		err := fmt.Errorf("job %d did not finish within %s", id, term.FormatDuration(c.enqueueTimeout))
+
+		stderr.Printf("%s: %s\n", term.Highlight(pt.task), err)
+		c.addToReport(pt.task, report.StatusFailure, nil, err)
+		c.reportSkippedDependents(scheduler.Failed(pt.task), pt.task)
+	}
+}
+
+// cancelJobs cancels the jobs that were not claimed by a worker yet.
+func (c *runCmd) cancelJobs(jobs map[int]*pendingTask) {
+	canceled, err := c.storage.CancelTaskRunJobs(context.WithoutCancel(ctx), slices.Sorted(maps.Keys(jobs)))
+	if err != nil {
+		stderr.ErrPrintln(err, "canceling jobs failed")
+		return
+	}
+
+	stderr.Printf("%d queued job(s) %s, %d running job(s) are processed by workers\n",
+		canceled, term.YellowHighlight("canceled"), int64(len(jobs))-canceled,
+	)
+}
+
+func jobError(job *storage.TaskRunJobWithID) error {
+	var msg string
+
+	switch job.State {
+	case storage.JobStateFailure:
+		msg = fmt.Sprintf("task command failed on worker %s", job.Worker)
+		if job.TaskRunID != 0 {
+			msg += fmt.Sprintf(", run stored with ID %d", job.TaskRunID)
+		}
+	case storage.JobStateCanceled:
+		msg = "job was canceled"
+	default:
+		msg = fmt.Sprintf("processing job on worker %s failed", job.Worker)
+	}
+
+	if job.Error != "" {
+		msg += ": " + job.Error
+	}
+
+	return errors.New(msg)
+}
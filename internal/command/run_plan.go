@@ -0,0 +1,139 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/simplesurance/baur/v5/internal/command/flag"
+	"github.com/simplesurance/baur/v5/internal/command/term"
+	"github.com/simplesurance/baur/v5/internal/format/table"
+	"github.com/simplesurance/baur/v5/pkg/baur"
+)
+
+// executionPlan is the JSON representation of the plan printed by --plan.
+type executionPlan struct {
+	// Tasks are the tasks that would be run, in the order in that they
+	// would be started.
+	Tasks []*plannedTask `json:"tasks"`
+}
+
+type plannedTask struct {
+	Task             string           `json:"task"`
+	TotalInputDigest string           `json:"total_input_digest"`
+	DependsOn        []string         `json:"depends_on"`
+	Outputs          []*plannedOutput `json:"outputs"`
+}
+
+type plannedOutput struct {
+	Name    string           `json:"name"`
+	Type    string           `json:"type"`
+	Uploads []*plannedUpload `json:"uploads"`
+}
+
+type plannedUpload struct {
+	Method      string `json:"method"`
+	Destination string `json:"destination"`
+}
+
+// mustPrintPlan prints the tasks that would be run, their outputs and upload
+// destinations to c.planOut in the format passed via --plan-format.
+// taskCnt is the number of tasks whose status was evaluated.
+func (c *runCmd) mustPrintPlan(pendingTasks []*pendingTask, taskCnt int) {
+	plan := executionPlan{Tasks: make([]*plannedTask, 0, len(pendingTasks))}
+
+	for _, pt := range pendingTasks {
+		p, err := newPlannedTask(pt)
+		exitOnErrf(err, "%s: creating execution plan failed", pt.task)
+
+		plan.Tasks = append(plan.Tasks, p)
+	}
+
+	if c.planFormat.Val == flag.FormatJSON {
+		enc := json.NewEncoder(c.planOut)
+		enc.SetIndent("", "  ")
+		exitOnErr(enc.Encode(&plan))
+		return
+	}
+
+	stdout.Printf("%d/%d task(s) with status %s would be run in the following order:\n\n",
+		len(pendingTasks), taskCnt, term.ColoredTaskStatus(baur.TaskStatusExecutionPending))
+
+	writePlanTable(c.planOut, &plan)
+}
+
+func newPlannedTask(pt *pendingTask) (*plannedTask, error) {
+	totalDigest, err := pt.inputs.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("calculating total input digest failed: %w", err)
+	}
+
+	outputs, err := baur.PlannedOutputs(pt.task)
+	if err != nil {
+		return nil, err
+	}
+
+	result := plannedTask{
+		Task:             pt.task.ID,
+		TotalInputDigest: totalDigest.String(),
+		DependsOn:        make([]string, 0, len(pt.task.TaskInfoDependencies)),
+		Outputs:          make([]*plannedOutput, 0, len(outputs)),
+	}
+
+	for _, ti := range pt.task.TaskInfoDependencies {
+		result.DependsOn = append(result.DependsOn, ti.Task.ID)
+	}
+
+	for _, o := range outputs {
+		po := plannedOutput{
+			Name:    o.Name,
+			Type:    o.Type.String(),
+			Uploads: make([]*plannedUpload, 0, len(o.Uploads)),
+		}
+
+		for _, u := range o.Uploads {
+			po.Uploads = append(po.Uploads, &plannedUpload{
+				Method:      u.Method.String(),
+				Destination: u.Destination.String(),
+			})
+		}
+
+		result.Outputs = append(result.Outputs, &po)
+	}
+
+	return &result, nil
+}
+
+// writePlanTable writes the plan as table, with a row per upload
+// destination. The task columns are only filled in the first row of a task.
+func writePlanTable(w io.Writer, plan *executionPlan) {
+	formatter := table.New([]string{
+		"#", "Task", "Total Input Digest", "Depends On", "Output", "Method", "Destination",
+	}, w)
+
+	for i, pt := range plan.Tasks {
+		taskCols := []any{i + 1, pt.Task, pt.TotalInputDigest, strings.Join(pt.DependsOn, ", ")}
+		emptyTaskCols := []any{"", "", "", ""}
+
+		if len(pt.Outputs) == 0 {
+			mustWriteRow(formatter, append(taskCols, "-", "", "")...)
+			continue
+		}
+
+		for _, o := range pt.Outputs {
+			if len(o.Uploads) == 0 {
+				mustWriteRow(formatter, append(taskCols, o.Name, "", "")...)
+				taskCols = emptyTaskCols
+				continue
+			}
+
+			for _, u := range o.Uploads {
+				mustWriteRow(formatter, append(taskCols, o.Name, u.Method, u.Destination)...)
+				taskCols = emptyTaskCols
+			}
+		}
+	}
+
+	exitOnErr(formatter.Flush())
+}
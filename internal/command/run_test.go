@@ -4,20 +4,28 @@
 package command
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/simplesurance/baur/v5/internal/events"
+	"github.com/simplesurance/baur/v5/internal/exec"
 	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
 	"github.com/simplesurance/baur/v5/internal/testutils/gittest"
 	"github.com/simplesurance/baur/v5/internal/testutils/repotest"
+	"github.com/simplesurance/baur/v5/pkg/baur"
 	"github.com/simplesurance/baur/v5/pkg/cfg"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+	"github.com/simplesurance/baur/v5/pkg/storage/postgres"
 )
 
 func TestRunSimultaneously(t *testing.T) {
@@ -477,3 +485,997 @@ func TestRunFailFastDisabledContinuesAfterError(t *testing.T) {
 	assert.Regexp(t, "^testapp.build.*failed: exit status 1", stderr.String())
 	assert.Contains(t, stdout.String(), "testapp.xbuild: run stored in database")
 }
+
+func TestRunTaskInfoDependenciesAreRecordedBeforeDependentsRun(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"bash", "-c", `test -f gen.out && grep -q "filecopy-artifacts/gen.out" "$GEN_TASKINFO"`},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+					TaskInfos: []cfg.TaskInfo{
+						{TaskName: "gen", EnvVarName: "GEN_TASKINFO"},
+					},
+				},
+			},
+			{
+				Name:    "gen",
+				Command: []string{"bash", "-c", "sleep 2; echo generated > gen.out"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+				Output: cfg.Output{
+					File: []cfg.FileOutput{
+						{
+							Path:     "gen.out",
+							FileCopy: []cfg.FileCopy{{Path: r.FilecopyArtifactDir}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"-p", "2"})
+	stdout, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	err = runCmdTest.Execute()
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode, stderr.String())
+
+	assert.Contains(t, stdout.String(), "testapp.gen: run stored in database")
+	assert.Contains(t, stdout.String(), "testapp.build: run stored in database")
+}
+
+func TestRunRunsPendingTaskInfoDependenciesOfOtherApps(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	genOutputPath := filepath.Join(r.FilecopyArtifactDir, "gen.out")
+
+	apps := []*cfg.App{
+		{
+			Name: "codegen",
+			Tasks: cfg.Tasks{
+				{
+					Name:    "gen",
+					Command: []string{"bash", "-c", "echo generated > gen.out"},
+					Input: cfg.Input{
+						Files: []cfg.FileInputs{
+							{Paths: []string{".app.toml"}},
+						},
+					},
+					Output: cfg.Output{
+						File: []cfg.FileOutput{
+							{
+								Path:     "gen.out",
+								FileCopy: []cfg.FileCopy{{Path: r.FilecopyArtifactDir}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: "svc",
+			Tasks: cfg.Tasks{
+				{
+					Name:    "build",
+					Command: []string{"test", "-f", genOutputPath},
+					Input: cfg.Input{
+						Files: []cfg.FileInputs{
+							{Paths: []string{".app.toml"}},
+						},
+						TaskInfos: []cfg.TaskInfo{
+							{TaskName: "codegen.gen", EnvVarName: "GEN_TASKINFO"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, app := range apps {
+		appDir := filepath.Join(r.Dir, app.Name)
+		require.NoError(t, os.Mkdir(appDir, 0o755))
+		require.NoError(t, app.ToFile(filepath.Join(appDir, ".app.toml")))
+	}
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"svc.build"})
+	stdout, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	err := runCmdTest.Execute()
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode, stderr.String())
+
+	assert.Contains(t, stdout.String(), "codegen.gen: run stored in database")
+	assert.Contains(t, stdout.String(), "svc.build: run stored in database")
+}
+
+func TestRunDependentsOfFailedTasksAreSkipped(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"bash", "-c", "exit 0"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+					TaskInfos: []cfg.TaskInfo{
+						{TaskName: "gen", EnvVarName: "GEN_TASKINFO"},
+					},
+				},
+			},
+			{
+				Name:    "gen",
+				Command: []string{"bash", "-c", "exit 1"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+			{
+				Name:    "check",
+				Command: []string{"bash", "-c", "exit 0"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"-p", "3"})
+	stdout, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	err = runCmdTest.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, 1, exitCode)
+
+	assert.Contains(t, stderr.String(), "testapp.build: execution skipped, TaskInfo dependency testapp.gen failed")
+	assert.Contains(t, stdout.String(), "testapp.check: run stored in database")
+}
+
+func TestRunRecordsFailedRuns(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "check",
+				Command: []string{"bash", "-c", "exit 3"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	stdout, _ := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	err = runCmdTest.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, stdout.String(), "testapp.check: failed run stored in database with ID 1")
+
+	statusOut := baurCSVStatus(t, nil, "")
+	require.Len(t, statusOut, 1)
+	assert.Equal(t, baur.TaskStatusExecutionPending.String(), statusOut[0].status)
+
+	lsRunsCmd := newLsRunsCmd()
+	lsRunsCmd.SetArgs([]string{"--format=csv", "--result=failure", "testapp.check"})
+	stdout, _ = interceptCmdOutput(t)
+	require.NoError(t, lsRunsCmd.Execute())
+	assert.Contains(t, stdout.String(), "1,testapp,check,failure,")
+
+	lsRunsCmd = newLsRunsCmd()
+	lsRunsCmd.SetArgs([]string{"--result=success", "testapp.check"})
+	_, stderr := interceptCmdOutput(t)
+	require.NoError(t, lsRunsCmd.Execute())
+	assert.Contains(t, stderr.String(), "no matching task runs exist")
+}
+
+func TestRunTerminatesTasksExceedingTheirTimeout(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"sleep", "60"},
+				Timeout: "1s",
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	_, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	err = runCmdTest.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, exitCodeError, exitCode)
+	assert.Contains(t, stderr.String(), "testapp.build: execution failed, task run exceeded timeout of 1s")
+}
+
+func TestRunTimeoutTerminatesRunningAndSkipsQueuedTasks(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "a",
+				Command: []string{"sleep", "60"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+			{
+				Name:    "b",
+				Command: []string{"true"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--timeout=1s"})
+	_, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	err = runCmdTest.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, exitCodeError, exitCode)
+	assert.Contains(t, stderr.String(), "testapp.a: execution terminated, run timeout exceeded")
+	assert.Contains(t, stderr.String(), "testapp.b: execution skipped")
+}
+
+func TestRunWarnsAboutUntrackedEnvVars(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	t.Setenv("BAUR_TEST_TRACKED", "1")
+	t.Setenv("BAUR_TEST_UNTRACKED", "1")
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"true"},
+				Environment: cfg.Environment{
+					Inherit: []string{"BAUR_TEST_*"},
+					Set:     map[string]string{"BAUR_TEST_SET": "{{ .AppName }}"},
+				},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+					EnvironmentVariables: []cfg.EnvVarsInputs{
+						{Names: []string{"BAUR_TEST_TRACKED"}},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--warn-untracked-env"})
+	_, stderr := interceptCmdOutput(t)
+	require.NoError(t, runCmdTest.Execute())
+
+	assert.Contains(t, stderr.String(),
+		"testapp.build: warning: the following environment variables are passed to the command but are not tracked as inputs: BAUR_TEST_UNTRACKED\n",
+	)
+}
+
+func TestRunSandboxFailsWhenTaskReadsUndeclaredFiles(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"cat", "input.txt", "undeclared.txt"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{"input.txt"}},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+	fstest.WriteToFile(t, []byte("in"), filepath.Join(r.Dir, "input.txt"))
+	fstest.WriteToFile(t, []byte("undeclared"), filepath.Join(r.Dir, "undeclared.txt"))
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--sandbox"})
+	_, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, runCmdTest.Execute())
+	assert.Equal(t, exitCodeError, exitCode)
+	assert.Contains(t, stderr.String(), "undeclared.txt: No such file or directory")
+}
+
+func TestRunAuditInputsReportsUndeclaredReads(t *testing.T) {
+	if !exec.TracingSupported() {
+		t.Skip("tracing is not supported on this platform")
+	}
+
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"cat", "input.txt", "undeclared.txt"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{"input.txt", "unread.txt"}},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+	fstest.WriteToFile(t, []byte("in"), filepath.Join(r.Dir, "input.txt"))
+	fstest.WriteToFile(t, []byte("unread"), filepath.Join(r.Dir, "unread.txt"))
+	fstest.WriteToFile(t, []byte("undeclared"), filepath.Join(r.Dir, "undeclared.txt"))
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--audit-inputs", "--audit-inputs-suggest"})
+	stdout, _ := interceptCmdOutput(t)
+
+	require.NoError(t, runCmdTest.Execute())
+	assert.Contains(t, stdout.String(), "file(s) were read that are not inputs:\n  undeclared.txt\n")
+	assert.Contains(t, stdout.String(), "input file(s) were not read:\n  unread.txt\n")
+	assert.Contains(t, stdout.String(), "\"undeclared.txt\",\n")
+}
+
+func TestRunRetriesFailedTaskAndStoresAttempts(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:       "check",
+				Command:    []string{"sh", "-c", "if [ -e marker ]; then exit 0; fi; touch marker; exit 1"},
+				Retries:    2,
+				RetryDelay: "10ms",
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+		},
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	stdout, stderr := interceptCmdOutput(t)
+	require.NoError(t, runCmdTest.Execute())
+	assert.Contains(t, stderr.String(), "attempt 1/3 failed")
+	assert.Contains(t, stdout.String(), "after 2 attempts")
+
+	lsRunsCmd := newLsRunsCmd()
+	lsRunsCmd.SetArgs([]string{"--format=csv", "testapp.check"})
+	stdout, _ = interceptCmdOutput(t)
+	require.NoError(t, lsRunsCmd.Execute())
+	assert.Contains(t, stdout.String(), "1,testapp,check,success,")
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(stdout.String()), ",2"), stdout.String())
+}
+
+func TestRunDoesNotRunTasksOfSameMutexGroupInParallel(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	lockDir := filepath.Join(t.TempDir(), "lock")
+	cmd := []string{"sh", "-c", fmt.Sprintf("mkdir %s || exit 1; sleep 0.3; rmdir %s", lockDir, lockDir)}
+
+	appCfg := cfg.App{Name: "testapp"}
+	for _, name := range []string{"a", "b", "c"} {
+		appCfg.Tasks = append(appCfg.Tasks, &cfg.Task{
+			Name:       name,
+			Command:    cmd,
+			MutexGroup: "db",
+			Input: cfg.Input{
+				Files: []cfg.FileInputs{
+					{Paths: []string{".app.toml"}},
+				},
+			},
+		})
+	}
+
+	err := appCfg.ToFile(filepath.Join(r.Dir, ".app.toml"))
+	require.NoError(t, err)
+
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--parallel-runs=3"})
+	_, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, runCmdTest.Execute())
+	assert.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+}
+
+func TestRunUploadsToMultipleDestinationsInParallel(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	destDir1 := filepath.Join(r.FilecopyArtifactDir, "1")
+	destDir2 := filepath.Join(r.FilecopyArtifactDir, "2")
+
+	appCfg := cfg.App{Name: "testapp"}
+	for _, name := range []string{"a", "b"} {
+		appCfg.Tasks = append(appCfg.Tasks, &cfg.Task{
+			Name:    name,
+			Command: []string{"sh", "-c", "echo " + name + " > " + name + ".txt"},
+			Input: cfg.Input{
+				Files: []cfg.FileInputs{
+					{Paths: []string{".app.toml"}},
+				},
+			},
+			Output: cfg.Output{
+				File: []cfg.FileOutput{
+					{
+						Path: name + ".txt",
+						FileCopy: []cfg.FileCopy{
+							{Path: destDir1},
+							{Path: destDir2},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	require.NoError(t, appCfg.ToFile(filepath.Join(r.Dir, ".app.toml")))
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--parallel-runs=2", "--parallel-uploads=2"})
+	stdout, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, runCmdTest.Execute())
+	require.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+	assert.Contains(t, stdout.String(), "testapp.a: 1 output(s) uploaded to 2 destination(s)")
+	assert.Contains(t, stdout.String(), "testapp.b: 1 output(s) uploaded to 2 destination(s)")
+
+	for _, p := range []string{"a.txt", "b.txt"} {
+		assert.FileExists(t, filepath.Join(destDir1, p))
+		assert.FileExists(t, filepath.Join(destDir2, p))
+	}
+
+	statusOut := baurCSVStatus(t, nil, "")
+	require.Len(t, statusOut, 2)
+	for _, s := range statusOut {
+		assert.Equal(t, baur.TaskStatusRunExist.String(), s.status)
+	}
+}
+
+func TestRunWritesEvents(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"sh", "-c", "echo hello > out.txt"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+				Output: cfg.Output{
+					File: []cfg.FileOutput{
+						{
+							Path:     "out.txt",
+							FileCopy: []cfg.FileCopy{{Path: r.FilecopyArtifactDir}},
+						},
+					},
+				},
+			},
+		},
+	}
+	require.NoError(t, appCfg.ToFile(filepath.Join(r.Dir, ".app.toml")))
+	doInitDb(t)
+
+	eventsPath := filepath.Join(t.TempDir(), "events.ndjson")
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--events=" + eventsPath})
+	_, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, runCmdTest.Execute())
+	require.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+
+	f, err := os.Open(eventsPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+
+	var evTypes []events.Type
+	evs := map[events.Type]json.RawMessage{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var ev struct {
+			Type events.Type     `json:"type"`
+			Task string          `json:"task"`
+			Data json.RawMessage `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &ev), sc.Text())
+		assert.Equal(t, "testapp.build", ev.Task)
+		evTypes = append(evTypes, ev.Type)
+		evs[ev.Type] = ev.Data
+	}
+	require.NoError(t, sc.Err())
+
+	assert.Equal(t,
+		[]events.Type{
+			events.TypeStatusEvaluated,
+			events.TypeTaskStarted,
+			events.TypeTaskFinished,
+			events.TypeOutputCreated,
+			events.TypeUploadStarted,
+			events.TypeUploadFinished,
+			events.TypeRunStored,
+		},
+		evTypes,
+	)
+
+	var finished events.TaskFinished
+	require.NoError(t, json.Unmarshal(evs[events.TypeTaskFinished], &finished))
+	assert.Equal(t, events.ResultSuccess, finished.Result)
+	assert.Equal(t, 1, finished.Attempts)
+
+	var output events.OutputCreated
+	require.NoError(t, json.Unmarshal(evs[events.TypeOutputCreated], &output))
+	assert.Equal(t, uint64(len("hello\n")), output.SizeBytes)
+	assert.NotEmpty(t, output.Digest)
+
+	var uploadStarted events.UploadStarted
+	require.NoError(t, json.Unmarshal(evs[events.TypeUploadStarted], &uploadStarted))
+	var uploadFinished events.UploadFinished
+	require.NoError(t, json.Unmarshal(evs[events.TypeUploadFinished], &uploadFinished))
+	assert.NotEmpty(t, uploadStarted.URL)
+	assert.Equal(t, uploadFinished.URL, uploadStarted.URL)
+
+	var stored events.RunStored
+	require.NoError(t, json.Unmarshal(evs[events.TypeRunStored], &stored))
+	assert.NotZero(t, stored.RunID)
+	assert.Equal(t, "success", stored.Result)
+}
+
+func TestRunWritesReports(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "build",
+				Command: []string{"true"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+			{
+				Name:    "check",
+				Command: []string{"sh", "-c", "echo check-failed-output; exit 3"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+		},
+	}
+	require.NoError(t, appCfg.ToFile(filepath.Join(r.Dir, ".app.toml")))
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"testapp.build"})
+	require.NoError(t, runCmdTest.Execute())
+
+	reportDir := t.TempDir()
+	junitPath := filepath.Join(reportDir, "report.xml")
+	mdPath := filepath.Join(reportDir, "report.md")
+
+	runCmdTest = newRunCmd()
+	runCmdTest.SetArgs([]string{"--report-junit=" + junitPath, "--report-markdown=" + mdPath})
+	_, _ = interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, runCmdTest.Execute())
+	require.Equal(t, exitCodeError, exitCode)
+
+	junit, err := os.ReadFile(junitPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(junit), `<testsuite name="testapp" tests="2" failures="1" skipped="1"`)
+	assert.Contains(t, string(junit), "check-failed-output")
+
+	md, err := os.ReadFile(mdPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(md), "| testapp | 2 | 1 | 1 | 0 | 1 | 0 |")
+	assert.Contains(t, string(md), "### testapp.check")
+}
+
+func TestRunShardsRunEachTaskOnce(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{Name: "testapp"}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		task := cfg.Task{
+			Name:    name,
+			Command: []string{"true"},
+			Input: cfg.Input{
+				Files: []cfg.FileInputs{
+					{Paths: []string{".app.toml"}},
+				},
+			},
+		}
+		if name == "d" {
+			task.Input.TaskInfos = []cfg.TaskInfo{{TaskName: "c", EnvVarName: "C_INFO"}}
+		}
+		appCfg.Tasks = append(appCfg.Tasks, &task)
+	}
+	require.NoError(t, appCfg.ToFile(filepath.Join(r.Dir, ".app.toml")))
+	gittest.CommitFilesToGit(t, r.Dir)
+	doInitDb(t)
+
+	var shardOutputs []string
+	for _, shard := range []string{"1/2", "2/2"} {
+		runCmdTest := newRunCmd()
+		runCmdTest.SetArgs([]string{"--shard=" + shard})
+		stdout, _ := interceptCmdOutput(t)
+		require.NoError(t, runCmdTest.Execute())
+		shardOutputs = append(shardOutputs, stdout.String())
+	}
+
+	assert.Contains(t, shardOutputs[0], "2/4 task(s) assigned to shard 1/2")
+	assert.Contains(t, shardOutputs[1], "2/4 task(s) assigned to shard 2/2")
+
+	// task c and d must be in the same shard, d depends on c
+	for _, out := range shardOutputs {
+		assert.Equal(t,
+			strings.Contains(out, "testapp.c: execution"),
+			strings.Contains(out, "testapp.d: execution"),
+			out,
+		)
+	}
+
+	statusOut := baurCSVStatus(t, nil, "")
+	require.Len(t, statusOut, 4)
+	for _, s := range statusOut {
+		assert.Equal(t, baur.TaskStatusRunExist.String(), s.status, s.taskID)
+	}
+}
+
+func TestRunWaitsForExpiredLeaseOfOtherProcess(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	app := r.CreateSimpleApp(t)
+	doInitDb(t)
+
+	taskID := app.Name + "." + app.Tasks[0].Name
+
+	repo := mustFindRepository()
+	vcsState := mustGetRepoState(repo.Path)
+	task := mustArgToTask(repo, vcsState, taskID)
+	inputs, err := baur.NewInputResolver(vcsState, repo.Path, nil, true).Resolve(ctx, task)
+	require.NoError(t, err)
+	totalDigest, err := inputs.Digest()
+	require.NoError(t, err)
+
+	clt, err := postgres.New(ctx, mustGetPSQLURI(repo.Cfg), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { clt.Close() })
+
+	// the lease of the other process is never released, it expires
+	require.NoError(t, clt.AcquireTaskRunLease(ctx, task.AppName, task.Name, totalDigest.String(), "other", time.Second))
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{taskID})
+	stdout, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, runCmdTest.Execute())
+	require.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+	assert.Contains(t, stdout.String(), "waiting for another baur process that runs the task with the same inputs")
+
+	for _, s := range baurCSVStatus(t, nil, "") {
+		if s.taskID == taskID {
+			assert.Equal(t, baur.TaskStatusRunExist.String(), s.status)
+		}
+	}
+}
+
+func TestRunEnqueueIsProcessedByWorker(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{Name: "testapp"}
+	for _, name := range []string{"a", "b"} {
+		task := cfg.Task{
+			Name:    name,
+			Command: []string{"true"},
+			Input: cfg.Input{
+				Files: []cfg.FileInputs{
+					{Paths: []string{".app.toml"}},
+				},
+			},
+		}
+		if name == "b" {
+			task.Input.TaskInfos = []cfg.TaskInfo{{TaskName: "a", EnvVarName: "A_INFO"}}
+		}
+		appCfg.Tasks = append(appCfg.Tasks, &task)
+	}
+	require.NoError(t, appCfg.ToFile(filepath.Join(r.Dir, ".app.toml")))
+	gittest.CommitFilesToGit(t, r.Dir)
+	doInitDb(t)
+
+	stdout, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--enqueue"})
+
+	runFinished := make(chan struct{})
+	go func() {
+		defer close(runFinished)
+		assert.NoError(t, runCmdTest.Execute())
+	}()
+
+	// task b is only enqueued after the job of task a finished, the
+	// worker is restarted until the run command terminated
+	timeout := time.After(time.Minute)
+	for {
+		workerCmdTest := newWorkerCmd()
+		workerCmdTest.SetArgs([]string{"--exit-when-idle"})
+		require.NoError(t, workerCmdTest.Execute())
+
+		select {
+		case <-runFinished:
+		case <-timeout:
+			t.Fatal("run --enqueue did not terminate")
+		case <-time.After(100 * time.Millisecond):
+			continue
+		}
+
+		break
+	}
+
+	require.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+	assert.Contains(t, stdout.String(), "Enqueuing 2/2 task(s)")
+
+	statusOut := baurCSVStatus(t, nil, "")
+	require.Len(t, statusOut, 2)
+	for _, s := range statusOut {
+		assert.Equal(t, baur.TaskStatusRunExist.String(), s.status, s.taskID)
+	}
+}
+
+func TestRunEnqueueFailsWhenJobsDoNotFinishInTime(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	r.CreateSimpleApp(t)
+	gittest.CommitFilesToGit(t, r.Dir)
+	doInitDb(t)
+
+	_, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--enqueue", "--enqueue-timeout=100ms"})
+	require.NoError(t, runCmdTest.Execute())
+
+	assert.Equal(t, exitCodeError, exitCode)
+	assert.Contains(t, stderr.String(), "did not finish within 100ms")
+}
+
+func TestWorkerFailsJobOfOtherCommit(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	app := r.CreateSimpleApp(t)
+	doInitDb(t)
+
+	repo := mustFindRepository()
+	clt, err := postgres.New(ctx, mustGetPSQLURI(repo.Cfg), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { clt.Close() })
+
+	ids, err := clt.EnqueueTaskRunJobs(ctx, []*storage.TaskRunJob{{
+		ApplicationName:  app.Name,
+		TaskName:         app.Tasks[0].Name,
+		VCSRevision:      "0000000000000000000000000000000000000000",
+		TotalInputDigest: "sha384:123",
+	}})
+	require.NoError(t, err)
+
+	_, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	workerCmdTest := newWorkerCmd()
+	workerCmdTest.SetArgs([]string{"--exit-when-idle"})
+	require.NoError(t, workerCmdTest.Execute())
+	assert.Equal(t, exitCodeError, exitCode)
+	assert.Contains(t, stderr.String(), "HEAD of the git repository is")
+
+	jobs, err := clt.TaskRunJobs(ctx, ids)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, storage.JobStateError, jobs[0].State)
+	assert.NotEmpty(t, jobs[0].Error)
+}
+
+func TestRunPlanDoesNotRunTasks(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	app := r.CreateSimpleApp(t)
+	doInitDb(t)
+
+	runCmdTest := newRunCmd()
+	runCmdTest.SetArgs([]string{"--plan", "--plan-format=json"})
+	stdout, stderr := interceptCmdOutput(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	require.NoError(t, runCmdTest.Execute())
+	require.Equal(t, exitCodeSuccess, exitCode, stderr.String())
+
+	var plan executionPlan
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &plan), stdout.String())
+	require.Len(t, plan.Tasks, len(app.Tasks))
+
+	buildTaskID := app.Name + "." + app.Tasks[0].Name
+	var buildTask *plannedTask
+	for _, pt := range plan.Tasks {
+		assert.NotEmpty(t, pt.TotalInputDigest)
+		if pt.Task == buildTaskID {
+			buildTask = pt
+		}
+	}
+	require.NotNil(t, buildTask, "plan does not contain %s", buildTaskID)
+	require.Len(t, buildTask.Outputs, 1)
+	require.Len(t, buildTask.Outputs[0].Uploads, 1)
+	assert.Equal(t, "filecopy", buildTask.Outputs[0].Uploads[0].Method)
+
+	for _, s := range baurCSVStatus(t, nil, "") {
+		assert.Equal(t, baur.TaskStatusExecutionPending.String(), s.status, s.taskID)
+	}
+}
@@ -23,6 +23,12 @@ If the name or path of an application directory is passed,
 application information are shown.
 If a numeric task run ID is passed, information about the
 recorded task run are shown.
+If additionally --log is passed, the recorded output of the
+task run is printed. It contains the output of all attempts to
+run the command. Outputs larger than 1MiB are uploaded to the
+destination configured in the TaskLog section of the repository
+configuration, if one is configured. For those only the URL is
+printed.
 `
 
 const showExamples = `
@@ -30,6 +36,7 @@ baur show calc		show information about the calc application
 baur show calc.build	show information about the build task of the calc application
 baur show ui/shop	show information about the app in the ui/shop directory
 baur show 512		show information about build 512
+baur show --log 512	show the output of the command of build 512
 `
 
 func init() {
@@ -38,6 +45,8 @@ func init() {
 
 type showCmd struct {
 	cobra.Command
+
+	log bool
 }
 
 func newShowCmd() *showCmd {
@@ -54,6 +63,9 @@ func newShowCmd() *showCmd {
 
 	cmd.Run = cmd.run
 
+	cmd.Flags().BoolVar(&cmd.log, "log", false,
+		"print the recorded stdout and stderr output of a task run")
+
 	return &cmd
 }
 
@@ -62,10 +74,21 @@ func (c *showCmd) run(_ *cobra.Command, args []string) {
 
 	buildID, err := strconv.Atoi(arg)
 	if err == nil {
+		if c.log {
+			c.showBuildLog(buildID)
+			return
+		}
+
 		c.showBuild(buildID)
 		return
 	}
 
+	if c.log {
+		stderr.Printf("--log can only be passed with a task run ID argument\n")
+		exitFunc(exitCodeError)
+		return
+	}
+
 	if isDir, _ := fs.IsDir(arg); isDir {
 		c.showApp(arg)
 		return
@@ -376,6 +399,9 @@ func (*showCmd) showBuild(taskRunID int) {
 		mustWriteRow(formatter, "Result", term.RedHighlight(taskRun.Result))
 	}
 
+	mustWriteRow(formatter, "Exit Code:", term.Highlight(taskRun.ExitCode))
+	mustWriteRow(formatter, "Attempts:", term.Highlight(taskRun.Attempts))
+
 	mustWriteRow(formatter, "Started At:", term.Highlight(taskRun.StartTimestamp))
 	mustWriteRow(
 		formatter,
@@ -443,3 +469,30 @@ func (*showCmd) showBuild(taskRunID int) {
 	err = formatter.Flush()
 	exitOnErr(err)
 }
+
+func (*showCmd) showBuildLog(taskRunID int) {
+	repo := mustFindRepository()
+	storageClt := mustNewCompatibleStorageRepo(repo)
+	defer storageClt.Close()
+
+	log, err := storageClt.TaskRunLog(ctx, taskRunID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			stderr.Printf("no log for task run with id %d exists\n", taskRunID)
+			exitFunc(exitCodeNotExist)
+			return
+		}
+
+		stderr.Println(err)
+		exitFunc(exitCodeError)
+		return
+	}
+
+	if log.URL != "" {
+		stdout.Printf("the log of task run with id %d was uploaded to %s\n", taskRunID, log.URL)
+		return
+	}
+
+	_, err = stdout.Write(log.Content)
+	exitOnErr(err)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/simplesurance/baur/v5/internal/testutils/repotest"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
 )
 
 // TestShowArgs verifies that the show command works with all supported
@@ -72,3 +73,39 @@ func TestShowWithRepositoryArg(t *testing.T) {
 	repositoryPath = r.Dir
 	require.NoError(t, rootCmd.Execute())
 }
+
+func TestShowLogPrintsTaskRunOutput(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+
+	appCfg := cfg.App{
+		Name: "testapp",
+		Tasks: cfg.Tasks{
+			{
+				Name:    "check",
+				Command: []string{"sh", "-c", "echo checking; echo check failed >&2; exit 1"},
+				Input: cfg.Input{
+					Files: []cfg.FileInputs{
+						{Paths: []string{".app.toml"}},
+					},
+				},
+			},
+		},
+	}
+	require.NoError(t, appCfg.ToFile(filepath.Join(r.Dir, ".app.toml")))
+
+	runInitDb(t)
+
+	var exitCode int
+	interceptExitCode(t, &exitCode)
+
+	runCmd := newRunCmd()
+	runCmd.Run(&runCmd.Command, nil)
+	require.Equal(t, exitCodeError, exitCode)
+
+	showCmd := newShowCmd()
+	showCmd.SetArgs([]string{"--log", "1"})
+	stdoutBuf, _ := interceptCmdOutput(t)
+	require.NoError(t, showCmd.Execute())
+	require.Equal(t, "checking\ncheck failed\n", stdoutBuf.String())
+}
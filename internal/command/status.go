@@ -30,8 +30,19 @@ const (
 var statusLongHelp = fmt.Sprintf(
 	`List the task status in the repository.
 
+When %s is passed, only tasks are listed that are affected by files
+that were changed since the merge-base of REF and HEAD, including uncommitted
+changes and untracked files in the worktree. A task is affected
+when one of its input files or config files changed or a task that it
+references as TaskInfo input is affected. Input digests are not calculated to
+determine the affected tasks.
+Combined with %s, it lists the affected tasks without
+querying the database.
+
 Arguments:
 %s`,
+	term.Highlight("--"+flagNameChangedSince+"=REF"),
+	term.Highlight("--fields=task-id --quiet"),
 	targetHelp)
 
 func init() {
@@ -48,6 +59,7 @@ type statusCmd struct {
 	buildStatus             flag.TaskStatus
 	fields                  *flag.Fields
 	requireCleanGitWorktree bool
+	changedSince            string
 	format                  *flag.OneOf
 }
 
@@ -106,6 +118,10 @@ func newStatusCmd() *statusCmd {
 	cmd.Flags().BoolVarP(&cmd.requireCleanGitWorktree, flagNameRequireCleanGitWorktree, "c", false,
 		"fail if the git repository contains modified or untracked files")
 
+	cmd.Flags().StringVar(&cmd.changedSince, flagNameChangedSince, "",
+		"only show tasks affected by files changed since the merge-base of REF and HEAD,\n"+
+			"including uncommitted changes")
+
 	return &cmd
 }
 
@@ -183,6 +199,17 @@ func (c *statusCmd) run(_ *cobra.Command, args []string) {
 	tasks, err := loader.LoadTasks(args...)
 	exitOnErr(err)
 
+	inputResolver := newInputResolver(
+		vcsState,
+		repo.Path,
+		baur.AsInputStrings(c.inputStr...),
+		!c.requireCleanGitWorktree,
+	)
+
+	if c.changedSince != "" {
+		tasks = mustFilterAffectedTasks(repo.Path, inputResolver, tasks, c.changedSince)
+	}
+
 	storageQueryNeeded := c.storageQueryIsNeeded()
 
 	if storageQueryNeeded {
@@ -198,12 +225,7 @@ func (c *statusCmd) run(_ *cobra.Command, args []string) {
 	statusMgr := baur.NewTaskStatusEvaluator(
 		repo.Path,
 		storageClt,
-		baur.NewInputResolver(
-			mustGetRepoState(repo.Path),
-			repo.Path,
-			baur.AsInputStrings(c.inputStr...),
-			!c.requireCleanGitWorktree,
-		),
+		inputResolver,
 		c.lookupInputStr,
 	)
 
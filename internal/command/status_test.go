@@ -5,6 +5,7 @@ package command
 
 import (
 	"encoding/json"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
@@ -14,10 +15,13 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/simplesurance/baur/v5/internal/daemon"
 	"github.com/simplesurance/baur/v5/internal/testutils/dbtest"
 	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
 	"github.com/simplesurance/baur/v5/internal/testutils/gittest"
 	"github.com/simplesurance/baur/v5/internal/testutils/repotest"
+	"github.com/simplesurance/baur/v5/internal/vcs/git"
+	"github.com/simplesurance/baur/v5/pkg/baur"
 )
 
 func TestStatusTaskSpecArgParsing(t *testing.T) {
@@ -283,3 +287,82 @@ func TestStatusJson(t *testing.T) {
 		}}, res)
 	})
 }
+
+func TestStatusChangedSinceListsOnlyAffectedTasks(t *testing.T) {
+	initTest(t)
+
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	gittest.CreateRepository(t, r.Dir)
+	r.CreateAppWithNoOutputs(t, "app1")
+	r.CreateAppWithNoOutputs(t, "app2")
+	gittest.CommitFilesToGit(t, r.Dir)
+
+	baseCommit, err := git.CommitID(r.Dir)
+	require.NoError(t, err)
+
+	fstest.WriteToFile(t, []byte("changed"), filepath.Join(r.Dir, "app2", "app2.txt"))
+	gittest.CommitFilesToGit(t, r.Dir)
+
+	runInitDb(t)
+
+	stdoutBuf, _ := interceptCmdOutput(t)
+	statusCmd := newStatusCmd()
+	statusCmd.SetArgs([]string{"--" + flagNameChangedSince, baseCommit, "-q", "-f", "task-id"})
+	require.NoError(t, statusCmd.Execute())
+
+	assert.Equal(t, "app2.build\napp2.test\n", stdoutBuf.String())
+}
+
+func TestStatusChangedSinceFailsOnInvalidRef(t *testing.T) {
+	initTest(t)
+
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	gittest.CreateRepository(t, r.Dir)
+	r.CreateAppWithNoOutputs(t, "app1")
+	gittest.CommitFilesToGit(t, r.Dir)
+
+	_, stderrBuf := interceptCmdOutput(t)
+	statusCmd := newStatusCmd()
+	statusCmd.SetArgs([]string{"--" + flagNameChangedSince, "doesnotexist"})
+	require.Panics(t, func() { require.NoError(t, statusCmd.Execute()) })
+
+	assert.Contains(t, stderrBuf.String(), flagNameChangedSince)
+}
+
+func TestStatusResolvesInputsViaDaemon(t *testing.T) {
+	initTest(t)
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	app := r.CreateSimpleApp(t)
+	runInitDb(t)
+	gittest.CommitFilesToGit(t, ".")
+
+	runCmd := newRunCmd()
+	runCmd.Run(&runCmd.Command, nil)
+
+	repo := mustFindRepository()
+	srv, err := daemon.NewServer(t.Context(), repo.Path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	ln, err := net.Listen("unix", daemon.SocketPath(repo.Path))
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(t.Context(), ln) }()
+
+	require.NoError(t, daemon.NewClient(daemon.SocketPath(repo.Path), repo.Path, true).Ping(t.Context()))
+
+	statusOut := baurCSVStatus(t, nil, "")
+	assertStatusTasks(t, r, statusOut, baur.TaskStatusRunExist, "")
+
+	fstest.WriteToFile(t, []byte("2"), filepath.Join(r.Dir, app.Name, "output_content.txt"))
+
+	statusOut = baurCSVStatus(t, nil, "")
+	for _, s := range statusOut {
+		if s.taskID == app.Name+".build" {
+			assert.Equal(t, baur.TaskStatusExecutionPending.String(), s.status)
+			continue
+		}
+		assert.Equal(t, baur.TaskStatusRunExist.String(), s.status)
+	}
+}
@@ -0,0 +1,435 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur/v5/internal/command/term"
+	"github.com/simplesurance/baur/v5/internal/exec"
+	"github.com/simplesurance/baur/v5/internal/fswatch"
+	"github.com/simplesurance/baur/v5/internal/log"
+	"github.com/simplesurance/baur/v5/internal/output/docker"
+	"github.com/simplesurance/baur/v5/internal/output/filecopy"
+	"github.com/simplesurance/baur/v5/internal/output/s3"
+	"github.com/simplesurance/baur/v5/internal/set"
+	"github.com/simplesurance/baur/v5/internal/vcs/git"
+	"github.com/simplesurance/baur/v5/pkg/baur"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+const watchExample = `
+baur watch calc			run the pending tasks of the calc application whenever their inputs change
+baur watch --upload calc.build	run the build task of calc whenever its inputs change, upload the outputs and record the runs
+`
+
+var watchLongHelp = fmt.Sprintf(`
+Run tasks whenever their inputs change.
+
+The status of the tasks is evaluated and the tasks with status %s are
+run. Afterwards the directories containing the input files and the
+configuration files of the tasks are watched for changes. Directories in that
+created files would match the file input patterns of the tasks are watched too.
+When files change, baur waits until no further changes happen for the
+duration passed via %s. The status of the tasks is then evaluated again
+and the pending tasks whose inputs changed since they were run last are run.
+Tasks that reference other pending tasks as TaskInfo inputs are run after them.
+
+By default, outputs are not uploaded and task runs are not recorded. When
+%s is passed, outputs are uploaded and runs recorded as done by %s.
+
+Watching files is only supported on linux.
+
+Arguments:
+%s
+
+The following Environment Variables are supported:
+    %s
+
+  S3 Upload:
+    %s
+    %s
+    %s
+
+  Docker Registry Upload:
+    %s
+    %s
+    %s
+    %s
+`,
+	term.ColoredTaskStatus(baur.TaskStatusExecutionPending),
+	term.Highlight("--debounce"),
+	term.Highlight("--upload"),
+	term.Highlight("baur run"),
+	targetHelp,
+
+	term.Highlight(envVarPSQLURL),
+
+	term.Highlight("AWS_REGION"),
+	term.Highlight("AWS_ACCESS_KEY_ID"),
+	term.Highlight("AWS_SECRET_ACCESS_KEY"),
+
+	term.Highlight("DOCKER_HOST"),
+	term.Highlight("DOCKER_API_VERSION"),
+	term.Highlight("DOCKER_CERT_PATH"),
+	term.Highlight("DOCKER_TLS_VERIFY"))
+
+func init() {
+	rootCmd.AddCommand(&newWatchCmd().Command)
+}
+
+type watchCmd struct {
+	cobra.Command
+
+	// Cmdline parameters
+	debounce   time.Duration
+	upload     bool
+	showOutput bool
+
+	// other fields
+	storage      storage.Storer
+	dockerClient *docker.Client
+	uploader     *baur.Uploader
+
+	// lastRunDigests contains per task ID the total input digest of the
+	// last run of the task.
+	lastRunDigests map[string]string
+}
+
+func newWatchCmd() *watchCmd {
+	cmd := watchCmd{
+		Command: cobra.Command{
+			Use:               "watch [TARGET|APP_DIR]...",
+			Short:             "run tasks whenever their inputs change",
+			Long:              strings.TrimSpace(watchLongHelp),
+			Example:           strings.TrimSpace(watchExample),
+			ValidArgsFunction: newCompleteTargetFunc(completeTargetFuncOpts{}),
+		},
+		lastRunDigests: map[string]string{},
+	}
+
+	cmd.Run = cmd.run
+
+	cmd.Flags().DurationVar(&cmd.debounce, "debounce", 500*time.Millisecond,
+		"duration to wait for further changes before the tasks are evaluated")
+	cmd.Flags().BoolVar(&cmd.upload, "upload", false,
+		"upload task outputs and record the runs")
+	cmd.Flags().BoolVarP(&cmd.showOutput, "show-task-output", "o", false,
+		"show the output of tasks, if disabled the output is only shown "+
+			"when task execution fails",
+	)
+
+	return &cmd
+}
+
+func (c *watchCmd) run(_ *cobra.Command, args []string) {
+	if !fswatch.Supported() {
+		stderr.Printf("watching files is not supported on %s\n", runtime.GOOS)
+		exitFunc(exitCodeError)
+	}
+
+	watcher, err := fswatch.New()
+	exitOnErr(err)
+	defer watcher.Close()
+
+	repo := mustFindRepository()
+
+	c.storage = mustNewCompatibleStorageRepo(repo)
+	defer c.storage.Close()
+
+	c.dockerClient, err = docker.NewClient(log.StdLogger.Debugf)
+	exitOnErr(err)
+
+	if c.upload {
+		s3Client, err := s3.NewClient(ctx, log.StdLogger)
+		exitOnErr(err)
+		c.uploader = baur.NewUploader(c.dockerClient, s3Client, filecopy.New(log.Debugf))
+	} else {
+		stdout.Printf("outputs won't be uploaded and task runs not recorded, pass --upload to enable it\n\n")
+	}
+
+	watchCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dirs, err := c.runChangedTasks(watchCtx, args)
+	exitOnErr(err)
+
+	for {
+		exitOnErr(watcher.Watch(dirs))
+		stdout.Printf("\nwatching %d directories for changes\n", len(dirs))
+
+		changed, err := watcher.Wait(watchCtx, c.debounce)
+		if err != nil {
+			if watchCtx.Err() != nil {
+				return
+			}
+			exitOnErr(err)
+		}
+
+		stdout.PrintSep()
+		stdout.Printf("%d file(s) changed\n", len(changed))
+		log.Debugf("changed files: %s\n", strings.Join(changed, ", "))
+
+		newDirs, err := c.runChangedTasks(watchCtx, args)
+		if err != nil {
+			if watchCtx.Err() != nil {
+				return
+			}
+
+			// the configuration can be invalid while it is edited,
+			// the previous directories are watched further to
+			// notice when it was fixed
+			stderr.ErrPrintln(err)
+			continue
+		}
+
+		dirs = newDirs
+		saveFileDigestCaches()
+	}
+}
+
+// runChangedTasks loads the tasks, evaluates their status and runs the
+// pending ones whose inputs changed since they were run last.
+// It returns the directories that have to be watched to notice changes of
+// the inputs of the tasks.
+func (c *watchCmd) runChangedTasks(watchCtx context.Context, args []string) ([]string, error) {
+	// the repository and the resolvers are created for every evaluation,
+	// they cache the configuration and resolved inputs
+	repo, err := findRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	gitRepo, err := git.NewRepositoryWithCheck(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	loader, err := baur.NewLoader(repo.Cfg, gitRepo.CommitID, log.StdLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := loader.LoadTasks(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	baur.SortTasksByID(tasks)
+
+	statusEvaluator := baur.NewTaskStatusEvaluator(
+		repo.Path,
+		c.storage,
+		newInputResolver(gitRepo, repo.Path, nil, true),
+		"",
+	)
+
+	watchDirs := set.Set[string]{}
+	watchDirs.Add(filepath.Dir(repo.CfgPath))
+
+	var changedTasks []*baur.Task
+	changedTaskInputs := map[string]*baur.Inputs{}
+
+	for _, task := range tasks {
+		status, inputs, _, statusErr := statusEvaluator.Status(watchCtx, task)
+		if statusErr != nil {
+			// e.g. an input pattern matches no files while files
+			// are moved, the task is evaluated again on the next
+			// change
+			stderr.ErrPrintf(statusErr, "%s: evaluating task status failed", task)
+			inputs = nil
+		}
+
+		dirs, err := baur.InputDirectories(task, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: determining input directories failed: %w", task, err)
+		}
+		for _, dir := range dirs {
+			watchDirs.Add(dir)
+		}
+
+		if statusErr != nil || status != baur.TaskStatusExecutionPending {
+			continue
+		}
+
+		totalDigest, err := inputs.Digest()
+		if err != nil {
+			return nil, fmt.Errorf("%s: calculating total input digest failed: %w", task, err)
+		}
+
+		if c.lastRunDigests[task.ID] == totalDigest.String() {
+			continue
+		}
+
+		changedTasks = append(changedTasks, task)
+		changedTaskInputs[task.ID] = inputs
+	}
+
+	c.runTasks(watchCtx, repo, gitRepo, statusEvaluator, changedTasks, changedTaskInputs)
+
+	result := watchDirs.Slice()
+	slices.Sort(result)
+
+	return result, nil
+}
+
+// runTasks runs the tasks one after another, in the order of their TaskInfo
+// dependencies.
+func (c *watchCmd) runTasks(
+	watchCtx context.Context,
+	repo *baur.Repository,
+	gitRepo *git.Repository,
+	statusEvaluator *baur.TaskStatusEvaluator,
+	tasks []*baur.Task,
+	inputs map[string]*baur.Inputs,
+) {
+	if len(tasks) == 0 {
+		stdout.Printf("no task with status %s has changed inputs\n",
+			term.ColoredTaskStatus(baur.TaskStatusExecutionPending))
+		return
+	}
+
+	scheduler, err := baur.NewTaskScheduler(tasks)
+	if err != nil {
+		stderr.ErrPrintln(err)
+		return
+	}
+
+	taskRunner := baur.NewTaskRunner(baur.NewTaskInfoCreator(c.storage, statusEvaluator), false)
+	if c.showOutput && !verboseFlag {
+		taskRunner.LogFn = stderr.Printf
+	}
+	taskRunner.RetryFn = printTaskRetry
+
+	stdout.Printf("Running %d task(s) with status %s and changed inputs\n\n",
+		len(tasks), term.ColoredTaskStatus(baur.TaskStatusExecutionPending))
+
+	queue := scheduler.Start()
+	for len(queue) > 0 && watchCtx.Err() == nil {
+		task := queue[0]
+		queue = queue[1:]
+
+		if c.runTask(watchCtx, taskRunner, repo, gitRepo, task, inputs[task.ID]) {
+			queue = append(queue, scheduler.Completed(task)...)
+			continue
+		}
+
+		for _, skipped := range scheduler.Failed(task) {
+			stderr.Printf("%s: execution %s, TaskInfo dependency %s %s\n",
+				term.Highlight(skipped),
+				statusStrSkipped,
+				term.Highlight(task),
+				statusStrFailed,
+			)
+		}
+	}
+}
+
+// runTask runs the task and, if --upload was passed, uploads its outputs and
+// records the run. The result is printed, true is returned if it was
+// successful.
+func (c *watchCmd) runTask(
+	watchCtx context.Context,
+	taskRunner *baur.TaskRunner,
+	repo *baur.Repository,
+	gitRepo *git.Repository,
+	task *baur.Task,
+	inputs *baur.Inputs,
+) bool {
+	totalDigest, err := inputs.Digest()
+	if err != nil {
+		stderr.ErrPrintln(err, task.ID)
+		return false
+	}
+	// the task is only run again when its inputs changed, also if it
+	// failed
+	c.lastRunDigests[task.ID] = totalDigest.String()
+
+	result, err := taskRunner.Run(watchCtx, task)
+	if err == nil {
+		defer deleteTaskLog(task, result)
+		err = result.ExpectSuccess()
+	}
+	if err != nil {
+		var ee *exec.ExitCodeError
+		if !errors.As(err, &ee) {
+			stderr.Printf("%s: executing command %s: %s\n", term.Highlight(task), statusStrFailed, err)
+			return false
+		}
+
+		stderr.Printf("%s: %s\n",
+			term.Highlight(task),
+			ee.ColoredError(term.Highlight, term.RedHighlight, !c.showOutput && !verboseFlag),
+		)
+
+		if c.upload {
+			c.storeRun(watchCtx, repo, gitRepo, task, inputs, result, nil)
+		}
+
+		return false
+	}
+
+	stdout.TaskPrintf(task, "execution %s (%s)\n",
+		statusStrSuccess,
+		term.FormatDuration(result.StopTime.Sub(result.StartTime)),
+	)
+
+	outputs, err := baur.OutputsFromTask(c.dockerClient, task)
+	if err != nil {
+		stderr.ErrPrintln(err, task.ID)
+		return false
+	}
+	defer removeOutputArchives(task, outputs)
+
+	if !declaredOutputsExist(task, outputs) {
+		// error is printed in declaredOutputsExist()
+		return false
+	}
+
+	if !c.upload {
+		return true
+	}
+
+	uploadResults, err := uploadOutputs(watchCtx, c.uploader, task, outputs, nil)
+	if err != nil {
+		// error is printed in uploadOutputs()
+		return false
+	}
+
+	return c.storeRun(watchCtx, repo, gitRepo, task, inputs, result, uploadResults)
+}
+
+// storeRun uploads the log of the run if it is too large to be stored in the
+// database, records the run in the database and prints the result.
+func (c *watchCmd) storeRun(
+	watchCtx context.Context,
+	repo *baur.Repository,
+	gitRepo *git.Repository,
+	task *baur.Task,
+	inputs *baur.Inputs,
+	result *baur.RunResult,
+	uploadResults []*baur.UploadResult,
+) bool {
+	uploadTaskLog(watchCtx, c.uploader, &repo.Cfg.TaskLog, task, result)
+
+	id, err := baur.StoreRun(ctx, c.storage, gitRepo, task, inputs, result, uploadResults)
+	if err != nil {
+		stderr.Printf("%s: recording run in database %s, %s\n",
+			term.Highlight(task), statusStrFailed, err)
+		return false
+	}
+
+	stdout.TaskPrintf(task, "run stored in database with ID %s\n", term.Highlight(id))
+
+	return true
+}
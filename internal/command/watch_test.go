@@ -0,0 +1,48 @@
+//go:build dbtest
+// +build dbtest
+
+package command
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
+	"github.com/simplesurance/baur/v5/internal/testutils/repotest"
+)
+
+func TestWatchRunsOnlyTasksWithChangedInputs(t *testing.T) {
+	initTest(t)
+	r := repotest.CreateBaurRepository(t, repotest.WithNewDB())
+	app := r.CreateSimpleApp(t)
+	doInitDb(t)
+
+	stdout, stderr := interceptCmdOutput(t)
+
+	watchCmdTest := newWatchCmd()
+	watchCmdTest.storage = mustNewCompatibleStorageRepo(mustFindRepository())
+	t.Cleanup(func() { watchCmdTest.storage.Close() })
+
+	dirs, err := watchCmdTest.runChangedTasks(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, stderr.String())
+	assert.Contains(t, dirs, filepath.Join(r.Dir, app.Name))
+	assert.Contains(t, stdout.String(), "Running 2 task(s)")
+
+	stdout.Reset()
+	_, err = watchCmdTest.runChangedTasks(ctx, nil)
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "no task with status")
+
+	fstest.WriteToFile(t, []byte("2"), filepath.Join(r.Dir, app.Name, "output_content.txt"))
+
+	stdout.Reset()
+	_, err = watchCmdTest.runChangedTasks(ctx, nil)
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), "Running 1 task(s)")
+	assert.Contains(t, stdout.String(), "simpleApp.build")
+	assert.NotContains(t, stdout.String(), "simpleApp.check")
+}
@@ -0,0 +1,449 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/simplesurance/baur/v5/internal/command/term"
+	"github.com/simplesurance/baur/v5/internal/exec"
+	"github.com/simplesurance/baur/v5/internal/log"
+	"github.com/simplesurance/baur/v5/internal/output/docker"
+	"github.com/simplesurance/baur/v5/internal/output/filecopy"
+	"github.com/simplesurance/baur/v5/internal/output/s3"
+	"github.com/simplesurance/baur/v5/internal/vcs/git"
+	"github.com/simplesurance/baur/v5/pkg/baur"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+const workerExample = `
+baur worker			process jobs until the command is terminated
+baur worker --checkout		check out the git commit of each job before processing it
+baur worker --exit-when-idle	process jobs until the queue is empty
+`
+
+var workerLongHelp = fmt.Sprintf(`
+Process task run jobs that were enqueued via %s.
+
+The worker claims queued jobs from the database one at a time. For each job
+it verifies that the git repository is at the commit that the job was
+enqueued for. If %s is passed, the commit is checked out instead, the
+worktree must not contain modified files then.
+The inputs of the task are resolved and must match the inputs of the job.
+If a run with the same inputs was recorded in the meantime, the job succeeds
+without executing the task. Otherwise the task is run, its outputs are
+uploaded and the run is recorded, like with %s.
+
+While a job is processed, the worker renews the lease of the job in the
+database. When a worker terminates unexpectedly, its job is claimed again by
+another worker after the lease expired. The expiration duration is set via
+%s.
+
+Exit Codes:
+  %d - Success
+  %d - Error, with %s: processing a job failed
+  %d - Interrupted
+
+The following Environment Variables are supported:
+    %s
+
+  S3 Upload:
+    %s
+    %s
+    %s
+
+  Docker Registry Upload:
+    %s
+    %s
+    %s
+    %s
+`,
+	term.Highlight("baur run --enqueue"),
+	term.Highlight("--checkout"),
+	term.Highlight("baur run"),
+	term.Highlight("--job-lease-ttl"),
+
+	exitCodeSuccess,
+	exitCodeError,
+	term.Highlight("--exit-when-idle"),
+	exitCodeInterrupted,
+
+	term.Highlight(envVarPSQLURL),
+
+	term.Highlight("AWS_REGION"),
+	term.Highlight("AWS_ACCESS_KEY_ID"),
+	term.Highlight("AWS_SECRET_ACCESS_KEY"),
+
+	term.Highlight("DOCKER_HOST"),
+	term.Highlight("DOCKER_API_VERSION"),
+	term.Highlight("DOCKER_CERT_PATH"),
+	term.Highlight("DOCKER_TLS_VERIFY"))
+
+func init() {
+	rootCmd.AddCommand(&newWorkerCmd().Command)
+}
+
+type workerCmd struct {
+	cobra.Command
+
+	// Cmdline parameters
+	pollInterval time.Duration
+	jobLeaseTTL  time.Duration
+	exitWhenIdle bool
+	checkout     bool
+	showOutput   bool
+
+	// other fields
+	id           string
+	repoRootPath string
+	storage      storage.Storer
+	dockerClient *docker.Client
+	uploader     *baur.Uploader
+}
+
+func newWorkerCmd() *workerCmd {
+	cmd := workerCmd{
+		Command: cobra.Command{
+			Use:     "worker",
+			Short:   "run tasks of jobs enqueued via 'baur run --enqueue'",
+			Long:    strings.TrimSpace(workerLongHelp),
+			Example: strings.TrimSpace(workerExample),
+			Args:    cobra.NoArgs,
+		},
+	}
+
+	cmd.Run = cmd.run
+
+	cmd.Flags().DurationVar(&cmd.pollInterval, "poll-interval", 5*time.Second,
+		"interval in that the queue is checked for new jobs when it is empty")
+	cmd.Flags().DurationVar(&cmd.jobLeaseTTL, "job-lease-ttl", time.Minute,
+		"duration after that a job of a terminated worker can be claimed by another worker")
+	cmd.Flags().BoolVar(&cmd.exitWhenIdle, "exit-when-idle", false,
+		"terminate when the queue contains no jobs")
+	cmd.Flags().BoolVar(&cmd.checkout, "checkout", false,
+		"check out the git commit of a job, instead of failing when HEAD differs")
+	cmd.Flags().BoolVarP(&cmd.showOutput, "show-task-output", "o", false,
+		"show the output of tasks, if disabled the output is only shown "+
+			"when task execution fails",
+	)
+
+	return &cmd
+}
+
+func (c *workerCmd) run(_ *cobra.Command, _ []string) {
+	var err error
+
+	if c.jobLeaseTTL <= 0 {
+		stderr.Printf("--job-lease-ttl must be greater than 0\n")
+		exitFunc(exitCodeError)
+	}
+
+	repo := mustFindRepository()
+	c.repoRootPath = repo.Path
+	c.id = processIdentifier()
+
+	c.storage = mustNewCompatibleStorageRepo(repo)
+	defer c.storage.Close()
+
+	c.dockerClient, err = docker.NewClient(log.StdLogger.Debugf)
+	exitOnErr(err)
+
+	s3Client, err := s3.NewClient(ctx, log.StdLogger)
+	exitOnErr(err)
+	c.uploader = baur.NewUploader(c.dockerClient, s3Client, filecopy.New(log.Debugf))
+
+	execCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	stdout.Printf("worker %s is waiting for jobs\n", term.Highlight(c.id))
+
+	var jobFailed bool
+	for execCtx.Err() == nil {
+		job, err := c.storage.ClaimTaskRunJob(execCtx, c.id, c.jobLeaseTTL)
+		if err != nil {
+			if execCtx.Err() != nil {
+				break
+			}
+
+			if !errors.Is(err, storage.ErrNotExist) {
+				exitOnErr(err, "claiming job failed")
+			}
+
+			if c.exitWhenIdle {
+				break
+			}
+
+			select {
+			case <-execCtx.Done():
+			case <-time.After(c.pollInterval):
+			}
+
+			continue
+		}
+
+		if !c.processJob(execCtx, job) {
+			jobFailed = true
+		}
+	}
+
+	if execCtx.Err() != nil {
+		stderr.Printf("worker was %s\n", term.RedHighlight("interrupted"))
+		exitFunc(exitCodeInterrupted)
+		return
+	}
+
+	if jobFailed {
+		exitFunc(exitCodeError)
+	}
+}
+
+// processJob runs the task of the job and records the result of the job in
+// the database. It returns true if the job was successful.
+func (c *workerCmd) processJob(execCtx context.Context, job *storage.TaskRunJobWithID) bool {
+	taskID := fmt.Sprintf("%s.%s", job.ApplicationName, job.TaskName)
+
+	stdout.PrintSep()
+	stdout.Printf("%s: processing job %s, commit %s\n",
+		term.Highlight(taskID), term.Highlight(job.ID), job.VCSRevision)
+
+	stopRenewal := c.startJobLeaseRenewal(execCtx, job)
+	state, runID, err := c.runJob(execCtx, job)
+	stopRenewal()
+
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+		stderr.Printf("%s: job %d %s, %s\n", term.Highlight(taskID), job.ID, statusStrFailed, err)
+	} else {
+		stdout.Printf("%s: job %d %s\n", term.Highlight(taskID), job.ID, statusStrSuccess)
+	}
+
+	// the result is also recorded when the worker is terminated, to
+	// prevent that the job stays in the running state
+	err = c.storage.FinishTaskRunJob(context.WithoutCancel(execCtx), job.ID, c.id, state, runID, errMsg)
+	if err != nil {
+		stderr.ErrPrintf(err, "%s: recording result of job %d failed", taskID, job.ID)
+		return false
+	}
+
+	return state == storage.JobStateSuccess
+}
+
+// startJobLeaseRenewal renews the lease of the job periodically until the
+// returned function is called.
+func (c *workerCmd) startJobLeaseRenewal(execCtx context.Context, job *storage.TaskRunJobWithID) func() {
+	renewCtx, cancel := context.WithCancel(context.WithoutCancel(execCtx))
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(max(c.jobLeaseTTL/3, time.Millisecond))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := c.storage.RenewTaskRunJob(renewCtx, job.ID, c.id, c.jobLeaseTTL)
+			if err != nil && renewCtx.Err() == nil {
+				stderr.ErrPrintf(err, "%s.%s: renewing lease of job %d failed",
+					job.ApplicationName, job.TaskName, job.ID)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// runJob runs the task of the job, uploads its outputs and records the run.
+// It returns the state that the job is finished with and the ID of the
+// recorded run, if one was recorded.
+func (c *workerCmd) runJob(execCtx context.Context, job *storage.TaskRunJobWithID) (storage.JobState, int, error) {
+	if err := c.ensureCommit(execCtx, job.VCSRevision); err != nil {
+		return storage.JobStateError, 0, err
+	}
+
+	// the repository is loaded for every job, the configuration can
+	// differ between commits
+	repo, err := findRepository()
+	if err != nil {
+		return storage.JobStateError, 0, err
+	}
+
+	gitRepo, err := git.NewRepositoryWithCheck(repo.Path)
+	if err != nil {
+		return storage.JobStateError, 0, err
+	}
+
+	task, err := loadJobTask(repo, gitRepo, job)
+	if err != nil {
+		return storage.JobStateError, 0, err
+	}
+
+	statusEvaluator := baur.NewTaskStatusEvaluator(
+		repo.Path,
+		c.storage,
+		newInputResolver(gitRepo, repo.Path, nil, true),
+		"",
+	)
+
+	status, inputs, run, err := statusEvaluator.Status(execCtx, task)
+	if err != nil {
+		return storage.JobStateError, 0, fmt.Errorf("evaluating task status failed: %w", err)
+	}
+
+	totalDigest, err := inputs.Digest()
+	if err != nil {
+		return storage.JobStateError, 0, fmt.Errorf("calculating total input digest failed: %w", err)
+	}
+
+	if totalDigest.String() != job.TotalInputDigest {
+		return storage.JobStateError, 0, fmt.Errorf(
+			"the total input digest of the task %s differs from the digest of the job %s",
+			totalDigest, job.TotalInputDigest,
+		)
+	}
+
+	if status == baur.TaskStatusRunExist {
+		stdout.TaskPrintf(task, "execution %s, a run with the same inputs was recorded with ID %s\n",
+			statusStrSkipped, term.Highlight(run.ID),
+		)
+		return storage.JobStateSuccess, run.ID, nil
+	}
+
+	taskRunner := baur.NewTaskRunner(baur.NewTaskInfoCreator(c.storage, statusEvaluator), false)
+	if c.showOutput && !verboseFlag {
+		taskRunner.LogFn = stderr.Printf
+	}
+	taskRunner.RetryFn = printTaskRetry
+
+	result, err := taskRunner.Run(execCtx, task)
+	if err != nil {
+		return storage.JobStateError, 0, fmt.Errorf("executing command failed: %w", err)
+	}
+	defer deleteTaskLog(task, result)
+
+	if err := result.ExpectSuccess(); err != nil {
+		var ee *exec.ExitCodeError
+		if errors.As(err, &ee) {
+			stderr.Printf("%s: %s\n",
+				term.Highlight(task),
+				ee.ColoredError(term.Highlight, term.RedHighlight, !c.showOutput && !verboseFlag),
+			)
+			err = errors.New(ee.ColoredError(fmt.Sprint, fmt.Sprint, false))
+		}
+
+		uploadTaskLog(execCtx, c.uploader, &repo.Cfg.TaskLog, task, result)
+
+		id, storeErr := baur.StoreRun(ctx, c.storage, gitRepo, task, inputs, result, nil)
+		if storeErr != nil {
+			return storage.JobStateFailure, 0, fmt.Errorf("%w, recording failed run failed: %w", err, storeErr)
+		}
+
+		stdout.TaskPrintf(task, "failed run stored in database with ID %s\n", term.Highlight(id))
+
+		return storage.JobStateFailure, id, err
+	}
+
+	stdout.TaskPrintf(task, "execution %s (%s)\n",
+		statusStrSuccess,
+		term.FormatDuration(result.StopTime.Sub(result.StartTime)),
+	)
+
+	outputs, err := baur.OutputsFromTask(c.dockerClient, task)
+	if err != nil {
+		return storage.JobStateError, 0, err
+	}
+	defer removeOutputArchives(task, outputs)
+
+	if !declaredOutputsExist(task, outputs) {
+		// error is printed in declaredOutputsExist()
+		return storage.JobStateError, 0, errors.New("declared outputs do not exist")
+	}
+
+	uploadResults, err := uploadOutputs(execCtx, c.uploader, task, outputs, nil)
+	if err != nil {
+		return storage.JobStateError, 0, err
+	}
+
+	uploadTaskLog(execCtx, c.uploader, &repo.Cfg.TaskLog, task, result)
+
+	id, err := baur.StoreRun(ctx, c.storage, gitRepo, task, inputs, result, uploadResults)
+	if err != nil {
+		return storage.JobStateError, 0, fmt.Errorf("recording run in database failed: %w", err)
+	}
+
+	stdout.TaskPrintf(task, "run stored in database with ID %s\n", term.Highlight(id))
+
+	return storage.JobStateSuccess, id, nil
+}
+
+// ensureCommit checks that HEAD of the git repository is commitID. If it
+// differs and --checkout was passed, commitID is checked out.
+func (c *workerCmd) ensureCommit(execCtx context.Context, commitID string) error {
+	head, err := git.CommitID(c.repoRootPath)
+	if err != nil {
+		return err
+	}
+
+	if head == commitID {
+		return nil
+	}
+
+	if !c.checkout {
+		return fmt.Errorf("job was enqueued for commit %s but HEAD of the git repository is %s, pass --checkout to check it out", commitID, head)
+	}
+
+	dirty, err := git.WorktreeIsDirty(c.repoRootPath)
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("checking out commit %s failed, the git repository contains modified or untracked files", commitID)
+	}
+
+	if err := git.Checkout(execCtx, c.repoRootPath, commitID); err != nil {
+		return fmt.Errorf("checking out commit %s failed: %w", commitID, err)
+	}
+
+	stdout.Printf("checked out commit %s\n", term.Highlight(commitID))
+
+	return nil
+}
+
+// loadJobTask loads the task of the job from the repository.
+func loadJobTask(repo *baur.Repository, gitRepo *git.Repository, job *storage.TaskRunJobWithID) (*baur.Task, error) {
+	loader, err := baur.NewLoader(repo.Cfg, gitRepo.CommitID, log.StdLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	taskID := fmt.Sprintf("%s.%s", job.ApplicationName, job.TaskName)
+	tasks, err := loader.LoadTasks(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasks) != 1 {
+		return nil, fmt.Errorf("task %s not found in the repository", taskID)
+	}
+
+	return tasks[0], nil
+}
@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"path/filepath"
+
+	"github.com/simplesurance/baur/v5/internal/resolve/gosource"
+	"github.com/simplesurance/baur/v5/internal/version"
+	"github.com/simplesurance/baur/v5/pkg/baur"
+)
+
+// ErrNotRunning is returned when no server is listening on the socket.
+var ErrNotRunning = errors.New("baur daemon is not running")
+
+// InputsError is returned by Client.ResolveFileInputs when the server failed
+// to resolve the inputs of the task. Other errors mean that the request could
+// not be served.
+type InputsError struct {
+	Msg string
+}
+
+func (e *InputsError) Error() string {
+	return e.Msg
+}
+
+// Client sends requests to a daemon server.
+type Client struct {
+	socketPath            string
+	repositoryDir         string
+	hashGitUntrackedFiles bool
+	goHostEnv             []string
+}
+
+// NewClient returns a client for the server listening on socketPath.
+// hashGitUntrackedFiles has the same meaning as for baur.NewInputResolver.
+// The server resolves GolangSources inputs with the environment of the
+// client.
+func NewClient(socketPath, repositoryDir string, hashGitUntrackedFiles bool) *Client {
+	return &Client{
+		socketPath:            socketPath,
+		repositoryDir:         repositoryDir,
+		hashGitUntrackedFiles: hashGitUntrackedFiles,
+		goHostEnv:             gosource.HostEnv(),
+	}
+}
+
+// Ping returns nil if a server is listening on the socket and serves requests
+// for the repository.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, &request{Method: methodPing})
+	return err
+}
+
+// ResolveFileInputs resolves the file inputs of task via the server.
+// It implements baur.FileInputResolver.
+func (c *Client) ResolveFileInputs(ctx context.Context, task *baur.Task) ([]*baur.InputFile, error) {
+	resp, err := c.do(ctx, &request{
+		Method:                methodResolveFileInputs,
+		HashGitUntrackedFiles: c.hashGitUntrackedFiles,
+		GoHostEnv:             c.goHostEnv,
+		Task:                  newTask(task),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*baur.InputFile, 0, len(resp.Files))
+	for _, f := range resp.Files {
+		if f.ContentDigest == nil {
+			return nil, fmt.Errorf("baur daemon returned no content digest for %q", f.RelPath)
+		}
+
+		result = append(result, baur.NewInputFile(
+			f.AbsPath,
+			f.RelPath,
+			f.OwnerHasExecutablePerm,
+			baur.WithRealpath(f.RelRealPath),
+			baur.WithContentDigest(f.ContentDigest),
+		))
+	}
+
+	return result, nil
+}
+
+// do sends req to the server and returns its response.
+// Connections are only established if the directory of the socket and the
+// server process are owned by the user, otherwise other users could serve
+// forged file digests.
+func (c *Client) do(ctx context.Context, req *request) (*response, error) {
+	if err := checkSocketDir(filepath.Dir(c.socketPath)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %w", ErrNotRunning, err)
+		}
+
+		return nil, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: connecting to %s failed: %w", ErrNotRunning, c.socketPath, err)
+	}
+	defer conn.Close()
+
+	if err := checkPeerUID(conn); err != nil {
+		return nil, err
+	}
+
+	// interrupt reading and writing when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	req.Version = version.CurSemVer.String()
+	req.RepositoryDir = c.repositoryDir
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, errors.Join(fmt.Errorf("sending request to baur daemon failed: %w", err), ctx.Err())
+	}
+
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, errors.Join(fmt.Errorf("reading response from baur daemon failed: %w", err), ctx.Err())
+	}
+
+	if resp.Error != "" {
+		if resp.InputsError {
+			return nil, &InputsError{Msg: resp.Error}
+		}
+
+		return nil, fmt.Errorf("baur daemon: %s", resp.Error)
+	}
+
+	return &resp, nil
+}
+
+func newTask(t *baur.Task) *task {
+	return &task{
+		ID:            t.ID,
+		Directory:     t.Directory,
+		CfgFilepaths:  t.CfgFilepaths,
+		Files:         t.UnresolvedInputs.Files,
+		GolangSources: t.UnresolvedInputs.GolangSources,
+		ExcludedFiles: t.UnresolvedInputs.ExcludedFiles.Paths,
+	}
+}
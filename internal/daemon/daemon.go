@@ -0,0 +1,85 @@
+// Package daemon provides a server that resolves the file inputs of tasks in a
+// long-running process and a client to query it.
+// The server keeps the resolved inputs and file digests cached between
+// requests and discards them when files change.
+//
+// Clients and the server communicate via a unix domain socket. Each
+// connection carries a single JSON encoded request and response.
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/simplesurance/baur/v5/internal/digest"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+const (
+	methodPing              = "ping"
+	methodResolveFileInputs = "resolve_file_inputs"
+)
+
+// SocketPath returns the path of the unix domain socket that the server for
+// the baur repository in repositoryDir listens on.
+// The socket is located in a directory of the user in $XDG_RUNTIME_DIR, if it
+// is unset in the default directory for temporary files. The directory is
+// only accessible by the user, see [Listen].
+func SocketPath(repositoryDir string) string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	sum := sha256.Sum256([]byte(repositoryDir))
+
+	return filepath.Join(
+		dir,
+		"baur-"+strconv.Itoa(os.Getuid()),
+		hex.EncodeToString(sum[:8])+".sock",
+	)
+}
+
+type request struct {
+	// Version is the baur version of the client, requests are only
+	// served when it matches the version of the server
+	Version               string
+	RepositoryDir         string
+	Method                string
+	HashGitUntrackedFiles bool
+	// GoHostEnv are the environment variables of the client that are
+	// passed to the Go query tool when GolangSources inputs are resolved
+	GoHostEnv []string
+	Task      *task
+}
+
+// task contains the information of a baur.Task that are required to resolve
+// its file inputs.
+type task struct {
+	ID            string
+	Directory     string
+	CfgFilepaths  []string
+	Files         []cfg.FileInputs
+	GolangSources []cfg.GolangSources
+	ExcludedFiles []string
+}
+
+type response struct {
+	Error string
+	// InputsError is true when Error describes why resolving the inputs
+	// of the task failed, it is false when the request could not be
+	// served
+	InputsError bool
+	Files       []*inputFile
+}
+
+type inputFile struct {
+	AbsPath                string
+	RelPath                string
+	RelRealPath            string
+	OwnerHasExecutablePerm bool
+	ContentDigest          *digest.Digest
+}
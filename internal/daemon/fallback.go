@@ -0,0 +1,50 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/simplesurance/baur/v5/pkg/baur"
+)
+
+// FallbackResolver resolves file inputs via a Client. When the server can not
+// serve a request, e.g. because it was stopped or runs a different baur
+// version, the file inputs of the task and of all following tasks are
+// resolved by a fallback resolver instead.
+// It implements baur.FileInputResolver.
+type FallbackResolver struct {
+	client     *Client
+	fallback   baur.FileInputResolver
+	onFallback func(error)
+
+	useFallback atomic.Bool
+}
+
+// NewFallbackResolver returns a FallbackResolver that falls back to fallback.
+// onFallback is called once with the error of the failed request when it
+// falls back.
+func NewFallbackResolver(client *Client, fallback baur.FileInputResolver, onFallback func(error)) *FallbackResolver {
+	return &FallbackResolver{
+		client:     client,
+		fallback:   fallback,
+		onFallback: onFallback,
+	}
+}
+
+func (r *FallbackResolver) ResolveFileInputs(ctx context.Context, task *baur.Task) ([]*baur.InputFile, error) {
+	if !r.useFallback.Load() {
+		files, err := r.client.ResolveFileInputs(ctx, task)
+
+		var inputsErr *InputsError
+		if err == nil || errors.As(err, &inputsErr) || ctx.Err() != nil {
+			return files, err
+		}
+
+		if r.useFallback.CompareAndSwap(false, true) {
+			r.onFallback(err)
+		}
+	}
+
+	return r.fallback.ResolveFileInputs(ctx, task)
+}
@@ -0,0 +1,371 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/simplesurance/baur/v5/internal/fswatch"
+	"github.com/simplesurance/baur/v5/internal/log"
+	"github.com/simplesurance/baur/v5/internal/set"
+	"github.com/simplesurance/baur/v5/internal/vcs/git"
+	"github.com/simplesurance/baur/v5/internal/version"
+	"github.com/simplesurance/baur/v5/pkg/baur"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+// Server resolves file inputs of tasks of a baur repository.
+// Resolved inputs, file digests and the object IDs of the files tracked in
+// the git repository are cached. The directories containing the inputs of
+// resolved tasks are watched, when files in them change the cached
+// information about them are discarded. When the git repository changes, all
+// cached information are discarded.
+type Server struct {
+	repositoryDir string
+	gitDir        string
+	watcher       *fswatch.Watcher
+
+	mu          sync.Mutex
+	gitRepo     *gitRepository
+	resolvers   map[resolverKey]*baur.InputResolver
+	watchedDirs set.Set[string]
+}
+
+// resolverKey identifies the InputResolver that serves requests with the
+// same parameters.
+type resolverKey struct {
+	hashGitUntrackedFiles bool
+	// goHostEnv are the NUL-separated GoHostEnv environment variables
+	// of the request
+	goHostEnv string
+}
+
+// inputsError is returned when resolving the inputs of a task failed.
+type inputsError struct {
+	err error
+}
+
+func (e *inputsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *inputsError) Unwrap() error {
+	return e.err
+}
+
+// gitRepository passes calls to a git.Repository, the repository is replaced
+// when files changed to discard its cached information about untracked files.
+type gitRepository struct {
+	repositoryDir string
+	repo          *git.Repository
+}
+
+func (g *gitRepository) WithoutUntracked(paths ...string) ([]string, error) {
+	return g.repo.WithoutUntracked(paths...)
+}
+
+func (g *gitRepository) reset() {
+	g.repo = git.NewRepository(g.repositoryDir)
+}
+
+// NewServer creates a server for the baur repository in repositoryDir.
+// The server must be closed when it is not needed anymore.
+func NewServer(ctx context.Context, repositoryDir string) (*Server, error) {
+	gitDir, err := git.GitDir(ctx, repositoryDir)
+	if err != nil {
+		return nil, fmt.Errorf("determining git directory failed: %w", err)
+	}
+
+	watcher, err := fswatch.New()
+	if err != nil {
+		return nil, err
+	}
+
+	s := Server{
+		repositoryDir: repositoryDir,
+		gitDir:        gitDir,
+		watcher:       watcher,
+		gitRepo:       &gitRepository{repositoryDir: repositoryDir},
+		resolvers:     map[resolverKey]*baur.InputResolver{},
+		// changes in the git directory are watched to notice changes of
+		// the index and HEAD, it also contains the cookie files of
+		// fswatch.Sync
+		watchedDirs: set.From([]string{gitDir}),
+	}
+	s.gitRepo.reset()
+
+	if err := watcher.Watch(s.watchedDirs.Slice()); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Close releases the resources of the server.
+func (s *Server) Close() error {
+	return s.watcher.Close()
+}
+
+// Serve accepts connections on ln and serves their requests until ctx is
+// cancelled. ln is closed when Serve returns.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer stop()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			_ = ln.Close()
+			return fmt.Errorf("accepting connection failed: %w", err)
+		}
+
+		wg.Go(func() {
+			defer conn.Close()
+			s.handleConn(ctx, conn)
+		})
+	}
+}
+
+func (s *Server) handleConn(ctx context.Context, conn net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		log.Debugf("daemon: reading request failed: %s\n", err)
+		return
+	}
+
+	resp, err := s.handleRequest(ctx, &req)
+	if err != nil {
+		log.Debugf("daemon: %s request failed: %s\n", req.Method, err)
+		var inputsErr *inputsError
+		resp = &response{Error: err.Error(), InputsError: errors.As(err, &inputsErr)}
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.Debugf("daemon: sending response failed: %s\n", err)
+	}
+}
+
+func (s *Server) handleRequest(ctx context.Context, req *request) (*response, error) {
+	if v := version.CurSemVer.String(); req.Version != v {
+		return nil, fmt.Errorf("the daemon runs baur %s, the client baur %s, the daemon must be restarted", v, req.Version)
+	}
+
+	if req.RepositoryDir != s.repositoryDir {
+		return nil, fmt.Errorf("the daemon serves requests for the repository %q, not %q", s.repositoryDir, req.RepositoryDir)
+	}
+
+	switch req.Method {
+	case methodPing:
+		return &response{}, nil
+
+	case methodResolveFileInputs:
+		if req.Task == nil {
+			return nil, errors.New("task is missing in request")
+		}
+
+		files, err := s.resolveFileInputs(ctx, req.Task, req.HashGitUntrackedFiles, req.GoHostEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		return &response{Files: files}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported method %q", req.Method)
+	}
+}
+
+func (s *Server) resolveFileInputs(ctx context.Context, t *task, hashGitUntrackedFiles bool, goHostEnv []string) ([]*inputFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.applyChanges(ctx); err != nil {
+		return nil, err
+	}
+
+	task := baur.Task{
+		ID:             t.ID,
+		RepositoryRoot: s.repositoryDir,
+		Directory:      t.Directory,
+		CfgFilepaths:   t.CfgFilepaths,
+		UnresolvedInputs: &cfg.Input{
+			Files:         t.Files,
+			GolangSources: t.GolangSources,
+			ExcludedFiles: cfg.FileExcludeList{Paths: t.ExcludedFiles},
+		},
+	}
+
+	// the directories are watched before the inputs are resolved,
+	// otherwise changes that happen while resolving could be missed
+	dirs, err := baur.InputDirectories(&task, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.watch(dirs); err != nil {
+		return nil, err
+	}
+
+	resolver := s.resolver(hashGitUntrackedFiles, goHostEnv)
+	for {
+		files, err := resolver.ResolveFileInputs(ctx, &task)
+		if err != nil {
+			return nil, &inputsError{err: err}
+		}
+
+		inputs := make([]baur.Input, 0, len(files))
+		for _, f := range files {
+			inputs = append(inputs, f)
+		}
+
+		dirs, err := baur.InputDirectories(&task, baur.NewInputs(inputs))
+		if err != nil {
+			return nil, err
+		}
+
+		newDirs := set.Set[string]{}
+		for _, dir := range dirs {
+			if !s.watchedDirs.Contains(dir) {
+				newDirs.Add(dir)
+			}
+		}
+
+		if len(newDirs) == 0 {
+			result, err := toInputFiles(files)
+			if err != nil {
+				return nil, &inputsError{err: err}
+			}
+
+			return result, nil
+		}
+
+		// e.g. golang source files can be located in directories that
+		// were not watched while they were resolved, their changes
+		// could have been missed
+		if err := s.watch(newDirs.Slice()); err != nil {
+			return nil, err
+		}
+
+		var unwatchedFiles []string
+		for _, f := range files {
+			if newDirs.Contains(filepath.Dir(f.AbsPath())) {
+				unwatchedFiles = append(unwatchedFiles, f.AbsPath())
+			}
+		}
+
+		s.invalidate(unwatchedFiles)
+	}
+}
+
+// applyChanges discards the cached information about files that changed
+// since the previous call.
+func (s *Server) applyChanges(ctx context.Context) error {
+	changed, err := s.watcher.Sync(ctx, s.gitDir)
+	if err != nil {
+		return fmt.Errorf("retrieving changed files failed: %w", err)
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	for _, path := range changed {
+		// an empty path means that changes were lost
+		if path == "" || path == s.gitDir || filepath.Dir(path) == s.gitDir {
+			log.Debugf("daemon: git repository changed, discarding all cached information\n")
+			s.gitRepo.reset()
+			s.resolvers = map[resolverKey]*baur.InputResolver{}
+			return nil
+		}
+
+		// the path could be a deleted watched directory, it is watched
+		// again when it is required
+		s.watchedDirs.Remove(path)
+	}
+
+	log.Debugf("daemon: %d files changed\n", len(changed))
+	s.invalidate(changed)
+
+	return nil
+}
+
+func (s *Server) invalidate(paths []string) {
+	s.gitRepo.reset()
+
+	for _, r := range s.resolvers {
+		r.Invalidate(paths...)
+	}
+}
+
+func (s *Server) watch(dirs []string) error {
+	for _, dir := range dirs {
+		s.watchedDirs.Add(dir)
+	}
+
+	return s.watcher.Watch(s.watchedDirs.Slice())
+}
+
+// resolver returns the InputResolver for requests with the passed
+// parameters. GolangSources inputs are resolved with goHostEnv instead of
+// the environment of the server, to get the same result as the client when
+// it resolves them itself.
+func (s *Server) resolver(hashGitUntrackedFiles bool, goHostEnv []string) *baur.InputResolver {
+	key := resolverKey{
+		hashGitUntrackedFiles: hashGitUntrackedFiles,
+		goHostEnv:             strings.Join(goHostEnv, "\x00"),
+	}
+	if r, exists := s.resolvers[key]; exists {
+		return r
+	}
+
+	if goHostEnv == nil {
+		goHostEnv = []string{}
+	}
+
+	r := baur.NewInputResolver(
+		s.gitRepo, s.repositoryDir, nil, hashGitUntrackedFiles,
+		baur.WithGolangSourcesHostEnv(goHostEnv),
+	)
+	s.resolvers[key] = r
+
+	return r
+}
+
+func toInputFiles(files []*baur.InputFile) ([]*inputFile, error) {
+	result := make([]*inputFile, 0, len(files))
+
+	for _, f := range files {
+		d, err := f.ContentDigest()
+		if err != nil {
+			return nil, fmt.Errorf("%s: calculating digest failed: %w", f.RelPath(), err)
+		}
+
+		result = append(result, &inputFile{
+			AbsPath:                f.AbsPath(),
+			RelPath:                f.RelPath(),
+			RelRealPath:            f.RelRealPath(),
+			OwnerHasExecutablePerm: f.OwnerHasExecutablePerm(),
+			ContentDigest:          d,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,219 @@
+//go:build linux
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/log"
+	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
+	"github.com/simplesurance/baur/v5/internal/testutils/gittest"
+	"github.com/simplesurance/baur/v5/internal/vcs/git"
+	"github.com/simplesurance/baur/v5/pkg/baur"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+func startServer(t *testing.T, repoDir string) string {
+	t.Helper()
+
+	srv, err := NewServer(t.Context(), repoDir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	socketPath := filepath.Join(t.TempDir(), "baur", "baur.sock")
+	ln, err := Listen(socketPath)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Serve(t.Context(), ln) }()
+	t.Cleanup(func() { require.NoError(t, <-errCh) })
+
+	return socketPath
+}
+
+func TestResolvedInputsMatchLocallyResolvedOnes(t *testing.T) {
+	log.RedirectToTestingLog(t)
+
+	repoDir := t.TempDir()
+	gittest.CreateRepository(t, repoDir)
+	fstest.WriteToFile(t, []byte("1"), filepath.Join(repoDir, "a.txt"))
+	gittest.CommitFilesToGit(t, repoDir)
+	fstest.WriteToFile(t, []byte("1"), filepath.Join(repoDir, "untracked.txt"))
+
+	socketPath := startServer(t, repoDir)
+	clt := NewClient(socketPath, repoDir, true)
+	require.NoError(t, clt.Ping(t.Context()))
+
+	task := baur.Task{
+		ID:             "app.build",
+		RepositoryRoot: repoDir,
+		Directory:      repoDir,
+		UnresolvedInputs: &cfg.Input{
+			Files: []cfg.FileInputs{{Paths: []string{"**/*.txt"}}},
+		},
+	}
+
+	assertDigestsEqual := func() {
+		t.Helper()
+
+		localInputs, err := baur.NewInputResolver(git.NewRepository(repoDir), repoDir, nil, true).Resolve(t.Context(), &task)
+		require.NoError(t, err)
+		localDigest, err := localInputs.Digest()
+		require.NoError(t, err)
+
+		daemonInputs, err := baur.NewInputResolver(
+			git.NewRepository(repoDir), repoDir, nil, true, baur.WithFileInputResolver(clt),
+		).Resolve(t.Context(), &task)
+		require.NoError(t, err)
+		daemonDigest, err := daemonInputs.Digest()
+		require.NoError(t, err)
+
+		assert.Equal(t, fmt.Sprint(localInputs.Inputs()), fmt.Sprint(daemonInputs.Inputs()))
+		assert.Equal(t, localDigest.String(), daemonDigest.String())
+	}
+
+	assertDigestsEqual()
+
+	fstest.WriteToFile(t, []byte("2"), filepath.Join(repoDir, "a.txt"))
+	fstest.WriteToFile(t, []byte("2"), filepath.Join(repoDir, "sub", "b.txt"))
+	assertDigestsEqual()
+
+	gittest.CommitFilesToGit(t, repoDir)
+	assertDigestsEqual()
+}
+
+func TestRequestsForOtherRepositoriesFail(t *testing.T) {
+	log.RedirectToTestingLog(t)
+
+	repoDir := t.TempDir()
+	gittest.CreateRepository(t, repoDir)
+
+	socketPath := startServer(t, repoDir)
+
+	err := NewClient(socketPath, t.TempDir(), true).Ping(t.Context())
+	require.ErrorContains(t, err, "the daemon serves requests for the repository")
+}
+
+func TestListenCreatesSocketDirOnlyAccessibleByUser(t *testing.T) {
+	repoDir := t.TempDir()
+	gittest.CreateRepository(t, repoDir)
+
+	socketPath := startServer(t, repoDir)
+
+	fi, err := os.Stat(filepath.Dir(socketPath))
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o700), fi.Mode().Perm())
+}
+
+func TestSocketDirAccessibleByOtherUsersIsNotUsed(t *testing.T) {
+	repoDir := t.TempDir()
+	gittest.CreateRepository(t, repoDir)
+
+	socketPath := startServer(t, repoDir)
+	require.NoError(t, os.Chmod(filepath.Dir(socketPath), 0o755))
+
+	err := NewClient(socketPath, repoDir, true).Ping(t.Context())
+	require.ErrorIs(t, err, ErrUntrusted)
+
+	_, err = Listen(socketPath)
+	require.ErrorIs(t, err, ErrUntrusted)
+}
+
+func TestPingFailsWhenNoServerIsRunning(t *testing.T) {
+	err := NewClient(filepath.Join(t.TempDir(), "baur", "baur.sock"), t.TempDir(), true).Ping(t.Context())
+	require.ErrorIs(t, err, ErrNotRunning)
+}
+
+func TestGolangSourcesAreResolvedWithClientEnv(t *testing.T) {
+	log.RedirectToTestingLog(t)
+
+	repoDir := t.TempDir()
+	gittest.CreateRepository(t, repoDir)
+	fstest.WriteToFile(t, []byte("module example.com/app\n"), filepath.Join(repoDir, "go.mod"))
+	fstest.WriteToFile(t, []byte("package main\n\nfunc main() {}\n"), filepath.Join(repoDir, "main.go"))
+	gittest.CommitFilesToGit(t, repoDir)
+
+	socketPath := startServer(t, repoDir)
+
+	task := baur.Task{
+		ID:             "app.build",
+		RepositoryRoot: repoDir,
+		Directory:      repoDir,
+		UnresolvedInputs: &cfg.Input{
+			GolangSources: []cfg.GolangSources{{Queries: []string{"."}}},
+		},
+	}
+
+	files, err := NewClient(socketPath, repoDir, true).ResolveFileInputs(t.Context(), &task)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	// the go tool can not be found via the PATH of the client
+	clt := NewClient(socketPath, repoDir, true)
+	clt.goHostEnv = []string{"PATH=" + t.TempDir()}
+	_, err = clt.ResolveFileInputs(t.Context(), &task)
+	var inputsErr *InputsError
+	require.ErrorAs(t, err, &inputsErr)
+}
+
+type fileInputResolverMock struct {
+	calls int
+}
+
+func (r *fileInputResolverMock) ResolveFileInputs(context.Context, *baur.Task) ([]*baur.InputFile, error) {
+	r.calls++
+	return nil, nil
+}
+
+func TestFallbackResolverResolvesInProcessWhenServerFails(t *testing.T) {
+	clt := NewClient(filepath.Join(t.TempDir(), "baur", "baur.sock"), t.TempDir(), true)
+	fallback := fileInputResolverMock{}
+
+	var fallbackErrs []error
+	r := NewFallbackResolver(clt, &fallback, func(err error) { fallbackErrs = append(fallbackErrs, err) })
+
+	task := baur.Task{ID: "app.build", UnresolvedInputs: &cfg.Input{}}
+	for range 2 {
+		_, err := r.ResolveFileInputs(t.Context(), &task)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, fallback.calls)
+	require.Len(t, fallbackErrs, 1)
+	assert.ErrorIs(t, fallbackErrs[0], ErrNotRunning)
+}
+
+func TestFallbackResolverReturnsInputsErrors(t *testing.T) {
+	log.RedirectToTestingLog(t)
+
+	repoDir := t.TempDir()
+	gittest.CreateRepository(t, repoDir)
+
+	socketPath := startServer(t, repoDir)
+	fallback := fileInputResolverMock{}
+	r := NewFallbackResolver(NewClient(socketPath, repoDir, true), &fallback, func(err error) {
+		t.Errorf("unexpected fallback: %s", err)
+	})
+
+	task := baur.Task{
+		ID:             "app.build",
+		RepositoryRoot: repoDir,
+		Directory:      repoDir,
+		UnresolvedInputs: &cfg.Input{
+			Files: []cfg.FileInputs{{Paths: []string{"missing.txt"}}},
+		},
+	}
+
+	_, err := r.ResolveFileInputs(t.Context(), &task)
+	var inputsErr *InputsError
+	require.ErrorAs(t, err, &inputsErr)
+	assert.Zero(t, fallback.calls)
+}
@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// ErrUntrusted is returned when the directory of the socket or the process
+// listening on it is not owned by the user.
+var ErrUntrusted = errors.New("baur daemon socket is not trusted")
+
+// Listen listens on the unix domain socket socketPath.
+// The directory of the socket is created, only accessible by the user. If it
+// exists but is not owned by the user or accessible by other users, an error
+// wrapping ErrUntrusted is returned.
+// The socket file of a server that did not terminate cleanly is removed.
+func Listen(socketPath string) (net.Listener, error) {
+	dir := filepath.Dir(socketPath)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating socket directory failed: %w", err)
+	}
+
+	if err := checkSocketDir(dir); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("removing stale socket file failed: %w", err)
+	}
+
+	return net.Listen("unix", socketPath)
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkSocketDir returns an error wrapping ErrUntrusted if dir is not a
+// directory that is owned by the user and only accessible by it.
+func checkSocketDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrUntrusted, dir)
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%w: retrieving the owner of %s failed", ErrUntrusted, dir)
+	}
+
+	if int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%w: %s is owned by uid %d, expected uid %d", ErrUntrusted, dir, st.Uid, os.Getuid())
+	}
+
+	if fi.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%w: %s is accessible by other users, permissions: %s", ErrUntrusted, dir, fi.Mode().Perm())
+	}
+
+	return nil
+}
+
+// checkPeerUID returns an error wrapping ErrUntrusted if the process on the
+// other end of conn does not run as the user.
+func checkPeerUID(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("%w: %T is not a unix domain socket connection", ErrUntrusted, conn)
+	}
+
+	rc, err := uc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+
+	err = rc.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err := errors.Join(err, credErr); err != nil {
+		return fmt.Errorf("retrieving the credentials of the baur daemon process failed: %w", err)
+	}
+
+	if int(cred.Uid) != os.Getuid() {
+		return fmt.Errorf("%w: the baur daemon process runs as uid %d, expected uid %d", ErrUntrusted, cred.Uid, os.Getuid())
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+)
+
+// checkSocketDir returns the error of os.Lstat if dir can not be accessed,
+// otherwise an error wrapping ErrUntrusted, verifying the owner is not
+// supported on the platform.
+func checkSocketDir(dir string) error {
+	if _, err := os.Lstat(dir); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: verifying the owner of %s is not supported on %s: %w", ErrUntrusted, dir, runtime.GOOS, errors.ErrUnsupported)
+}
+
+// checkPeerUID returns an error wrapping ErrUntrusted, verifying the owner of
+// the server process is not supported on the platform.
+func checkPeerUID(net.Conn) error {
+	return fmt.Errorf("%w: verifying the owner of the baur daemon process is not supported on %s: %w", ErrUntrusted, runtime.GOOS, errors.ErrUnsupported)
+}
@@ -0,0 +1,289 @@
+// Package digestcache provides a persistent cache for digests of files.
+package digestcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/simplesurance/baur/v5/internal/digest"
+)
+
+const (
+	fileFormatVersion = 1
+
+	// racyDuration is the minimum time that must have passed since a file
+	// was modified before its digest is cached. Modifications within the
+	// timestamp granularity of the filesystem can otherwise not be
+	// detected.
+	racyDuration = 2 * time.Second
+
+	// maxUnusedDuration is the duration after that entries that were not
+	// used are removed from the cache.
+	maxUnusedDuration = 30 * 24 * time.Hour
+
+	// lastUsedUpdateInterval is the minimum duration after that the last
+	// usage time of an entry is updated, it prevents that the cache file is
+	// rewritten on every use.
+	lastUsedUpdateInterval = 24 * time.Hour
+)
+
+type PrintfFn func(format string, a ...any)
+
+// HashFn calculates the digest of the file at absPath.
+type HashFn func(absPath string) (*digest.Digest, error)
+
+// fileID identifies a version of a file. If the file is modified, replaced
+// or its metadata changes, its fileID changes.
+type fileID struct {
+	Dev   uint64
+	Inode uint64
+	Size  int64
+	// Mtime and Ctime are in nanoseconds since the unix epoch
+	Mtime int64
+	Ctime int64
+}
+
+type entry struct {
+	FileID fileID
+	Digest *digest.Digest
+	// LastUsed is the time in seconds since the unix epoch
+	LastUsed int64
+}
+
+type cacheFile struct {
+	Version int
+	Entries map[string]*entry
+}
+
+// Cache stores digests of files in a file, it is keyed by the path, inode,
+// size, mtime and ctime of the files.
+// Digests of files that are not in the cache are calculated with a HashFn.
+// Cache is safe for concurrent use.
+//
+// Caching is only supported on platforms on that the inode and ctime of
+// files can be retrieved, on other platforms all digests are calculated.
+type Cache struct {
+	path      string
+	hashFn    HashFn
+	debugLogf PrintfFn
+
+	loadOnce sync.Once
+	mu       sync.Mutex
+	entries  map[string]*entry
+	modified bool
+}
+
+// DefaultDir returns the default directory for cache files, it is the baur
+// directory in the user's cache directory ($XDG_CACHE_HOME on linux).
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "baur"), nil
+}
+
+// FilePath returns the path of the file in cacheDir in that the digests of
+// files in the baur repository in repositoryDir are stored.
+func FilePath(cacheDir, repositoryDir string) string {
+	sum := sha256.Sum256([]byte(repositoryDir))
+
+	return filepath.Join(cacheDir, "digests-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// New returns a cache that stores digests in the file at path.
+// The file is read when a digest is requested the first time.
+func New(path string, hashFn HashFn, debugLogf PrintfFn) *Cache {
+	return &Cache{
+		path:      path,
+		hashFn:    hashFn,
+		debugLogf: debugLogf,
+	}
+}
+
+// File returns the digest of the file at absPath.
+// If it is not cached or the file changed, it is calculated with the HashFn.
+func (c *Cache) File(absPath string) (*digest.Digest, error) {
+	c.loadOnce.Do(c.load)
+
+	id, supported, err := statFileID(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !supported {
+		return c.hashFn(absPath)
+	}
+
+	if d := c.get(absPath, id); d != nil {
+		return d, nil
+	}
+
+	d, err := c.hashFn(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(time.Unix(0, id.Mtime)) < racyDuration {
+		return d, nil
+	}
+
+	// the file could have been modified while it was hashed
+	idAfter, _, err := statFileID(absPath)
+	if err != nil || idAfter != id {
+		return d, nil
+	}
+
+	c.add(absPath, id, d)
+
+	return d, nil
+}
+
+func (c *Cache) get(absPath string, id fileID) *digest.Digest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, exists := c.entries[absPath]
+	if !exists || e.FileID != id {
+		return nil
+	}
+
+	if now := time.Now(); now.Sub(time.Unix(e.LastUsed, 0)) > lastUsedUpdateInterval {
+		e.LastUsed = now.Unix()
+		c.modified = true
+	}
+
+	return e.Digest
+}
+
+func (c *Cache) add(absPath string, id fileID, d *digest.Digest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[absPath] = &entry{
+		FileID:   id,
+		Digest:   d,
+		LastUsed: time.Now().Unix(),
+	}
+	c.modified = true
+}
+
+func (c *Cache) load() {
+	entries, err := readCacheFile(c.path)
+	if err != nil {
+		c.debugLogf("digestcache: reading %s failed, starting with an empty cache: %s\n", c.path, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = entries
+	c.debugLogf("digestcache: loaded %d entries from %s\n", len(entries), c.path)
+}
+
+// Save writes the cache to its file, if it was modified.
+// Entries that are stored in the file by other processes in the meantime are
+// preserved. Entries that were not used for 30 days are removed.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.modified {
+		return nil
+	}
+
+	stored, err := readCacheFile(c.path)
+	if err != nil {
+		c.debugLogf("digestcache: reading %s failed, overwriting it: %s\n", c.path, err)
+	}
+
+	entries := make(map[string]*entry, len(stored)+len(c.entries))
+	maps.Copy(entries, stored)
+	maps.Copy(entries, c.entries)
+
+	minLastUsed := time.Now().Add(-maxUnusedDuration).Unix()
+	maps.DeleteFunc(entries, func(_ string, e *entry) bool {
+		return e.LastUsed < minLastUsed
+	})
+
+	if err := writeCacheFile(c.path, entries); err != nil {
+		return err
+	}
+
+	c.modified = false
+	c.debugLogf("digestcache: wrote %d entries to %s\n", len(entries), c.path)
+
+	return nil
+}
+
+func readCacheFile(path string) (map[string]*entry, error) {
+	result := map[string]*entry{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return result, nil
+		}
+		return result, err
+	}
+
+	var f cacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return result, err
+	}
+
+	if f.Version != fileFormatVersion {
+		return result, fmt.Errorf("unsupported file format version %d", f.Version)
+	}
+
+	if f.Entries == nil {
+		return result, nil
+	}
+
+	maps.DeleteFunc(f.Entries, func(_ string, e *entry) bool {
+		return e == nil || e.Digest == nil
+	})
+
+	return f.Entries, nil
+}
+
+// writeCacheFile writes the entries to a temporary file and renames it to
+// path, readers never see a partially written file.
+func writeCacheFile(path string, entries map[string]*entry) error {
+	dir := filepath.Dir(path)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = json.NewEncoder(f).Encode(&cacheFile{
+		Version: fileFormatVersion,
+		Entries: entries,
+	})
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s failed: %w", f.Name(), err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing %s failed: %w", f.Name(), err)
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,148 @@
+//go:build linux || darwin
+
+package digestcache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/digest"
+	"github.com/simplesurance/baur/v5/internal/digest/sha384"
+	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
+)
+
+type countingHasher struct {
+	calls int
+}
+
+func (h *countingHasher) File(absPath string) (*digest.Digest, error) {
+	h.calls++
+	return sha384.File(absPath)
+}
+
+// writeOldFile writes content to path and sets its modification time to a
+// time that is older than racyDuration.
+func writeOldFile(t *testing.T, path, content string, age time.Duration) {
+	t.Helper()
+
+	fstest.WriteToFile(t, []byte(content), path)
+	mtime := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestDigestIsCalculatedWhenFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "file")
+	writeOldFile(t, f, "1", time.Hour)
+
+	var hasher countingHasher
+	c := New(filepath.Join(dir, "cache.json"), hasher.File, t.Logf)
+
+	d1, err := c.File(f)
+	require.NoError(t, err)
+	d2, err := c.File(f)
+	require.NoError(t, err)
+	assert.Equal(t, d1, d2)
+	assert.Equal(t, 1, hasher.calls)
+
+	// same size, different modification time
+	writeOldFile(t, f, "2", 2*time.Hour)
+	d3, err := c.File(f)
+	require.NoError(t, err)
+	assert.NotEqual(t, d1.String(), d3.String())
+	assert.Equal(t, 2, hasher.calls)
+}
+
+func TestRecentlyModifiedFilesAreNotCached(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "file")
+	fstest.WriteToFile(t, []byte("1"), f)
+
+	var hasher countingHasher
+	c := New(filepath.Join(dir, "cache.json"), hasher.File, t.Logf)
+
+	_, err := c.File(f)
+	require.NoError(t, err)
+	_, err = c.File(f)
+	require.NoError(t, err)
+	assert.Equal(t, 2, hasher.calls)
+}
+
+func TestSavedDigestsAreLoaded(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cachedir", "cache.json")
+	f1 := filepath.Join(dir, "file1")
+	f2 := filepath.Join(dir, "file2")
+	writeOldFile(t, f1, "1", time.Hour)
+	writeOldFile(t, f2, "2", time.Hour)
+
+	var hasher countingHasher
+
+	c1 := New(cachePath, hasher.File, t.Logf)
+	_, err := c1.File(f1)
+	require.NoError(t, err)
+
+	// c2 is created before c1 is saved, its entries and the ones of c1 are
+	// stored
+	c2 := New(cachePath, hasher.File, t.Logf)
+	_, err = c2.File(f2)
+	require.NoError(t, err)
+
+	require.NoError(t, c1.Save())
+	require.NoError(t, c2.Save())
+	assert.Equal(t, 2, hasher.calls)
+
+	c3 := New(cachePath, hasher.File, t.Logf)
+	_, err = c3.File(f1)
+	require.NoError(t, err)
+	_, err = c3.File(f2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, hasher.calls)
+}
+
+func TestInvalidCacheFileIsIgnored(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache.json")
+	fstest.WriteToFile(t, []byte("{invalid"), cachePath)
+
+	f := filepath.Join(dir, "file")
+	writeOldFile(t, f, "1", time.Hour)
+
+	var hasher countingHasher
+	c := New(cachePath, hasher.File, t.Logf)
+	_, err := c.File(f)
+	require.NoError(t, err)
+	require.NoError(t, c.Save())
+
+	_, err = New(cachePath, hasher.File, t.Logf).File(f)
+	require.NoError(t, err)
+	assert.Equal(t, 1, hasher.calls)
+}
+
+func TestCacheFileWithoutEntriesIsLoaded(t *testing.T) {
+	for _, content := range []string{`{"Version": 1}`, `{"Version": 1, "Entries": null}`} {
+		t.Run(content, func(t *testing.T) {
+			dir := t.TempDir()
+			cachePath := filepath.Join(dir, "cache.json")
+			fstest.WriteToFile(t, []byte(content), cachePath)
+
+			f := filepath.Join(dir, "file")
+			writeOldFile(t, f, "1", time.Hour)
+
+			var hasher countingHasher
+			c := New(cachePath, hasher.File, t.Logf)
+			_, err := c.File(f)
+			require.NoError(t, err)
+			require.NoError(t, c.Save())
+
+			_, err = New(cachePath, hasher.File, t.Logf).File(f)
+			require.NoError(t, err)
+			assert.Equal(t, 1, hasher.calls)
+		})
+	}
+}
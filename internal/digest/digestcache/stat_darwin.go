@@ -0,0 +1,30 @@
+//go:build darwin
+
+package digestcache
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// statFileID returns the fileID of the file at path, symlinks are followed.
+func statFileID(path string) (id fileID, supported bool, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileID{}, false, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false, fmt.Errorf("%s: stat returned unexpected type %T", path, fi.Sys())
+	}
+
+	return fileID{
+		Dev:   uint64(st.Dev), //nolint:gosec // device IDs are not negative
+		Inode: st.Ino,
+		Size:  st.Size,
+		Mtime: st.Mtimespec.Nano(),
+		Ctime: st.Ctimespec.Nano(),
+	}, true, nil
+}
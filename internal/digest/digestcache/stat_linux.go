@@ -0,0 +1,30 @@
+//go:build linux
+
+package digestcache
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// statFileID returns the fileID of the file at path, symlinks are followed.
+func statFileID(path string) (id fileID, supported bool, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileID{}, false, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false, fmt.Errorf("%s: stat returned unexpected type %T", path, fi.Sys())
+	}
+
+	return fileID{
+		Dev:   st.Dev,
+		Inode: st.Ino,
+		Size:  st.Size,
+		Mtime: st.Mtim.Nano(),
+		Ctime: st.Ctim.Nano(),
+	}, true, nil
+}
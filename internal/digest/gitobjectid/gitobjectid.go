@@ -2,6 +2,14 @@ package gitobjectid
 
 import (
 	"context"
+	"crypto/sha1" //nolint:gosec // git object IDs are sha1 sums
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
 
 	"github.com/simplesurance/baur/v5/internal/digest"
 	"github.com/simplesurance/baur/v5/internal/vcs/git"
@@ -11,9 +19,16 @@ type PrintfFn func(format string, a ...any)
 
 // Hash calculates git object IDs for files.
 // Object IDs are read the first time a digest is requested from the git repository.
+//
+// If git does not convert the content of files in the repository, object IDs
+// are calculated in-process. Otherwise they are calculated by running git.
 type Hash struct {
 	repoDir   string
 	debugLogf PrintfFn
+
+	initOnce sync.Once
+	// newHash is nil when object IDs are calculated by git
+	newHash func() hash.Hash
 }
 
 func New(gitRepositoryDir string, debugLogf PrintfFn) *Hash {
@@ -25,6 +40,12 @@ func New(gitRepositoryDir string, debugLogf PrintfFn) *Hash {
 
 // File calculcates the git object ID for the file at absPath.
 func (h *Hash) File(absPath string) (*digest.Digest, error) {
+	h.initOnce.Do(h.init)
+
+	if h.newHash != nil {
+		return h.calcObjectID(absPath)
+	}
+
 	h.debugLogf("gitobjectid: object id not in cache, calculating ID for %q", absPath)
 	objectID, err := git.ObjectID(context.TODO(), absPath, h.repoDir)
 	if err != nil {
@@ -33,3 +54,71 @@ func (h *Hash) File(absPath string) (*digest.Digest, error) {
 
 	return digest.FromStrDigest(objectID, digest.GitObjectID)
 }
+
+func (h *Hash) init() {
+	ctx := context.TODO()
+
+	format, err := git.ObjectFormat(ctx, h.repoDir)
+	if err != nil {
+		h.debugLogf("gitobjectid: determining object format failed, calculating object IDs via git: %s\n", err)
+		return
+	}
+
+	conversions, err := git.ContentConversionsConfigured(ctx, h.repoDir)
+	if err != nil {
+		h.debugLogf("gitobjectid: determining if content conversions are configured failed, calculating object IDs via git: %s\n", err)
+		return
+	}
+
+	if conversions {
+		h.debugLogf("gitobjectid: content conversions might be configured, calculating object IDs via git\n")
+		return
+	}
+
+	switch format {
+	case "sha1":
+		h.newHash = sha1.New
+	case "sha256":
+		h.newHash = sha256.New
+	default:
+		h.debugLogf("gitobjectid: unsupported object format %q, calculating object IDs via git\n", format)
+	}
+}
+
+// calcObjectID calculates the object ID of the file at absPath as git does
+// for blobs, when it does not convert their content.
+func (h *Hash) calcObjectID(absPath string) (*digest.Digest, error) {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: not a regular file", absPath)
+	}
+
+	hasher := h.newHash()
+	if _, err := fmt.Fprintf(hasher, "blob %d\x00", fi.Size()); err != nil {
+		return nil, err
+	}
+
+	n, err := io.Copy(hasher, f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s failed: %w", absPath, err)
+	}
+
+	if n != fi.Size() {
+		return nil, errors.New(absPath + ": file size changed while it was read")
+	}
+
+	return &digest.Digest{
+		Sum:       hasher.Sum(nil),
+		Algorithm: digest.GitObjectID,
+	}, nil
+}
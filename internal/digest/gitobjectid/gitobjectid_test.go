@@ -0,0 +1,71 @@
+package gitobjectid
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/digest"
+	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
+	"github.com/simplesurance/baur/v5/internal/testutils/gittest"
+	"github.com/simplesurance/baur/v5/internal/vcs/git"
+)
+
+func TestInProcessObjectIDMatchesGitObjectID(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+
+	tempDir := t.TempDir()
+	gittest.CreateRepository(t, tempDir)
+
+	for name, content := range map[string]string{
+		"empty":    "",
+		"text":     "hello\nworld\n",
+		"crlf":     "hello\r\nworld\r\n",
+		"binary":   "\x00\x01\x02\xff",
+		"noeolext": "no newline at the end",
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := filepath.Join(tempDir, name)
+			fstest.WriteToFile(t, []byte(content), f)
+
+			h := New(tempDir, t.Logf)
+			d, err := h.File(f)
+			require.NoError(t, err)
+			require.NotNil(t, h.newHash, "object id was not calculated in-process")
+
+			objectID, err := git.ObjectID(t.Context(), f, name)
+			require.NoError(t, err)
+			expected, err := digest.FromStrDigest(objectID, digest.GitObjectID)
+			require.NoError(t, err)
+
+			assert.Equal(t, expected.String(), d.String())
+		})
+	}
+}
+
+func TestObjectIDIsCalculatedByGitWhenAttributesExist(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+
+	tempDir := t.TempDir()
+	gittest.CreateRepository(t, tempDir)
+	fstest.WriteToFile(t, []byte("*.txt text eol=lf"), filepath.Join(tempDir, ".gitattributes"))
+
+	f := filepath.Join(tempDir, "file.txt")
+	fstest.WriteToFile(t, []byte("hello\r\nworld\r\n"), f)
+
+	h := New(tempDir, t.Logf)
+	d, err := h.File(f)
+	require.NoError(t, err)
+	assert.Nil(t, h.newHash)
+
+	objectID, err := git.ObjectID(t.Context(), f, "file.txt")
+	require.NoError(t, err)
+	expected, err := digest.FromStrDigest(objectID, digest.GitObjectID)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected.String(), d.String())
+}
@@ -0,0 +1,115 @@
+// Package events provides a machine-readable stream of events that happen
+// while baur runs tasks. Events are encoded as newline-delimited JSON.
+package events
+
+import (
+	"time"
+)
+
+// Type identifies the kind of an event.
+type Type string
+
+const (
+	// TypeStatusEvaluated is emitted when the status of a task was
+	// evaluated, Data is a *StatusEvaluated.
+	TypeStatusEvaluated Type = "status_evaluated"
+	// TypeTaskStarted is emitted before the command of a task is
+	// executed, it has no Data.
+	TypeTaskStarted Type = "task_started"
+	// TypeTaskFinished is emitted when the execution of a task
+	// finished, failed or was skipped, Data is a *TaskFinished.
+	TypeTaskFinished Type = "task_finished"
+	// TypeOutputCreated is emitted for each output that was produced by
+	// a successful task run, Data is an *OutputCreated.
+	TypeOutputCreated Type = "output_created"
+	// TypeUploadStarted is emitted before an output is uploaded to a
+	// destination, Data is an *UploadStarted.
+	TypeUploadStarted Type = "upload_started"
+	// TypeUploadFinished is emitted when an output was uploaded to a
+	// destination, Data is an *UploadFinished.
+	TypeUploadFinished Type = "upload_finished"
+	// TypeUploadFailed is emitted when uploading an output failed, Data
+	// is an *UploadFailed.
+	TypeUploadFailed Type = "upload_failed"
+	// TypeRunStored is emitted when a task run was recorded in the
+	// database, Data is a *RunStored.
+	TypeRunStored Type = "run_stored"
+)
+
+// Results of a task execution, used in TaskFinished.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultSkipped = "skipped"
+	ResultError   = "error"
+)
+
+// Event is a single event of the stream.
+type Event struct {
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	// Task is the ID of the task that the event belongs to.
+	Task string `json:"task,omitempty"`
+	// Data contains the type specific information of the event.
+	Data any `json:"data,omitempty"`
+}
+
+// StatusEvaluated is the data of a TypeStatusEvaluated event.
+type StatusEvaluated struct {
+	Status string `json:"status"`
+	// RunID is the ID of the existing task run, it is only set if a run
+	// with the same inputs exists.
+	RunID int `json:"run_id,omitempty"`
+}
+
+// TaskFinished is the data of a TypeTaskFinished event.
+type TaskFinished struct {
+	// Result is one of ResultSuccess, ResultFailure (the command exited
+	// with a non-zero code), ResultSkipped or ResultError (the command
+	// could not be executed or was terminated).
+	Result string `json:"result"`
+	// ExitCode, DurationMs and Attempts are only set if the command was
+	// executed.
+	ExitCode   int   `json:"exit_code"`
+	DurationMs int64 `json:"duration_ms"`
+	Attempts   int   `json:"attempts"`
+	// Error describes why the task failed or was skipped.
+	Error string `json:"error,omitempty"`
+}
+
+// OutputCreated is the data of a TypeOutputCreated event.
+type OutputCreated struct {
+	Output    string `json:"output"`
+	Type      string `json:"output_type"`
+	SizeBytes uint64 `json:"size_bytes"`
+	Digest    string `json:"digest"`
+}
+
+// UploadStarted is the data of a TypeUploadStarted event.
+type UploadStarted struct {
+	Output      string `json:"output"`
+	Destination string `json:"destination"`
+	URL         string `json:"url"`
+}
+
+// UploadFinished is the data of a TypeUploadFinished event.
+type UploadFinished struct {
+	Output         string `json:"output"`
+	URL            string `json:"url"`
+	Method         string `json:"method"`
+	DurationMs     int64  `json:"duration_ms"`
+	BytesPerSecond uint64 `json:"bytes_per_second"`
+}
+
+// UploadFailed is the data of a TypeUploadFailed event.
+type UploadFailed struct {
+	Output string `json:"output"`
+	Error  string `json:"error"`
+}
+
+// RunStored is the data of a TypeRunStored event.
+type RunStored struct {
+	RunID int `json:"run_id"`
+	// Result is the result of the stored run, "success" or "failure".
+	Result string `json:"result"`
+}
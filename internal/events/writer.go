@@ -0,0 +1,40 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Writer writes events as newline-delimited JSON.
+// It is safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	// nowFn returns the current time, it can be replaced in testcases.
+	nowFn func() time.Time
+}
+
+// NewWriter returns a Writer that writes events to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		enc:   json.NewEncoder(w),
+		nowFn: time.Now,
+	}
+}
+
+// Emit writes an event of the given type. task is the ID of the task the
+// event belongs to, it can be empty. data must be nil or the data struct of
+// the event type.
+func (w *Writer) Emit(typ Type, task string, data any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.enc.Encode(&Event{
+		Type: typ,
+		Time: w.nowFn().UTC(),
+		Task: task,
+		Data: data,
+	})
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmitWritesNewlineDelimitedJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.nowFn = func() time.Time {
+		return time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	}
+
+	require.NoError(t, w.Emit(TypeTaskStarted, "app.build", nil))
+	require.NoError(t, w.Emit(TypeTaskFinished, "app.build", &TaskFinished{
+		Result:     ResultSuccess,
+		DurationMs: 1500,
+		Attempts:   1,
+	}))
+
+	assert.Equal(t,
+		`{"type":"task_started","time":"2024-05-01T10:00:00Z","task":"app.build"}`+"\n"+
+			`{"type":"task_finished","time":"2024-05-01T10:00:00Z","task":"app.build","data":{"result":"success","exit_code":0,"duration_ms":1500,"attempts":1}}`+"\n",
+		buf.String(),
+	)
+}
+
+func TestConcurrentEmitsAreNotInterleaved(t *testing.T) {
+	const eventCnt = 100
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	var wg sync.WaitGroup
+	for range eventCnt {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, w.Emit(TypeRunStored, "app.build", &RunStored{RunID: 1, Result: ResultSuccess}))
+		}()
+	}
+	wg.Wait()
+
+	var lines int
+	sc := bufio.NewScanner(strings.NewReader(buf.String()))
+	for sc.Scan() {
+		var ev Event
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &ev))
+		assert.Equal(t, TypeRunStored, ev.Type)
+		lines++
+	}
+	require.NoError(t, sc.Err())
+	assert.Equal(t, eventCnt, lines)
+}
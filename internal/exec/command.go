@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"syscall"
 	"time"
 
 	"github.com/fatih/color"
@@ -43,7 +44,8 @@ type Cmd struct {
 
 	maxStoredErrBytesPerStream int
 
-	expectSuccess bool
+	expectSuccess     bool
+	traceFileAccesses bool
 
 	logFn                    PrintfFn
 	logPrefix                string
@@ -90,6 +92,15 @@ func (c *Cmd) ExpectSuccess() *Cmd {
 	return c
 }
 
+// TraceFileAccesses traces the syscalls of the process and its descendants
+// and records the files that are opened or executed in Result.FileAccesses.
+// Tracing is only supported on Linux amd64, on other platforms Run() returns
+// an error wrapping errors.ErrUnsupported.
+func (c *Cmd) TraceFileAccesses() *Cmd {
+	c.traceFileAccesses = true
+	return c
+}
+
 // Directory defines the directiory in which the command is executed.
 func (c *Cmd) Directory(dir string) *Cmd {
 	c.dir = dir
@@ -195,10 +206,22 @@ func (c *Cmd) resolveDir(d string) string {
 func (c *Cmd) Run(ctx context.Context) (*Result, error) {
 	cmd := exec.CommandContext(ctx, c.name, c.args...)
 	cmd.SysProcAttr = defSysProcAttr()
+	setProcessGroupSysProcAttr(cmd.SysProcAttr)
 	cmd.WaitDelay = time.Minute
+	// when ctx is done, the process and its descendants are asked to
+	// terminate, if they are still running after WaitDelay they are killed
+	cmd.Cancel = func() error {
+		return terminateProcessGroup(cmd.Process, cmd.WaitDelay)
+	}
 	cmd.Dir = c.resolveDir(c.dir)
 	cmd.Env = c.env
 
+	if c.traceFileAccesses {
+		if err := setTraceSysProcAttr(cmd.SysProcAttr); err != nil {
+			return nil, err
+		}
+	}
+
 	stdoutLogWriterCloseFn := func() error { return nil }
 	stderrLogWriterCloseFn := func() error { return nil }
 	var stdoutLogWriter, stderrLogWriter io.Writer
@@ -226,7 +249,23 @@ func (c *Cmd) Run(ctx context.Context) (*Result, error) {
 		return nil, errors.Join(err, stdoutLogWriterCloseFn(), stderrLogWriterCloseFn())
 	}
 
+	var traceRes *traceResult
+	if c.traceFileAccesses {
+		var traceErr error
+		traceRes, traceErr = traceFileAccesses(ctx, cmd.Process.Pid, cmd.WaitDelay, c.logf)
+		if traceErr != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return nil, errors.Join(traceErr, stdoutLogWriterCloseFn(), stderrLogWriterCloseFn())
+		}
+	}
+
 	err = cmd.Wait()
+	if traceRes != nil && errors.Is(err, syscall.ECHILD) {
+		// the process was already reaped by traceFileAccesses()
+		err = nil
+	}
+
 	logWriterErr := errors.Join(stdoutLogWriterCloseFn(), stderrLogWriterCloseFn())
 	if err != nil && ctx.Err() != nil {
 		return nil, errors.Join(ctx.Err(), err, logWriterErr)
@@ -242,13 +281,21 @@ func (c *Cmd) Run(ctx context.Context) (*Result, error) {
 	}
 
 	result := Result{
-		Command:  cmd.String(),
-		Dir:      cmd.Dir,
-		ExitCode: cmd.ProcessState.ExitCode(),
-		Success:  cmd.ProcessState.Success(),
-		stdout:   &stdoutPss,
-		stderr:   &stderrPss,
-		ee:       ee,
+		Command: cmd.String(),
+		Dir:     cmd.Dir,
+		stdout:  &stdoutPss,
+		stderr:  &stderrPss,
+	}
+
+	if traceRes != nil {
+		result.ExitCode = traceRes.exitCode
+		result.Success = traceRes.success
+		result.exitStatus = traceRes.status
+		result.FileAccesses = traceRes.fileAccesses
+	} else {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+		result.Success = cmd.ProcessState.Success()
+		result.exitStatus = cmd.ProcessState.String()
 	}
 	c.logf("command terminated with exit code: %d\n", result.ExitCode)
 
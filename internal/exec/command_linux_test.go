@@ -108,3 +108,39 @@ func TestStdoutStderrPrefixSaver(t *testing.T) {
 		})
 	}
 }
+
+func TestCanceledCommandIsTerminatedWithSigterm(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var stdout bytes.Buffer
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := Command(
+		"sh", "-c",
+		"trap 'echo terminated; exit 1' TERM; while true; do sleep 0.1; done",
+	).Stdout(&stdout).Run(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "terminated\n", stdout.String())
+}
+
+func TestCanceledCommandTerminatesDescendants(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var stdout bytes.Buffer
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := Command(
+		"sh", "-c",
+		`sh -c "trap 'echo descendant terminated; exit 1' TERM; while true; do sleep 0.1; done" & wait`,
+	).Stdout(&stdout).Run(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "descendant terminated\n", stdout.String())
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package exec
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// setProcessGroupSysProcAttr configures attr to start the process in a new
+// process group, that contains the process and all its descendants that do
+// not create a process group on their own.
+func setProcessGroupSysProcAttr(attr *syscall.SysProcAttr) {
+	attr.Setpgid = true
+}
+
+// terminateProcessGroup sends SIGTERM to the process group of p.
+// If the group still exists after killDelay, SIGKILL is sent to it.
+// p must have been started with the SysProcAttr configured by
+// setProcessGroupSysProcAttr.
+func terminateProcessGroup(p *os.Process, killDelay time.Duration) error {
+	pgid := p.Pid
+
+	time.AfterFunc(killDelay, func() {
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+	})
+
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		return p.Kill()
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package exec
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func setProcessGroupSysProcAttr(*syscall.SysProcAttr) {}
+
+// terminateProcessGroup kills the process p, process groups are not
+// supported on windows.
+func terminateProcessGroup(p *os.Process, _ time.Duration) error {
+	return p.Kill()
+}
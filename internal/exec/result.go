@@ -2,7 +2,6 @@ package exec
 
 import (
 	"fmt"
-	"os/exec"
 	"strings"
 )
 
@@ -24,7 +23,7 @@ func (e *ExitCodeError) ColoredError(highlightFn, errorFn SprintFn, withCmdOutpu
 	result.WriteString("\" ")
 	result.WriteString(errorFn("failed"))
 	result.WriteString(": ")
-	result.WriteString(e.ee.String())
+	result.WriteString(e.exitStatus)
 
 	if !withCmdOutput || (len(e.stdout.Bytes()) == 0 && len(e.stderr.Bytes()) == 0) {
 		return result.String()
@@ -65,8 +64,14 @@ type Result struct {
 	Command  string
 	Dir      string
 	ExitCode int
-	ee       *exec.ExitError
 	Success  bool
+	// FileAccesses are the files that were opened or executed by the
+	// process and its descendants, it is only set when
+	// Cmd.TraceFileAccesses() was called.
+	FileAccesses []FileAccess
+
+	// exitStatus describes how the process terminated, e.g. "exit status 1"
+	exitStatus string
 
 	stdout *prefixSuffixSaver
 	stderr *prefixSuffixSaver
@@ -0,0 +1,18 @@
+package exec
+
+// FileAccess describes a file that was opened or executed by a traced
+// process.
+type FileAccess struct {
+	// Path is the absolute path of the file, symlinks are not resolved.
+	Path  string
+	Read  bool
+	Write bool
+}
+
+// traceResult is the result of tracing the execution of a process.
+type traceResult struct {
+	exitCode     int
+	success      bool
+	status       string
+	fileAccesses []FileAccess
+}
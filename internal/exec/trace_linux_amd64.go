@@ -0,0 +1,453 @@
+//go:build linux && amd64
+
+package exec
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// syscallStopSignal is the stop signal of syscall-stops when
+	// PTRACE_O_TRACESYSGOOD is set
+	syscallStopSignal = syscall.SIGTRAP | 0x80
+
+	sysExecveat = 322
+	sysOpenat2  = 437
+
+	ptraceOExitKill = 0x100000
+
+	atFdCwd = -100
+
+	maxPathLen = 4096
+
+	pAll = 0
+
+	// nonTraceePollInterval is the interval in that it is checked again
+	// for state changes of tracees, when a child process that is not
+	// traced terminated and was not reaped yet
+	nonTraceePollInterval = 10 * time.Millisecond
+)
+
+// TracingSupported returns true if TraceFileAccesses() is supported on the
+// platform.
+func TracingSupported() bool {
+	return true
+}
+
+func setTraceSysProcAttr(attr *syscall.SysProcAttr) error {
+	attr.Ptrace = true
+	// the process group is used to only wait for events of the traced
+	// processes, other child processes of baur are not affected
+	attr.Setpgid = true
+
+	return nil
+}
+
+// tracee is the state of a traced thread.
+type tracee struct {
+	inSyscall bool
+	// access is the file access of the current syscall, it is
+	// recorded when the syscall succeeds
+	access *FileAccess
+}
+
+type tracer struct {
+	pid int
+	// tid is the ID of the OS thread that traces the processes
+	tid      int
+	tracees  map[int]*tracee
+	accesses map[FileAccess]struct{}
+	logf     PrintfFn
+}
+
+// traceFileAccesses traces the syscalls of the process with pid and all its
+// descendants, until all of them terminated. The process must have been
+// started with the SysProcAttr returned by setTraceSysProcAttr() from the
+// current OS thread.
+// If ctx is canceled and the processes are still running after waitDelay,
+// they are killed.
+func traceFileAccesses(ctx context.Context, pid int, waitDelay time.Duration, logf PrintfFn) (*traceResult, error) {
+	t := tracer{
+		pid:      pid,
+		tid:      syscall.Gettid(),
+		tracees:  map[int]*tracee{},
+		accesses: map[FileAccess]struct{}{},
+		logf:     logf,
+	}
+
+	var killTimerMu sync.Mutex
+	var killTimer *time.Timer
+	// killed is set when the processes are killed, to also kill the
+	// tracees that are not part of the process group anymore
+	var killed atomic.Bool
+	stopCtxWatch := context.AfterFunc(ctx, func() {
+		killTimerMu.Lock()
+		defer killTimerMu.Unlock()
+
+		killTimer = time.AfterFunc(waitDelay, func() {
+			killed.Store(true)
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+		})
+	})
+	defer func() {
+		stopCtxWatch()
+
+		killTimerMu.Lock()
+		defer killTimerMu.Unlock()
+
+		if killTimer != nil {
+			killTimer.Stop()
+		}
+	}()
+
+	var ws syscall.WaitStatus
+	// the process stops with SIGTRAP after execve
+	if _, err := syscall.Wait4(pid, &ws, syscall.WALL, nil); err != nil {
+		return nil, fmt.Errorf("waiting for traced process failed: %w", err)
+	}
+
+	if !ws.Stopped() {
+		return nil, fmt.Errorf("traced process terminated unexpectedly: %s", waitStatusString(ws))
+	}
+
+	err := syscall.PtraceSetOptions(pid,
+		syscall.PTRACE_O_TRACESYSGOOD|
+			syscall.PTRACE_O_TRACEFORK|
+			syscall.PTRACE_O_TRACEVFORK|
+			syscall.PTRACE_O_TRACECLONE|
+			syscall.PTRACE_O_TRACEEXEC|
+			ptraceOExitKill,
+	)
+	if err != nil {
+		_ = syscall.Kill(-pid, syscall.SIGKILL)
+		return nil, fmt.Errorf("setting ptrace options failed: %w", err)
+	}
+
+	t.tracees[pid] = &tracee{}
+	if err := syscall.PtraceSyscall(pid, 0); err != nil {
+		_ = syscall.Kill(-pid, syscall.SIGKILL)
+		return nil, fmt.Errorf("resuming traced process failed: %w", err)
+	}
+
+	var result *traceResult
+	for len(t.tracees) > 0 {
+		if killed.Load() {
+			t.killTracees()
+		}
+
+		wpid, err := t.wait(&ws)
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+
+			if errors.Is(err, syscall.ECHILD) {
+				break
+			}
+
+			return nil, fmt.Errorf("waiting for traced processes failed: %w", err)
+		}
+
+		if ws.Exited() || ws.Signaled() {
+			delete(t.tracees, wpid)
+
+			if wpid == pid {
+				result = &traceResult{
+					exitCode: ws.ExitStatus(),
+					success:  ws.Exited() && ws.ExitStatus() == 0,
+					status:   waitStatusString(ws),
+				}
+			}
+
+			continue
+		}
+
+		if !ws.Stopped() {
+			continue
+		}
+
+		t.handleStop(wpid, ws)
+	}
+
+	if result == nil {
+		return nil, errors.New("exit status of traced process is unknown")
+	}
+
+	result.fileAccesses = make([]FileAccess, 0, len(t.accesses))
+	for a := range t.accesses {
+		result.fileAccesses = append(result.fileAccesses, a)
+	}
+
+	return result, nil
+}
+
+// wait waits for a state change of one of the tracees, stores it in ws and
+// returns the pid of the tracee.
+// Descendants can leave the process group of the traced process, therefore
+// state changes of all child processes are waited for. To not reap child
+// processes that are not traced, e.g. of commands that are run concurrently,
+// the pid of a state change is determined without reaping the child first.
+func (t *tracer) wait(ws *syscall.WaitStatus) (int, error) {
+	for {
+		pid, err := waitidNoWait()
+		if err != nil {
+			return 0, err
+		}
+
+		if !t.isTracee(pid) {
+			// a terminated child process that is not traced, it
+			// is reaped by the goroutine that started it
+			time.Sleep(nonTraceePollInterval)
+			continue
+		}
+
+		return syscall.Wait4(pid, ws, syscall.WALL, nil)
+	}
+}
+
+func (t *tracer) isTracee(pid int) bool {
+	if _, exists := t.tracees[pid]; exists {
+		return true
+	}
+
+	// a new child process or thread whose initial stop was not
+	// processed yet
+	tracerPid, err := tracerPid(pid)
+	if err != nil {
+		return false
+	}
+
+	return tracerPid == t.tid
+}
+
+func (t *tracer) killTracees() {
+	for pid := range t.tracees {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+	}
+}
+
+// waitidNoWait waits for a child process to terminate or a tracee to stop
+// and returns its pid, without reaping it.
+func waitidNoWait() (int, error) {
+	// siginfo_t is 128 bytes long, si_pid is at offset 16 on amd64
+	var info [128]byte
+
+	for {
+		_, _, errno := syscall.Syscall6(
+			syscall.SYS_WAITID,
+			pAll,
+			0,
+			uintptr(unsafe.Pointer(&info[0])),
+			syscall.WEXITED|syscall.WNOWAIT|syscall.WALL,
+			0, 0,
+		)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return 0, errno
+		}
+
+		return int(int32(binary.LittleEndian.Uint32(info[16:]))), nil
+	}
+}
+
+// tracerPid returns the ID of the thread that traces the process or thread
+// pid, or 0 if it is not traced.
+func tracerPid(pid int) (int, error) {
+	status, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0, err
+	}
+
+	for line := range strings.Lines(string(status)) {
+		if v, found := strings.CutPrefix(line, "TracerPid:"); found {
+			return strconv.Atoi(strings.TrimSpace(v))
+		}
+	}
+
+	return 0, errors.New("TracerPid field not found")
+}
+
+func (t *tracer) handleStop(pid int, ws syscall.WaitStatus) {
+	tr, known := t.tracees[pid]
+	if !known {
+		// a new child process or thread, it was automatically
+		// attached because of the PTRACE_O_TRACE{FORK,VFORK,CLONE}
+		// options and starts with a SIGSTOP that must not be injected
+		tr = &tracee{}
+		t.tracees[pid] = tr
+
+		if ws.StopSignal() == syscall.SIGSTOP {
+			t.resume(pid, 0)
+			return
+		}
+	}
+
+	sig := ws.StopSignal()
+	switch {
+	case sig == syscallStopSignal:
+		t.handleSyscallStop(pid, tr)
+		t.resume(pid, 0)
+
+	case sig == syscall.SIGTRAP && ws.TrapCause() == syscall.PTRACE_EVENT_EXEC:
+		// when a thread that is not the thread group leader calls
+		// execve, it takes over the ID of the leader, its former ID is
+		// gone without an exit notification
+		if formerTid, err := syscall.PtraceGetEventMsg(pid); err == nil && int(formerTid) != pid {
+			delete(t.tracees, int(formerTid))
+		}
+		t.resume(pid, 0)
+
+	case sig == syscall.SIGTRAP && ws.TrapCause() > 0:
+		// ptrace event stop (fork, clone)
+		t.resume(pid, 0)
+
+	default:
+		// signal-delivery-stop, the signal is delivered to the process
+		t.resume(pid, sig)
+	}
+}
+
+func (t *tracer) resume(pid int, sig syscall.Signal) {
+	err := syscall.PtraceSyscall(pid, int(sig))
+	if err != nil && !errors.Is(err, syscall.ESRCH) {
+		t.logf("resuming traced process %d failed: %s\n", pid, err)
+	}
+}
+
+func (t *tracer) handleSyscallStop(pid int, tr *tracee) {
+	var regs syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(pid, &regs); err != nil {
+		t.logf("reading registers of traced process %d failed: %s\n", pid, err)
+		return
+	}
+
+	if !tr.inSyscall {
+		tr.inSyscall = true
+		tr.access = t.syscallFileAccess(pid, &regs)
+		return
+	}
+
+	tr.inSyscall = false
+	if tr.access != nil && int64(regs.Rax) >= 0 {
+		t.accesses[*tr.access] = struct{}{}
+	}
+	tr.access = nil
+}
+
+// syscallFileAccess returns the file access of the syscall that pid is
+// about to execute. If it is not a syscall that opens or executes a file, nil
+// is returned.
+func (t *tracer) syscallFileAccess(pid int, regs *syscall.PtraceRegs) *FileAccess {
+	var dirfd int
+	var pathAddr uintptr
+	var flags int
+
+	switch regs.Orig_rax {
+	case syscall.SYS_OPEN:
+		dirfd, pathAddr, flags = atFdCwd, uintptr(regs.Rdi), int(regs.Rsi)
+
+	case syscall.SYS_CREAT:
+		dirfd, pathAddr, flags = atFdCwd, uintptr(regs.Rdi), syscall.O_WRONLY|syscall.O_CREAT|syscall.O_TRUNC
+
+	case syscall.SYS_OPENAT:
+		dirfd, pathAddr, flags = int(int32(regs.Rdi)), uintptr(regs.Rsi), int(regs.Rdx)
+
+	case sysOpenat2:
+		// the first field of struct open_how are the flags
+		buf := make([]byte, 8)
+		if _, err := syscall.PtracePeekData(pid, uintptr(regs.Rdx), buf); err != nil {
+			return nil
+		}
+		dirfd, pathAddr = int(int32(regs.Rdi)), uintptr(regs.Rsi)
+		flags = int(binary.LittleEndian.Uint64(buf))
+
+	case syscall.SYS_EXECVE:
+		dirfd, pathAddr, flags = atFdCwd, uintptr(regs.Rdi), syscall.O_RDONLY
+
+	case sysExecveat:
+		dirfd, pathAddr, flags = int(int32(regs.Rdi)), uintptr(regs.Rsi), syscall.O_RDONLY
+
+	default:
+		return nil
+	}
+
+	path, err := peekString(pid, pathAddr)
+	if err != nil || path == "" {
+		return nil
+	}
+
+	if !filepath.IsAbs(path) {
+		dir, err := tracedProcessDir(pid, dirfd)
+		if err != nil {
+			return nil
+		}
+
+		path = filepath.Join(dir, path)
+	}
+
+	accMode := flags & syscall.O_ACCMODE
+	return &FileAccess{
+		Path:  filepath.Clean(path),
+		Read:  accMode != syscall.O_WRONLY,
+		Write: accMode != syscall.O_RDONLY || flags&(syscall.O_CREAT|syscall.O_TRUNC) != 0,
+	}
+}
+
+// tracedProcessDir returns the path of the directory referenced by dirfd
+// of process pid.
+func tracedProcessDir(pid, dirfd int) (string, error) {
+	procDir := "/proc/" + strconv.Itoa(pid)
+	if dirfd == atFdCwd {
+		return os.Readlink(procDir + "/cwd")
+	}
+
+	return os.Readlink(procDir + "/fd/" + strconv.Itoa(dirfd))
+}
+
+// peekString reads a NUL-terminated string at addr from the memory of the
+// traced process pid.
+func peekString(pid int, addr uintptr) (string, error) {
+	var result []byte
+	buf := make([]byte, 64)
+
+	for len(result) < maxPathLen {
+		n, err := syscall.PtracePeekData(pid, addr, buf)
+		if err != nil {
+			return "", err
+		}
+
+		for _, b := range buf[:n] {
+			if b == 0 {
+				return string(result), nil
+			}
+
+			result = append(result, b)
+		}
+
+		addr += uintptr(n)
+	}
+
+	return "", errors.New("string exceeds max. path length")
+}
+
+func waitStatusString(ws syscall.WaitStatus) string {
+	if ws.Signaled() {
+		return "signal: " + ws.Signal().String()
+	}
+
+	return "exit status " + strconv.Itoa(ws.ExitStatus())
+}
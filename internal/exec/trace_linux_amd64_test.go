@@ -0,0 +1,62 @@
+//go:build linux && amd64
+
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceFileAccesses(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "in.txt"), []byte("hello"), 0o644))
+
+	res, err := Command("sh", "-c", "cat in.txt > out.txt; (cat in.txt) >/dev/null; exit 3").
+		Directory(dir).
+		TraceFileAccesses().
+		Run(t.Context())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, res.ExitCode)
+	assert.False(t, res.Success)
+	assert.Contains(t, res.FileAccesses, FileAccess{Path: filepath.Join(dir, "in.txt"), Read: true})
+	assert.Contains(t, res.FileAccesses, FileAccess{Path: filepath.Join(dir, "out.txt"), Write: true})
+	assert.ErrorContains(t, res.ExpectSuccess(), "exit status 3")
+}
+
+func TestTraceFileAccessesOfDescendantsInNewSession(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "in.txt"), []byte("hello"), 0o644))
+
+	res, err := Command("sh", "-c", "setsid --wait sh -c 'sleep 0.1; cat in.txt' >/dev/null").
+		Directory(dir).
+		TraceFileAccesses().
+		Run(t.Context())
+	require.NoError(t, err)
+
+	assert.True(t, res.Success)
+	assert.Contains(t, res.FileAccesses, FileAccess{Path: filepath.Join(dir, "in.txt"), Read: true})
+}
+
+func TestTracingDoesNotReapOtherChildProcesses(t *testing.T) {
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			res, err := Command("sh", "-c", "exit 7").Run(t.Context())
+			if assert.NoError(t, err) {
+				assert.Equal(t, 7, res.ExitCode)
+			}
+		})
+	}
+
+	res, err := Command("sh", "-c", "sleep 0.5").TraceFileAccesses().Run(t.Context())
+	require.NoError(t, err)
+	assert.True(t, res.Success)
+
+	wg.Wait()
+}
@@ -0,0 +1,26 @@
+//go:build !(linux && amd64)
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// TracingSupported returns true if TraceFileAccesses() is supported on the
+// platform.
+func TracingSupported() bool {
+	return false
+}
+
+func setTraceSysProcAttr(*syscall.SysProcAttr) error {
+	return fmt.Errorf("tracing file accesses is not supported on %s/%s: %w", runtime.GOOS, runtime.GOARCH, errors.ErrUnsupported)
+}
+
+func traceFileAccesses(context.Context, int, time.Duration, PrintfFn) (*traceResult, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -233,6 +233,38 @@ func AbsPath(rootPath, path string) string {
 	return filepath.Join(rootPath, path)
 }
 
+// CopyFile copies the file src to dst, the permission bits of src are
+// applied to dst. If dst exists it is overwritten.
+func CopyFile(src, dst string) error {
+	srcFd, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFd.Close()
+
+	srcFi, err := srcFd.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstFd, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, srcFi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dstFd, srcFd); err != nil {
+		_ = dstFd.Close()
+		return fmt.Errorf("copying %s to %s failed: %w", src, dst, err)
+	}
+
+	if err := dstFd.Chmod(srcFi.Mode().Perm()); err != nil {
+		_ = dstFd.Close()
+		return err
+	}
+
+	return dstFd.Close()
+}
+
 const FileBackupSuffix = ".bak"
 
 // BackupFile renames a file to <OldName><FileBackupSuffix>.
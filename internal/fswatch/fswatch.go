@@ -0,0 +1,176 @@
+// Package fswatch reports changes of files in watched directories.
+package fswatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/simplesurance/baur/v5/internal/set"
+)
+
+// ErrClosed is returned by Wait and Sync when the Watcher was closed.
+var ErrClosed = errors.New("watcher is closed")
+
+// cookiePrefix is the name prefix of the files that Sync creates, changes of
+// them are not reported.
+const cookiePrefix = ".baur-fswatch-cookie-"
+
+var cookieSeq atomic.Uint64
+
+// changeQueue collects the paths of changed files until they are retrieved
+// via Wait or Sync.
+type changeQueue struct {
+	mu      sync.Mutex
+	changed set.Set[string]
+	// cookies contains the channels that are closed when the change of
+	// the cookie file with the path is received
+	cookies map[string]chan struct{}
+	err     error
+
+	// notifyCh receives a value when a change was added
+	notifyCh chan struct{}
+	// doneCh is closed when no further changes are reported, err is then
+	// set
+	doneCh chan struct{}
+}
+
+func newChangeQueue() changeQueue {
+	return changeQueue{
+		changed:  set.Set[string]{},
+		cookies:  map[string]chan struct{}{},
+		notifyCh: make(chan struct{}, 1),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+func (q *changeQueue) add(path string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if strings.HasPrefix(filepath.Base(path), cookiePrefix) {
+		if ch, exists := q.cookies[path]; exists {
+			close(ch)
+			delete(q.cookies, path)
+		}
+		return
+	}
+
+	q.changed.Add(path)
+
+	select {
+	case q.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+func (q *changeQueue) addCookie(path string) <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ch := make(chan struct{})
+	q.cookies[path] = ch
+
+	return ch
+}
+
+func (q *changeQueue) removeCookie(path string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.cookies, path)
+}
+
+func (q *changeQueue) done(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.err = err
+	close(q.doneCh)
+}
+
+func (q *changeQueue) take() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	result := q.changed.Slice()
+	slices.Sort(result)
+	q.changed = set.Set[string]{}
+
+	return result
+}
+
+func (q *changeQueue) doneErr() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.err
+}
+
+// Wait blocks until a file in one of the watched directories was created,
+// modified, moved or deleted. It then waits until no further change happened
+// for the debounce duration and returns the paths of the changed files,
+// sorted and without duplicates.
+// Changes that happened since the previous call of Wait or Sync are also
+// reported.
+// An empty path in the result means that changes were lost because too many
+// happened at once.
+func (w *Watcher) Wait(ctx context.Context, debounce time.Duration) ([]string, error) {
+	// quietCh is nil until the first change was received, receiving from
+	// it blocks until then
+	var quietCh <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case <-w.doneCh:
+			return nil, w.doneErr()
+
+		case <-w.notifyCh:
+			quietCh = time.After(debounce)
+
+		case <-quietCh:
+			// the changes could have been retrieved by Sync in
+			// the meantime
+			if result := w.take(); len(result) > 0 {
+				return result, nil
+			}
+			quietCh = nil
+		}
+	}
+}
+
+// Sync returns the changes that happened before it was called and were not
+// retrieved yet by Wait or Sync. The result is sorted and does not contain
+// duplicates, it can be empty.
+// dir must be a watched directory. To ensure that all preceding changes were
+// received, a temporary file is created in it and Sync waits until its
+// creation is reported.
+func (w *Watcher) Sync(ctx context.Context, dir string) ([]string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("%s%d-%d", cookiePrefix, os.Getpid(), cookieSeq.Add(1)))
+	ch := w.addCookie(path)
+	defer w.removeCookie(path)
+
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		return nil, fmt.Errorf("creating cookie file failed: %w", err)
+	}
+	defer os.Remove(path)
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-w.doneCh:
+		return nil, w.doneErr()
+	case <-ch:
+		return w.take(), nil
+	}
+}
@@ -0,0 +1,178 @@
+//go:build linux
+
+package fswatch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/simplesurance/baur/v5/internal/set"
+)
+
+const watchMask = syscall.IN_CREATE |
+	syscall.IN_DELETE |
+	syscall.IN_MODIFY |
+	syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF |
+	syscall.IN_MOVE_SELF |
+	syscall.IN_ONLYDIR
+
+// Watcher reports changes of files in a set of directories via inotify.
+// Subdirectories are not watched recursively.
+type Watcher struct {
+	changeQueue
+
+	f  *os.File
+	fd int
+
+	dirsMu sync.Mutex
+	// dirs contains the watch descriptors of the watched directories
+	dirs map[string]int
+	// wds maps watch descriptors to the watched directories, the same
+	// directory can be watched via multiple paths
+	wds map[int][]string
+}
+
+// Supported returns true if watching files is supported on the platform.
+func Supported() bool {
+	return true
+}
+
+// New returns a new Watcher that does not watch any directory.
+// The Watcher must be closed when it is not needed anymore.
+func New() (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("creating inotify instance failed: %w", err)
+	}
+
+	w := Watcher{
+		// the file descriptor is non-blocking, reads are done via the
+		// go runtime poller and are interrupted when the file is closed
+		changeQueue: newChangeQueue(),
+		f:           os.NewFile(uintptr(fd), "inotify"),
+		fd:          fd,
+		dirs:        map[string]int{},
+		wds:         map[int][]string{},
+	}
+
+	go w.read()
+
+	return &w, nil
+}
+
+// Watch replaces the set of watched directories with dirs.
+// Directories that do not exist are ignored.
+func (w *Watcher) Watch(dirs []string) error {
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+
+	wanted := set.From(dirs)
+
+	for dir, wd := range w.dirs {
+		if wanted.Contains(dir) {
+			continue
+		}
+
+		delete(w.dirs, dir)
+		w.wds[wd] = slices.DeleteFunc(w.wds[wd], func(d string) bool { return d == dir })
+		if len(w.wds[wd]) > 0 {
+			continue
+		}
+
+		delete(w.wds, wd)
+		// removing fails when the directory was deleted, the watch was
+		// then already removed by the kernel
+		_, _ = syscall.InotifyRmWatch(w.fd, uint32(wd)) //nolint:gosec // watch descriptors are positive
+	}
+
+	for dir := range wanted {
+		if _, exists := w.dirs[dir]; exists {
+			continue
+		}
+
+		wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+		if err != nil {
+			if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR) {
+				continue
+			}
+
+			return fmt.Errorf("watching %s failed: %w", dir, err)
+		}
+
+		w.dirs[dir] = wd
+		w.wds[wd] = append(w.wds[wd], dir)
+	}
+
+	return nil
+}
+
+// Close stops watching all directories and releases the resources of the
+// Watcher.
+func (w *Watcher) Close() error {
+	return w.f.Close()
+}
+
+func (w *Watcher) read() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				w.done(ErrClosed)
+			} else {
+				w.done(fmt.Errorf("reading inotify events failed: %w", err))
+			}
+			return
+		}
+
+		w.parseEvents(buf[:n])
+	}
+}
+
+// parseEvents parses the inotify_event structs in buf and sends the paths of
+// the changed files to the change queue.
+func (w *Watcher) parseEvents(buf []byte) {
+	for len(buf) >= syscall.SizeofInotifyEvent {
+		wd := int(int32(binary.NativeEndian.Uint32(buf[0:4]))) //nolint:gosec // the field is an int32
+		mask := binary.NativeEndian.Uint32(buf[4:8])
+		nameLen := int(binary.NativeEndian.Uint32(buf[12:16]))
+
+		end := min(syscall.SizeofInotifyEvent+nameLen, len(buf))
+		name := strings.TrimRight(string(buf[syscall.SizeofInotifyEvent:end]), "\x00")
+		buf = buf[end:]
+
+		if mask&syscall.IN_Q_OVERFLOW != 0 {
+			w.add("")
+			continue
+		}
+
+		w.dirsMu.Lock()
+		dirs := w.wds[wd]
+		if mask&syscall.IN_IGNORED != 0 {
+			// the watch was removed, because the directory was
+			// deleted or Watch() removed it
+			for _, dir := range dirs {
+				delete(w.dirs, dir)
+			}
+			delete(w.wds, wd)
+			dirs = nil
+		}
+		w.dirsMu.Unlock()
+
+		if len(dirs) == 0 {
+			continue
+		}
+
+		w.add(filepath.Join(dirs[0], name))
+	}
+}
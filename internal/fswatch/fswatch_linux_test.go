@@ -0,0 +1,92 @@
+//go:build linux
+
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDebounce = 50 * time.Millisecond
+
+func TestWaitReportsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	otherDir := t.TempDir()
+
+	w, err := New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+
+	require.NoError(t, w.Watch([]string{dir, filepath.Join(dir, "doesnotexist")}))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("1"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b"), []byte("1"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("2"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(otherDir, "c"), []byte("1"), 0o600))
+
+	changed, err := w.Wait(t.Context(), testDebounce)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, changed)
+}
+
+func TestWatchReplacesWatchedDirectories(t *testing.T) {
+	dir1 := t.TempDir()
+	dir2 := t.TempDir()
+
+	w, err := New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+
+	require.NoError(t, w.Watch([]string{dir1}))
+	require.NoError(t, w.Watch([]string{dir2}))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir1, "a"), []byte("1"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir2, "b"), []byte("1"), 0o600))
+
+	changed, err := w.Wait(t.Context(), testDebounce)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir2, "b")}, changed)
+}
+
+func TestWaitReturnsErrorWhenClosed(t *testing.T) {
+	w, err := New()
+	require.NoError(t, err)
+
+	require.NoError(t, w.Close())
+
+	_, err = w.Wait(t.Context(), testDebounce)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestSyncReturnsPrecedingChanges(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = w.Close() })
+
+	require.NoError(t, w.Watch([]string{dir}))
+
+	changed, err := w.Sync(t.Context(), dir)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("1"), 0o600))
+
+	changed, err = w.Sync(t.Context(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a")}, changed)
+
+	changed, err = w.Sync(t.Context(), dir)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "cookie files were not removed")
+}
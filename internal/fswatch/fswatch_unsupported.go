@@ -0,0 +1,35 @@
+//go:build !linux
+
+package fswatch
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// Watcher reports changes of files in a set of directories.
+// It is not supported on this platform.
+type Watcher struct {
+	changeQueue
+}
+
+// Supported returns true if watching files is supported on the platform.
+func Supported() bool {
+	return false
+}
+
+// New returns an error, watching files is not supported on the platform.
+func New() (*Watcher, error) {
+	return nil, fmt.Errorf("watching files is not supported on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
+
+// Watch returns errors.ErrUnsupported.
+func (*Watcher) Watch([]string) error {
+	return errors.ErrUnsupported
+}
+
+// Close returns errors.ErrUnsupported.
+func (*Watcher) Close() error {
+	return errors.ErrUnsupported
+}
@@ -3,10 +3,12 @@ package docker
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
+	"strings"
 
 	docker "github.com/fsouza/go-dockerclient"
 )
@@ -131,7 +133,18 @@ func (c *Client) getAuth(server string) docker.AuthConfiguration {
 // Upload tags and uploads an image to a docker registry repository.
 // On success it returns the path  to the uploaded docker image, in the format:
 // [registry]:/repository/tag
-func (c *Client) Upload(image, registryAddr, repository, tag string) (string, error) {
+// DestinationURI returns the URI of the image that Upload pushes to the
+// repository with the given tag in the registry at registryAddr.
+// If registryAddr is empty, DefaultRegistry is used.
+func DestinationURI(registryAddr, repository, tag string) string {
+	if registryAddr == "" {
+		registryAddr = DefaultRegistry
+	}
+
+	return registryAddr + "/" + repository + ":" + tag
+}
+
+func (c *Client) Upload(ctx context.Context, image, registryAddr, repository, tag string) (string, error) {
 	var addrRepo string
 	var destURI string
 
@@ -140,12 +153,13 @@ func (c *Client) Upload(image, registryAddr, repository, tag string) (string, er
 	}
 
 	addrRepo = registryAddr + "/" + repository
-	destURI = registryAddr + "/" + repository + ":" + tag
+	destURI = DestinationURI(registryAddr, repository, tag)
 
 	c.debugLogFn("docker: creating tag, repo: %q, tag: %q referring to image %q", addrRepo, tag, image)
 	err := c.clt.TagImage(image, docker.TagImageOptions{
-		Repo: addrRepo,
-		Tag:  tag,
+		Repo:    addrRepo,
+		Tag:     tag,
+		Context: ctx,
 	})
 	if err != nil {
 		return "", fmt.Errorf("tagging image failed: %w", err)
@@ -161,6 +175,7 @@ func (c *Client) Upload(image, registryAddr, repository, tag string) (string, er
 		Name:         addrRepo,
 		Tag:          tag,
 		OutputStream: outStream,
+		Context:      ctx,
 	}, auth)
 
 	for {
@@ -201,3 +216,67 @@ func (c *Client) Exists(imageID string) (bool, error) {
 	}
 	return true, nil
 }
+
+// parseImageURI splits an image URI, in the format returned by Upload(), into
+// the registry address and the image reference that can be used to pull the
+// image.
+// If the URI does not contain a tag, "latest" is returned as tag.
+func parseImageURI(uri string) (registryAddr, repository, tag string) {
+	repository = uri
+	tag = "latest"
+
+	if idx := strings.LastIndex(repository, ":"); idx > strings.LastIndex(repository, "/") {
+		tag = repository[idx+1:]
+		repository = repository[:idx]
+	}
+
+	if after, found := strings.CutPrefix(repository, DefaultRegistry+"/"); found {
+		return DefaultRegistry, after, tag
+	}
+
+	if idx := strings.Index(repository, "/"); idx != -1 {
+		return repository[:idx], repository, tag
+	}
+
+	return DefaultRegistry, repository, tag
+}
+
+// Pull downloads an image from a docker registry and returns its ID.
+// uri must be in the format returned by Upload().
+func (c *Client) Pull(ctx context.Context, uri string) (string, error) {
+	registryAddr, repository, tag := parseImageURI(uri)
+
+	auth := c.getAuth(registryAddr)
+
+	var outBuf bytes.Buffer
+	outStream := bufio.NewWriter(&outBuf)
+
+	c.debugLogFn("docker: pulling image, repo: %q, tag: %q", repository, tag)
+	err := c.clt.PullImage(docker.PullImageOptions{
+		Repository:   repository,
+		Tag:          tag,
+		OutputStream: outStream,
+		Context:      ctx,
+	}, auth)
+
+	for {
+		outStream.Flush()
+		line, err := outBuf.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		c.debugLogFn("docker: " + line)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	img, err := c.clt.InspectImage(repository + ":" + tag)
+	if err != nil {
+		return "", fmt.Errorf("inspecting pulled image failed: %w", err)
+	}
+
+	return img.ID, nil
+}
@@ -137,3 +137,46 @@ func TestGetAuth(t *testing.T) {
 		assert.Equal(t, myRegistryUser, auth.Username)
 	})
 }
+
+func TestParseImageURI(t *testing.T) {
+	testcases := []struct {
+		uri          string
+		registryAddr string
+		repository   string
+		tag          string
+	}{
+		{
+			uri:          DefaultRegistry + "/baur/app:1.0",
+			registryAddr: DefaultRegistry,
+			repository:   "baur/app",
+			tag:          "1.0",
+		},
+		{
+			uri:          "myregistry.com:5000/baur/app:abc",
+			registryAddr: "myregistry.com:5000",
+			repository:   "myregistry.com:5000/baur/app",
+			tag:          "abc",
+		},
+		{
+			uri:          "myregistry.com:5000/app",
+			registryAddr: "myregistry.com:5000",
+			repository:   "myregistry.com:5000/app",
+			tag:          "latest",
+		},
+		{
+			uri:          "app:2",
+			registryAddr: DefaultRegistry,
+			repository:   "app",
+			tag:          "2",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.uri, func(t *testing.T) {
+			registryAddr, repository, tag := parseImageURI(tc.uri)
+			assert.Equal(t, tc.registryAddr, registryAddr)
+			assert.Equal(t, tc.repository, repository)
+			assert.Equal(t, tc.tag, tag)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package filecopy
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -26,7 +27,22 @@ func New(debugLogFn func(string, ...any)) *Client {
 	return &Client{debugLogFn: logFn}
 }
 
-func copyFile(src, dst string) error {
+// ctxReader is an io.Reader that fails with the error of the context, when
+// the context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+func copyFile(ctx context.Context, src, dst string) error {
 	srcFd, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("opening %s failed: %w", src, err)
@@ -46,7 +62,7 @@ func copyFile(src, dst string) error {
 		return fmt.Errorf("opening %s failed: %w", dst, err)
 	}
 
-	_, err = io.Copy(dstFd, srcFd)
+	_, err = io.Copy(dstFd, &ctxReader{ctx: ctx, r: srcFd})
 	if err != nil {
 		_ = dstFd.Close()
 
@@ -60,7 +76,8 @@ func copyFile(src, dst string) error {
 // If the destination directory does not exist, it is created.
 // If the destination path exist and is not a regular file an error is returned.
 // If it exists, is a file and it differs the file is overwritten.
-func (c *Client) Upload(src, dst string) (string, error) {
+// When ctx is canceled, copying is aborted and an error is returned.
+func (c *Client) Upload(ctx context.Context, src, dst string) (string, error) {
 	destDir := filepath.Dir(dst)
 
 	isDir, err := fs.IsDir(destDir)
@@ -84,7 +101,7 @@ func (c *Client) Upload(src, dst string) (string, error) {
 			return "", err
 		}
 
-		return dst, copyFile(src, dst)
+		return dst, copyFile(ctx, src, dst)
 	}
 
 	if !regFile {
@@ -103,5 +120,5 @@ func (c *Client) Upload(src, dst string) (string, error) {
 
 	c.debugLogFn("filecopy: '%s' already exist, overwriting file", dst)
 
-	return dst, copyFile(src, dst)
+	return dst, copyFile(ctx, src, dst)
 }
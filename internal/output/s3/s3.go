@@ -61,14 +61,14 @@ func NewClient(ctx context.Context, logger Logger) (*Client, error) {
 
 // Upload uploads a file to an s3 bucket, on success it returns the s3:// URL
 // of the object.
-func (c *Client) Upload(filepath, bucket, key string) (string, error) {
+func (c *Client) Upload(ctx context.Context, filepath, bucket, key string) (string, error) {
 	f, err := os.Open(filepath)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
 
-	res, err := c.uploader.Upload(context.TODO(),
+	res, err := c.uploader.Upload(ctx,
 		&s3.PutObjectInput{
 			Bucket: aws.String(bucket),
 			Key:    aws.String(key),
@@ -79,13 +79,18 @@ func (c *Client) Upload(filepath, bucket, key string) (string, error) {
 		return "", err
 	}
 
-	url := url.URL{
+	return URL(bucket, *res.Key), nil
+}
+
+// URL returns the s3:// URL of the object with key in bucket.
+func URL(bucket, key string) string {
+	u := url.URL{
 		Scheme: "s3",
 		Host:   bucket,
-		Path:   *res.Key,
+		Path:   key,
 	}
 
-	return url.String(), err
+	return u.String()
 }
 
 func (c *Client) Download(ctx context.Context, bucket, key, filepath string) error {
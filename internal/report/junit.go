@@ -0,0 +1,104 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Content string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML document to w.
+// Each app is a test suite and each task a test case. Tasks that were skipped
+// or not executed because a run with the same inputs exists, are reported
+// as skipped test cases.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	apps, total := summarize(r.Results())
+
+	doc := junitTestSuites{
+		Name:     "baur",
+		Tests:    total.total,
+		Failures: total.failed,
+		Skipped:  total.skipped + total.cached,
+		Time:     junitDuration(total.duration),
+	}
+
+	for _, app := range apps {
+		suite := junitTestSuite{
+			Name:     app.name,
+			Tests:    app.total,
+			Failures: app.failed,
+			Skipped:  app.skipped + app.cached,
+			Time:     junitDuration(app.duration),
+		}
+
+		for _, res := range app.results {
+			tc := junitTestCase{
+				Name:      res.TaskName,
+				ClassName: res.AppName,
+				Time:      junitDuration(res.Duration),
+			}
+
+			switch res.Status {
+			case StatusFailure:
+				tc.Failure = &junitMessage{Message: res.Message, Content: res.Output}
+			case StatusSkipped:
+				tc.Skipped = &junitMessage{Message: res.Message}
+			case StatusCached:
+				tc.Skipped = &junitMessage{Message: "run with same inputs exists"}
+			}
+
+			suite.TestCases = append(suite.TestCases, &tc)
+		}
+
+		doc.Suites = append(doc.Suites, &suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("encoding junit xml failed: %w", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
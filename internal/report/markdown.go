@@ -0,0 +1,118 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteMarkdown writes the report as Markdown document to w.
+// The document contains a table with the number of cache hits and misses per
+// app, a table with the results of all tasks and the output of failed tasks.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	results := r.Results()
+	apps, total := summarize(results)
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# baur run report\n\n")
+
+	fmt.Fprintf(bw, "| App | Tasks | Cache Hits | Cache Misses | Succeeded | Failed | Skipped |\n")
+	fmt.Fprintf(bw, "|-----|------:|-----------:|-------------:|----------:|-------:|--------:|\n")
+	for _, app := range apps {
+		writeMarkdownSummaryRow(bw, mdEscape(app.name), app)
+	}
+	writeMarkdownSummaryRow(bw, "**Total**", total)
+
+	if len(results) > 0 {
+		fmt.Fprintf(bw, "\n## Tasks\n\n")
+		fmt.Fprintf(bw, "| Task | Status | Duration |\n")
+		fmt.Fprintf(bw, "|------|--------|---------:|\n")
+		for _, res := range results {
+			fmt.Fprintf(bw, "| %s | %s | %s |\n",
+				mdEscape(res.ID()), mdStatus(res.Status), mdDuration(res),
+			)
+		}
+	}
+
+	if total.failed > 0 {
+		fmt.Fprintf(bw, "\n## Failures\n")
+
+		for _, res := range results {
+			if res.Status != StatusFailure {
+				continue
+			}
+
+			fmt.Fprintf(bw, "\n### %s\n\n", mdEscape(res.ID()))
+			if res.Message != "" {
+				fmt.Fprintf(bw, "%s\n", mdEscape(res.Message))
+			}
+
+			if res.Output != "" {
+				fence := mdCodeFence(res.Output)
+				fmt.Fprintf(bw, "\n<details><summary>Output</summary>\n\n%s\n%s\n%s\n\n</details>\n",
+					fence, strings.TrimRight(res.Output, "\n"), fence,
+				)
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeMarkdownSummaryRow(w io.Writer, name string, s *appSummary) {
+	fmt.Fprintf(w, "| %s | %d | %d | %d | %d | %d | %d |\n",
+		name, s.total, s.cached, s.cacheMisses(), s.succeeded, s.failed, s.skipped,
+	)
+}
+
+func mdStatus(s Status) string {
+	switch s {
+	case StatusSuccess:
+		return ":white_check_mark: success"
+	case StatusFailure:
+		return ":x: failure"
+	case StatusSkipped:
+		return ":next_track_button: skipped"
+	case StatusCached:
+		return ":recycle: cached"
+	default:
+		return string(s)
+	}
+}
+
+func mdDuration(r *TaskResult) string {
+	if r.Status == StatusCached || r.Duration == 0 {
+		return "-"
+	}
+
+	return r.Duration.Round(time.Millisecond).String()
+}
+
+var mdEscaper = strings.NewReplacer(
+	"|", `\|`,
+	"\n", " ",
+)
+
+// mdEscape escapes s to be used in a table cell or heading.
+func mdEscape(s string) string {
+	return mdEscaper.Replace(s)
+}
+
+// mdCodeFence returns a code fence that is longer than any sequence of
+// backticks in s.
+func mdCodeFence(s string) string {
+	var longest, cur int
+	for _, c := range s {
+		if c == '`' {
+			cur++
+			longest = max(longest, cur)
+			continue
+		}
+		cur = 0
+	}
+
+	return strings.Repeat("`", max(3, longest+1))
+}
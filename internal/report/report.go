@@ -0,0 +1,139 @@
+// Package report creates reports about the results of task runs in formats
+// that can be rendered by CI systems.
+package report
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the result of a task in a report.
+type Status string
+
+const (
+	// StatusSuccess is the status of a task that was executed and whose
+	// run was completed successfully.
+	StatusSuccess Status = "success"
+	// StatusFailure is the status of a task whose execution, upload or
+	// recording failed.
+	StatusFailure Status = "failure"
+	// StatusSkipped is the status of a task that was not executed because
+	// the run was aborted or a task that it depends on failed.
+	StatusSkipped Status = "skipped"
+	// StatusCached is the status of a task that was not executed because
+	// a run with the same inputs exists.
+	StatusCached Status = "cached"
+)
+
+// TaskResult is the result of a single task.
+type TaskResult struct {
+	AppName  string
+	TaskName string
+	Status   Status
+	// Duration is the execution duration of the task command.
+	Duration time.Duration
+	// Message describes why the task failed or was skipped.
+	Message string
+	// Output is the output of the task command, it is only set for failed
+	// tasks.
+	Output string
+}
+
+// ID returns the task ID, in the format <APP-NAME>.<TASK-NAME>.
+func (r *TaskResult) ID() string {
+	return r.AppName + "." + r.TaskName
+}
+
+// Report collects the results of tasks.
+// It is safe for concurrent use.
+type Report struct {
+	mu      sync.Mutex
+	results []*TaskResult
+}
+
+// New returns an empty report.
+func New() *Report {
+	return &Report{}
+}
+
+// Add adds the result of a task to the report.
+func (r *Report) Add(result *TaskResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = append(r.results, result)
+}
+
+// Results returns the results of the report, sorted by task ID.
+func (r *Report) Results() []*TaskResult {
+	r.mu.Lock()
+	result := slices.Clone(r.results)
+	r.mu.Unlock()
+
+	slices.SortStableFunc(result, func(a, b *TaskResult) int {
+		return strings.Compare(a.ID(), b.ID())
+	})
+
+	return result
+}
+
+// appSummary contains the number of tasks of an app per status.
+type appSummary struct {
+	name      string
+	total     int
+	succeeded int
+	failed    int
+	skipped   int
+	cached    int
+	duration  time.Duration
+	results   []*TaskResult
+}
+
+// cacheMisses returns the number of tasks for that no run with the same
+// inputs existed.
+func (s *appSummary) cacheMisses() int {
+	return s.total - s.cached
+}
+
+func (s *appSummary) add(r *TaskResult) {
+	s.total++
+	s.duration += r.Duration
+	s.results = append(s.results, r)
+
+	switch r.Status {
+	case StatusSuccess:
+		s.succeeded++
+	case StatusFailure:
+		s.failed++
+	case StatusSkipped:
+		s.skipped++
+	case StatusCached:
+		s.cached++
+	}
+}
+
+// summarize groups the results by app name. The apps are sorted by name.
+func summarize(results []*TaskResult) (apps []*appSummary, total *appSummary) {
+	total = &appSummary{}
+	byName := map[string]*appSummary{}
+
+	for _, r := range results {
+		s, exists := byName[r.AppName]
+		if !exists {
+			s = &appSummary{name: r.AppName}
+			byName[r.AppName] = s
+			apps = append(apps, s)
+		}
+
+		s.add(r)
+		total.add(r)
+	}
+
+	slices.SortFunc(apps, func(a, b *appSummary) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	return apps, total
+}
@@ -0,0 +1,93 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() *Report {
+	r := New()
+	r.Add(&TaskResult{AppName: "app2", TaskName: "build", Status: StatusCached})
+	r.Add(&TaskResult{
+		AppName:  "app1",
+		TaskName: "test",
+		Status:   StatusFailure,
+		Duration: 2500 * time.Millisecond,
+		Message:  `executing "make test" failed: exit status 1`,
+		Output:   "FAIL: TestX\n",
+	})
+	r.Add(&TaskResult{
+		AppName:  "app1",
+		TaskName: "build",
+		Status:   StatusSuccess,
+		Duration: 1200 * time.Millisecond,
+	})
+	r.Add(&TaskResult{
+		AppName:  "app2",
+		TaskName: "check",
+		Status:   StatusSkipped,
+		Message:  "dependency app1.test failed",
+	})
+
+	return r
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testReport().WriteJUnit(&buf))
+
+	var doc junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc), buf.String())
+
+	assert.Equal(t, 4, doc.Tests)
+	assert.Equal(t, 1, doc.Failures)
+	assert.Equal(t, 2, doc.Skipped)
+	assert.Equal(t, "3.700", doc.Time)
+
+	require.Len(t, doc.Suites, 2)
+
+	app1 := doc.Suites[0]
+	assert.Equal(t, "app1", app1.Name)
+	assert.Equal(t, 1, app1.Failures)
+	require.Len(t, app1.TestCases, 2)
+	assert.Equal(t, "build", app1.TestCases[0].Name)
+	assert.Equal(t, "1.200", app1.TestCases[0].Time)
+	assert.Nil(t, app1.TestCases[0].Failure)
+	assert.Nil(t, app1.TestCases[0].Skipped)
+	assert.Equal(t, "test", app1.TestCases[1].Name)
+	require.NotNil(t, app1.TestCases[1].Failure)
+	assert.Equal(t, `executing "make test" failed: exit status 1`, app1.TestCases[1].Failure.Message)
+	assert.Equal(t, "FAIL: TestX\n", app1.TestCases[1].Failure.Content)
+
+	app2 := doc.Suites[1]
+	assert.Equal(t, "app2", app2.Name)
+	assert.Equal(t, 2, app2.Skipped)
+	require.Len(t, app2.TestCases, 2)
+	require.NotNil(t, app2.TestCases[0].Skipped)
+	require.NotNil(t, app2.TestCases[1].Skipped)
+	assert.Equal(t, "dependency app1.test failed", app2.TestCases[1].Skipped.Message)
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testReport().WriteMarkdown(&buf))
+
+	md := buf.String()
+	assert.Contains(t, md, "| app1 | 2 | 0 | 2 | 1 | 1 | 0 |\n")
+	assert.Contains(t, md, "| app2 | 2 | 1 | 1 | 0 | 0 | 1 |\n")
+	assert.Contains(t, md, "| **Total** | 4 | 1 | 3 | 1 | 1 | 1 |\n")
+	assert.Contains(t, md, "| app1.build | :white_check_mark: success | 1.2s |\n")
+	assert.Contains(t, md, "| app2.build | :recycle: cached | - |\n")
+	assert.Contains(t, md, "### app1.test\n\nexecuting \"make test\" failed: exit status 1\n")
+	assert.Contains(t, md, "```\nFAIL: TestX\n```\n")
+}
+
+func TestMarkdownCodeFenceIsLongerThanBackticksInOutput(t *testing.T) {
+	assert.Equal(t, "```", mdCodeFence("abc"))
+	assert.Equal(t, "````", mdCodeFence("a ``` b"))
+}
@@ -26,22 +26,40 @@ var defLogFn = func(string, ...any) {}
 // Resolver determines all Go Source files that are imported by Go-Files
 // in the passed paths
 type Resolver struct {
-	logFn func(string, ...any)
+	logFn   func(string, ...any)
+	hostEnv []string
+}
+
+// Opt is an option for NewResolver.
+type Opt func(*Resolver)
+
+// WithHostEnv configures the resolver to use env instead of the environment
+// variables returned by HostEnv.
+func WithHostEnv(env []string) Opt {
+	return func(r *Resolver) {
+		r.hostEnv = env
+	}
 }
 
 // NewResolver returns a resolver that resolves all go source files in the
 // GoDirs and their imports to filepaths.
 // env specifies the environment variables to use during resolving.
 // If empty or nil the default Go environment is used.
-func NewResolver(debugLogFn func(string, ...any)) *Resolver {
+func NewResolver(debugLogFn func(string, ...any), opts ...Opt) *Resolver {
 	logFn := defLogFn
 	if debugLogFn != nil {
 		logFn = debugLogFn
 	}
 
-	return &Resolver{
+	r := Resolver{
 		logFn: logFn,
 	}
+
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	return &r
 }
 
 func resolveGlobs(workDir string, queries []string) ([]string, error) {
@@ -99,7 +117,11 @@ func (r *Resolver) Resolve(ctx context.Context,
 		return nil, errors.New("queries parameter is empty")
 	}
 
-	env := append(whitelistedEnv(), environment...)
+	hostEnv := r.hostEnv
+	if hostEnv == nil {
+		hostEnv = HostEnv()
+	}
+	env := slices.Concat(hostEnv, environment)
 
 	// relative query paths are interpreted as relative to the cwd, not the
 	// cfg.WorkDir passed to packages.Resolve
@@ -120,9 +142,9 @@ func (r *Resolver) Resolve(ctx context.Context,
 	return r.resolve(ctx, workdir, goEnv, env, buildFlags, withTests, queries)
 }
 
-// whitelistedEnvVars returns whitelisted environment variables from the host
-// that are set during resolving.
-func whitelistedEnv() []string {
+// HostEnv returns the whitelisted environment variables from the host that
+// are set during resolving.
+func HostEnv() []string {
 	var env []string
 
 	// PATH might be required to locate the "go list" tool on the host
@@ -1,130 +1,140 @@
 package routines
 
 import (
+	"slices"
 	"sync"
+
+	"github.com/simplesurance/baur/v5/internal/set"
 )
 
 // Pool is a FIFO go-routine work pool.
-// Work can be scheduled and is executed by the first free worker in the pool.
-// Scheduled work is executed in queueing order.
+// The pool has a fixed number of slots, each work occupies 1 or more slots
+// while it is executed. Work can be scheduled and is executed when enough
+// slots are free.
+// Scheduled work is started in queueing order. Work that belongs to a mutex
+// group that is in use by running work is skipped until the group is free.
 type Pool struct {
-	wq        []WorkFn
-	terminate bool
-	wqMutex   sync.Mutex // protects wq and terminate
-
-	workChan chan WorkFn
-
-	schedulerNotifyChan chan struct{}
-
-	terminateWg sync.WaitGroup
+	wq          []*work
+	terminate   bool
+	slots       uint
+	freeSlots   uint
+	mutexGroups set.Set[string] // mutex groups of running work
+	mu          sync.Mutex      // protects all fields above
+
+	wg sync.WaitGroup
 }
 
 // WorkFn is a function that is executed by the pool workers.
 type WorkFn func()
 
-// NewPool creates and start a new go-routine pool.
-// The pool starts <routines> number of workers.
-func NewPool(routines uint) *Pool {
-	p := Pool{
-		workChan:            make(chan WorkFn, routines),
-		schedulerNotifyChan: make(chan struct{}, 1),
-	}
+type work struct {
+	fn         WorkFn
+	weight     uint
+	mutexGroup string
+}
 
-	p.terminateWg.Add(1)
-	go p.scheduler()
+// QueueOpt is an option for Queue().
+type QueueOpt func(*work)
 
-	for i := uint(0); i < routines; i++ {
-		p.terminateWg.Add(1)
-		go p.worker()
+// WithWeight sets the number of slots that the work occupies while it is
+// executed. The default weight is 1. A weight that is bigger than the number
+// of slots of the pool is reduced to the number of slots, the work is then
+// executed exclusively.
+func WithWeight(weight uint) QueueOpt {
+	return func(w *work) {
+		w.weight = max(weight, 1)
 	}
-
-	return &p
 }
 
-func (p *Pool) scheduler() {
-	defer p.terminateWg.Done()
-
-	for {
-		<-p.schedulerNotifyChan
-
-		p.wqMutex.Lock()
-		work := p._popWork()
-		if work == nil {
-			if p.terminate {
-				close(p.workChan)
-				p.wqMutex.Unlock()
-				return
-			}
-
-			p.wqMutex.Unlock()
-			continue
-		}
-
-		p.wqMutex.Unlock()
-
-		p.workChan <- work
+// WithMutexGroup assigns the work to a mutex group. Work of the same mutex
+// group is never executed in parallel. An empty name assigns the work to no
+// group.
+func WithMutexGroup(name string) QueueOpt {
+	return func(w *work) {
+		w.mutexGroup = name
 	}
 }
 
-func (p *Pool) _popWork() WorkFn {
-	if len(p.wq) == 0 {
-		return nil
+// NewPool creates a new go-routine pool with <routines> number of slots.
+func NewPool(routines uint) *Pool {
+	return &Pool{
+		slots:       routines,
+		freeSlots:   routines,
+		mutexGroups: set.Set[string]{},
 	}
-
-	w := p.wq[0]
-	p.wq = p.wq[1:]
-
-	return w
 }
 
-func (p *Pool) worker() {
-	defer p.terminateWg.Done()
+// _schedule starts the execution of queued work for that enough slots are
+// free. p.mu must be held when it is called.
+func (p *Pool) _schedule() {
+	for i := 0; i < len(p.wq); {
+		w := p.wq[i]
+
+		if w.mutexGroup != "" && p.mutexGroups.Contains(w.mutexGroup) {
+			i++
+			continue
+		}
 
-	for {
-		workFn, open := <-p.workChan
-		if !open {
+		if w.weight > p.freeSlots {
+			// work that is queued later is not started, to prevent
+			// that work with a high weight starves
 			return
 		}
 
-		p.notifyScheduler()
+		p.wq = slices.Delete(p.wq, i, i+1)
+		p.freeSlots -= w.weight
+		if w.mutexGroup != "" {
+			p.mutexGroups.Add(w.mutexGroup)
+		}
 
-		workFn()
+		go p.run(w)
 	}
 }
 
-func (p *Pool) notifyScheduler() {
-	select {
-	case p.schedulerNotifyChan <- struct{}{}:
+func (p *Pool) run(w *work) {
+	defer p.wg.Done()
+
+	w.fn()
 
-	default:
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.freeSlots += w.weight
+	if w.mutexGroup != "" {
+		p.mutexGroups.Remove(w.mutexGroup)
 	}
+
+	p._schedule()
 }
 
 // Queue queues new work for the pool.
 // If Queue() is called after Wait(), the method panics.
 // The method never blocks.
-func (p *Pool) Queue(workFn WorkFn) {
-	p.wqMutex.Lock()
-	defer p.wqMutex.Unlock()
+func (p *Pool) Queue(workFn WorkFn, opts ...QueueOpt) {
+	w := work{fn: workFn, weight: 1}
+	for _, opt := range opts {
+		opt(&w)
+	}
+	w.weight = min(w.weight, max(p.slots, 1))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
 	if p.terminate {
 		panic("work was queued on a closed pool")
 	}
 
-	p.wq = append(p.wq, workFn)
-	p.notifyScheduler()
+	p.wg.Add(1)
+	p.wq = append(p.wq, &w)
+	p._schedule()
 }
 
-// Wait waits until the workqueue is empty and then terminates the worker
-// goroutines.
+// Wait waits until all queued work was executed.
 // After Wait() was called, no further work must be queued.
 func (p *Pool) Wait() {
-	p.wqMutex.Lock()
+	p.mu.Lock()
 	p.terminate = true
-	p.wqMutex.Unlock()
-
-	p.notifyScheduler()
+	p.mu.Unlock()
 
-	p.terminateWg.Wait()
-	close(p.schedulerNotifyChan)
+	p.wg.Wait()
 }
@@ -62,3 +62,85 @@ func TestExecutionOrder(t *testing.T) {
 		assert.Equal(t, i, executedorder[i])
 	}
 }
+
+// concurrencyTracker records the max. sum of weights of work that was
+// executed in parallel.
+type concurrencyTracker struct {
+	mu      sync.Mutex
+	current uint
+	max     uint
+}
+
+func (c *concurrencyTracker) run(weight uint) {
+	c.mu.Lock()
+	c.current += weight
+	c.max = max(c.max, c.current)
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	c.current -= weight
+	c.mu.Unlock()
+}
+
+func TestWeightedWorkDoesNotExceedSlots(t *testing.T) {
+	var tracker concurrencyTracker
+	var done atomic.Int32
+
+	pool := NewPool(4)
+
+	for i := range 40 {
+		weight := uint(i%4 + 1)
+		pool.Queue(func() {
+			tracker.run(weight)
+			done.Add(1)
+		}, WithWeight(weight))
+	}
+
+	pool.Wait()
+
+	assert.Equal(t, int32(40), done.Load())
+	assert.LessOrEqual(t, tracker.max, uint(4))
+	assert.Greater(t, tracker.max, uint(1))
+}
+
+func TestWorkWithWeightExceedingSlotsIsExecuted(t *testing.T) {
+	var tracker concurrencyTracker
+	var done atomic.Int32
+
+	pool := NewPool(2)
+
+	for range 5 {
+		pool.Queue(func() {
+			tracker.run(1)
+			done.Add(1)
+		})
+	}
+	pool.Queue(func() {
+		tracker.run(2)
+		done.Add(1)
+	}, WithWeight(10))
+
+	pool.Wait()
+
+	assert.Equal(t, int32(6), done.Load())
+	assert.LessOrEqual(t, tracker.max, uint(2))
+}
+
+func TestWorkOfSameMutexGroupDoesNotRunInParallel(t *testing.T) {
+	var groupTracker concurrencyTracker
+	var otherTracker concurrencyTracker
+
+	pool := NewPool(8)
+
+	for range 10 {
+		pool.Queue(func() { groupTracker.run(1) }, WithMutexGroup("db"))
+		pool.Queue(func() { otherTracker.run(1) })
+	}
+
+	pool.Wait()
+
+	assert.Equal(t, uint(1), groupTracker.max)
+	assert.Greater(t, otherTracker.max, uint(1))
+}
@@ -29,3 +29,7 @@ func (s Set[T]) Contains(v T) bool {
 	_, exists := s[v]
 	return exists
 }
+
+func (s Set[T]) Remove(v T) {
+	delete(s, v)
+}
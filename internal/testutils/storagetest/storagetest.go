@@ -0,0 +1,805 @@
+// Package storagetest provides a test suite that verifies that a
+// storage.Storer implementation behaves as specified by the interface.
+package storagetest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+// NewClientFn returns a client for an empty storage, without a baur schema.
+// Resources of the client must be released via t.Cleanup.
+type NewClientFn func(t *testing.T) storage.Storer
+
+// Run runs the test suite against the storage clients returned by newClient.
+// Every test uses a new client.
+func Run(t *testing.T, newClient NewClientFn) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, storage.Storer)
+	}{
+		{"InitTwiceReturnsErrExists", testInitTwiceReturnsErrExists},
+		{"IsCompatibleSchemaNotExist", testIsCompatibleSchemaNotExist},
+		{"IsCompatibleAfterInit", testIsCompatibleAfterInit},
+		{"UpgradeCreatesSchema", testUpgradeCreatesSchema},
+		{"SaveTaskRun", testSaveTaskRun},
+		{"LatestTaskRunByDigest", testLatestTaskRunByDigest},
+		{"LatestTaskRunByDigestIgnoresFailedRuns", testLatestTaskRunByDigestIgnoresFailedRuns},
+		{"LatestTaskRunByDigestReturnsErrNotExist", testLatestTaskRunByDigestReturnsErrNotExist},
+		{"TaskRunLog", testTaskRunLog},
+		{"TaskRun", testTaskRun},
+		{"TaskRunWithoutOutputWithoutVCS", testTaskRunWithoutOutputWithoutVCS},
+		{"ReturnErrNotExistForUnknownTaskRunID", testReturnErrNotExistForUnknownTaskRunID},
+		{"Inputs", testInputs},
+		{"Outputs", testOutputs},
+		{"TaskRuns", testTaskRuns},
+		{"TaskRunLeaseIsExclusive", testTaskRunLeaseIsExclusive},
+		{"ExpiredTaskRunLeaseCanBeAcquired", testExpiredTaskRunLeaseCanBeAcquired},
+		{"TaskRunJobQueue", testTaskRunJobQueue},
+		{"ExpiredTaskRunJobIsReclaimed", testExpiredTaskRunJobIsReclaimed},
+		{"TaskRunsDelete", testTaskRunsDelete},
+		{"AvgTaskRunDurations", testAvgTaskRunDurations},
+		{"Releases", testReleases},
+		{"ReleasesDelete", testReleasesDelete},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newClient(t))
+		})
+	}
+}
+
+func mustInit(t *testing.T, clt storage.Storer) {
+	t.Helper()
+	require.NoError(t, clt.Init(t.Context()))
+}
+
+// dropMonotonicTimevals drops the monotonic clock values from the timestamps
+// of tr and rounds them, to prevent that comparisons of local and retrieved
+// objects fail because of the monotonic clock value or minor timestamp
+// changes.
+func dropMonotonicTimevals(tr *storage.TaskRun) *storage.TaskRun {
+	tr.StartTimestamp = tr.StartTimestamp.Round(time.Millisecond)
+	tr.StopTimestamp = tr.StopTimestamp.Round(time.Millisecond)
+
+	return tr
+}
+
+func outputsDropMonotonicTimevals(outputs []*storage.Output) []*storage.Output {
+	for _, o := range outputs {
+		for _, upload := range o.Uploads {
+			upload.UploadStartTimestamp = upload.UploadStartTimestamp.Round(time.Millisecond)
+			upload.UploadStopTimestamp = upload.UploadStopTimestamp.Round(time.Millisecond)
+		}
+	}
+
+	return outputs
+}
+
+func newTaskRun() *storage.TaskRunFull {
+	return &storage.TaskRunFull{
+		TaskRun: storage.TaskRun{
+			ApplicationName:  "baurHimself",
+			TaskName:         "build",
+			VCSRevision:      "1",
+			VCSIsDirty:       false,
+			StartTimestamp:   time.Now(),
+			StopTimestamp:    time.Now().Add(5 * time.Minute),
+			Result:           storage.ResultSuccess,
+			TotalInputDigest: "1234567890",
+		},
+		Inputs: storage.Inputs{
+			Files: []*storage.InputFile{
+				{
+					Path:   "main.go",
+					Digest: "45",
+				},
+			},
+		},
+		Outputs: []*storage.Output{
+			{
+				Name:      "binary",
+				Type:      storage.ArtifactTypeFile,
+				Digest:    "456",
+				SizeBytes: 300,
+				Uploads: []*storage.Upload{
+					{
+						URI:                  "file:///tmp/binary",
+						UploadStartTimestamp: time.Now(),
+						UploadStopTimestamp:  time.Now().Add(5 * time.Second),
+						Method:               storage.UploadMethodFileCopy,
+					},
+				},
+			},
+			{
+				Name:      "binary2",
+				Type:      storage.ArtifactTypeFile,
+				Digest:    "4561",
+				SizeBytes: 2,
+				Uploads: []*storage.Upload{
+					{
+						URI:                  "s3://tmp/test",
+						UploadStartTimestamp: time.Now(),
+						UploadStopTimestamp:  time.Now().Add(5 * time.Second),
+						Method:               storage.UploadMethodS3,
+					},
+					{
+						URI:                  "file://myftp.com/file.bin",
+						UploadStartTimestamp: time.Now(),
+						UploadStopTimestamp:  time.Now().Add(5 * time.Second),
+						Method:               storage.UploadMethodFileCopy,
+					},
+				},
+			},
+		},
+	}
+}
+
+func saveTaskRun(t *testing.T, clt storage.Storer, tr *storage.TaskRunFull) int {
+	t.Helper()
+
+	id, err := clt.SaveTaskRun(t.Context(), tr)
+	require.NoError(t, err)
+	require.Positive(t, id)
+
+	return id
+}
+
+func testInitTwiceReturnsErrExists(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+	require.ErrorIs(t, clt.Init(t.Context()), storage.ErrExists)
+}
+
+func testIsCompatibleSchemaNotExist(t *testing.T, clt storage.Storer) {
+	require.ErrorIs(t, clt.IsCompatible(t.Context()), storage.ErrNotExist)
+
+	_, err := clt.SchemaVersion(t.Context())
+	require.ErrorIs(t, err, storage.ErrNotExist)
+}
+
+func testIsCompatibleAfterInit(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+	require.NoError(t, clt.IsCompatible(t.Context()))
+
+	ver, err := clt.SchemaVersion(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, clt.MaxSchemaVersion(), ver)
+}
+
+func testUpgradeCreatesSchema(t *testing.T, clt storage.Storer) {
+	require.NoError(t, clt.Upgrade(t.Context()))
+	require.NoError(t, clt.IsCompatible(t.Context()))
+
+	// upgrading a current schema is a no-op
+	require.NoError(t, clt.Upgrade(t.Context()))
+	require.NoError(t, clt.IsCompatible(t.Context()))
+}
+
+func testSaveTaskRun(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	id1 := saveTaskRun(t, clt, newTaskRun())
+	// records that already exist, like the app, task and inputs, are
+	// reused
+	id2 := saveTaskRun(t, clt, newTaskRun())
+	assert.NotEqual(t, id1, id2)
+}
+
+func testLatestTaskRunByDigest(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	run1 := newTaskRun()
+	run2 := newTaskRun()
+	run2.StopTimestamp = run2.StopTimestamp.Add(time.Second)
+
+	saveTaskRun(t, clt, run1)
+	id := saveTaskRun(t, clt, run2)
+
+	latestTaskRun, err := clt.LatestTaskRunByDigest(t.Context(), run2.ApplicationName, run2.TaskName, run2.TotalInputDigest)
+	require.NoError(t, err)
+
+	assert.Equal(t, id, latestTaskRun.ID, "wrong record id")
+	assert.Equal(t, dropMonotonicTimevals(&run2.TaskRun), dropMonotonicTimevals(&latestTaskRun.TaskRun))
+}
+
+func testLatestTaskRunByDigestIgnoresFailedRuns(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	successfulRun := newTaskRun()
+	failedRun := newTaskRun()
+	failedRun.StopTimestamp = failedRun.StopTimestamp.Add(time.Second)
+	failedRun.Result = storage.ResultFailure
+	failedRun.ExitCode = 2
+	failedRun.Attempts = 3
+
+	successfulID := saveTaskRun(t, clt, successfulRun)
+	failedID := saveTaskRun(t, clt, failedRun)
+
+	latestTaskRun, err := clt.LatestTaskRunByDigest(t.Context(), failedRun.ApplicationName, failedRun.TaskName, failedRun.TotalInputDigest)
+	require.NoError(t, err)
+	assert.Equal(t, successfulID, latestTaskRun.ID, "wrong record id")
+
+	failedTaskRun, err := clt.TaskRun(t.Context(), failedID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.ResultFailure, failedTaskRun.Result)
+	assert.Equal(t, 2, failedTaskRun.ExitCode)
+	assert.Equal(t, 3, failedTaskRun.Attempts)
+}
+
+func testLatestTaskRunByDigestReturnsErrNotExist(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	taskRun, err := clt.LatestTaskRunByDigest(t.Context(), "myapp", "mytask", "241abc")
+	require.ErrorIs(t, err, storage.ErrNotExist)
+	assert.Nil(t, taskRun)
+}
+
+func testTaskRunLog(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	run := newTaskRun()
+	idWithoutLog := saveTaskRun(t, clt, run)
+
+	run.Log = &storage.TaskRunLog{Content: []byte("building...\ndone\n")}
+	idWithLog := saveTaskRun(t, clt, run)
+
+	log, err := clt.TaskRunLog(t.Context(), idWithLog)
+	require.NoError(t, err)
+	assert.Equal(t, run.Log.Content, log.Content)
+	assert.Empty(t, log.URL)
+
+	run.Log = &storage.TaskRunLog{URL: "s3://bucket/build.log"}
+	idWithUploadedLog := saveTaskRun(t, clt, run)
+
+	log, err = clt.TaskRunLog(t.Context(), idWithUploadedLog)
+	require.NoError(t, err)
+	assert.Empty(t, log.Content)
+	assert.Equal(t, run.Log.URL, log.URL)
+
+	_, err = clt.TaskRunLog(t.Context(), idWithoutLog)
+	require.ErrorIs(t, err, storage.ErrNotExist)
+}
+
+func testTaskRun(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	run := newTaskRun()
+	id := saveTaskRun(t, clt, run)
+
+	taskRun, err := clt.TaskRun(t.Context(), id)
+	require.NoError(t, err)
+
+	assert.Equal(t, id, taskRun.ID)
+	assert.Equal(t, dropMonotonicTimevals(&run.TaskRun), dropMonotonicTimevals(&taskRun.TaskRun))
+}
+
+func testTaskRunWithoutOutputWithoutVCS(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	run := newTaskRun()
+	run.VCSRevision = ""
+	run.Outputs = nil
+	dropMonotonicTimevals(&run.TaskRun)
+
+	id := saveTaskRun(t, clt, run)
+
+	tr, err := clt.TaskRun(t.Context(), id)
+	require.NoError(t, err)
+	assert.Equal(t, run.TaskRun, *dropMonotonicTimevals(&tr.TaskRun))
+
+	_, err = clt.Outputs(t.Context(), id)
+	require.ErrorIs(t, err, storage.ErrNotExist)
+}
+
+func testReturnErrNotExistForUnknownTaskRunID(t *testing.T, clt storage.Storer) {
+	const id = 113124
+
+	mustInit(t, clt)
+
+	taskRun, err := clt.TaskRun(t.Context(), id)
+	require.ErrorIs(t, err, storage.ErrNotExist)
+	assert.Nil(t, taskRun)
+
+	inputs, err := clt.Inputs(t.Context(), id)
+	require.ErrorIs(t, err, storage.ErrNotExist)
+	assert.Nil(t, inputs)
+
+	outputs, err := clt.Outputs(t.Context(), id)
+	require.ErrorIs(t, err, storage.ErrNotExist)
+	assert.Nil(t, outputs)
+}
+
+func testInputs(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	run := newTaskRun()
+	run.Inputs = storage.Inputs{
+		Files: []*storage.InputFile{
+			{Path: "main.go", Digest: "45"},
+			{Path: "util.go", Digest: "46"},
+			{Path: "file://Makefile", Digest: "47"},
+		},
+		Strings: []*storage.InputString{
+			{String: "hello", Digest: "45"},
+			{String: "bye", Digest: "46"},
+		},
+		EnvironmentVariables: []*storage.InputEnvVar{
+			{Name: "VER", Digest: "45"},
+			{Name: "MYNUMBER", Digest: "9"},
+		},
+		TaskInfo: []*storage.InputTaskInfo{
+			{Name: "shop.build", Digest: "48"},
+		},
+	}
+
+	id := saveTaskRun(t, clt, run)
+
+	inputs, err := clt.Inputs(t.Context(), id)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, run.Inputs.Files, inputs.Files)
+	assert.ElementsMatch(t, run.Inputs.Strings, inputs.Strings)
+	assert.ElementsMatch(t, run.Inputs.EnvironmentVariables, inputs.EnvironmentVariables)
+	assert.ElementsMatch(t, run.Inputs.TaskInfo, inputs.TaskInfo)
+}
+
+func testOutputs(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	run := newTaskRun()
+	id := saveTaskRun(t, clt, run)
+
+	outputs, err := clt.Outputs(t.Context(), id)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, outputsDropMonotonicTimevals(run.Outputs), outputsDropMonotonicTimevals(outputs))
+}
+
+func testTaskRuns(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	run := newTaskRun()
+	run.Inputs.Strings = []*storage.InputString{{String: "hello", Digest: "1"}}
+	dropMonotonicTimevals(&run.TaskRun)
+	id := saveTaskRun(t, clt, run)
+
+	run1 := newTaskRun()
+	run1.StartTimestamp = run.StartTimestamp.Add(-time.Second)
+	run1.StopTimestamp = run.StopTimestamp
+	run1.TaskName = "check"
+	run1.Inputs.Files = []*storage.InputFile{{Path: "check.go", Digest: "2"}}
+	dropMonotonicTimevals(&run1.TaskRun)
+	id1 := saveTaskRun(t, clt, run1)
+
+	expected := &storage.TaskRunWithID{ID: id, TaskRun: run.TaskRun}
+	expected1 := &storage.TaskRunWithID{ID: id1, TaskRun: run1.TaskRun}
+
+	testcases := []*struct {
+		name    string
+		filters []*storage.Filter
+		sorters []*storage.Sorter
+		limit   uint
+
+		expectedTaskRuns []*storage.TaskRunWithID
+		expectOrder      bool
+		expectedError    error
+	}{
+		{
+			name: "EqAppNameAndTaskName",
+			filters: []*storage.Filter{
+				{Field: storage.FieldTaskName, Operator: storage.OpEQ, Value: run1.TaskName},
+				{Field: storage.FieldApplicationName, Operator: storage.OpEQ, Value: run1.ApplicationName},
+			},
+			expectedTaskRuns: []*storage.TaskRunWithID{expected1},
+		},
+		{
+			name: "INAppNames",
+			filters: []*storage.Filter{
+				{Field: storage.FieldApplicationName, Operator: storage.OpIN, Value: []string{run1.ApplicationName, "testApp"}},
+			},
+			expectedTaskRuns: []*storage.TaskRunWithID{expected, expected1},
+		},
+		{
+			name: "INIDs",
+			filters: []*storage.Filter{
+				{Field: storage.FieldID, Operator: storage.OpIN, Value: []int{id1}},
+			},
+			expectedTaskRuns: []*storage.TaskRunWithID{expected1},
+		},
+		{
+			name: "InputString",
+			filters: []*storage.Filter{
+				{Field: storage.FieldInputString, Operator: storage.OpEQ, Value: "hello"},
+			},
+			expectedTaskRuns: []*storage.TaskRunWithID{expected},
+		},
+		{
+			name: "InputFilePath",
+			filters: []*storage.Filter{
+				{Field: storage.FieldInputFilePath, Operator: storage.OpEQ, Value: "check.go"},
+			},
+			expectedTaskRuns: []*storage.TaskRunWithID{expected1},
+		},
+		{
+			name: "StartTimeGT",
+			filters: []*storage.Filter{
+				{Field: storage.FieldStartTime, Operator: storage.OpGT, Value: run1.StartTimestamp},
+			},
+			expectedTaskRuns: []*storage.TaskRunWithID{expected},
+		},
+		{
+			name: "OrderByDurationAsc",
+			sorters: []*storage.Sorter{
+				{Field: storage.FieldDuration, Order: storage.OrderAsc},
+			},
+			expectedTaskRuns: []*storage.TaskRunWithID{expected, expected1},
+			expectOrder:      true,
+		},
+		{
+			name: "OrderByDurationDesc",
+			sorters: []*storage.Sorter{
+				{Field: storage.FieldDuration, Order: storage.OrderDesc},
+			},
+			expectedTaskRuns: []*storage.TaskRunWithID{expected1, expected},
+			expectOrder:      true,
+		},
+		{
+			name: "OrderByStartTimeWithLimit",
+			filters: []*storage.Filter{
+				{Field: storage.FieldResult, Operator: storage.OpEQ, Value: storage.ResultSuccess},
+			},
+			sorters: []*storage.Sorter{
+				{Field: storage.FieldStartTime, Order: storage.OrderDesc},
+			},
+			limit:            1,
+			expectedTaskRuns: []*storage.TaskRunWithID{expected},
+			expectOrder:      true,
+		},
+		{
+			name: "NoMatch",
+			filters: []*storage.Filter{
+				{Field: storage.FieldID, Operator: storage.OpEQ, Value: -500},
+			},
+			expectedError: storage.ErrNotExist,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var result []*storage.TaskRunWithID
+
+			err := clt.TaskRuns(t.Context(), tc.filters, tc.sorters, tc.limit, func(tr *storage.TaskRunWithID) error {
+				dropMonotonicTimevals(&tr.TaskRun)
+				result = append(result, tr)
+				return nil
+			})
+			if tc.expectedError != nil {
+				require.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			if tc.expectOrder {
+				assert.Equal(t, tc.expectedTaskRuns, result)
+				return
+			}
+
+			assert.ElementsMatch(t, tc.expectedTaskRuns, result)
+		})
+	}
+}
+
+func testTaskRunLeaseIsExclusive(t *testing.T, clt storage.Storer) {
+	ctx := t.Context()
+	mustInit(t, clt)
+
+	require.NoError(t, clt.AcquireTaskRunLease(ctx, "app", "build", "123", "holder1", time.Minute))
+	require.ErrorIs(t, clt.AcquireTaskRunLease(ctx, "app", "build", "123", "holder2", time.Minute), storage.ErrExists)
+
+	// leases of other digests are independent
+	require.NoError(t, clt.AcquireTaskRunLease(ctx, "app", "build", "456", "holder2", time.Minute))
+
+	// acquiring an own lease renews it
+	require.NoError(t, clt.AcquireTaskRunLease(ctx, "app", "build", "123", "holder1", time.Minute))
+	require.NoError(t, clt.RenewTaskRunLease(ctx, "app", "build", "123", "holder1", time.Minute))
+	require.ErrorIs(t, clt.RenewTaskRunLease(ctx, "app", "build", "123", "holder2", time.Minute), storage.ErrNotExist)
+
+	require.ErrorIs(t, clt.ReleaseTaskRunLease(ctx, "app", "build", "123", "holder2"), storage.ErrNotExist)
+	require.NoError(t, clt.ReleaseTaskRunLease(ctx, "app", "build", "123", "holder1"))
+
+	require.NoError(t, clt.AcquireTaskRunLease(ctx, "app", "build", "123", "holder2", time.Minute))
+}
+
+func testExpiredTaskRunLeaseCanBeAcquired(t *testing.T, clt storage.Storer) {
+	ctx := t.Context()
+	mustInit(t, clt)
+
+	require.NoError(t, clt.AcquireTaskRunLease(ctx, "app", "build", "123", "holder1", time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	require.NoError(t, clt.AcquireTaskRunLease(ctx, "app", "build", "123", "holder2", time.Minute))
+	require.ErrorIs(t, clt.RenewTaskRunLease(ctx, "app", "build", "123", "holder1", time.Minute), storage.ErrNotExist)
+}
+
+func testTaskRunJobQueue(t *testing.T, clt storage.Storer) {
+	ctx := t.Context()
+	mustInit(t, clt)
+
+	ids, err := clt.EnqueueTaskRunJobs(ctx, []*storage.TaskRunJob{
+		{ApplicationName: "app", TaskName: "build", VCSRevision: "abc", TotalInputDigest: "1"},
+		{ApplicationName: "app", TaskName: "check", VCSRevision: "abc", TotalInputDigest: "2"},
+		{ApplicationName: "app", TaskName: "lint", VCSRevision: "abc", TotalInputDigest: "3"},
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+
+	job, err := clt.ClaimTaskRunJob(ctx, "worker1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ids[0], job.ID)
+	assert.Equal(t, "build", job.TaskName)
+	assert.Equal(t, storage.JobStateRunning, job.State)
+	assert.Equal(t, "worker1", job.Worker)
+
+	job, err = clt.ClaimTaskRunJob(ctx, "worker2", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ids[1], job.ID)
+
+	canceled, err := clt.CancelTaskRunJobs(ctx, ids)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), canceled)
+
+	_, err = clt.ClaimTaskRunJob(ctx, "worker1", time.Minute)
+	require.ErrorIs(t, err, storage.ErrNotExist)
+
+	taskRunID := saveTaskRun(t, clt, newTaskRun())
+	require.NoError(t, clt.RenewTaskRunJob(ctx, ids[0], "worker1", time.Minute))
+	require.ErrorIs(t, clt.RenewTaskRunJob(ctx, ids[0], "worker2", time.Minute), storage.ErrNotExist)
+	require.ErrorIs(t, clt.FinishTaskRunJob(ctx, ids[0], "worker2", storage.JobStateSuccess, taskRunID, ""), storage.ErrNotExist)
+	require.NoError(t, clt.FinishTaskRunJob(ctx, ids[0], "worker1", storage.JobStateSuccess, taskRunID, ""))
+	require.NoError(t, clt.FinishTaskRunJob(ctx, ids[1], "worker2", storage.JobStateError, 0, "digest mismatch"))
+	require.ErrorIs(t, clt.FinishTaskRunJob(ctx, ids[1], "worker2", storage.JobStateSuccess, 0, ""), storage.ErrNotExist)
+	require.ErrorIs(t, clt.RenewTaskRunJob(ctx, ids[1], "worker2", time.Minute), storage.ErrNotExist)
+	require.Error(t, clt.FinishTaskRunJob(ctx, ids[2], "worker1", storage.JobStateRunning, 0, ""))
+
+	jobs, err := clt.TaskRunJobs(ctx, ids)
+	require.NoError(t, err)
+	require.Len(t, jobs, 3)
+	assert.Equal(t, storage.JobStateSuccess, jobs[0].State)
+	assert.Equal(t, taskRunID, jobs[0].TaskRunID)
+	assert.Equal(t, storage.JobStateError, jobs[1].State)
+	assert.Equal(t, "digest mismatch", jobs[1].Error)
+	assert.Equal(t, "worker2", jobs[1].Worker)
+	assert.Zero(t, jobs[1].TaskRunID)
+	assert.Equal(t, storage.JobStateCanceled, jobs[2].State)
+}
+
+func testExpiredTaskRunJobIsReclaimed(t *testing.T, clt storage.Storer) {
+	ctx := t.Context()
+	mustInit(t, clt)
+
+	ids, err := clt.EnqueueTaskRunJobs(ctx, []*storage.TaskRunJob{
+		{ApplicationName: "app", TaskName: "build", VCSRevision: "abc", TotalInputDigest: "1"},
+	})
+	require.NoError(t, err)
+
+	job, err := clt.ClaimTaskRunJob(ctx, "worker1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ids[0], job.ID)
+
+	_, err = clt.ClaimTaskRunJob(ctx, "worker2", time.Minute)
+	require.ErrorIs(t, err, storage.ErrNotExist)
+
+	require.NoError(t, clt.RenewTaskRunJob(ctx, ids[0], "worker1", time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	job, err = clt.ClaimTaskRunJob(ctx, "worker2", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ids[0], job.ID)
+	assert.Equal(t, storage.JobStateRunning, job.State)
+	assert.Equal(t, "worker2", job.Worker)
+
+	_, err = clt.ClaimTaskRunJob(ctx, "worker3", time.Minute)
+	require.ErrorIs(t, err, storage.ErrNotExist)
+
+	require.ErrorIs(t, clt.RenewTaskRunJob(ctx, ids[0], "worker1", time.Minute), storage.ErrNotExist)
+	require.ErrorIs(t, clt.FinishTaskRunJob(ctx, ids[0], "worker1", storage.JobStateError, 0, ""), storage.ErrNotExist)
+	require.NoError(t, clt.FinishTaskRunJob(ctx, ids[0], "worker2", storage.JobStateSuccess, 0, ""))
+}
+
+func testTaskRunsDelete(t *testing.T, clt storage.Storer) {
+	ctx := t.Context()
+	mustInit(t, clt)
+
+	tr := newTaskRun()
+	tr.StartTimestamp = time.Now().Add(-time.Minute)
+	tr.Inputs.Files = append(tr.Inputs.Files, &storage.InputFile{Path: "abc.go", Digest: "01"})
+	saveTaskRun(t, clt, tr)
+
+	checkResultFn := func(result *storage.TaskRunsDeleteResult) {
+		t.Helper()
+
+		require.NotNil(t, result)
+		assert.Equal(t, int64(1), result.DeletedTaskRuns)
+		assert.Equal(t, int64(1), result.DeletedVCS)
+		assert.Equal(t, int64(1), result.DeletedApps)
+		assert.Equal(t, int64(1), result.DeletedTasks)
+		assert.Equal(t, int64(2), result.DeletedInputs)
+		assert.Equal(t, int64(2), result.DeletedOutputs)
+		assert.Equal(t, int64(3), result.DeletedUploads)
+	}
+
+	result, err := clt.TaskRunsDelete(ctx, time.Now(), true)
+	require.NoError(t, err)
+	checkResultFn(result)
+
+	result, err = clt.TaskRunsDelete(ctx, time.Now(), false)
+	require.NoError(t, err)
+	checkResultFn(result)
+
+	err = clt.TaskRuns(ctx, nil, nil, storage.NoLimit, func(*storage.TaskRunWithID) error { return nil })
+	require.ErrorIs(t, err, storage.ErrNotExist)
+}
+
+func testAvgTaskRunDurations(t *testing.T, clt storage.Storer) {
+	mustInit(t, clt)
+
+	stoppedBefore := time.Now()
+	startTime := stoppedBefore.Add(-time.Hour)
+
+	saveRun := func(appName string, duration time.Duration, result storage.Result, start time.Time) {
+		t.Helper()
+
+		tr := newTaskRun()
+		tr.ApplicationName = appName
+		tr.StartTimestamp = start
+		tr.StopTimestamp = start.Add(duration)
+		tr.Result = result
+		saveTaskRun(t, clt, tr)
+	}
+
+	for _, d := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 4 * time.Minute} {
+		saveRun("app1", d, storage.ResultSuccess, startTime)
+	}
+	saveRun("app1", 10*time.Minute, storage.ResultFailure, startTime)
+	saveRun("app1", 20*time.Minute, storage.ResultSuccess, stoppedBefore)
+	saveRun("app2", 6*time.Minute, storage.ResultSuccess, startTime)
+	saveRun("app3", 7*time.Minute, storage.ResultSuccess, startTime)
+
+	durations, err := clt.AvgTaskRunDurations(t.Context(), []string{"app1", "app2"}, stoppedBefore, 3)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(
+		t,
+		[]*storage.TaskRunDuration{
+			{ApplicationName: "app1", TaskName: "build", AvgDuration: 3 * time.Minute},
+			{ApplicationName: "app2", TaskName: "build", AvgDuration: 6 * time.Minute},
+		},
+		durations,
+	)
+
+	durations, err = clt.AvgTaskRunDurations(t.Context(), []string{"unknown"}, stoppedBefore, 3)
+	require.NoError(t, err)
+	assert.Empty(t, durations)
+}
+
+func testReleases(t *testing.T, clt storage.Storer) {
+	ctx := t.Context()
+	mustInit(t, clt)
+
+	run := newTaskRun()
+	id1 := saveTaskRun(t, clt, run)
+
+	runWithoutOutputs := newTaskRun()
+	runWithoutOutputs.TaskName = "check"
+	runWithoutOutputs.Outputs = nil
+	id2 := saveTaskRun(t, clt, runWithoutOutputs)
+
+	exists, err := clt.ReleaseExists(ctx, "v1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	metadata := []byte("changelog")
+	require.NoError(t, clt.CreateRelease(ctx, "v1", time.Now(), []int{id1, id2}, bytes.NewReader(metadata)))
+	require.ErrorIs(t, clt.CreateRelease(ctx, "v1", time.Now(), []int{id1}, nil), storage.ErrExists)
+	require.NoError(t, clt.CreateRelease(ctx, "v2", time.Now(), []int{id2}, nil))
+
+	exists, err = clt.ReleaseExists(ctx, "v1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	result, err := clt.ReleaseTaskRuns(ctx, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, []*storage.ReleaseTaskRunsResult{
+		{
+			AppName:      run.ApplicationName,
+			TaskName:     run.TaskName,
+			RunID:        id1,
+			OutputID:     result[0].OutputID,
+			OutputName:   "binary",
+			OutputType:   storage.ArtifactTypeFile,
+			URI:          "file:///tmp/binary",
+			UploadMethod: storage.UploadMethodFileCopy,
+		},
+		{
+			AppName:      run.ApplicationName,
+			TaskName:     run.TaskName,
+			RunID:        id1,
+			OutputID:     result[1].OutputID,
+			OutputName:   "binary2",
+			OutputType:   storage.ArtifactTypeFile,
+			URI:          "file://myftp.com/file.bin",
+			UploadMethod: storage.UploadMethodFileCopy,
+		},
+		{
+			AppName:      run.ApplicationName,
+			TaskName:     run.TaskName,
+			RunID:        id1,
+			OutputID:     result[2].OutputID,
+			OutputName:   "binary2",
+			OutputType:   storage.ArtifactTypeFile,
+			URI:          "s3://tmp/test",
+			UploadMethod: storage.UploadMethodS3,
+		},
+		{
+			AppName:  runWithoutOutputs.ApplicationName,
+			TaskName: runWithoutOutputs.TaskName,
+			RunID:    id2,
+		},
+	}, result)
+
+	_, err = clt.ReleaseTaskRuns(ctx, "v3")
+	require.ErrorIs(t, err, storage.ErrNotExist)
+
+	data, err := clt.ReleaseMetadata(ctx, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, metadata, data)
+
+	data, err = clt.ReleaseMetadata(ctx, "v2")
+	require.NoError(t, err)
+	assert.Empty(t, data)
+
+	_, err = clt.ReleaseMetadata(ctx, "v3")
+	require.ErrorIs(t, err, storage.ErrNotExist)
+}
+
+func testReleasesDelete(t *testing.T, clt storage.Storer) {
+	ctx := t.Context()
+	mustInit(t, clt)
+
+	id := saveTaskRun(t, clt, newTaskRun())
+	createdAt := time.Now().Add(-time.Hour)
+	require.NoError(t, clt.CreateRelease(ctx, "v1", createdAt, []int{id}, nil))
+	require.NoError(t, clt.CreateRelease(ctx, "v2", time.Now(), []int{id}, nil))
+
+	// task runs that are part of a release are not deleted
+	trResult, err := clt.TaskRunsDelete(ctx, time.Now(), false)
+	require.NoError(t, err)
+	assert.Zero(t, trResult.DeletedTaskRuns)
+
+	result, err := clt.ReleasesDelete(ctx, createdAt.Add(time.Minute), true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.DeletedReleases)
+
+	exists, err := clt.ReleaseExists(ctx, "v1")
+	require.NoError(t, err)
+	assert.True(t, exists, "release was deleted in pretend mode")
+
+	result, err = clt.ReleasesDelete(ctx, createdAt.Add(time.Minute), false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.DeletedReleases)
+
+	exists, err = clt.ReleaseExists(ctx, "v1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = clt.ReleaseExists(ctx, "v2")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
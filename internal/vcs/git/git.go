@@ -10,7 +10,9 @@ import (
 	"os"
 	stdexec "os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/simplesurance/baur/v5/internal/exec"
 	"github.com/simplesurance/baur/v5/internal/fs"
@@ -55,6 +57,46 @@ func CommitID(dir string) (string, error) {
 	return commitID, err
 }
 
+// GitDir returns the absolute path of the .git directory of the repository
+// that contains dir.
+func GitDir(ctx context.Context, dir string) (string, error) {
+	res, err := exec.Command("git", "rev-parse", "--absolute-git-dir").Directory(dir).ExpectSuccess().RunCombinedOut(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	gitDir := strings.TrimSpace(res.StrOutput())
+	if len(gitDir) == 0 {
+		return "", errors.New("executing git rev-parse --absolute-git-dir failed, no Stdout output")
+	}
+
+	return gitDir, nil
+}
+
+// CommitTime returns the committer date of HEAD by running git show in the
+// passed directory.
+func CommitTime(ctx context.Context, dir string) (time.Time, error) {
+	res, err := exec.Command("git", "show", "-s", "--format=%ct", "HEAD").Directory(dir).ExpectSuccess().RunCombinedOut(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	out := strings.TrimSpace(res.StrOutput())
+	sec, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing output of git show failed, %q is not a unix timestamp: %w", out, err)
+	}
+
+	return time.Unix(sec, 0), nil
+}
+
+// Checkout checks out the commit rev in the passed directory, HEAD is
+// detached.
+func Checkout(ctx context.Context, dir, rev string) error {
+	_, err := exec.Command("git", "checkout", "--quiet", "--detach", rev).Directory(dir).ExpectSuccess().RunCombinedOut(ctx)
+	return err
+}
+
 // WorktreeIsDirty returns true if the repository contains modified files,
 // untracked files are considered, files in .gitignore are ignored
 func WorktreeIsDirty(dir string) (bool, error) {
@@ -112,6 +154,180 @@ func UntrackedFiles(dir string) ([]string, error) {
 	return res, nil
 }
 
+// ChangedFiles returns the paths of files that were changed since the
+// merge-base of ref and HEAD. Changes of commits on the HEAD side, uncommitted
+// changes of tracked files and untracked files that are not ignored are
+// included. Renamed files are reported with their old and new path.
+// The returned paths are relative to dir, changes of files outside of dir are
+// ignored.
+func ChangedFiles(ctx context.Context, dir, ref string) ([]string, error) {
+	res, err := exec.Command("git", "merge-base", ref, "HEAD").Directory(dir).ExpectSuccess().RunCombinedOut(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mergeBase := strings.TrimSpace(res.StrOutput())
+
+	// without a second commit, git diff compares the commit with the
+	// worktree
+	changed, err := nullTerminatedOutput(ctx, dir,
+		"git", "diff",
+		"--name-only",
+		"--no-renames",
+		"--relative",
+		"-z",
+		mergeBase,
+		"--",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := nullTerminatedOutput(ctx, dir,
+		"git", "ls-files",
+		"--others",
+		"--exclude-standard",
+		"-z",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(changed, untracked...), nil
+}
+
+// nullTerminatedOutput runs the command in dir and returns the
+// null-terminated paths that it prints to stdout.
+func nullTerminatedOutput(ctx context.Context, dir, name string, args ...string) ([]string, error) {
+	var stdout bytes.Buffer
+
+	_, err := exec.Command(name, args...).
+		Directory(dir).
+		ExpectSuccess().
+		Stdout(&stdout).
+		Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	sc := bufio.NewScanner(&stdout)
+	sc.Split(scanNullTerminatedLines)
+	for sc.Scan() {
+		res = append(res, filepath.FromSlash(sc.Text()))
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("scanning output of %s failed: %w", name+" "+args[0], err)
+	}
+
+	return res, nil
+}
+
+// ObjectFormat returns the name of the hash algorithm that the repository
+// containing dir uses for object IDs, "sha1" or "sha256".
+func ObjectFormat(ctx context.Context, dir string) (string, error) {
+	res, err := exec.Command("git", "rev-parse", "--show-object-format").Directory(dir).ExpectSuccess().RunCombinedOut(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	format := strings.TrimSpace(res.StrOutput())
+	if len(format) == 0 {
+		return "", errors.New("executing git rev-parse --show-object-format failed, no Stdout output")
+	}
+
+	return format, nil
+}
+
+// ContentConversionsConfigured returns true if git might convert the content
+// of files in the repository containing dir when it calculates their object
+// IDs.
+// This is the case when core.autocrlf is enabled or when gitattributes exist,
+// they can configure end-of-line conversions and filters.
+func ContentConversionsConfigured(ctx context.Context, dir string) (bool, error) {
+	autocrlf, err := configValue(ctx, dir, "core.autocrlf")
+	if err != nil {
+		return false, err
+	}
+	if autocrlf != "" && autocrlf != "false" {
+		return true, nil
+	}
+
+	attributeFiles, err := attributeFilePaths(ctx, dir)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range attributeFiles {
+		if fs.FileExists(p) {
+			return true, nil
+		}
+	}
+
+	res, err := exec.Command(
+		"git", "ls-files", "-z", "--cached", "--others", "--exclude-standard",
+		"--", ":(top,glob)**/.gitattributes",
+	).Directory(dir).ExpectSuccess().RunCombinedOut(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return len(res.CombinedOutput) > 0, nil
+}
+
+// attributeFilePaths returns the paths of the global and the repository
+// specific gitattributes files.
+func attributeFilePaths(ctx context.Context, dir string) ([]string, error) {
+	globalFile, err := configValue(ctx, dir, "core.attributesFile")
+	if err != nil {
+		return nil, err
+	}
+
+	if globalFile == "" {
+		if xdgCfgDir := os.Getenv("XDG_CONFIG_HOME"); xdgCfgDir != "" {
+			globalFile = filepath.Join(xdgCfgDir, "git", "attributes")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			globalFile = filepath.Join(home, ".config", "git", "attributes")
+		}
+	}
+
+	res, err := exec.Command("git", "rev-parse", "--git-path", "info/attributes").Directory(dir).ExpectSuccess().RunCombinedOut(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	repoFile := strings.TrimSpace(res.StrOutput())
+	if !filepath.IsAbs(repoFile) {
+		repoFile = filepath.Join(dir, repoFile)
+	}
+
+	if globalFile == "" {
+		return []string{repoFile}, nil
+	}
+
+	return []string{globalFile, repoFile}, nil
+}
+
+// configValue returns the value of the git config option with the given name.
+// If the option is not set, an empty string is returned.
+func configValue(ctx context.Context, dir, name string) (string, error) {
+	res, err := exec.Command("git", "config", "--path", "--get", name).Directory(dir).RunCombinedOut(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// exit code 1 means that the option is not set
+	if res.ExitCode == 1 {
+		return "", nil
+	}
+
+	if err := res.ExpectSuccess(); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(res.StrOutput()), nil
+}
+
 // ObjectID calculates the git ID (hash) of a fille.
 func ObjectID(ctx context.Context, absFilePath, repoRelFilePath string) (string, error) {
 	// TODO: calculate the object ID instead of running an external command
@@ -2,9 +2,11 @@ package git
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/simplesurance/baur/v5/internal/exec"
@@ -103,3 +105,140 @@ func TestUntrackedFilesDoesNotContainTrackedFile(t *testing.T) {
 
 	require.Empty(t, untrackedFiles)
 }
+
+func TestChangedFiles(t *testing.T) {
+	log.RedirectToTestingLog(t)
+	oldExecDebugFfN := exec.DefaultLogFn
+	exec.DefaultLogFn = t.Logf
+	t.Cleanup(func() {
+		exec.DefaultLogFn = oldExecDebugFfN
+	})
+
+	tempDir := t.TempDir()
+
+	gittest.CreateRepository(t, tempDir)
+
+	fstest.WriteToFile(t, []byte("1"), filepath.Join(tempDir, "f1"))
+	fstest.WriteToFile(t, []byte("2"), filepath.Join(tempDir, "f2"))
+	gittest.CommitFilesToGit(t, tempDir)
+
+	_, err := exec.Command("git", "tag", "base").Directory(tempDir).ExpectSuccess().Run(t.Context())
+	require.NoError(t, err)
+
+	fstest.WriteToFile(t, []byte("changed"), filepath.Join(tempDir, "f1"))
+	fstest.WriteToFile(t, []byte("new"), filepath.Join(tempDir, "sub", "f3"))
+	gittest.CommitFilesToGit(t, tempDir)
+
+	changed, err := ChangedFiles(t.Context(), tempDir, "base")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"f1", filepath.Join("sub", "f3")}, changed)
+
+	fstest.WriteToFile(t, []byte("uncommitted"), filepath.Join(tempDir, "f2"))
+	fstest.WriteToFile(t, []byte("untracked"), filepath.Join(tempDir, "sub", "f4"))
+	fstest.WriteToFile(t, []byte("ignored.txt"), filepath.Join(tempDir, ".gitignore"))
+	fstest.WriteToFile(t, []byte("ignored"), filepath.Join(tempDir, "ignored.txt"))
+
+	changed, err = ChangedFiles(t.Context(), tempDir, "base")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"f1", "f2", ".gitignore", filepath.Join("sub", "f3"), filepath.Join("sub", "f4")}, changed)
+
+	changed, err = ChangedFiles(t.Context(), filepath.Join(tempDir, "sub"), "base")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"f3", "f4"}, changed)
+}
+
+func TestChangedFilesFailsOnInvalidRef(t *testing.T) {
+	tempDir := t.TempDir()
+
+	gittest.CreateRepository(t, tempDir)
+	fstest.WriteToFile(t, []byte("1"), filepath.Join(tempDir, "f1"))
+	gittest.CommitFilesToGit(t, tempDir)
+
+	_, err := ChangedFiles(t.Context(), tempDir, "doesnotexist")
+	require.Error(t, err)
+}
+
+func TestCommitTime(t *testing.T) {
+	tempDir := t.TempDir()
+
+	gittest.CreateRepository(t, tempDir)
+	fstest.WriteToFile(t, []byte("1"), filepath.Join(tempDir, "f1"))
+	gittest.CommitFilesToGit(t, tempDir)
+
+	_, err := exec.Command("git", "commit", "--amend", "--no-edit", "--date=@1700000000").
+		Directory(tempDir).
+		Env(append(os.Environ(), "GIT_COMMITTER_DATE=@1700000100")).
+		ExpectSuccess().
+		Run(t.Context())
+	require.NoError(t, err)
+
+	commitTime, err := CommitTime(t.Context(), tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1700000100), commitTime.Unix())
+}
+
+func TestCheckout(t *testing.T) {
+	tempDir := t.TempDir()
+
+	gittest.CreateRepository(t, tempDir)
+	fstest.WriteToFile(t, []byte("1"), filepath.Join(tempDir, "f1"))
+	gittest.CommitFilesToGit(t, tempDir)
+
+	firstCommit, err := CommitID(tempDir)
+	require.NoError(t, err)
+
+	fstest.WriteToFile(t, []byte("2"), filepath.Join(tempDir, "f1"))
+	gittest.CommitFilesToGit(t, tempDir)
+
+	require.NoError(t, Checkout(t.Context(), tempDir, firstCommit))
+
+	commitID, err := CommitID(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, firstCommit, commitID)
+}
+
+func TestGitDir(t *testing.T) {
+	tempDir := t.TempDir()
+	gittest.CreateRepository(t, tempDir)
+
+	subDir := filepath.Join(tempDir, "sub")
+	require.NoError(t, os.Mkdir(subDir, 0o755))
+
+	gitDir, err := GitDir(t.Context(), subDir)
+	require.NoError(t, err)
+
+	realTempDir, err := filepath.EvalSymlinks(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(realTempDir, ".git"), gitDir)
+}
+
+func TestContentConversionsConfigured(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+
+	tempDir := t.TempDir()
+	gittest.CreateRepository(t, tempDir)
+
+	configured, err := ContentConversionsConfigured(t.Context(), tempDir)
+	require.NoError(t, err)
+	assert.False(t, configured)
+
+	attrFile := filepath.Join(tempDir, "sub", ".gitattributes")
+	fstest.WriteToFile(t, []byte("*.txt text"), attrFile)
+	configured, err = ContentConversionsConfigured(t.Context(), tempDir)
+	require.NoError(t, err)
+	assert.True(t, configured)
+
+	require.NoError(t, os.Remove(attrFile))
+	fstest.WriteToFile(t, []byte("*.txt text"), filepath.Join(tempDir, ".git", "info", "attributes"))
+	configured, err = ContentConversionsConfigured(t.Context(), tempDir)
+	require.NoError(t, err)
+	assert.True(t, configured)
+
+	require.NoError(t, os.Remove(filepath.Join(tempDir, ".git", "info", "attributes")))
+	_, err = exec.Command("git", "config", "core.autocrlf", "input").Directory(tempDir).ExpectSuccess().RunCombinedOut(t.Context())
+	require.NoError(t, err)
+	configured, err = ContentConversionsConfigured(t.Context(), tempDir)
+	require.NoError(t, err)
+	assert.True(t, configured)
+}
@@ -92,6 +92,15 @@ func (h *TrackedObjects) createDb(ch <-chan *Object, finishedCh chan struct{}) {
 	h.info = m
 }
 
+// Forget removes the information about the files at absPaths.
+// Get returns ErrObjectNotFound for them afterwards.
+// It is used when the files were modified after the objects were loaded.
+func (h *TrackedObjects) Forget(absPaths ...string) {
+	for _, p := range absPaths {
+		delete(h.info, p)
+	}
+}
+
 // Get returns a TrackedObject for the file at absPath in the git repository.
 // If the file does not exist, is untracked or modified ErrObjectNotFound is returned.
 //
@@ -0,0 +1,157 @@
+package baur
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/simplesurance/baur/v5/internal/fs"
+	"github.com/simplesurance/baur/v5/internal/set"
+)
+
+// AffectedTaskFinder determines the tasks that are affected by changed files,
+// without calculating digests of their inputs.
+type AffectedTaskFinder struct {
+	repoDir       string
+	inputResolver *InputResolver
+
+	// affected caches the result per task ID.
+	affected map[string]bool
+}
+
+// NewAffectedTaskFinder returns an AffectedTaskFinder for the baur repository
+// in repoDir.
+func NewAffectedTaskFinder(repoDir string, inputResolver *InputResolver) *AffectedTaskFinder {
+	return &AffectedTaskFinder{
+		repoDir:       repoDir,
+		inputResolver: inputResolver,
+		affected:      map[string]bool{},
+	}
+}
+
+// Affected returns the elements of tasks that are affected by changes of
+// changedFiles. changedFiles are paths relative to the repository directory.
+// A task is affected when:
+//   - a changed file is part of its resolved file inputs, this includes
+//     golang source files and the config files of the task,
+//   - a changed file matches one of its file input patterns, this detects
+//     removed input files,
+//   - a task that it references as TaskInfo input is affected.
+func (f *AffectedTaskFinder) Affected(ctx context.Context, tasks []*Task, changedFiles []string) ([]*Task, error) {
+	changed := make(set.Set[string], len(changedFiles))
+	for _, p := range changedFiles {
+		changed.Add(filepath.Join(f.repoDir, p))
+	}
+
+	var result []*Task
+	for _, task := range tasks {
+		affected, err := f.isAffected(ctx, task, changed)
+		if err != nil {
+			return nil, err
+		}
+
+		if affected {
+			result = append(result, task)
+		}
+	}
+
+	return result, nil
+}
+
+func (f *AffectedTaskFinder) isAffected(ctx context.Context, task *Task, changed set.Set[string]) (bool, error) {
+	if affected, exists := f.affected[task.ID]; exists {
+		return affected, nil
+	}
+
+	affected, err := f.inputsChanged(ctx, task, changed)
+	if err != nil {
+		return false, err
+	}
+
+	if !affected {
+		for _, ti := range task.TaskInfoDependencies {
+			affected, err = f.isAffected(ctx, ti.Task, changed)
+			if err != nil {
+				return false, err
+			}
+
+			if affected {
+				break
+			}
+		}
+	}
+
+	f.affected[task.ID] = affected
+
+	return affected, nil
+}
+
+func (f *AffectedTaskFinder) inputsChanged(ctx context.Context, task *Task, changed set.Set[string]) (bool, error) {
+	if len(changed) == 0 {
+		return false, nil
+	}
+
+	for _, p := range task.CfgFilepaths {
+		if changed.Contains(p) {
+			return true, nil
+		}
+	}
+
+	matches, err := f.matchesFileInputPattern(task, changed)
+	if err != nil {
+		return false, err
+	}
+	if matches {
+		return true, nil
+	}
+
+	// the file inputs are resolved in-process, a configured
+	// FileInputResolver could calculate their digests
+	inputFiles, err := f.inputResolver.ResolveFileInputs(ctx, task)
+	if err != nil {
+		return false, fmt.Errorf("%s: resolving inputs failed: %w", task, err)
+	}
+
+	for _, inputFile := range inputFiles {
+		if changed.Contains(inputFile.AbsPath()) {
+			return true, nil
+		}
+
+		if inputFile.repoRelRealPath != "" && changed.Contains(filepath.Join(f.repoDir, inputFile.repoRelRealPath)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// matchesFileInputPattern returns true if a path in changed matches a
+// file input pattern of the task and none of its ExcludedFiles patterns.
+func (f *AffectedTaskFinder) matchesFileInputPattern(task *Task, changed set.Set[string]) (bool, error) {
+	excludePatterns := fs.AbsPaths(task.Directory, task.UnresolvedInputs.ExcludedFiles.Paths)
+
+	for _, in := range task.UnresolvedInputs.Files {
+		for _, pattern := range fs.AbsPaths(task.Directory, in.Paths) {
+			for p := range changed {
+				matches, err := fs.MatchGlob(pattern, p)
+				if err != nil {
+					return false, fmt.Errorf("%s: matching file input pattern %q failed: %w", task, pattern, err)
+				}
+				if !matches {
+					continue
+				}
+
+				excluded, _, err := f.inputResolver.globPathResolver.Matches(p, excludePatterns)
+				if err != nil {
+					return false, fmt.Errorf("%s: ExcludedFiles: %w", task, err)
+				}
+
+				if !excluded {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,141 @@
+package baur
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/digest"
+	"github.com/simplesurance/baur/v5/internal/log"
+	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
+	"github.com/simplesurance/baur/v5/internal/testutils/gittest"
+	"github.com/simplesurance/baur/v5/internal/vcs/git"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+func newAffectedTestTask(repoDir, appName, name string, inputPaths ...string) *Task {
+	return &Task{
+		ID:           appName + "." + name,
+		Directory:    filepath.Join(repoDir, appName),
+		CfgFilepaths: []string{filepath.Join(repoDir, appName, ".app.toml")},
+		UnresolvedInputs: &cfg.Input{
+			Files: []cfg.FileInputs{{Paths: inputPaths}},
+			ExcludedFiles: cfg.FileExcludeList{
+				Paths: []string{"*_test.go"},
+			},
+		},
+	}
+}
+
+func TestAffectedTasks(t *testing.T) {
+	log.RedirectToTestingLog(t)
+
+	repoDir := t.TempDir()
+	for _, f := range []string{
+		"app1/.app.toml", "app1/main.go", "app1/main_test.go",
+		"app2/.app.toml", "app2/main.go",
+		"app3/.app.toml", "app3/main.go",
+		"proto/api.proto",
+	} {
+		fstest.WriteToFile(t, []byte(f), filepath.Join(repoDir, f))
+	}
+	gittest.CreateRepository(t, repoDir)
+
+	app1 := newAffectedTestTask(repoDir, "app1", "build", "*.go")
+	app2 := newAffectedTestTask(repoDir, "app2", "build", "*.go", "../proto/*.proto")
+	app3 := newAffectedTestTask(repoDir, "app3", "build", "*.go")
+	app3.TaskInfoDependencies = []*TaskInfo{{Task: app2}}
+	tasks := []*Task{app1, app2, app3}
+
+	testcases := []struct {
+		name         string
+		changedFiles []string
+		expected     []*Task
+	}{
+		{
+			name:         "no_changes",
+			changedFiles: nil,
+			expected:     nil,
+		},
+		{
+			name:         "unrelated_file",
+			changedFiles: []string{"README.md"},
+			expected:     nil,
+		},
+		{
+			name:         "excluded_file",
+			changedFiles: []string{"app1/main_test.go"},
+			expected:     nil,
+		},
+		{
+			name:         "input_file",
+			changedFiles: []string{"app1/main.go"},
+			expected:     []*Task{app1},
+		},
+		{
+			name:         "config_file",
+			changedFiles: []string{"app1/.app.toml"},
+			expected:     []*Task{app1},
+		},
+		{
+			name:         "removed_input_file",
+			changedFiles: []string{"app1/removed.go"},
+			expected:     []*Task{app1},
+		},
+		{
+			name:         "taskinfo_dependency",
+			changedFiles: []string{"proto/api.proto"},
+			expected:     []*Task{app2, app3},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			finder := NewAffectedTaskFinder(
+				repoDir,
+				NewInputResolver(git.NewRepository(repoDir), repoDir, nil, true),
+			)
+
+			affected, err := finder.Affected(t.Context(), tasks, tc.changedFiles)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, affected)
+		})
+	}
+}
+
+type failingFileInputResolver struct{}
+
+func (failingFileInputResolver) ResolveFileInputs(context.Context, *Task) ([]*InputFile, error) {
+	return nil, errors.New("unexpected ResolveFileInputs call")
+}
+
+func TestAffectedTasksDoesNotCalculateDigests(t *testing.T) {
+	log.RedirectToTestingLog(t)
+
+	repoDir := t.TempDir()
+	fstest.WriteToFile(t, []byte("a"), filepath.Join(repoDir, "app1/.app.toml"))
+	gittest.CreateRepository(t, repoDir)
+	fstest.WriteToFile(t, []byte("untracked"), filepath.Join(repoDir, "app1/main.go"))
+
+	hashFn := func(string) (*digest.Digest, error) {
+		t.Error("digest of a file was calculated")
+		return nil, errors.New("unexpected hashFn call")
+	}
+
+	finder := NewAffectedTaskFinder(
+		repoDir,
+		NewInputResolver(git.NewRepository(repoDir), repoDir, nil, true,
+			WithFileHashFn(hashFn),
+			WithFileInputResolver(failingFileInputResolver{}),
+		),
+	)
+
+	task := newAffectedTestTask(repoDir, "app1", "build", "*.go")
+	affected, err := finder.Affected(t.Context(), []*Task{task}, []string{"README.md"})
+	require.NoError(t, err)
+	assert.Empty(t, affected)
+}
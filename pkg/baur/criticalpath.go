@@ -0,0 +1,69 @@
+package baur
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// SortTasksByCriticalPath sorts tasks by the expected durations of their
+// critical paths in descending order.
+// The critical path of a task is the longest chain of tasks, starting with the
+// task itself, that directly or indirectly depend on it via TaskInfo
+// dependencies. Only dependents that are in tasks are considered.
+// durations contains the expected duration per task ID, tasks that are
+// missing in durations are assumed to finish immediately.
+// Tasks with the same critical path duration keep their order, tasks without
+// known durations are therefore sorted after the other tasks in their
+// original order.
+func SortTasksByCriticalPath(tasks []*Task, durations map[string]time.Duration) {
+	dependents := make(map[string][]*Task, len(tasks))
+	for _, t := range tasks {
+		dependents[t.ID] = nil
+	}
+
+	for _, t := range tasks {
+		for _, dep := range t.TaskInfoDependencies {
+			if _, exists := dependents[dep.Task.ID]; !exists {
+				continue
+			}
+
+			dependents[dep.Task.ID] = append(dependents[dep.Task.ID], t)
+		}
+	}
+
+	pathDurations := make(map[string]time.Duration, len(tasks))
+	visiting := make(map[string]bool, len(tasks))
+
+	var pathDuration func(t *Task) time.Duration
+	pathDuration = func(t *Task) time.Duration {
+		if d, exists := pathDurations[t.ID]; exists {
+			return d
+		}
+
+		// cyclic dependencies are reported by the TaskScheduler, they
+		// are ignored here
+		if visiting[t.ID] {
+			return 0
+		}
+		visiting[t.ID] = true
+
+		var longest time.Duration
+		for _, dependent := range dependents[t.ID] {
+			longest = max(longest, pathDuration(dependent))
+		}
+
+		d := durations[t.ID] + longest
+		pathDurations[t.ID] = d
+
+		return d
+	}
+
+	for _, t := range tasks {
+		pathDuration(t)
+	}
+
+	slices.SortStableFunc(tasks, func(a, b *Task) int {
+		return cmp.Compare(pathDurations[b.ID], pathDurations[a.ID])
+	})
+}
@@ -0,0 +1,57 @@
+package baur
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortTasksByCriticalPathLongestFirst(t *testing.T) {
+	a := newSchedulerTestTask("app.a")
+	b := newSchedulerTestTask("app.b")
+	c := newSchedulerTestTask("app.c")
+	tasks := []*Task{a, b, c}
+
+	SortTasksByCriticalPath(tasks, map[string]time.Duration{
+		"app.a": time.Minute,
+		"app.b": 2 * time.Minute,
+		"app.c": 20 * time.Minute,
+	})
+
+	assert.Equal(t, []*Task{c, b, a}, tasks)
+}
+
+func TestSortTasksByCriticalPathConsidersDependents(t *testing.T) {
+	gen := newSchedulerTestTask("app.gen")
+	integration := newSchedulerTestTask("app.integration", gen)
+	lint := newSchedulerTestTask("app.lint")
+	unit := newSchedulerTestTask("app.unit")
+	tasks := []*Task{gen, integration, lint, unit}
+
+	// app.gen is short but app.integration can only start after it
+	// finished, the chain is the critical path
+	SortTasksByCriticalPath(tasks, map[string]time.Duration{
+		"app.gen":         time.Second,
+		"app.integration": 20 * time.Minute,
+		"app.lint":        5 * time.Minute,
+		"app.unit":        10 * time.Minute,
+	})
+
+	assert.Equal(t, []*Task{gen, integration, unit, lint}, tasks)
+}
+
+func TestSortTasksByCriticalPathKeepsOrderOfUnknownTasks(t *testing.T) {
+	a := newSchedulerTestTask("app.a")
+	b := newSchedulerTestTask("app.b")
+	c := newSchedulerTestTask("app.c")
+	d := newSchedulerTestTask("app.d", a)
+	tasks := []*Task{a, b, c, d}
+
+	SortTasksByCriticalPath(tasks, map[string]time.Duration{
+		"app.c": time.Minute,
+		"app.d": time.Second,
+	})
+
+	assert.Equal(t, []*Task{c, a, d, b}, tasks)
+}
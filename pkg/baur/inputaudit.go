@@ -0,0 +1,157 @@
+package baur
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/simplesurance/baur/v5/internal/exec"
+	"github.com/simplesurance/baur/v5/internal/fs"
+	"github.com/simplesurance/baur/v5/internal/set"
+)
+
+// InputAudit is the result of comparing the files that were accessed by the
+// command of a task with the inputs of the task.
+type InputAudit struct {
+	task *Task
+
+	// UndeclaredReads are the repository relative paths of files that
+	// were read by the command but are not inputs of the task.
+	UndeclaredReads []string
+	// UnreadInputs are the repository relative paths of input files that
+	// were not read by the command. Config files of the task are not
+	// included.
+	UnreadInputs []string
+}
+
+// AuditInputs compares the files in accesses with the input files of the
+// task.
+// Only regular files in the repository are considered. Files that were
+// written by the command and files in the .git directory are ignored.
+func AuditInputs(task *Task, inputs *Inputs, accesses []exec.FileAccess) *InputAudit {
+	written := set.Set[string]{}
+	for _, a := range accesses {
+		if a.Write {
+			written.Add(a.Path)
+		}
+	}
+
+	// the paths of the accesses contain the real path of the directories
+	// in that a process was executed
+	repoDirs := []string{task.RepositoryRoot}
+	if realPath, err := fs.RealPath(task.RepositoryRoot); err == nil && realPath != task.RepositoryRoot {
+		repoDirs = append(repoDirs, realPath)
+	}
+
+	read := set.Set[string]{}
+	for _, a := range accesses {
+		if !a.Read || written.Contains(a.Path) {
+			continue
+		}
+
+		relPath, ok := repoRelPath(repoDirs, a.Path)
+		if !ok {
+			continue
+		}
+
+		isFile, err := fs.IsRegularFile(a.Path)
+		if err != nil || !isFile {
+			continue
+		}
+
+		read.Add(relPath)
+	}
+
+	cfgFiles := set.Set[string]{}
+	for _, p := range task.CfgFilepaths {
+		if relPath, ok := repoRelPath(repoDirs, p); ok {
+			cfgFiles.Add(relPath)
+		}
+	}
+
+	declared := set.Set[string]{}
+	result := InputAudit{task: task}
+
+	for _, in := range inputs.Inputs() {
+		f, ok := in.(*InputFile)
+		if !ok {
+			continue
+		}
+
+		declared.Add(f.RelPath())
+		if f.repoRelRealPath != "" {
+			declared.Add(f.repoRelRealPath)
+		}
+
+		if read.Contains(f.RelPath()) || (f.repoRelRealPath != "" && read.Contains(f.repoRelRealPath)) {
+			continue
+		}
+
+		if cfgFiles.Contains(f.RelPath()) {
+			continue
+		}
+
+		result.UnreadInputs = append(result.UnreadInputs, f.RelPath())
+	}
+
+	for p := range read {
+		if !declared.Contains(p) {
+			result.UndeclaredReads = append(result.UndeclaredReads, p)
+		}
+	}
+
+	slices.Sort(result.UndeclaredReads)
+	slices.Sort(result.UnreadInputs)
+
+	return &result
+}
+
+// repoRelPath returns path relative to the first element of repoDirs that
+// contains it. If path is not in one of the repoDirs or is in a .git
+// directory, false is returned.
+func repoRelPath(repoDirs []string, path string) (string, bool) {
+	for _, repoDir := range repoDirs {
+		relPath, err := filepath.Rel(repoDir, path)
+		if err != nil || !filepath.IsLocal(relPath) {
+			continue
+		}
+
+		if relPath == ".git" || strings.HasPrefix(relPath, ".git"+string(filepath.Separator)) {
+			return "", false
+		}
+
+		return relPath, true
+	}
+
+	return "", false
+}
+
+// SuggestedFileInputs returns a Task.Input.Files configuration section
+// containing the UndeclaredReads, with paths relative to the task directory.
+// If no undeclared files were read, an empty string is returned.
+func (a *InputAudit) SuggestedFileInputs() string {
+	if len(a.UndeclaredReads) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[[Task.Input.Files]]\n  paths = [\n")
+
+	for _, p := range a.UndeclaredReads {
+		relPath, err := filepath.Rel(a.task.Directory, filepath.Join(a.task.RepositoryRoot, p))
+		if err != nil {
+			// can not happen, both paths are absolute
+			panic(fmt.Sprintf("computing relative path of %q to %q failed: %s", p, a.task.Directory, err))
+		}
+
+		sb.WriteString("    ")
+		sb.WriteString(strconv.Quote(filepath.ToSlash(relPath)))
+		sb.WriteString(",\n")
+	}
+
+	sb.WriteString("  ]\n")
+
+	return sb.String()
+}
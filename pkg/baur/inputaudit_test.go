@@ -0,0 +1,77 @@
+package baur
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/simplesurance/baur/v5/internal/exec"
+	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
+)
+
+func TestAuditInputs(t *testing.T) {
+	repoDir := t.TempDir()
+	taskDir := filepath.Join(repoDir, "app")
+	cfgFile := filepath.Join(taskDir, ".app.toml")
+
+	for _, p := range []string{"main.go", "util.go", "unused.go", "out.bin"} {
+		fstest.WriteToFile(t, []byte(p), filepath.Join(taskDir, p))
+	}
+	fstest.WriteToFile(t, []byte("lib"), filepath.Join(repoDir, "lib", "lib.go"))
+	fstest.WriteToFile(t, []byte("cfg"), cfgFile)
+	fstest.WriteToFile(t, []byte("git"), filepath.Join(repoDir, ".git", "HEAD"))
+
+	task := Task{
+		ID:             "app.build",
+		RepositoryRoot: repoDir,
+		Directory:      taskDir,
+		CfgFilepaths:   []string{cfgFile},
+	}
+
+	inputs := NewInputs([]Input{
+		NewInputFile(filepath.Join(taskDir, "main.go"), filepath.Join("app", "main.go"), false),
+		NewInputFile(filepath.Join(taskDir, "unused.go"), filepath.Join("app", "unused.go"), false),
+		NewInputFile(cfgFile, filepath.Join("app", ".app.toml"), false),
+		NewInputEnvVar("GOFLAGS", ""),
+	})
+
+	accesses := []exec.FileAccess{
+		{Path: filepath.Join(taskDir, "main.go"), Read: true},
+		{Path: filepath.Join(taskDir, "util.go"), Read: true},
+		{Path: filepath.Join(repoDir, "lib", "lib.go"), Read: true},
+		{Path: filepath.Join(repoDir, ".git", "HEAD"), Read: true},
+		{Path: filepath.Join(taskDir, "out.bin"), Read: true, Write: true},
+		{Path: "/usr/bin/go", Read: true},
+		{Path: taskDir, Read: true},
+	}
+
+	audit := AuditInputs(&task, inputs, accesses)
+	assert.Equal(t, []string{filepath.Join("app", "util.go"), filepath.Join("lib", "lib.go")}, audit.UndeclaredReads)
+	assert.Equal(t, []string{filepath.Join("app", "unused.go")}, audit.UnreadInputs)
+	assert.Equal(t, "[[Task.Input.Files]]\n  paths = [\n    \"util.go\",\n    \"../lib/lib.go\",\n  ]\n", audit.SuggestedFileInputs())
+}
+
+func TestRunTracesFileAccesses(t *testing.T) {
+	if !exec.TracingSupported() {
+		t.Skip("tracing is not supported on this platform")
+	}
+
+	repoDir := t.TempDir()
+	fstest.WriteToFile(t, []byte("in"), filepath.Join(repoDir, "in.txt"))
+
+	tr := NewTaskRunner(nil, false)
+	tr.TraceFileAccesses = true
+	task := Task{
+		ID:             "app.build",
+		RepositoryRoot: repoDir,
+		Directory:      repoDir,
+		Command:        []string{"cat", "in.txt"},
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	assert.NoError(t, err)
+
+	audit := AuditInputs(&task, NewInputs(nil), res.FileAccesses)
+	assert.Equal(t, []string{"in.txt"}, audit.UndeclaredReads)
+}
@@ -0,0 +1,116 @@
+package baur
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/simplesurance/baur/v5/internal/set"
+)
+
+// InputDirectories returns the directories in that changes can affect the
+// inputs of the task.
+// These are the directories that contain the resolved input files and the
+// config files of the task, and the directories in that files can be created
+// that would match the file input patterns of the task.
+// For a pattern, the directory preceding the first path element that
+// contains a glob meta character and all its subdirectories are returned.
+// If a directory does not exist, its closest existing parent directory is
+// returned instead. inputs can be nil.
+// The result is sorted and does not contain duplicates.
+func InputDirectories(task *Task, inputs *Inputs) ([]string, error) {
+	result := set.Set[string]{}
+
+	for _, p := range task.CfgFilepaths {
+		result.Add(existingDir(filepath.Dir(p)))
+	}
+
+	if inputs != nil {
+		for _, in := range inputs.Inputs() {
+			if f, ok := in.(*InputFile); ok {
+				result.Add(filepath.Dir(f.AbsPath()))
+			}
+		}
+	}
+
+	for _, fileInputs := range task.UnresolvedInputs.Files {
+		for _, p := range fileInputs.Paths {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(task.Directory, p)
+			}
+
+			if !isGlobPattern(p) {
+				result.Add(existingDir(filepath.Dir(p)))
+				continue
+			}
+
+			dir := existingDir(globBaseDir(p))
+			if err := addSubDirs(result, dir); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	dirs := result.Slice()
+	slices.Sort(dirs)
+
+	return dirs, nil
+}
+
+// globBaseDir returns the directory preceding the first path element of
+// pattern that contains a glob meta character.
+func globBaseDir(pattern string) string {
+	elems := strings.Split(filepath.ToSlash(pattern), "/")
+	for i, elem := range elems {
+		if isGlobPattern(elem) {
+			return filepath.FromSlash(strings.Join(elems[:i], "/"))
+		}
+	}
+
+	return filepath.Dir(pattern)
+}
+
+// existingDir returns dir if it exists, otherwise its closest existing parent
+// directory.
+func existingDir(dir string) string {
+	for {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// addSubDirs adds dir and all its subdirectories to result, .git directories
+// are skipped.
+func addSubDirs(result set.Set[string], dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// directories can be deleted while walking
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		result.Add(path)
+
+		return nil
+	})
+}
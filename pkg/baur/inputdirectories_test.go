@@ -0,0 +1,61 @@
+package baur
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+func TestInputDirectories(t *testing.T) {
+	repoDir := t.TempDir()
+	appDir := filepath.Join(repoDir, "app")
+	sharedDir := filepath.Join(repoDir, "shared")
+
+	for _, dir := range []string{
+		filepath.Join(appDir, "src", "pkg"),
+		filepath.Join(appDir, "src", ".git"),
+		filepath.Join(appDir, "docs"),
+		filepath.Join(sharedDir, "lib"),
+	} {
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+	}
+
+	task := Task{
+		Directory:    appDir,
+		CfgFilepaths: []string{filepath.Join(appDir, ".app.toml"), filepath.Join(repoDir, "includes", "tasks.toml")},
+		UnresolvedInputs: &cfg.Input{
+			Files: []cfg.FileInputs{
+				{
+					Paths: []string{
+						"src/**/*.go",
+						"../shared/lib/version.txt",
+						"gen/*.txt",
+					},
+				},
+			},
+		},
+	}
+
+	inputs := NewInputs([]Input{
+		NewInputFile(filepath.Join(appDir, "docs", "README.md"), "app/docs/README.md", false),
+	})
+
+	dirs, err := InputDirectories(&task, inputs)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		// the includes directory does not exist, its parent is returned
+		repoDir,
+		// gen does not exist, its parent is returned
+		appDir,
+		filepath.Join(appDir, "docs"),
+		filepath.Join(appDir, "src"),
+		filepath.Join(appDir, "src", "pkg"),
+		filepath.Join(sharedDir, "lib"),
+	}, dirs)
+}
@@ -74,6 +74,39 @@ func (f *InputFile) AbsPath() string {
 	return f.absPath
 }
 
+// RelRealPath returns the baur repository relative path of the file with
+// all symlinks resolved.
+// If the path does not contain symlinks, an empty string is returned.
+func (f *InputFile) RelRealPath() string {
+	return f.repoRelRealPath
+}
+
+// OwnerHasExecutablePerm returns true if the owner of the file has executable
+// permissions.
+func (f *InputFile) OwnerHasExecutablePerm() bool {
+	return f.ownerHasExecutablePerm
+}
+
+// ContentDigest returns the digest of the file content.
+// If it wasn't calculated yet, it is calculated with the file hash function.
+func (f *InputFile) ContentDigest() (*digest.Digest, error) {
+	if f.contentDigest != nil {
+		return f.contentDigest, nil
+	}
+
+	if f.fileHasher == nil {
+		return nil, errors.ErrUnsupported
+	}
+
+	d, err := f.fileHasher(f.absPath)
+	if err != nil {
+		return nil, err
+	}
+	f.contentDigest = d
+
+	return d, nil
+}
+
 // CalcDigest calculates the digest of the file.
 // The Digest is the sha384 sum of the repoRelPath and the content of the file.
 // If neither a file hash function nor the content digest was provided on
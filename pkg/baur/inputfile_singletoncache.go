@@ -1,6 +1,8 @@
 package baur
 
 import (
+	"maps"
+
 	"github.com/simplesurance/baur/v5/internal/digest"
 )
 
@@ -26,6 +28,13 @@ func (c *InputFileSingletonCache) Get(absPath string) (f *InputFile, exists bool
 	return f, exists
 }
 
+// RemoveFunc removes all InputFiles from the cache for that del returns true.
+func (c *InputFileSingletonCache) RemoveFunc(del func(*InputFile) bool) {
+	maps.DeleteFunc(c.cache, func(_ string, f *InputFile) bool {
+		return del(f)
+	})
+}
+
 // Add adds f to the cache and returns it.
 func (c *InputFileSingletonCache) Add(f *InputFile) *InputFile {
 	c.cache[f.AbsPath()] = f
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
@@ -16,6 +17,7 @@ import (
 	"github.com/simplesurance/baur/v5/internal/log"
 	"github.com/simplesurance/baur/v5/internal/resolve/glob"
 	"github.com/simplesurance/baur/v5/internal/resolve/gosource"
+	"github.com/simplesurance/baur/v5/internal/set"
 	"github.com/simplesurance/baur/v5/internal/vcs/git"
 	"github.com/simplesurance/baur/v5/pkg/cfg"
 )
@@ -46,13 +48,50 @@ type InputResolver struct {
 	gitTrackedDb            *git.TrackedObjects
 	fileHashfn              FileHashFn
 	fixedInputs             []Input
+	fileInputResolver       FileInputResolver
 }
 
 type GitUntrackedFilesResolver interface {
 	WithoutUntracked(paths ...string) ([]string, error)
 }
 
-func NewInputResolver(gitRepo GitUntrackedFilesResolver, repoDir string, fixedInputs []Input, hashGitUntrackedFiles bool) *InputResolver {
+// FileInputResolver resolves the file and golang source inputs and the config
+// files of a task to InputFiles.
+type FileInputResolver interface {
+	ResolveFileInputs(ctx context.Context, task *Task) ([]*InputFile, error)
+}
+
+type InputResolverOpt func(*InputResolver)
+
+// WithFileInputResolver configures the InputResolver to delegate resolving
+// the file inputs of tasks to r.
+func WithFileInputResolver(r FileInputResolver) InputResolverOpt {
+	return func(i *InputResolver) {
+		i.fileInputResolver = r
+	}
+}
+
+// WithFileHashFn configures the InputResolver to calculate the digests of
+// files that are untracked or modified in the git repository with fn.
+// It has no effect when the InputResolver is created with
+// hashGitUntrackedFiles set to false.
+func WithFileHashFn(fn FileHashFn) InputResolverOpt {
+	return func(i *InputResolver) {
+		i.fileHashfn = fn
+	}
+}
+
+// WithGolangSourcesHostEnv configures the InputResolver to use env instead
+// of the environment of the current process when it passes host environment
+// variables, like PATH, HOME and GOCACHE, to the Go query tool while
+// resolving GolangSources inputs.
+func WithGolangSourcesHostEnv(env []string) InputResolverOpt {
+	return func(i *InputResolver) {
+		i.goSourceResolver = gosource.NewResolver(log.Debugf, gosource.WithHostEnv(env))
+	}
+}
+
+func NewInputResolver(gitRepo GitUntrackedFilesResolver, repoDir string, fixedInputs []Input, hashGitUntrackedFiles bool, opts ...InputResolverOpt) *InputResolver {
 	result := InputResolver{
 		repoDir:                 repoDir,
 		globPathResolver:        &glob.Resolver{},
@@ -62,16 +101,25 @@ func NewInputResolver(gitRepo GitUntrackedFilesResolver, repoDir string, fixedIn
 		inputFileSingletonCache: NewInputFileSingletonCache(),
 		fixedInputs:             fixedInputs,
 	}
+	result.fileInputResolver = &result
+
+	for _, opt := range opts {
+		opt(&result)
+	}
 
 	result.gitTrackedDb = git.NewTrackedObjects(repoDir, log.Debugf)
-	g := gitobjectid.New(repoDir, log.Debugf)
-	log.Debugf("inputresolver: using gitobject file hasher\n")
 
-	if hashGitUntrackedFiles {
-		result.fileHashfn = g.File
+	if !hashGitUntrackedFiles {
+		result.fileHashfn = nil
 		return &result
 	}
 
+	if result.fileHashfn == nil {
+		g := gitobjectid.New(repoDir, log.Debugf)
+		log.Debugf("inputresolver: using gitobject file hasher\n")
+		result.fileHashfn = g.File
+	}
+
 	return &result
 }
 
@@ -79,18 +127,7 @@ func NewInputResolver(gitRepo GitUntrackedFilesResolver, repoDir string, fixedIn
 // If an input definition does not resolve to >=1 paths, an error is returned.
 // The resolved Files are deduplicated.
 func (i *InputResolver) Resolve(ctx context.Context, task *Task) (*Inputs, error) {
-	goSourcePaths, err := i.resolveGoSrcInputs(ctx, task.Directory, task.UnresolvedInputs.GolangSources)
-	if err != nil {
-		return nil, fmt.Errorf("resolving golang source inputs failed: %w", err)
-	}
-
-	globPaths, err := i.resolveFileInputs(task.Directory, task.UnresolvedInputs.Files)
-	if err != nil {
-		return nil, fmt.Errorf("resolving file inputs failed: %w", err)
-	}
-
-	inputPaths := slices.Concat(globPaths, goSourcePaths, task.CfgFilepaths)
-	uniqInputs, err := i.pathsToUniqInputs(inputPaths, fs.AbsPaths(task.Directory, task.UnresolvedInputs.ExcludedFiles.Paths))
+	inputFiles, err := i.fileInputResolver.ResolveFileInputs(ctx, task)
 	if err != nil {
 		return nil, err
 	}
@@ -100,6 +137,16 @@ func (i *InputResolver) Resolve(ctx context.Context, task *Task) (*Inputs, error
 		return nil, fmt.Errorf("resolving environment variable inputs failed: %w", err)
 	}
 
+	if task.Environment != nil && len(task.Environment.Set) > 0 {
+		if envVars == nil {
+			envVars = make(map[string]string, len(task.Environment.Set))
+		}
+
+		// the variables are passed with these values to the command,
+		// they overwrite the ones of the baur process
+		maps.Copy(envVars, task.Environment.Set)
+	}
+
 	inputTasks, err := i.resolveTaskInfos(ctx, task.TaskInfoDependencies)
 	if err != nil {
 		return nil, err
@@ -109,6 +156,11 @@ func (i *InputResolver) Resolve(ctx context.Context, task *Task) (*Inputs, error
 	log.Debugf("inputresolver: cache statistic: %d entries, %d hits, %d miss, ratio %.2f%%\n",
 		stats.Entries, stats.Hits, stats.Miss, stats.HitRatio())
 
+	uniqInputs := make([]Input, 0, len(inputFiles))
+	for _, f := range inputFiles {
+		uniqInputs = append(uniqInputs, f)
+	}
+
 	inputs := NewInputs(slices.Concat(
 		uniqInputs,
 		envVarMapToInputslice(envVars),
@@ -119,6 +171,43 @@ func (i *InputResolver) Resolve(ctx context.Context, task *Task) (*Inputs, error
 	return inputs, nil
 }
 
+// ResolveFileInputs resolves the file and golang source inputs and the
+// config files of the task, excluded files are removed from the result.
+// The result is deduplicated.
+func (i *InputResolver) ResolveFileInputs(ctx context.Context, task *Task) ([]*InputFile, error) {
+	goSourcePaths, err := i.resolveGoSrcInputs(ctx, task.Directory, task.UnresolvedInputs.GolangSources)
+	if err != nil {
+		return nil, fmt.Errorf("resolving golang source inputs failed: %w", err)
+	}
+
+	globPaths, err := i.resolveFileInputs(task.Directory, task.UnresolvedInputs.Files)
+	if err != nil {
+		return nil, fmt.Errorf("resolving file inputs failed: %w", err)
+	}
+
+	inputPaths := slices.Concat(globPaths, goSourcePaths, task.CfgFilepaths)
+	return i.pathsToUniqInputs(inputPaths, fs.AbsPaths(task.Directory, task.UnresolvedInputs.ExcludedFiles.Paths))
+}
+
+// Invalidate removes cached information about the files at absPaths.
+// The cached results of resolving file and golang source inputs are
+// discarded completely, because created or deleted files can change them.
+func (i *InputResolver) Invalidate(absPaths ...string) {
+	changed := set.From(absPaths)
+
+	i.resolverCache.Reset()
+	i.gitTrackedDb.Forget(absPaths...)
+	i.inputFileSingletonCache.RemoveFunc(func(f *InputFile) bool {
+		if changed.Contains(f.AbsPath()) {
+			return true
+		}
+
+		// the input file is a symlink, its digest is calculated from
+		// the file it points to
+		return f.RelRealPath() != "" && changed.Contains(filepath.Join(i.repoDir, f.RelRealPath()))
+	})
+}
+
 func (i *InputResolver) resolveTaskInfos(ctx context.Context, taskInfos []*TaskInfo) ([]Input, error) {
 	result := make([]Input, 0, len(taskInfos))
 
@@ -248,10 +337,10 @@ func (i *InputResolver) resolveGoSrcInputs(ctx context.Context, appDir string, i
 	return result, nil
 }
 
-func (i *InputResolver) pathsToUniqInputs(paths, excludePatterns []string) ([]Input, error) {
+func (i *InputResolver) pathsToUniqInputs(paths, excludePatterns []string) ([]*InputFile, error) {
 	pathsCount := len(paths)
 
-	res := make([]Input, 0, pathsCount)
+	res := make([]*InputFile, 0, pathsCount)
 	dedupMap := make(map[string]struct{}, pathsCount)
 
 	for _, path := range paths {
@@ -57,6 +57,14 @@ func newInputResolverCache() *inputResolverCache {
 	}
 }
 
+// Reset removes all entries from the cache.
+func (i *inputResolverCache) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.cache = lru.New(inputResolverCacheMaxEntries)
+}
+
 func strSliceStr(in []string) string {
 	if len(in) == 0 {
 		return "[]"
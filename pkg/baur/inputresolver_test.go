@@ -479,6 +479,35 @@ func TestResolveEnvVarInputs(t *testing.T) {
 	}
 }
 
+func TestEnvironmentSetVarsAreInputs(t *testing.T) {
+	log.RedirectToTestingLog(t)
+	t.Setenv("VarA", "fromenv")
+
+	task := Task{
+		ID:        "app.build",
+		Directory: t.TempDir(),
+		Environment: &cfg.Environment{
+			Set: map[string]string{"VarA": "fromcfg", "VarB": "1"},
+		},
+		UnresolvedInputs: &cfg.Input{
+			EnvironmentVariables: []cfg.EnvVarsInputs{{Names: []string{"VarA"}}},
+		},
+	}
+
+	resolver := NewInputResolver(&DummyGitUntrackedFilesResolver{}, task.Directory, nil, true)
+	inputs, err := resolver.Resolve(t.Context(), &task)
+	require.NoError(t, err)
+
+	envVars := map[string]string{}
+	for _, in := range inputs.Inputs() {
+		envVar, ok := in.(*InputEnvVar)
+		require.True(t, ok, "input %s is not an InputEnvVar", in)
+		envVars[envVar.Name()] = envVar.value
+	}
+
+	require.Equal(t, map[string]string{"VarA": "fromcfg", "VarB": "1"}, envVars)
+}
+
 func TestExcludedFiles(t *testing.T) {
 	testcases := []struct {
 		Name           string
@@ -975,3 +1004,60 @@ func TestFileInSymlinkDir(t *testing.T) {
 	testFn(true)
 	testFn(false)
 }
+
+func TestInvalidateDiscardsCachedInformation(t *testing.T) {
+	log.RedirectToTestingLog(t)
+	tempDir := t.TempDir()
+	gittest.CreateRepository(t, tempDir)
+
+	f := filepath.Join(tempDir, "file.txt")
+	fstest.WriteToFile(t, []byte("1"), f)
+	symlinkTarget := filepath.Join(tempDir, "target")
+	fstest.WriteToFile(t, []byte("1"), symlinkTarget)
+	fstest.Symlink(t, symlinkTarget, filepath.Join(tempDir, "link.txt"))
+	gittest.CommitFilesToGit(t, tempDir)
+
+	task := Task{
+		RepositoryRoot: tempDir,
+		Directory:      tempDir,
+		UnresolvedInputs: &cfg.Input{
+			Files: []cfg.FileInputs{{Paths: []string{"*.txt"}}},
+		},
+	}
+
+	resolver := NewInputResolver(git.NewRepository(tempDir), tempDir, nil, true)
+	resolve := func() *Inputs {
+		t.Helper()
+		inputs, err := resolver.Resolve(t.Context(), &task)
+		require.NoError(t, err)
+		return inputs
+	}
+
+	before := resolve()
+	digestBefore, err := before.Digest()
+	require.NoError(t, err)
+
+	newFile := filepath.Join(tempDir, "new.txt")
+	fstest.WriteToFile(t, []byte("2"), f)
+	fstest.WriteToFile(t, []byte("2"), newFile)
+
+	cached := resolve()
+	assert.Len(t, cached.Inputs(), 2)
+	digestCached, err := cached.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, digestBefore.String(), digestCached.String())
+
+	resolver.Invalidate(f, newFile)
+	after := resolve()
+	assert.Len(t, after.Inputs(), 3)
+	digestAfter, err := after.Digest()
+	require.NoError(t, err)
+	assert.NotEqual(t, digestBefore.String(), digestAfter.String())
+
+	fstest.WriteToFile(t, []byte("2"), symlinkTarget)
+	resolver.Invalidate(symlinkTarget)
+	afterSymlinkChange := resolve()
+	digestAfterSymlinkChange, err := afterSymlinkChange.Digest()
+	require.NoError(t, err)
+	assert.NotEqual(t, digestAfter.String(), digestAfterSymlinkChange.String())
+}
@@ -3,10 +3,12 @@ package baur
 import (
 	"cmp"
 	"fmt"
+	"runtime"
 	"slices"
 
 	"github.com/simplesurance/baur/v5/internal/digest"
 	"github.com/simplesurance/baur/v5/internal/digest/sha384"
+	"github.com/simplesurance/baur/v5/internal/routines"
 )
 
 // Inputs are resolved Inputs of a task.
@@ -44,6 +46,8 @@ func (in *Inputs) Digest() (*digest.Digest, error) {
 		return in.digest, nil
 	}
 
+	calcFileContentDigests(in.inputs)
+
 	digests := make([]*digest.Digest, len(in.inputs))
 
 	for i, input := range in.inputs {
@@ -65,6 +69,31 @@ func (in *Inputs) Digest() (*digest.Digest, error) {
 	return in.digest, nil
 }
 
+// calcFileContentDigests calculates the content digests of the InputFiles in
+// inputs in parallel.
+// Errors are ignored, calculating the digests of the failed files is retried
+// when their digest is requested.
+func calcFileContentDigests(inputs []Input) {
+	var pool *routines.Pool
+
+	for _, input := range inputs {
+		f, ok := input.(*InputFile)
+		if !ok || f.contentDigest != nil || f.fileHasher == nil {
+			continue
+		}
+
+		if pool == nil {
+			pool = routines.NewPool(uint(runtime.NumCPU())) //nolint:gosec // NumCPU is positive
+		}
+
+		pool.Queue(func() { _, _ = f.ContentDigest() })
+	}
+
+	if pool != nil {
+		pool.Wait()
+	}
+}
+
 // Sort order the inputs alphabetically by their string representation.
 func (in *Inputs) Sort() {
 	slices.SortFunc(in.inputs, func(a, b Input) int {
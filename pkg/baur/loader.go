@@ -3,6 +3,7 @@ package baur
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"golang.org/x/exp/maps"
@@ -196,28 +197,139 @@ func taskCount(apps []*App) int {
 	return cnt
 }
 
+// allTasks instantiates all tasks of apps.
+// Tasks that are referenced as TaskInfo inputs are also instantiated, also
+// when they belong to other apps than the passed ones. They are only set as
+// TaskInfoDependencies, the returned slice only contains the tasks of apps.
 func (a *Loader) allTasks(apps []*App) ([]*Task, error) {
 	taskCnt := taskCount(apps)
 	tasks := make(map[string]*Task, taskCnt)
-	tasksWithTaskInfoInputs := make([]*Task, 0, taskCnt)
+	result := make([]*Task, 0, taskCnt)
 
 	for _, app := range apps {
 		for _, taskCfg := range app.cfg.Tasks {
 			task := NewTask(taskCfg, app.Name, app.repositoryRootPath, app.Path)
 			tasks[task.ID] = task
-			if len(task.UnresolvedInputs.TaskInfos) > 0 {
-				tasksWithTaskInfoInputs = append(tasksWithTaskInfoInputs, task)
-			}
+			result = append(result, task)
 		}
 	}
 
+	tasksWithTaskInfoInputs, err := a.loadTaskInfoTasks(apps, tasks)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, task := range tasksWithTaskInfoInputs {
 		if err := task.setTaskInfoDependencies(tasks); err != nil {
 			return nil, err
 		}
 	}
 
-	return maps.Values(tasks), nil
+	if err := validateTaskInfoDependenciesAreAcyclic(tasksWithTaskInfoInputs); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// loadTaskInfoTasks loads the apps that contain tasks which are referenced
+// as TaskInfo inputs by tasks and are not part of loadedApps.
+// Their tasks are added to the tasks map. This is repeated until the
+// referenced tasks of all tasks in the map are loaded.
+// All tasks in the map that have TaskInfo inputs are returned.
+func (a *Loader) loadTaskInfoTasks(loadedApps []*App, tasks map[string]*Task) ([]*Task, error) {
+	var result []*Task
+
+	appNames := set.Set[string]{}
+	for _, app := range loadedApps {
+		appNames.Add(app.Name)
+	}
+
+	queue := maps.Values(tasks)
+	for len(queue) > 0 {
+		missingApps := set.Set[string]{}
+
+		for _, task := range queue {
+			if len(task.UnresolvedInputs.TaskInfos) == 0 {
+				continue
+			}
+
+			result = append(result, task)
+
+			for _, appName := range task.taskInfoAppNames() {
+				if !appNames.Contains(appName) {
+					missingApps.Add(appName)
+				}
+			}
+		}
+
+		queue = nil
+		if len(missingApps) == 0 {
+			break
+		}
+
+		a.logger.Debugf("loader: loading apps with tasks referenced as TaskInfo inputs: %+v", missingApps.Slice())
+
+		apps, err := a.appNames(missingApps.Slice()...)
+		if err != nil {
+			return nil, fmt.Errorf("loading apps of tasks referenced as TaskInfo inputs failed: %w", err)
+		}
+
+		for _, app := range apps {
+			appNames.Add(app.Name)
+
+			for _, taskCfg := range app.cfg.Tasks {
+				task := NewTask(taskCfg, app.Name, app.repositoryRootPath, app.Path)
+				tasks[task.ID] = task
+				queue = append(queue, task)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// validateTaskInfoDependenciesAreAcyclic returns an error if the
+// TaskInfoDependencies of tasks, including the ones of tasks of other apps,
+// contain a cycle.
+func validateTaskInfoDependenciesAreAcyclic(tasks []*Task) error {
+	finished := make(map[*Task]struct{}, len(tasks))
+
+	var visit func(task *Task, path []*Task) error
+	visit = func(task *Task, path []*Task) error {
+		if _, exists := finished[task]; exists {
+			return nil
+		}
+
+		if idx := slices.Index(path, task); idx != -1 {
+			cycle := make([]string, 0, len(path)-idx+1)
+			for _, t := range path[idx:] {
+				cycle = append(cycle, t.ID)
+			}
+			cycle = append(cycle, task.ID)
+
+			return fmt.Errorf("TaskInfo dependencies are cyclic: %s", strings.Join(cycle, " -> "))
+		}
+
+		path = append(path, task)
+		for _, ti := range task.TaskInfoDependencies {
+			if err := visit(ti.Task, path); err != nil {
+				return err
+			}
+		}
+
+		finished[task] = struct{}{}
+
+		return nil
+	}
+
+	for _, task := range tasks {
+		if err := visit(task, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // appDirs load apps from the given directories.
@@ -1,9 +1,11 @@
 package baur
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/simplesurance/baur/v5/internal/log"
@@ -56,3 +58,93 @@ func TestErrorOnDuplicateAppNames(t *testing.T) {
 	// _, err = loader.LoadTasks("app1.build")
 	// require.ErrorAs(t, err, &wantedErr)
 }
+
+func newTaskInfoTestApp(name string, taskInfoTaskNames ...string) *cfg.App {
+	task := cfg.Task{
+		Name:    "build",
+		Command: []string{"true"},
+	}
+
+	for _, taskName := range taskInfoTaskNames {
+		task.Input.TaskInfos = append(task.Input.TaskInfos, cfg.TaskInfo{
+			TaskName:   taskName,
+			EnvVarName: "TASKINFO",
+		})
+	}
+
+	return &cfg.App{
+		Name:  name,
+		Tasks: cfg.Tasks{&task},
+	}
+}
+
+func newTaskInfoTestLoader(t *testing.T, apps ...*cfg.App) *Loader {
+	t.Helper()
+	log.RedirectToTestingLog(t)
+
+	repoDir := t.TempDir()
+	repoCfgPath := filepath.Join(repoDir, RepositoryCfgFile)
+	require.NoError(t, cfg.ExampleRepository().ToFile(repoCfgPath))
+
+	for _, app := range apps {
+		appDir := filepath.Join(repoDir, app.Name)
+		require.NoError(t, os.Mkdir(appDir, 0o755))
+		require.NoError(t, app.ToFile(filepath.Join(appDir, AppCfgFile)))
+	}
+
+	repoCfg, err := cfg.RepositoryFromFile(repoCfgPath)
+	require.NoError(t, err)
+
+	loader, err := NewLoader(repoCfg, nil, log.StdLogger)
+	require.NoError(t, err)
+
+	return loader
+}
+
+func TestLoadTasksResolvesTaskInfosOfOtherApps(t *testing.T) {
+	loader := newTaskInfoTestLoader(t,
+		newTaskInfoTestApp("proto"),
+		newTaskInfoTestApp("codegen", "proto.build"),
+		newTaskInfoTestApp("service", "codegen.build"),
+	)
+
+	tasks, err := loader.LoadTasks("service.build")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	task := tasks[0]
+	require.Len(t, task.TaskInfoDependencies, 1)
+	codegen := task.TaskInfoDependencies[0].Task
+	assert.Equal(t, "codegen.build", codegen.ID)
+
+	require.Len(t, codegen.TaskInfoDependencies, 1)
+	assert.Equal(t, "proto.build", codegen.TaskInfoDependencies[0].Task.ID)
+}
+
+func TestLoadTasksFailsOnCyclicTaskInfosOfDifferentApps(t *testing.T) {
+	loader := newTaskInfoTestLoader(t,
+		newTaskInfoTestApp("app1", "app2.build"),
+		newTaskInfoTestApp("app2", "app3.build"),
+		newTaskInfoTestApp("app3", "app1.build"),
+	)
+
+	_, err := loader.LoadTasks("app1")
+	require.ErrorContains(t, err, "TaskInfo dependencies are cyclic: app1.build -> app2.build -> app3.build -> app1.build")
+}
+
+func TestLoadTasksFailsWhenTaskInfoReferencesUnknownTask(t *testing.T) {
+	loader := newTaskInfoTestLoader(t,
+		newTaskInfoTestApp("app1", "app2.check"),
+		newTaskInfoTestApp("app2"),
+	)
+
+	_, err := loader.LoadTasks("*")
+	require.ErrorContains(t, err, `a task with the id "app2.check" does not exist`)
+
+	loader = newTaskInfoTestLoader(t,
+		newTaskInfoTestApp("app1", "unknown.build"),
+	)
+
+	_, err = loader.LoadTasks("*")
+	require.ErrorContains(t, err, "could not find the following apps: unknown")
+}
@@ -12,6 +12,7 @@ type OutputType int
 const (
 	DockerOutput OutputType = iota
 	FileOutput
+	FileTreeOutput
 )
 
 func (o OutputType) String() string {
@@ -20,6 +21,8 @@ func (o OutputType) String() string {
 		return "docker"
 	case FileOutput:
 		return "file"
+	case FileTreeOutput:
+		return "filetree"
 
 	default:
 		return "invalid OutputType"
@@ -84,6 +87,21 @@ func fileOutputs(task *Task) ([]Output, error) {
 			fileCopyUploads = append(fileCopyUploads, &UploadInfoFileCopy{FileCopy: fc})
 		}
 
+		isFileTree, err := isFileTreeOutputPath(task.Directory, fileOutput.Path)
+		if err != nil {
+			return nil, fmt.Errorf("output %q: %w", fileOutput.Path, err)
+		}
+
+		if isFileTree {
+			result = append(result, NewOutputFileTree(
+				fileOutput.Path,
+				task.Directory,
+				s3Uploads,
+				fileCopyUploads,
+			))
+			continue
+		}
+
 		result = append(result, NewOutputFile(
 			fileOutput.Path,
 			filepath.Join(task.Directory, fileOutput.Path),
@@ -0,0 +1,227 @@
+package baur
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/simplesurance/baur/v5/internal/archive"
+	"github.com/simplesurance/baur/v5/internal/digest"
+	"github.com/simplesurance/baur/v5/internal/digest/sha384"
+	"github.com/simplesurance/baur/v5/internal/fs"
+)
+
+// OutputFileTree is a directory or the files matching a glob pattern, created
+// by a task run.
+// The files are packaged into a reproducible tar archive. The archive is
+// uploaded and its digest is the digest of the output.
+type OutputFileTree struct {
+	name            string
+	taskDir         string
+	UploadsS3       []*UploadInfoS3
+	UploadsFilecopy []*UploadInfoFileCopy
+
+	// archivePath, digest and size are set when the archive is
+	// created, digest and size are kept when the archive is removed
+	archivePath string
+	digest      *digest.Digest
+	size        uint64
+}
+
+// NewOutputFileTree instantiates a new OutputFileTree.
+// name is a path to a directory or a glob pattern, relative to taskDir.
+func NewOutputFileTree(name, taskDir string, s3uploads []*UploadInfoS3, filecopyUploads []*UploadInfoFileCopy) *OutputFileTree {
+	return &OutputFileTree{
+		name:            name,
+		taskDir:         taskDir,
+		UploadsS3:       s3uploads,
+		UploadsFilecopy: filecopyUploads,
+	}
+}
+
+// isGlobPattern returns true if path contains glob metacharacters.
+func isGlobPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[{")
+}
+
+// isFileTreeOutputPath returns true if path, relative to taskDir, is a glob
+// pattern or a directory.
+func isFileTreeOutputPath(taskDir, path string) (bool, error) {
+	if isGlobPattern(path) {
+		return true, nil
+	}
+
+	isDir, err := fs.IsDir(filepath.Join(taskDir, path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return isDir, nil
+}
+
+func (f *OutputFileTree) String() string {
+	return "files: " + f.name
+}
+
+func (f *OutputFileTree) Name() string {
+	return f.name
+}
+
+func (f *OutputFileTree) Type() OutputType {
+	return FileTreeOutput
+}
+
+// Files returns the paths of the files that belong to the output, relative to
+// the task directory.
+func (f *OutputFileTree) Files() ([]string, error) {
+	pattern := filepath.Join(f.taskDir, f.name)
+	if !isGlobPattern(f.name) {
+		pattern = filepath.Join(pattern, "**")
+	}
+
+	paths, err := fs.FileGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(paths))
+	for _, p := range paths {
+		relPath, err := filepath.Rel(f.taskDir, p)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, relPath)
+	}
+
+	return result, nil
+}
+
+// Exists returns true if the output is a directory and it exists or if it is
+// a glob pattern that matches at least 1 file.
+func (f *OutputFileTree) Exists() (bool, error) {
+	if !isGlobPattern(f.name) {
+		isDir, err := fs.IsDir(filepath.Join(f.taskDir, f.name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		return isDir, nil
+	}
+
+	files, err := f.Files()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return len(files) > 0, nil
+}
+
+// archiveFilename returns the name of the archive file for the output.
+// Path separators and glob characters in the output name are replaced.
+func archiveFilename(name string) string {
+	return strings.NewReplacer(
+		"/", "_",
+		"\\", "_",
+		"*", "_",
+		"?", "_",
+		"[", "_",
+		"]", "_",
+		"{", "_",
+		"}", "_",
+	).Replace(filepath.Clean(name)) + ".tar"
+}
+
+// ArchivePath returns the path of the tar archive containing the files of the
+// output. If the archive does not exist, it is created in a temporary
+// directory. It is removed when RemoveArchive() is called.
+func (f *OutputFileTree) ArchivePath() (string, error) {
+	if f.archivePath != "" {
+		return f.archivePath, nil
+	}
+
+	files, err := f.Files()
+	if err != nil {
+		return "", fmt.Errorf("resolving files of %s failed: %w", f, err)
+	}
+
+	dir, err := os.MkdirTemp("", "baur-output")
+	if err != nil {
+		return "", err
+	}
+
+	archivePath := filepath.Join(dir, archiveFilename(f.name))
+	if err := archive.CreateTar(archivePath, f.taskDir, files); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("creating archive of %s failed: %w", f, err)
+	}
+
+	digest, err := sha384.File(archivePath)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	size, err := fs.FileSize(archivePath)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	f.archivePath = archivePath
+	f.digest = digest
+	f.size = uint64(size)
+
+	return archivePath, nil
+}
+
+// RemoveArchive removes the archive created by ArchivePath().
+func (f *OutputFileTree) RemoveArchive() error {
+	if f.archivePath == "" {
+		return nil
+	}
+
+	err := os.RemoveAll(filepath.Dir(f.archivePath))
+	if err != nil {
+		return err
+	}
+
+	f.archivePath = ""
+
+	return nil
+}
+
+// Digest returns the sha384 digest of the tar archive of the output.
+func (f *OutputFileTree) Digest() (*digest.Digest, error) {
+	if f.digest == nil {
+		if _, err := f.ArchivePath(); err != nil {
+			return nil, err
+		}
+	}
+
+	return f.digest, nil
+}
+
+// SizeBytes returns the size of the tar archive of the output.
+func (f *OutputFileTree) SizeBytes() (uint64, error) {
+	if f.digest == nil {
+		if _, err := f.ArchivePath(); err != nil {
+			return 0, err
+		}
+	}
+
+	return f.size, nil
+}
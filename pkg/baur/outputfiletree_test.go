@@ -0,0 +1,149 @@
+package baur
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/output/filecopy"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for path, content := range files {
+		absPath := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(absPath), 0o755))
+		require.NoError(t, os.WriteFile(absPath, []byte(content), 0o644))
+	}
+}
+
+func TestFileOutputsCreatesFileTreeOutputsForDirsAndGlobs(t *testing.T) {
+	taskDir := t.TempDir()
+	writeTestFiles(t, taskDir, map[string]string{
+		"dist/index.html":   "index",
+		"dist/css/main.css": "css",
+		"clients/a.go":      "a",
+		"clients/b.go":      "b",
+		"clients/README":    "readme",
+		"app.bin":           "bin",
+	})
+
+	fc := []cfg.FileCopy{{Path: t.TempDir()}}
+	task := Task{
+		Directory: taskDir,
+		Outputs: &cfg.Output{
+			File: []cfg.FileOutput{
+				{Path: "dist", FileCopy: fc},
+				{Path: "clients/*.go", FileCopy: fc},
+				{Path: "app.bin", FileCopy: fc},
+			},
+		},
+	}
+
+	outputs, err := fileOutputs(&task)
+	require.NoError(t, err)
+	require.Len(t, outputs, 3)
+
+	require.IsType(t, &OutputFileTree{}, outputs[0])
+	files, err := outputs[0].(*OutputFileTree).Files()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"dist/index.html", "dist/css/main.css"}, files)
+
+	require.IsType(t, &OutputFileTree{}, outputs[1])
+	files, err = outputs[1].(*OutputFileTree).Files()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"clients/a.go", "clients/b.go"}, files)
+
+	assert.IsType(t, &OutputFile{}, outputs[2])
+}
+
+func TestFileTreeDigestCoversContentNotModificationTimes(t *testing.T) {
+	taskDir := t.TempDir()
+	writeTestFiles(t, taskDir, map[string]string{
+		"dist/index.html": "index",
+		"dist/js/app.js":  "js",
+	})
+
+	o1 := NewOutputFileTree("dist", taskDir, nil, nil)
+	d1, err := o1.Digest()
+	require.NoError(t, err)
+	require.NoError(t, o1.RemoveArchive())
+
+	mtime := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(taskDir, "dist", "index.html"), mtime, mtime))
+
+	o2 := NewOutputFileTree("dist", taskDir, nil, nil)
+	d2, err := o2.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, d1.String(), d2.String())
+
+	writeTestFiles(t, taskDir, map[string]string{"dist/js/app.js": "changed"})
+	o3 := NewOutputFileTree("dist", taskDir, nil, nil)
+	d3, err := o3.Digest()
+	require.NoError(t, err)
+	assert.NotEqual(t, d1.String(), d3.String())
+
+	require.NoError(t, o2.RemoveArchive())
+	require.NoError(t, o3.RemoveArchive())
+}
+
+func TestFileTreeExists(t *testing.T) {
+	taskDir := t.TempDir()
+	writeTestFiles(t, taskDir, map[string]string{"dist/index.html": "index"})
+
+	exists, err := NewOutputFileTree("dist", taskDir, nil, nil).Exists()
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = NewOutputFileTree("dist/*.js", taskDir, nil, nil).Exists()
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = NewOutputFileTree("build/**", taskDir, nil, nil).Exists()
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRestoreFileTreeOutputExtractsArchive(t *testing.T) {
+	srcDir := t.TempDir()
+	writeTestFiles(t, srcDir, map[string]string{
+		"dist/index.html":   "index",
+		"dist/css/main.css": "css",
+	})
+
+	uploadDir := t.TempDir()
+	output := NewOutputFileTree("dist", srcDir, nil, []*UploadInfoFileCopy{{FileCopy: &cfg.FileCopy{Path: uploadDir}}})
+	t.Cleanup(func() { _ = output.RemoveArchive() })
+
+	var results []*UploadResult
+	uploader := NewUploader(nil, nil, filecopy.New(t.Logf))
+	err := uploader.Upload(t.Context(), output,
+		func(Output, UploadInfo, string) {},
+		func(_ Output, r *UploadResult) { results = append(results, r) },
+		nil,
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, filepath.Join(uploadDir, "dist.tar"), results[0].URL)
+
+	storageOutputs, err := toStorageOutputs(results)
+	require.NoError(t, err)
+	require.Len(t, storageOutputs, 1)
+	assert.Equal(t, storage.ArtifactTypeFileTree, storageOutputs[0].Type)
+
+	task := Task{ID: "app.build", Directory: t.TempDir()}
+	restorer := NewOutputRestorer(nil, nil, filecopy.New(t.Logf))
+	require.NoError(t, restorer.Restore(t.Context(), &task, storageOutputs, nil))
+
+	content, err := os.ReadFile(filepath.Join(task.Directory, "dist", "css", "main.css"))
+	require.NoError(t, err)
+	assert.Equal(t, "css", string(content))
+	assert.FileExists(t, filepath.Join(task.Directory, "dist", "index.html"))
+}
@@ -0,0 +1,240 @@
+package baur
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/simplesurance/baur/v5/internal/archive"
+	"github.com/simplesurance/baur/v5/internal/digest"
+	"github.com/simplesurance/baur/v5/internal/digest/sha384"
+	"github.com/simplesurance/baur/v5/internal/fs"
+	"github.com/simplesurance/baur/v5/internal/output/s3"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+// DockerImgPuller is an interface for downloading docker images from a docker registry.
+type DockerImgPuller interface {
+	// Pull downloads the image referenced by uri and returns its ID.
+	Pull(ctx context.Context, uri string) (string, error)
+}
+
+// OutputRestorer downloads recorded outputs of task runs to the locations
+// that are declared in the task configurations.
+type OutputRestorer struct {
+	s3clt       S3Downloader
+	dockerClt   DockerImgPuller
+	filecopyClt FileCopyUploader
+}
+
+func NewOutputRestorer(s3clt S3Downloader, dockerClt DockerImgPuller, filecopyClt FileCopyUploader) *OutputRestorer {
+	return &OutputRestorer{
+		s3clt:       s3clt,
+		dockerClt:   dockerClt,
+		filecopyClt: filecopyClt,
+	}
+}
+
+// RestoreStartFn is a function that is called before an output is downloaded
+// from uri to dest.
+type RestoreStartFn func(output *storage.Output, uri, dest string)
+
+// uploadMethodPreference is the order in that upload locations are tried
+// when an output was uploaded to multiple destinations.
+var uploadMethodPreference = []storage.UploadMethod{
+	storage.UploadMethodFileCopy,
+	storage.UploadMethodS3,
+	storage.UploadMethodDockerRegistry,
+}
+
+// Restore downloads the outputs of a task run of task.
+// File outputs are stored at their path relative to the task directory,
+// archives of file tree outputs are extracted into the task directory, docker
+// images are pulled and their image ID is written to the declared IDFile.
+// If an output was uploaded to multiple destinations, the download is tried
+// from one after the other until it succeeds. Filecopy destinations are
+// preferred over S3 ones.
+// After the download the digest of each output is compared with the one in
+// outputs, if they differ an error is returned and a downloaded file is
+// removed.
+func (r *OutputRestorer) Restore(ctx context.Context, task *Task, outputs []*storage.Output, startFn RestoreStartFn) error {
+	outputs = slices.Clone(outputs)
+	slices.SortFunc(outputs, func(a, b *storage.Output) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, output := range outputs {
+		if err := r.restoreOutput(ctx, task, output, startFn); err != nil {
+			return fmt.Errorf("%s: restoring output %s failed: %w", task, output.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func sortUploadsByPreference(uploads []*storage.Upload) []*storage.Upload {
+	result := slices.Clone(uploads)
+	slices.SortStableFunc(result, func(a, b *storage.Upload) int {
+		return slices.Index(uploadMethodPreference, a.Method) - slices.Index(uploadMethodPreference, b.Method)
+	})
+
+	return result
+}
+
+func (r *OutputRestorer) restoreOutput(ctx context.Context, task *Task, output *storage.Output, startFn RestoreStartFn) error {
+	if len(output.Uploads) == 0 {
+		return errors.New("output has no recorded uploads")
+	}
+
+	wantDigest, err := digest.FromString(output.Digest)
+	if err != nil {
+		return fmt.Errorf("recorded digest %q is invalid: %w", output.Digest, err)
+	}
+
+	dest := filepath.Join(task.Directory, output.Name)
+	if output.Type == storage.ArtifactTypeFileTree {
+		dest = task.Directory
+	}
+
+	if err := fs.Mkdir(filepath.Dir(dest)); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, upload := range sortUploadsByPreference(output.Uploads) {
+		if startFn != nil {
+			startFn(output, upload.URI, dest)
+		}
+
+		switch output.Type {
+		case storage.ArtifactTypeFile:
+			err = r.restoreFile(ctx, upload, dest, wantDigest)
+		case storage.ArtifactTypeFileTree:
+			err = r.restoreFileTree(ctx, upload, dest, wantDigest)
+		case storage.ArtifactTypeDocker:
+			err = r.restoreDockerImage(ctx, upload, dest, wantDigest)
+		default:
+			return fmt.Errorf("output has unsupported type %q", output.Type)
+		}
+		if err == nil {
+			return nil
+		}
+
+		var digestErr *digestMismatchError
+		if errors.As(err, &digestErr) {
+			return err
+		}
+
+		errs = append(errs, fmt.Errorf("downloading %s failed: %w", upload.URI, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+type digestMismatchError struct {
+	uri  string
+	got  *digest.Digest
+	want *digest.Digest
+}
+
+func (e *digestMismatchError) Error() string {
+	return fmt.Sprintf("digest of %s is %s, expected %s", e.uri, e.got, e.want)
+}
+
+func (r *OutputRestorer) restoreFile(ctx context.Context, upload *storage.Upload, dest string, wantDigest *digest.Digest) error {
+	tmpPath := dest + ".baur-download"
+
+	if err := r.downloadAndVerify(ctx, upload, tmpPath, wantDigest); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, dest); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+func (r *OutputRestorer) restoreFileTree(ctx context.Context, upload *storage.Upload, destDir string, wantDigest *digest.Digest) error {
+	tmpDir, err := os.MkdirTemp("", "baur-restore")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archivePath := filepath.Join(tmpDir, "output.tar")
+	if err := r.downloadAndVerify(ctx, upload, archivePath, wantDigest); err != nil {
+		return err
+	}
+
+	return archive.ExtractTar(archivePath, destDir)
+}
+
+// downloadAndVerify downloads the file from upload to dest and verifies that
+// its digest is wantDigest. On error dest is removed.
+func (r *OutputRestorer) downloadAndVerify(ctx context.Context, upload *storage.Upload, dest string, wantDigest *digest.Digest) error {
+	err := r.downloadFile(ctx, upload, dest)
+	if err != nil {
+		_ = os.Remove(dest)
+		return err
+	}
+
+	gotDigest, err := sha384.File(dest)
+	if err != nil {
+		_ = os.Remove(dest)
+		return err
+	}
+
+	if gotDigest.String() != wantDigest.String() {
+		_ = os.Remove(dest)
+		return &digestMismatchError{uri: upload.URI, got: gotDigest, want: wantDigest}
+	}
+
+	return nil
+}
+
+func (r *OutputRestorer) downloadFile(ctx context.Context, upload *storage.Upload, dest string) error {
+	switch upload.Method {
+	case storage.UploadMethodFileCopy:
+		_, err := r.filecopyClt.Upload(ctx, upload.URI, dest)
+		return err
+
+	case storage.UploadMethodS3:
+		bucket, key, err := s3.ParseURL(upload.URI)
+		if err != nil {
+			return fmt.Errorf("stored uri %s is malformed: %w", upload.URI, err)
+		}
+
+		return r.s3clt.Download(ctx, bucket, key, dest)
+
+	default:
+		return fmt.Errorf("restoring file outputs uploaded via %s is not supported", upload.Method)
+	}
+}
+
+func (r *OutputRestorer) restoreDockerImage(ctx context.Context, upload *storage.Upload, idFile string, wantDigest *digest.Digest) error {
+	if upload.Method != storage.UploadMethodDockerRegistry {
+		return fmt.Errorf("restoring docker images uploaded via %s is not supported", upload.Method)
+	}
+
+	imageID, err := r.dockerClt.Pull(ctx, upload.URI)
+	if err != nil {
+		return err
+	}
+
+	gotDigest, err := digest.FromString(imageID)
+	if err != nil {
+		return fmt.Errorf("image id %q of pulled image has an invalid format: %w", imageID, err)
+	}
+
+	if gotDigest.String() != wantDigest.String() {
+		return &digestMismatchError{uri: upload.URI, got: gotDigest, want: wantDigest}
+	}
+
+	return os.WriteFile(idFile, []byte(imageID+"\n"), 0o644)
+}
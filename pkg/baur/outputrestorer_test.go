@@ -0,0 +1,153 @@
+package baur
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/digest/sha384"
+	"github.com/simplesurance/baur/v5/internal/output/filecopy"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+type s3DownloaderFunc func(ctx context.Context, bucket, key, filepath string) error
+
+func (f s3DownloaderFunc) Download(ctx context.Context, bucket, key, filepath string) error {
+	return f(ctx, bucket, key, filepath)
+}
+
+type dockerPullerFunc func(ctx context.Context, uri string) (string, error)
+
+func (f dockerPullerFunc) Pull(ctx context.Context, uri string) (string, error) {
+	return f(ctx, uri)
+}
+
+// createRestoreTestFileOutput creates a file with content in dir and returns
+// an output record describing it as filecopy upload.
+func createRestoreTestFileOutput(t *testing.T, dir, name, content string) *storage.Output {
+	t.Helper()
+
+	path := filepath.Join(dir, filepath.Base(name))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	d, err := sha384.File(path)
+	require.NoError(t, err)
+
+	return &storage.Output{
+		Name:      name,
+		Type:      storage.ArtifactTypeFile,
+		Digest:    d.String(),
+		SizeBytes: uint64(len(content)),
+		Uploads: []*storage.Upload{
+			{URI: path, Method: storage.UploadMethodFileCopy},
+		},
+	}
+}
+
+func TestRestoreFileOutput(t *testing.T) {
+	uploadDir := t.TempDir()
+	task := Task{ID: "app.build", Directory: t.TempDir()}
+	output := createRestoreTestFileOutput(t, uploadDir, "dist/app.tar", "hello")
+
+	var started []string
+	r := NewOutputRestorer(nil, nil, filecopy.New(t.Logf))
+	err := r.Restore(t.Context(), &task, []*storage.Output{output}, func(_ *storage.Output, uri, dest string) {
+		started = append(started, uri+" -> "+dest)
+	})
+	require.NoError(t, err)
+
+	dest := filepath.Join(task.Directory, "dist", "app.tar")
+	content, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+	assert.Equal(t, []string{output.Uploads[0].URI + " -> " + dest}, started)
+}
+
+func TestRestoreFileOutputFailsOnDigestMismatch(t *testing.T) {
+	uploadDir := t.TempDir()
+	task := Task{ID: "app.build", Directory: t.TempDir()}
+	output := createRestoreTestFileOutput(t, uploadDir, "app.tar", "hello")
+	require.NoError(t, os.WriteFile(output.Uploads[0].URI, []byte("modified"), 0o644))
+
+	r := NewOutputRestorer(nil, nil, filecopy.New(t.Logf))
+	err := r.Restore(t.Context(), &task, []*storage.Output{output}, nil)
+	require.ErrorContains(t, err, "expected "+output.Digest)
+
+	assert.NoFileExists(t, filepath.Join(task.Directory, "app.tar"))
+	assert.NoFileExists(t, filepath.Join(task.Directory, "app.tar.baur-download"))
+}
+
+func TestRestoreFileOutputFallsBackToOtherUploads(t *testing.T) {
+	uploadDir := t.TempDir()
+	task := Task{ID: "app.build", Directory: t.TempDir()}
+	output := createRestoreTestFileOutput(t, uploadDir, "app.tar", "hello")
+
+	output.Uploads = append(output.Uploads, &storage.Upload{
+		URI:    "s3://bucket/app.tar",
+		Method: storage.UploadMethodS3,
+	})
+	require.NoError(t, os.Remove(output.Uploads[0].URI))
+
+	s3clt := s3DownloaderFunc(func(_ context.Context, bucket, key, filepath string) error {
+		assert.Equal(t, "bucket", bucket)
+		assert.Equal(t, "app.tar", key)
+		return os.WriteFile(filepath, []byte("hello"), 0o644)
+	})
+
+	r := NewOutputRestorer(s3clt, nil, filecopy.New(t.Logf))
+	require.NoError(t, r.Restore(t.Context(), &task, []*storage.Output{output}, nil))
+	assert.FileExists(t, filepath.Join(task.Directory, "app.tar"))
+}
+
+func TestRestoreDockerOutputWritesIDFile(t *testing.T) {
+	const imageID = "sha256:c4d9b2c6dfd8cbef6d3c1aa5e4bbcf3b41e5a6b3b7b2b3c4c4ad7a3a4bd8e8c1"
+	task := Task{ID: "app.build", Directory: t.TempDir()}
+	output := storage.Output{
+		Name:   "build/image.id",
+		Type:   storage.ArtifactTypeDocker,
+		Digest: imageID,
+		Uploads: []*storage.Upload{
+			{URI: "registry.example.com/app:1", Method: storage.UploadMethodDockerRegistry},
+		},
+	}
+
+	var pulledURIs []string
+	puller := dockerPullerFunc(func(_ context.Context, uri string) (string, error) {
+		pulledURIs = append(pulledURIs, uri)
+		return imageID, nil
+	})
+
+	r := NewOutputRestorer(nil, puller, nil)
+	require.NoError(t, r.Restore(t.Context(), &task, []*storage.Output{&output}, nil))
+
+	assert.Equal(t, []string{"registry.example.com/app:1"}, pulledURIs)
+	content, err := os.ReadFile(filepath.Join(task.Directory, "build", "image.id"))
+	require.NoError(t, err)
+	assert.Equal(t, imageID+"\n", string(content))
+}
+
+func TestRestoreDockerOutputFailsWhenPullFails(t *testing.T) {
+	task := Task{ID: "app.build", Directory: t.TempDir()}
+	output := storage.Output{
+		Name:   "image.id",
+		Type:   storage.ArtifactTypeDocker,
+		Digest: "sha256:c4d9b2c6dfd8cbef6d3c1aa5e4bbcf3b41e5a6b3b7b2b3c4c4ad7a3a4bd8e8c1",
+		Uploads: []*storage.Upload{
+			{URI: "registry.example.com/app:1", Method: storage.UploadMethodDockerRegistry},
+		},
+	}
+
+	puller := dockerPullerFunc(func(context.Context, string) (string, error) {
+		return "", errors.New("connection refused")
+	})
+
+	r := NewOutputRestorer(nil, puller, nil)
+	err := r.Restore(t.Context(), &task, []*storage.Output{&output}, nil)
+	require.ErrorContains(t, err, "connection refused")
+	assert.NoFileExists(t, filepath.Join(task.Directory, "image.id"))
+}
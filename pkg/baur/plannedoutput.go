@@ -0,0 +1,76 @@
+package baur
+
+import (
+	"fmt"
+)
+
+// PlannedOutput describes an output that running a task would produce.
+type PlannedOutput struct {
+	Name    string
+	Type    OutputType
+	Uploads []*PlannedUpload
+}
+
+// PlannedUpload describes an upload destination of a PlannedOutput.
+type PlannedUpload struct {
+	Method      UploadMethod
+	Destination UploadInfo
+}
+
+// PlannedOutputs returns the outputs that running the task would produce
+// and the destinations they would be uploaded to.
+// In contrast to [OutputsFromTask], the outputs do not have to exist. File
+// outputs whose path is a glob pattern or an existing directory are file tree
+// outputs.
+func PlannedOutputs(task *Task) ([]*PlannedOutput, error) {
+	result := make([]*PlannedOutput, 0, len(task.Outputs.DockerImage)+len(task.Outputs.File))
+
+	for _, dockerOutput := range task.Outputs.DockerImage {
+		o := PlannedOutput{
+			Name: dockerOutput.IDFile,
+			Type: DockerOutput,
+		}
+
+		for i := range dockerOutput.RegistryUpload {
+			o.Uploads = append(o.Uploads, &PlannedUpload{
+				Method:      UploadMethodDocker,
+				Destination: &UploadInfoDocker{DockerImageRegistryUpload: &dockerOutput.RegistryUpload[i]},
+			})
+		}
+
+		result = append(result, &o)
+	}
+
+	for _, fileOutput := range task.Outputs.File {
+		isFileTree, err := isFileTreeOutputPath(task.Directory, fileOutput.Path)
+		if err != nil {
+			return nil, fmt.Errorf("output %q: %w", fileOutput.Path, err)
+		}
+
+		o := PlannedOutput{
+			Name: fileOutput.Path,
+			Type: FileOutput,
+		}
+		if isFileTree {
+			o.Type = FileTreeOutput
+		}
+
+		for i := range fileOutput.S3Upload {
+			o.Uploads = append(o.Uploads, &PlannedUpload{
+				Method:      UploadMethodS3,
+				Destination: &UploadInfoS3{S3Upload: &fileOutput.S3Upload[i]},
+			})
+		}
+
+		for i := range fileOutput.FileCopy {
+			o.Uploads = append(o.Uploads, &PlannedUpload{
+				Method:      UploadMethodFilecopy,
+				Destination: &UploadInfoFileCopy{FileCopy: &fileOutput.FileCopy[i]},
+			})
+		}
+
+		result = append(result, &o)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package baur
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+func TestPlannedOutputs(t *testing.T) {
+	taskDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(taskDir, "docs"), 0o755))
+
+	task := Task{
+		Directory: taskDir,
+		Outputs: &cfg.Output{
+			DockerImage: []cfg.DockerImageOutput{
+				{
+					IDFile: "image.id",
+					RegistryUpload: []cfg.DockerImageRegistryUpload{
+						{Registry: "registry.example.com", Repository: "app", Tag: "v1"},
+					},
+				},
+			},
+			File: []cfg.FileOutput{
+				{
+					Path:     "dist/app",
+					S3Upload: []cfg.S3Upload{{Bucket: "artifacts", Key: "app-v1"}},
+					FileCopy: []cfg.FileCopy{{Path: "/srv/artifacts"}},
+				},
+				{
+					Path:     "docs",
+					FileCopy: []cfg.FileCopy{{Path: "/srv/docs"}},
+				},
+			},
+		},
+	}
+
+	outputs, err := PlannedOutputs(&task)
+	require.NoError(t, err)
+	require.Len(t, outputs, 3)
+
+	assert.Equal(t, "image.id", outputs[0].Name)
+	assert.Equal(t, DockerOutput, outputs[0].Type)
+	require.Len(t, outputs[0].Uploads, 1)
+	assert.Equal(t, UploadMethodDocker, outputs[0].Uploads[0].Method)
+	assert.Equal(t, "registry.example.com/app:v1", outputs[0].Uploads[0].Destination.String())
+
+	assert.Equal(t, "dist/app", outputs[1].Name)
+	assert.Equal(t, FileOutput, outputs[1].Type)
+	require.Len(t, outputs[1].Uploads, 2)
+	assert.Equal(t, UploadMethodS3, outputs[1].Uploads[0].Method)
+	assert.Equal(t, "s3://artifacts/app-v1", outputs[1].Uploads[0].Destination.String())
+	assert.Equal(t, UploadMethodFilecopy, outputs[1].Uploads[1].Method)
+	assert.Equal(t, "/srv/artifacts", outputs[1].Uploads[1].Destination.String())
+
+	assert.Equal(t, "docs", outputs[2].Name)
+	assert.Equal(t, FileTreeOutput, outputs[2].Type)
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/simplesurance/baur/v5/internal/archive"
 	"github.com/simplesurance/baur/v5/internal/fs"
 	"github.com/simplesurance/baur/v5/internal/output/s3"
 	"github.com/simplesurance/baur/v5/internal/set"
@@ -48,6 +50,8 @@ type DownloadOutputsParams struct {
 // to S3, others are ignored.
 // If the same output was uploaded to multiple S3 destinations, it is only
 // downloaded from one of them.
+// Archives of file tree outputs are extracted into the task directory in
+// [DownloadOutputsParams.DestDir] and removed afterwards.
 // If a task specified in [DownloadOutputs.TaskIDs] is not part of the release,
 // an error is returned.
 func (m *ReleaseManager) DownloadOutputs(
@@ -111,6 +115,9 @@ func (m *ReleaseManager) DownloadOutputs(
 			return err
 		}
 		destFile := filepath.Join(destDir, filepath.Base(tr.OutputName))
+		if tr.OutputType == storage.ArtifactTypeFileTree {
+			destFile = filepath.Join(destDir, archiveFilename(tr.OutputName))
+		}
 
 		bucket, objectKey, err := s3.ParseURL(tr.URI)
 		if err != nil {
@@ -132,6 +139,16 @@ func (m *ReleaseManager) DownloadOutputs(
 			)
 		}
 
+		if tr.OutputType == storage.ArtifactTypeFileTree {
+			if err := archive.ExtractTar(destFile, destDir); err != nil {
+				return fmt.Errorf("%s (%d): extracting %s failed: %w", tid, tr.RunID, destFile, err)
+			}
+
+			if err := os.Remove(destFile); err != nil {
+				return err
+			}
+		}
+
 		downloadedOutputs.Add(taskOutputID)
 	}
 
@@ -0,0 +1,172 @@
+package baur
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+const (
+	defRunLeaseTTL          = time.Minute
+	defRunLeasePollInterval = 5 * time.Second
+)
+
+// RunLeaser prevents that multiple baur processes execute the same task with
+// the same inputs concurrently.
+// Before a task is executed, a lease for the app name, task name and total
+// input digest is acquired in the storage. If another process holds the
+// lease, it is waited until the lease is released or expired. If the other
+// process recorded a successful run in the meantime, the run is returned
+// instead of a lease.
+// Acquired leases are renewed in the background until they are released.
+// Leases of processes that terminated without releasing them expire after
+// TTL.
+type RunLeaser struct {
+	storer storage.Storer
+	holder string
+
+	// TTL is the duration after that a lease expires when it is not
+	// renewed.
+	TTL time.Duration
+	// PollInterval is the interval in that it is checked if a lease of
+	// another process was released.
+	PollInterval time.Duration
+	// WaitFn is called when the lease of a task is held by another
+	// process, before waiting for it. It can be nil.
+	WaitFn func(task *Task)
+	// RenewErrFn is called when renewing a lease failed. It can be nil.
+	RenewErrFn func(task *Task, err error)
+}
+
+// NewRunLeaser returns a RunLeaser that acquires leases in storer for
+// holder. holder must uniquely identify the baur process.
+func NewRunLeaser(storer storage.Storer, holder string) *RunLeaser {
+	return &RunLeaser{
+		storer:       storer,
+		holder:       holder,
+		TTL:          defRunLeaseTTL,
+		PollInterval: defRunLeasePollInterval,
+	}
+}
+
+// RunLease is an acquired lease for executing a task.
+type RunLease struct {
+	leaser           *RunLeaser
+	task             *Task
+	totalInputDigest string
+
+	stopRenewal func()
+	renewalWg   sync.WaitGroup
+}
+
+// Acquire acquires the lease for executing task with inputs that have the
+// total digest totalInputDigest.
+// If the lease is held by another process, Acquire waits until the lease
+// was released or expired. If a successful run of the task with the same
+// inputs exists when the lease was acquired, the lease is released and the
+// existing run is returned instead.
+// When ctx is canceled while waiting, the cause of ctx is returned.
+func (l *RunLeaser) Acquire(ctx context.Context, task *Task, totalInputDigest string) (*RunLease, *storage.TaskRunWithID, error) {
+	waitFnCalled := false
+
+	for {
+		err := l.storer.AcquireTaskRunLease(ctx, task.AppName, task.Name, totalInputDigest, l.holder, l.TTL)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, storage.ErrExists) {
+			return nil, nil, fmt.Errorf("acquiring lease failed: %w", err)
+		}
+
+		if !waitFnCalled && l.WaitFn != nil {
+			l.WaitFn(task)
+			waitFnCalled = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, context.Cause(ctx)
+		case <-time.After(l.PollInterval):
+		}
+	}
+
+	// the previous holder of the lease, or a process that finished
+	// between the status evaluation and acquiring the lease, might have
+	// recorded a run
+	run, err := l.storer.LatestTaskRunByDigest(ctx, task.AppName, task.Name, totalInputDigest)
+	if err == nil {
+		if err := l.release(ctx, task, totalInputDigest); err != nil {
+			return nil, nil, err
+		}
+
+		return nil, run, nil
+	}
+
+	if !errors.Is(err, storage.ErrNotExist) {
+		return nil, nil, errors.Join(
+			fmt.Errorf("querying existing runs failed: %w", err),
+			l.release(ctx, task, totalInputDigest),
+		)
+	}
+
+	lease := RunLease{
+		leaser:           l,
+		task:             task,
+		totalInputDigest: totalInputDigest,
+	}
+	lease.startRenewal(ctx)
+
+	return &lease, nil, nil
+}
+
+func (l *RunLeaser) release(ctx context.Context, task *Task, totalInputDigest string) error {
+	err := l.storer.ReleaseTaskRunLease(context.WithoutCancel(ctx), task.AppName, task.Name, totalInputDigest, l.holder)
+	if err != nil {
+		return fmt.Errorf("releasing lease failed: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RunLease) startRenewal(ctx context.Context) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.stopRenewal = cancel
+
+	r.renewalWg.Add(1)
+	go func() {
+		defer r.renewalWg.Done()
+
+		ticker := time.NewTicker(max(r.leaser.TTL/3, time.Millisecond))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := r.leaser.storer.RenewTaskRunLease(
+				ctx, r.task.AppName, r.task.Name, r.totalInputDigest, r.leaser.holder, r.leaser.TTL,
+			)
+			if err != nil && ctx.Err() == nil && r.leaser.RenewErrFn != nil {
+				r.leaser.RenewErrFn(r.task, err)
+			}
+		}
+	}()
+}
+
+// Release stops renewing the lease and removes it from the storage.
+// If the lease expired and was acquired by another process, an error
+// wrapping [storage.ErrNotExist] is returned.
+func (r *RunLease) Release(ctx context.Context) error {
+	r.stopRenewal()
+	r.renewalWg.Wait()
+
+	return r.leaser.release(ctx, r.task, r.totalInputDigest)
+}
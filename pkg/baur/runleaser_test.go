@@ -0,0 +1,166 @@
+package baur
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+// leaseStorer is an in-memory implementation of the lease and
+// LatestTaskRunByDigest methods of storage.Storer, calling any other method
+// panics.
+type leaseStorer struct {
+	storage.Storer
+
+	mu     sync.Mutex
+	leases map[string]string // key -> holder
+	runs   map[string]*storage.TaskRunWithID
+}
+
+func newLeaseStorer() *leaseStorer {
+	return &leaseStorer{
+		leases: map[string]string{},
+		runs:   map[string]*storage.TaskRunWithID{},
+	}
+}
+
+func leaseKey(appName, taskName, digest string) string {
+	return appName + "." + taskName + "." + digest
+}
+
+func (s *leaseStorer) AcquireTaskRunLease(_ context.Context, appName, taskName, digest, holder string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := leaseKey(appName, taskName, digest)
+	if h, exists := s.leases[key]; exists && h != holder {
+		return storage.ErrExists
+	}
+
+	s.leases[key] = holder
+	return nil
+}
+
+func (s *leaseStorer) RenewTaskRunLease(_ context.Context, appName, taskName, digest, holder string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.leases[leaseKey(appName, taskName, digest)] != holder {
+		return storage.ErrNotExist
+	}
+
+	return nil
+}
+
+func (s *leaseStorer) ReleaseTaskRunLease(_ context.Context, appName, taskName, digest, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := leaseKey(appName, taskName, digest)
+	if s.leases[key] != holder {
+		return storage.ErrNotExist
+	}
+
+	delete(s.leases, key)
+	return nil
+}
+
+func (s *leaseStorer) LatestTaskRunByDigest(_ context.Context, appName, taskName, digest string) (*storage.TaskRunWithID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, exists := s.runs[leaseKey(appName, taskName, digest)]
+	if !exists {
+		return nil, storage.ErrNotExist
+	}
+
+	return run, nil
+}
+
+func (s *leaseStorer) addRun(appName, taskName, digest string, id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs[leaseKey(appName, taskName, digest)] = &storage.TaskRunWithID{ID: id}
+}
+
+func TestRunLeaserWaitsForHolderAndReturnsItsRun(t *testing.T) {
+	storer := newLeaseStorer()
+	task := &Task{ID: "app.build", AppName: "app", Name: "build"}
+
+	l1 := NewRunLeaser(storer, "holder1")
+	lease, run, err := l1.Acquire(t.Context(), task, "123")
+	require.NoError(t, err)
+	require.Nil(t, run)
+	require.NotNil(t, lease)
+
+	l2 := NewRunLeaser(storer, "holder2")
+	l2.PollInterval = time.Millisecond
+	waiting := make(chan struct{})
+	l2.WaitFn = func(*Task) { close(waiting) }
+
+	type result struct {
+		lease *RunLease
+		run   *storage.TaskRunWithID
+		err   error
+	}
+	resultCh := make(chan *result)
+	go func() {
+		lease, run, err := l2.Acquire(t.Context(), task, "123")
+		resultCh <- &result{lease, run, err}
+	}()
+
+	<-waiting
+	storer.addRun("app", "build", "123", 42)
+	require.NoError(t, lease.Release(t.Context()))
+
+	res := <-resultCh
+	require.NoError(t, res.err)
+	assert.Nil(t, res.lease)
+	require.NotNil(t, res.run)
+	assert.Equal(t, 42, res.run.ID)
+	assert.Empty(t, storer.leases, "lease was not released")
+}
+
+func TestRunLeaserAcquiresLeaseWhenHolderFailed(t *testing.T) {
+	storer := newLeaseStorer()
+	task := &Task{ID: "app.build", AppName: "app", Name: "build"}
+
+	lease1, _, err := NewRunLeaser(storer, "holder1").Acquire(t.Context(), task, "123")
+	require.NoError(t, err)
+
+	l2 := NewRunLeaser(storer, "holder2")
+	l2.PollInterval = time.Millisecond
+	l2.WaitFn = func(*Task) {
+		assert.NoError(t, lease1.Release(t.Context()))
+	}
+
+	lease2, run, err := l2.Acquire(t.Context(), task, "123")
+	require.NoError(t, err)
+	assert.Nil(t, run)
+	require.NotNil(t, lease2)
+	assert.Equal(t, "holder2", storer.leases[leaseKey("app", "build", "123")])
+
+	require.NoError(t, lease2.Release(t.Context()))
+}
+
+func TestRunLeaserStopsWaitingWhenContextIsCanceled(t *testing.T) {
+	storer := newLeaseStorer()
+	task := &Task{ID: "app.build", AppName: "app", Name: "build"}
+
+	_, _, err := NewRunLeaser(storer, "holder1").Acquire(t.Context(), task, "123")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	l2 := NewRunLeaser(storer, "holder2")
+	l2.WaitFn = func(*Task) { cancel() }
+
+	_, _, err = l2.Acquire(ctx, task, "123")
+	require.ErrorIs(t, err, context.Canceled)
+}
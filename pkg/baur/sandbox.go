@@ -0,0 +1,196 @@
+package baur
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/simplesurance/baur/v5/internal/fs"
+)
+
+// Sandbox is a temporary directory that mirrors the layout of the baur
+// repository and only contains the input files of a task.
+// Running the command of a task in a sandbox ensures that it can only read
+// files that are tracked as inputs via relative paths.
+// Reads via absolute paths, for example into the real repository directory,
+// are not prevented.
+type Sandbox struct {
+	dir     string
+	task    *Task
+	taskDir string
+}
+
+// NewSandbox creates a temporary directory and copies the files of inputs
+// into it, at the same path relative to the repository root.
+// Input files that are not located in the repository are not copied.
+func NewSandbox(task *Task, inputs *Inputs) (*Sandbox, error) {
+	relTaskDir, err := filepath.Rel(task.RepositoryRoot, task.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "baur-sandbox")
+	if err != nil {
+		return nil, err
+	}
+
+	sb := Sandbox{
+		dir:     dir,
+		task:    task,
+		taskDir: filepath.Join(dir, relTaskDir),
+	}
+
+	if err := sb.copyInputs(inputs); err != nil {
+		_ = sb.Remove()
+		return nil, err
+	}
+
+	return &sb, nil
+}
+
+func (s *Sandbox) copyInputs(inputs *Inputs) error {
+	if err := fs.Mkdir(s.taskDir); err != nil {
+		return err
+	}
+
+	for _, in := range inputs.Inputs() {
+		f, ok := in.(*InputFile)
+		if !ok {
+			continue
+		}
+
+		if !filepath.IsLocal(f.RelPath()) {
+			continue
+		}
+
+		dest := filepath.Join(s.dir, f.RelPath())
+		if err := fs.Mkdir(filepath.Dir(dest)); err != nil {
+			return err
+		}
+
+		if err := fs.CopyFile(f.AbsPath(), dest); err != nil {
+			return fmt.Errorf("copying input %s into sandbox failed: %w", f, err)
+		}
+	}
+
+	return nil
+}
+
+// Dir returns the path of the sandbox directory.
+func (s *Sandbox) Dir() string {
+	return s.dir
+}
+
+// Task returns a copy of the task with the directory pointing to the
+// corresponding directory in the sandbox.
+func (s *Sandbox) Task() *Task {
+	result := *s.task
+	result.Directory = s.taskDir
+
+	return &result
+}
+
+// CopyOutputs replaces the declared file outputs and docker image ID files
+// in the task directory of the repository with the ones in the sandbox.
+// Existing outputs in the task directory are deleted first, to prevent that
+// stale files from previous runs are uploaded.
+// An error is returned when a declared output does not exist in the sandbox.
+func (s *Sandbox) CopyOutputs() error {
+	if s.task.Outputs == nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(s.task.Outputs.File)+len(s.task.Outputs.DockerImage))
+	for _, out := range s.task.Outputs.File {
+		paths = append(paths, out.Path)
+	}
+	for _, out := range s.task.Outputs.DockerImage {
+		paths = append(paths, out.IDFile)
+	}
+
+	for _, path := range paths {
+		if err := s.removeOutput(path); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range paths {
+		if err := s.copyOutput(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeOutput deletes the files of the output path in the task directory
+// of the repository. Directories are kept.
+func (s *Sandbox) removeOutput(path string) error {
+	isFileTree, err := isFileTreeOutputPath(s.task.Directory, path)
+	if err != nil {
+		return err
+	}
+
+	files := []string{path}
+	if isFileTree {
+		files, err = NewOutputFileTree(path, s.task.Directory, nil, nil).Files()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return fmt.Errorf("resolving output %s in task directory failed: %w", path, err)
+		}
+	}
+
+	for _, relPath := range files {
+		err := os.Remove(filepath.Join(s.task.Directory, relPath))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("deleting previous output %s failed: %w", relPath, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Sandbox) copyOutput(path string) error {
+	isFileTree, err := isFileTreeOutputPath(s.taskDir, path)
+	if err != nil {
+		return err
+	}
+
+	files := []string{path}
+	if isFileTree {
+		files, err = NewOutputFileTree(path, s.taskDir, nil, nil).Files()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("output %s was not created by the task command", path)
+			}
+
+			return fmt.Errorf("resolving output %s in sandbox failed: %w", path, err)
+		}
+	}
+
+	for _, relPath := range files {
+		src := filepath.Join(s.taskDir, relPath)
+		if !fs.FileExists(src) {
+			return fmt.Errorf("output %s was not created by the task command", relPath)
+		}
+
+		dest := filepath.Join(s.task.Directory, relPath)
+		if err := fs.Mkdir(filepath.Dir(dest)); err != nil {
+			return err
+		}
+
+		if err := fs.CopyFile(src, dest); err != nil {
+			return fmt.Errorf("copying output %s from sandbox failed: %w", relPath, err)
+		}
+	}
+
+	return nil
+}
+
+// Remove deletes the sandbox directory.
+func (s *Sandbox) Remove() error {
+	return os.RemoveAll(s.dir)
+}
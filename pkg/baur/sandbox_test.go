@@ -0,0 +1,120 @@
+package baur
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+func createSandboxTestTask(t *testing.T, command string, outputs cfg.Output) (*Task, *Inputs) {
+	t.Helper()
+
+	repoDir := t.TempDir()
+	taskDir := filepath.Join(repoDir, "app")
+
+	fstest.WriteToFile(t, []byte("declared"), filepath.Join(taskDir, "src", "declared.txt"))
+	fstest.WriteToFile(t, []byte("undeclared"), filepath.Join(taskDir, "undeclared.txt"))
+	fstest.WriteToFile(t, []byte("lib"), filepath.Join(repoDir, "lib", "lib.txt"))
+
+	task := Task{
+		ID:             "app.build",
+		RepositoryRoot: repoDir,
+		Directory:      taskDir,
+		Command:        []string{"sh", "-c", command},
+		Outputs:        &outputs,
+	}
+
+	inputs := NewInputs([]Input{
+		NewInputFile(filepath.Join(taskDir, "src", "declared.txt"), filepath.Join("app", "src", "declared.txt"), false),
+		NewInputFile(filepath.Join(repoDir, "lib", "lib.txt"), filepath.Join("lib", "lib.txt"), false),
+		NewInputEnvVar("HOME", "/root"),
+	})
+
+	return &task, inputs
+}
+
+func TestRunInSandboxCopiesOutputsBack(t *testing.T) {
+	task, inputs := createSandboxTestTask(t,
+		"mkdir -p dist/docs && cat src/declared.txt ../lib/lib.txt > dist/app.txt && echo doc > dist/docs/index.html",
+		cfg.Output{
+			File: []cfg.FileOutput{
+				{Path: "dist/app.txt"},
+				{Path: "dist/docs"},
+			},
+		},
+	)
+
+	tr := NewTaskRunner(nil, false)
+	res, err := tr.RunInSandbox(t.Context(), task, inputs)
+	require.NoError(t, err)
+	require.NoError(t, res.ExpectSuccess())
+
+	content, err := os.ReadFile(filepath.Join(task.Directory, "dist", "app.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "declaredlib", string(content))
+	assert.FileExists(t, filepath.Join(task.Directory, "dist", "docs", "index.html"))
+}
+
+func TestRunInSandboxReplacesPreviousOutputs(t *testing.T) {
+	task, inputs := createSandboxTestTask(t,
+		"mkdir -p dist && echo new > dist/app.txt",
+		cfg.Output{
+			File: []cfg.FileOutput{{Path: "dist"}},
+		},
+	)
+	fstest.WriteToFile(t, []byte("old"), filepath.Join(task.Directory, "dist", "app.txt"))
+	fstest.WriteToFile(t, []byte("stale"), filepath.Join(task.Directory, "dist", "stale.txt"))
+
+	tr := NewTaskRunner(nil, false)
+	res, err := tr.RunInSandbox(t.Context(), task, inputs)
+	require.NoError(t, err)
+	require.NoError(t, res.ExpectSuccess())
+
+	content, err := os.ReadFile(filepath.Join(task.Directory, "dist", "app.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(content))
+	assert.NoFileExists(t, filepath.Join(task.Directory, "dist", "stale.txt"))
+}
+
+func TestRunInSandboxFailsWhenOutputIsNotCreated(t *testing.T) {
+	task, inputs := createSandboxTestTask(t, "true", cfg.Output{
+		File: []cfg.FileOutput{{Path: "out.txt"}},
+	})
+	fstest.WriteToFile(t, []byte("stale"), filepath.Join(task.Directory, "out.txt"))
+
+	tr := NewTaskRunner(nil, false)
+	_, err := tr.RunInSandbox(t.Context(), task, inputs)
+	require.ErrorContains(t, err, "out.txt was not created")
+	assert.NoFileExists(t, filepath.Join(task.Directory, "out.txt"))
+}
+
+func TestRunInSandboxFailsWhenUndeclaredFileIsRead(t *testing.T) {
+	task, inputs := createSandboxTestTask(t, "cat undeclared.txt > out.txt", cfg.Output{
+		File: []cfg.FileOutput{{Path: "out.txt"}},
+	})
+
+	tr := NewTaskRunner(nil, false)
+	res, err := tr.RunInSandbox(t.Context(), task, inputs)
+	require.NoError(t, err)
+	require.Error(t, res.ExpectSuccess())
+	assert.Contains(t, string(res.Output), "undeclared.txt")
+	assert.NoFileExists(t, filepath.Join(task.Directory, "out.txt"))
+}
+
+func TestSandboxIsRemoved(t *testing.T) {
+	task, inputs := createSandboxTestTask(t, "true", cfg.Output{})
+
+	sb, err := NewSandbox(task, inputs)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(sb.Dir(), "app", "src", "declared.txt"))
+	assert.NoFileExists(t, filepath.Join(sb.Dir(), "app", "undeclared.txt"))
+
+	require.NoError(t, sb.Remove())
+	assert.NoDirExists(t, sb.Dir())
+}
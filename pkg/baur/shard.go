@@ -0,0 +1,188 @@
+package baur
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/simplesurance/baur/v5/internal/set"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+// defShardTaskDuration is the duration that is assumed for tasks when no
+// durations of previous runs are known.
+const defShardTaskDuration = time.Second
+
+// ShardTasks partitions tasks into shardCount shards.
+// The partition only depends on the passed tasks and durations, calling the
+// function with the same arguments always results in the same shards.
+// Tasks that are connected via TaskInfo dependencies are assigned to the same
+// shard. The shards are balanced by the sum of the expected durations of
+// their tasks. durations contains the expected duration per task ID, for
+// tasks that are missing in durations the average of the known durations is
+// assumed.
+// The tasks in a shard are in the same order as in tasks.
+func ShardTasks(tasks []*Task, shardCount int, durations map[string]time.Duration) [][]*Task {
+	type group struct {
+		tasks    []*Task
+		duration time.Duration
+	}
+
+	taskOrder := make(map[string]int, len(tasks))
+	for i, t := range tasks {
+		taskOrder[t.ID] = i
+	}
+
+	groups := make([]*group, 0, len(tasks))
+	for _, connected := range connectedTasks(tasks) {
+		groups = append(groups, &group{tasks: connected})
+	}
+
+	defDuration := avgDuration(durations)
+	for _, g := range groups {
+		for _, t := range g.tasks {
+			d, exists := durations[t.ID]
+			if !exists {
+				d = defDuration
+			}
+			g.duration += d
+		}
+	}
+
+	// groups with the longest durations are assigned first to the shard
+	// with the smallest sum of durations, the first task ID is used as
+	// tie-breaker to make the result deterministic
+	slices.SortFunc(groups, func(a, b *group) int {
+		if c := cmp.Compare(b.duration, a.duration); c != 0 {
+			return c
+		}
+		return cmp.Compare(taskOrder[a.tasks[0].ID], taskOrder[b.tasks[0].ID])
+	})
+
+	shards := make([][]*Task, shardCount)
+	shardDurations := make([]time.Duration, shardCount)
+	for _, g := range groups {
+		idx := 0
+		for i := 1; i < shardCount; i++ {
+			if shardDurations[i] < shardDurations[idx] {
+				idx = i
+			}
+		}
+
+		shards[idx] = append(shards[idx], g.tasks...)
+		shardDurations[idx] += g.duration
+	}
+
+	for _, shard := range shards {
+		slices.SortFunc(shard, func(a, b *Task) int {
+			return cmp.Compare(taskOrder[a.ID], taskOrder[b.ID])
+		})
+	}
+
+	return shards
+}
+
+// connectedTasks groups tasks that are directly or indirectly connected via
+// TaskInfo dependencies. Dependencies that are not in tasks are ignored.
+// The groups and the tasks in a group are in the order of tasks.
+func connectedTasks(tasks []*Task) [][]*Task {
+	parent := make(map[string]string, len(tasks))
+	for _, t := range tasks {
+		parent[t.ID] = t.ID
+	}
+
+	var root func(id string) string
+	root = func(id string) string {
+		if parent[id] == id {
+			return id
+		}
+		r := root(parent[id])
+		parent[id] = r
+		return r
+	}
+
+	for _, t := range tasks {
+		for _, dep := range t.TaskInfoDependencies {
+			if _, exists := parent[dep.Task.ID]; !exists {
+				continue
+			}
+
+			r1, r2 := root(t.ID), root(dep.Task.ID)
+			if r1 != r2 {
+				parent[r2] = r1
+			}
+		}
+	}
+
+	var result [][]*Task
+	groupIdx := map[string]int{}
+	for _, t := range tasks {
+		r := root(t.ID)
+		idx, exists := groupIdx[r]
+		if !exists {
+			idx = len(result)
+			groupIdx[r] = idx
+			result = append(result, nil)
+		}
+		result[idx] = append(result[idx], t)
+	}
+
+	return result
+}
+
+func avgDuration(durations map[string]time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return defShardTaskDuration
+	}
+
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+
+	return sum / time.Duration(len(durations))
+}
+
+// AvgTaskRunDurations returns the average duration of the last maxRuns
+// successful runs per task ID, of the passed tasks. Only runs that stopped
+// before the passed time are considered. The durations are rounded to
+// seconds. Tasks without runs are not contained in the result.
+func AvgTaskRunDurations(
+	ctx context.Context,
+	storer storage.Storer,
+	tasks []*Task,
+	stoppedBefore time.Time,
+	maxRuns int,
+) (map[string]time.Duration, error) {
+	if len(tasks) == 0 {
+		return map[string]time.Duration{}, nil
+	}
+
+	taskIDs := set.Set[string]{}
+	appNames := set.Set[string]{}
+	for _, t := range tasks {
+		taskIDs.Add(t.ID)
+		appNames.Add(t.AppName)
+	}
+
+	appNamesSl := appNames.Slice()
+	slices.Sort(appNamesSl)
+
+	durations, err := storer.AvgTaskRunDurations(ctx, appNamesSl, stoppedBefore, maxRuns)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]time.Duration, len(durations))
+	for _, d := range durations {
+		id := taskID(d.ApplicationName, d.TaskName)
+		if !taskIDs.Contains(id) {
+			continue
+		}
+
+		result[id] = d.AvgDuration.Round(time.Second)
+	}
+
+	return result, nil
+}
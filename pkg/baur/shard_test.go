@@ -0,0 +1,74 @@
+package baur
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardTasksBalancesByDuration(t *testing.T) {
+	a := newSchedulerTestTask("app.a")
+	b := newSchedulerTestTask("app.b")
+	c := newSchedulerTestTask("app.c")
+	d := newSchedulerTestTask("app.d")
+	tasks := []*Task{a, b, c, d}
+
+	durations := map[string]time.Duration{
+		"app.a": 10 * time.Minute,
+		"app.b": 4 * time.Minute,
+		"app.c": 3 * time.Minute,
+		"app.d": 2 * time.Minute,
+	}
+
+	shards := ShardTasks(tasks, 2, durations)
+	require.Len(t, shards, 2)
+	assert.Equal(t, []*Task{a}, shards[0])
+	assert.Equal(t, []*Task{b, c, d}, shards[1])
+
+	assert.Equal(t, shards, ShardTasks(tasks, 2, durations), "partition is not deterministic")
+}
+
+func TestShardTasksAssignsDependenciesToSameShard(t *testing.T) {
+	gen := newSchedulerTestTask("proto.gen")
+	build := newSchedulerTestTask("app.build", gen)
+	deploy := newSchedulerTestTask("app.deploy", build)
+	lint := newSchedulerTestTask("app.lint")
+	other := newSchedulerTestTask("other.build")
+	tasks := []*Task{build, deploy, lint, other, gen}
+
+	shards := ShardTasks(tasks, 3, nil)
+	require.Len(t, shards, 3)
+	assert.Equal(t, []*Task{build, deploy, gen}, shards[0])
+	assert.Equal(t, []*Task{lint}, shards[1])
+	assert.Equal(t, []*Task{other}, shards[2])
+}
+
+func TestShardTasksUsesAverageDurationForUnknownTasks(t *testing.T) {
+	a := newSchedulerTestTask("app.a")
+	b := newSchedulerTestTask("app.b")
+	c := newSchedulerTestTask("app.c")
+	tasks := []*Task{a, b, c}
+
+	// app.c is assumed to run as long as the average of app.a and app.b
+	durations := map[string]time.Duration{
+		"app.a": 3 * time.Minute,
+		"app.b": time.Minute,
+	}
+
+	shards := ShardTasks(tasks, 2, durations)
+	require.Len(t, shards, 2)
+	assert.Equal(t, []*Task{a}, shards[0])
+	assert.Equal(t, []*Task{b, c}, shards[1])
+}
+
+func TestShardTasksMoreShardsThanTasks(t *testing.T) {
+	a := newSchedulerTestTask("app.a")
+
+	shards := ShardTasks([]*Task{a}, 3, nil)
+	require.Len(t, shards, 3)
+	assert.Equal(t, []*Task{a}, shards[0])
+	assert.Empty(t, shards[1])
+	assert.Empty(t, shards[2])
+}
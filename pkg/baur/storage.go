@@ -3,6 +3,7 @@ package baur
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/simplesurance/baur/v5/internal/vcs/git"
 	"github.com/simplesurance/baur/v5/pkg/storage"
@@ -53,6 +54,11 @@ func StoreRun(
 		return -1, err
 	}
 
+	log, err := storageLog(runResult)
+	if err != nil {
+		return -1, err
+	}
+
 	tr := storage.TaskRunFull{
 		TaskRun: storage.TaskRun{
 			ApplicationName:  task.AppName,
@@ -63,14 +69,41 @@ func StoreRun(
 			StopTimestamp:    runResult.StopTime,
 			TotalInputDigest: totalDigest.String(),
 			Result:           result,
+			ExitCode:         runResult.ExitCode,
+			Attempts:         runResult.Attempts,
 		},
 		Inputs:  *storageInputs,
 		Outputs: storageOutputs,
+		Log:     log,
 	}
 
 	return storer.SaveTaskRun(ctx, &tr)
 }
 
+// storageLog returns the log of runResult that is stored.
+// If the complete output was uploaded, only its URL is stored. Otherwise the
+// complete output is stored in the database.
+func storageLog(runResult *RunResult) (*storage.TaskRunLog, error) {
+	if runResult.LogURL != "" {
+		return &storage.TaskRunLog{URL: runResult.LogURL}, nil
+	}
+
+	if path := runResult.LogFile(); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading task output failed: %w", err)
+		}
+
+		return &storage.TaskRunLog{Content: content}, nil
+	}
+
+	if runResult.Output == nil {
+		return nil, nil
+	}
+
+	return &storage.TaskRunLog{Content: runResult.Output}, nil
+}
+
 func inputsToStorageInputs(inputs *Inputs) (*storage.Inputs, error) {
 	var result storage.Inputs
 
@@ -134,6 +167,8 @@ func toStorageOutputs(uploadResults []*UploadResult) ([]*storage.Output, error)
 				outputType = storage.ArtifactTypeDocker
 			case FileOutput:
 				outputType = storage.ArtifactTypeFile
+			case FileTreeOutput:
+				outputType = storage.ArtifactTypeFileTree
 			default:
 				return nil, fmt.Errorf("output %q is of unsupported type: %s", uploadResult.Output, uploadResult.Output.Type())
 			}
@@ -3,6 +3,8 @@ package baur
 import (
 	"fmt"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/simplesurance/baur/v5/pkg/cfg"
 )
@@ -22,15 +24,34 @@ type Task struct {
 
 	Name             string
 	Command          []string
+	Environment      *cfg.Environment
 	UnresolvedInputs *cfg.Input
 	Outputs          *cfg.Output
 	CfgFilepaths     []string
+	// Timeout is the maximum duration the command is allowed to run, 0
+	// means no timeout.
+	Timeout time.Duration
+	// Retries is the number of times the command is rerun when it fails.
+	Retries int
+	// RetryDelay is the duration to wait before the command is rerun.
+	RetryDelay time.Duration
+	// Weight is the number of parallelism slots that the task occupies
+	// while it runs, it is at least 1.
+	Weight uint
+	// MutexGroup is the name of the group of tasks that must not run in
+	// parallel, it is empty if the task is not part of a group.
+	MutexGroup string
 
 	TaskInfoDependencies []*TaskInfo
 }
 
 // NewTask returns a new Task.
 func NewTask(cfg *cfg.Task, appName, repositoryRootdir, workingDir string) *Task {
+	// the timeout and retry delay were validated when the config was
+	// loaded, invalid values can not happen
+	timeout, _ := cfg.TimeoutDuration()
+	retryDelay, _ := cfg.RetryDelayDuration()
+
 	return &Task{
 		ID:               taskID(appName, cfg.Name),
 		RepositoryRoot:   repositoryRootdir,
@@ -38,22 +59,28 @@ func NewTask(cfg *cfg.Task, appName, repositoryRootdir, workingDir string) *Task
 		Outputs:          &cfg.Output,
 		CfgFilepaths:     cfg.Filepaths(),
 		Command:          cfg.Command,
+		Environment:      &cfg.Environment,
 		Name:             cfg.Name,
 		AppName:          appName,
 		UnresolvedInputs: &cfg.Input,
+		Timeout:          timeout,
+		Retries:          cfg.Retries,
+		RetryDelay:       retryDelay,
+		Weight:           uint(max(cfg.Weight, 1)),
+		MutexGroup:       cfg.MutexGroup,
 	}
 }
 
 // setTaskInfoDependencies initializes the t.taskInfoDependencies field.
-// appTasks must be all tasks that are defined for the App to that t belongs.
-func (t *Task) setTaskInfoDependencies(appTasks map[string]*Task) error {
+// tasks must contain all tasks that are referenced by t as TaskInfo input.
+func (t *Task) setTaskInfoDependencies(tasks map[string]*Task) error {
 	for _, ti := range t.UnresolvedInputs.TaskInfos {
-		id := taskID(t.AppName, ti.TaskName)
-		dep, exists := appTasks[id]
+		id := t.taskInfoTaskID(&ti)
+		dep, exists := tasks[id]
 		if !exists {
 			return fmt.Errorf(
-				"%q references as Input.TaskInfo the task_id %q, a task with the id %q does not exist",
-				t.ID, id, ti.TaskName,
+				"%q references as Input.TaskInfo the task %q, a task with the id %q does not exist",
+				t.ID, ti.TaskName, id,
 			)
 		}
 
@@ -66,6 +93,28 @@ func (t *Task) setTaskInfoDependencies(appTasks map[string]*Task) error {
 	return nil
 }
 
+// taskInfoTaskID returns the ID of the task that is referenced by ti.
+// If ti only contains a task name, it references a task of the same app.
+func (t *Task) taskInfoTaskID(ti *cfg.TaskInfo) string {
+	if ti.IsTaskID() {
+		return ti.TaskName
+	}
+
+	return taskID(t.AppName, ti.TaskName)
+}
+
+// taskInfoAppNames returns the names of the apps that contain the tasks that
+// are referenced by t as TaskInfo inputs.
+func (t *Task) taskInfoAppNames() []string {
+	result := make([]string, 0, len(t.UnresolvedInputs.TaskInfos))
+	for _, ti := range t.UnresolvedInputs.TaskInfos {
+		appName, _, _ := strings.Cut(t.taskInfoTaskID(&ti), ".")
+		result = append(result, appName)
+	}
+
+	return result
+}
+
 // String returns ID()
 func (t *Task) String() string {
 	return t.ID
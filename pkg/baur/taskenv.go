@@ -0,0 +1,100 @@
+package baur
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/simplesurance/baur/v5/internal/set"
+)
+
+// CommandEnv returns the environment variables, in the format KEY=VALUE,
+// that are passed to the command of task.
+// environ are the environment variables of the baur process in the same
+// format, as returned by os.Environ().
+// Variables of environ are included according to Environment.Inherit of
+// the task, variables in Environment.Set are added and overwrite inherited
+// ones.
+func CommandEnv(task *Task, environ []string) ([]string, error) {
+	if task.Environment == nil {
+		return environ, nil
+	}
+
+	inheritAll, inheritPatterns := task.Environment.InheritedEnvVars()
+
+	result := make([]string, 0, len(environ)+len(task.Environment.Set))
+	for _, env := range environ {
+		name, _, _ := strings.Cut(env, "=")
+
+		if _, exists := task.Environment.Set[name]; exists {
+			continue
+		}
+
+		if !inheritAll {
+			matches, err := envVarNameMatchesAny(name, inheritPatterns)
+			if err != nil {
+				return nil, fmt.Errorf("Environment.inherit: %w", err)
+			}
+
+			if !matches {
+				continue
+			}
+		}
+
+		result = append(result, env)
+	}
+
+	setNames := make([]string, 0, len(task.Environment.Set))
+	for name := range task.Environment.Set {
+		setNames = append(setNames, name)
+	}
+	slices.Sort(setNames)
+
+	for _, name := range setNames {
+		result = append(result, name+"="+task.Environment.Set[name])
+	}
+
+	return result, nil
+}
+
+func envVarNameMatchesAny(name string, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		matches, err := path.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("environment variable name %q: %w", pattern, err)
+		}
+
+		if matches {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// UntrackedEnvVars returns the sorted names of environment variables that
+// are passed to the command of task but are not tracked as inputs.
+// env are the environment variables of the command, in the format
+// KEY=VALUE, as returned by CommandEnv(). inputs are the resolved inputs of
+// task.
+func UntrackedEnvVars(env []string, inputs *Inputs) []string {
+	tracked := set.Set[string]{}
+	for _, in := range inputs.Inputs() {
+		if envVar, ok := in.(*InputEnvVar); ok {
+			tracked.Add(envVar.Name())
+		}
+	}
+
+	var result []string
+	for _, e := range env {
+		name, _, _ := strings.Cut(e, "=")
+		if !tracked.Contains(name) {
+			result = append(result, name)
+		}
+	}
+
+	slices.Sort(result)
+
+	return result
+}
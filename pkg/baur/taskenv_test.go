@@ -0,0 +1,72 @@
+package baur
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+func TestCommandEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/root", "GOFLAGS=-mod=vendor", "GOPATH=/go", "SECRET="}
+
+	testcases := []struct {
+		name        string
+		environment *cfg.Environment
+		expected    []string
+	}{
+		{
+			name:     "nil environment inherits all",
+			expected: environ,
+		},
+		{
+			name:        "unset inherit inherits all",
+			environment: &cfg.Environment{},
+			expected:    environ,
+		},
+		{
+			name:        "inherit true",
+			environment: &cfg.Environment{Inherit: true},
+			expected:    environ,
+		},
+		{
+			name:        "inherit false",
+			environment: &cfg.Environment{Inherit: false},
+			expected:    []string{},
+		},
+		{
+			name:        "inherit names and patterns",
+			environment: &cfg.Environment{Inherit: []any{"PATH", "GO*", "UNDEFINED"}},
+			expected:    []string{"PATH=/bin", "GOFLAGS=-mod=vendor", "GOPATH=/go"},
+		},
+		{
+			name: "set overwrites inherited",
+			environment: &cfg.Environment{
+				Inherit: []string{"PATH", "HOME"},
+				Set:     map[string]string{"HOME": "/tmp", "CGO_ENABLED": "0"},
+			},
+			expected: []string{"PATH=/bin", "CGO_ENABLED=0", "HOME=/tmp"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := Task{ID: "app.build", Environment: tc.environment}
+			env, err := CommandEnv(&task, environ)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, env)
+		})
+	}
+}
+
+func TestUntrackedEnvVars(t *testing.T) {
+	inputs := NewInputs([]Input{
+		NewInputEnvVar("GOFLAGS", "-mod=vendor"),
+		NewInputString("GOPATH"),
+	})
+
+	untracked := UntrackedEnvVars([]string{"PATH=/bin", "GOFLAGS=-mod=vendor", "GOPATH=/go"}, inputs)
+	assert.Equal(t, []string{"GOPATH", "PATH"}, untracked)
+}
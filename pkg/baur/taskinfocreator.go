@@ -16,9 +16,10 @@ import (
    - TaskInfoCreator.CreateFileContent retrieves in 2 db operations first a
      whole run and then it's outputs, it only uses the runId and Outputs
      though.
-   - It depends on the execution order of tasks if the outputs referenced in
-     the TaskInfo are existing ones, queried from the database or are
-     non-existing ones that still need to be build. (FIXME: add this comment to
+   - If a referenced task is pending, the planned upload URIs from its
+     configuration are used. Callers that also run the referenced task must
+     ensure via the TaskScheduler that it was run and recorded before, to get
+     the URIs of the existing outputs.
    - The TaskInfoCreator stores a TaskStatusEvaluator reference, which
      references an InputResolver instance. This means that the inputResolver
      cache is hold much longer in the memory then before, which increases the
@@ -0,0 +1,98 @@
+package baur
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// MaxTaskRunLogSize is the maximum size of the output of a task run that is
+// returned in RunResult.Output. Of larger outputs the beginning and the end
+// are returned, the complete output is kept in a temporary file that can be
+// uploaded via Uploader.UploadLog.
+const MaxTaskRunLogSize = 1024 * 1024
+
+// taskLog stores the output of the executions of a task command in a
+// temporary file. It can be written to concurrently.
+type taskLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func newTaskLog() (*taskLog, error) {
+	f, err := os.CreateTemp("", "baur-task-output-*.log")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file for the task output failed: %w", err)
+	}
+
+	return &taskLog{f: f}, nil
+}
+
+func (l *taskLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+
+	return n, err
+}
+
+// Bytes returns the stored output. If it is larger than maxSize, the first
+// and last maxSize/2 bytes are returned, separated by a line that
+// describes how many bytes were omitted.
+func (l *taskLog) Bytes(maxSize int64) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size <= maxSize {
+		buf := make([]byte, l.size)
+		if _, err := l.f.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("reading task output from %s failed: %w", l.f.Name(), err)
+		}
+
+		return buf, nil
+	}
+
+	partSize := maxSize / 2
+	msg := fmt.Sprintf("\n... omitting %d bytes ...\n", l.size-2*partSize)
+	buf := make([]byte, 2*partSize+int64(len(msg)))
+
+	if _, err := l.f.ReadAt(buf[:partSize], 0); err != nil {
+		return nil, fmt.Errorf("reading task output from %s failed: %w", l.f.Name(), err)
+	}
+
+	copy(buf[partSize:], msg)
+
+	if _, err := l.f.ReadAt(buf[partSize+int64(len(msg)):], l.size-partSize); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading task output from %s failed: %w", l.f.Name(), err)
+	}
+
+	return buf, nil
+}
+
+// Size returns the number of bytes that were written.
+func (l *taskLog) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.size
+}
+
+// Path returns the path of the temporary file.
+func (l *taskLog) Path() string {
+	return l.f.Name()
+}
+
+// Close closes and deletes the temporary file.
+func (l *taskLog) Close() error {
+	err := l.f.Close()
+	if rmErr := os.Remove(l.f.Name()); rmErr != nil && err == nil {
+		err = rmErr
+	}
+
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sync/atomic"
 	"time"
@@ -24,6 +25,10 @@ func (e *ErrUntrackedGitFilesExist) Error() string {
 // ErrTaskRunSkipped is returned when a task run was skipped instead of executed.
 var ErrTaskRunSkipped = errors.New("task run skipped")
 
+// ErrTaskRunTimeout is returned when the command of a task was terminated
+// because it exceeded the timeout of the task.
+var ErrTaskRunTimeout = errors.New("task run exceeded timeout")
+
 type TaskInfoRetriever interface {
 	Inputs(*Task) (*Inputs, error)
 	Task(id string) (*Task, error)
@@ -35,9 +40,18 @@ type TaskRunner struct {
 	skipEnabled         uint32 // must be accessed via atomic operations
 	LogFn               exec.PrintfFn
 	GitUntrackedFilesFn func(dir string) ([]string, error)
-	taskInfoCreator     *TaskInfoCreator
+	// TraceFileAccesses enables recording the files that are accessed by
+	// the command in RunResult.FileAccesses.
+	TraceFileAccesses bool
+	// RetryFn is called when the command of a task failed and is rerun.
+	RetryFn         TaskRetryFn
+	taskInfoCreator *TaskInfoCreator
 }
 
+// TaskRetryFn is called when a failed attempt to run the command of a task
+// is retried. err describes why the attempt failed.
+type TaskRetryFn func(task *Task, attempt int, err error)
+
 func NewTaskRunner(taskInfoCreator *TaskInfoCreator, skipAfterError bool) *TaskRunner {
 	return &TaskRunner{
 		LogFn:           exec.DefaultLogFn,
@@ -49,8 +63,46 @@ func NewTaskRunner(taskInfoCreator *TaskInfoCreator, skipAfterError bool) *TaskR
 // RunResult represents the results of a task run.
 type RunResult struct {
 	*exec.Result
+	// StartTime is the time when the first attempt to run the command
+	// started.
 	StartTime time.Time
 	StopTime  time.Time
+	// Output is the combined stdout and stderr output of all executions
+	// of the command. If it is larger than MaxTaskRunLogSize, only its
+	// beginning and end are contained, the complete output is stored in
+	// the file returned by LogFile.
+	Output []byte
+	// LogURL is the URL that the complete output was uploaded to by
+	// Uploader.UploadLog.
+	LogURL string
+	// Attempts is the number of times the command was executed.
+	Attempts int
+
+	log *taskLog
+}
+
+// LogFile returns the path of a temporary file that contains the complete
+// output of the command, if it is larger than MaxTaskRunLogSize.
+// Otherwise an empty string is returned.
+func (r *RunResult) LogFile() string {
+	if r.log == nil {
+		return ""
+	}
+
+	return r.log.Path()
+}
+
+// Close deletes the temporary file that contains the complete output of the
+// command, if one exists.
+func (r *RunResult) Close() error {
+	if r.log == nil {
+		return nil
+	}
+
+	err := r.log.Close()
+	r.log = nil
+
+	return err
 }
 
 func (t *TaskRunner) deleteTmpFiles(paths []string) {
@@ -91,10 +143,22 @@ func (t *TaskRunner) createTaskInfoEnv(ctx context.Context, task *Task) ([]strin
 
 // Run executes the command of a task and returns the execution result.
 // The output of the commands are logged with debug log level.
-func (t *TaskRunner) Run(task *Task) (*RunResult, error) {
-	if t.skipAfterError && t.SkipRunsIsEnabled() {
+// When ctx is canceled, the command is terminated. If ctx is already canceled
+// when Run is called, the command is not executed and ErrTaskRunSkipped is
+// returned.
+// If the task has a timeout and it is exceeded, the command is terminated
+// and an error wrapping ErrTaskRunTimeout is returned.
+// If the command fails or exceeds the timeout, it is rerun up to
+// task.Retries times. Before a rerun, RetryFn is called and task.RetryDelay
+// is waited.
+// If the output of the command is larger than MaxTaskRunLogSize, the
+// returned RunResult references a temporary file that must be deleted via
+// RunResult.Close.
+func (t *TaskRunner) Run(ctx context.Context, task *Task) (*RunResult, error) {
+	if t.skipRun(ctx) {
 		return nil, ErrTaskRunSkipped
 	}
+
 	if t.GitUntrackedFilesFn != nil {
 		untracked, err := t.GitUntrackedFilesFn(task.RepositoryRoot)
 		if err != nil {
@@ -106,20 +170,114 @@ func (t *TaskRunner) Run(task *Task) (*RunResult, error) {
 		}
 	}
 
-	env, deleteTempTaskInfoFilesFn, err := t.createTaskInfoEnv(context.TODO(), task)
+	cmdEnv, err := CommandEnv(task, os.Environ())
+	if err != nil {
+		return nil, err
+	}
+
+	taskInfoEnv, deleteTempTaskInfoFilesFn, err := t.createTaskInfoEnv(ctx, task)
 	if err != nil {
 		return nil, err
 	}
 	defer deleteTempTaskInfoFilesFn()
 
+	env := append(cmdEnv, taskInfoEnv...)
 	startTime := time.Now()
-	execResult, err := exec.Command(task.Command[0], task.Command[1:]...).
+
+	output, err := newTaskLog()
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			fmt.Fprintf(output, "\n--- attempt %d/%d ---\n", attempt, task.Retries+1)
+		}
+
+		result, err := t.runCommand(ctx, task, env, output)
+		if err == nil {
+			result.StartTime = startTime
+			result.Attempts = attempt
+		}
+
+		failure := err
+		if err == nil {
+			failure = result.ExpectSuccess()
+		}
+
+		if failure == nil || attempt > task.Retries || !isRetryable(failure) || t.skipRun(ctx) {
+			return t.withOutput(result, err, output)
+		}
+
+		if t.RetryFn != nil {
+			t.RetryFn(task, attempt, failure)
+		}
+
+		if !sleep(ctx, task.RetryDelay) {
+			return t.withOutput(result, err, output)
+		}
+	}
+}
+
+// withOutput sets result.Output to the content of output, if result is not
+// nil. If err is nil and output is larger than MaxTaskRunLogSize, the
+// temporary file is kept and assigned to result, otherwise it is deleted.
+func (t *TaskRunner) withOutput(result *RunResult, err error, output *taskLog) (*RunResult, error) {
+	closeOutput := func() {
+		if err := output.Close(); err != nil {
+			t.LogFn("deleting temporary task output file failed: %s\n", err)
+		}
+	}
+
+	if result == nil {
+		closeOutput()
+		return result, err
+	}
+
+	var readErr error
+	result.Output, readErr = output.Bytes(MaxTaskRunLogSize)
+	if readErr != nil {
+		closeOutput()
+		return nil, errors.Join(err, readErr)
+	}
+
+	if err == nil && output.Size() > MaxTaskRunLogSize {
+		result.log = output
+	} else {
+		closeOutput()
+	}
+
+	return result, err
+}
+
+// runCommand executes the command of the task once and writes its combined
+// stdout and stderr output to output.
+func (t *TaskRunner) runCommand(ctx context.Context, task *Task, env []string, output io.Writer) (*RunResult, error) {
+	cmdCtx := ctx
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		cmdCtx, cancel = context.WithTimeoutCause(ctx, task.Timeout, ErrTaskRunTimeout)
+		defer cancel()
+	}
+
+	cmd := exec.Command(task.Command[0], task.Command[1:]...).
 		Directory(task.Directory).
 		LogPrefix(color.YellowString(fmt.Sprintf("%s: ", task))).
 		LogFn(t.LogFn).
-		Env(append(os.Environ(), env...)).
-		Run(context.TODO())
+		Env(env).
+		Stdout(output).
+		Stderr(output)
+	if t.TraceFileAccesses {
+		cmd.TraceFileAccesses()
+	}
+
+	startTime := time.Now()
+	execResult, err := cmd.Run(cmdCtx)
 	if err != nil {
+		if ctx.Err() == nil && errors.Is(context.Cause(cmdCtx), ErrTaskRunTimeout) {
+			return nil, fmt.Errorf("%w of %s, command was terminated", ErrTaskRunTimeout, task.Timeout)
+		}
+
 		return nil, err
 	}
 
@@ -127,9 +285,79 @@ func (t *TaskRunner) Run(task *Task) (*RunResult, error) {
 		Result:    execResult,
 		StartTime: startTime,
 		StopTime:  time.Now(),
+		Attempts:  1,
 	}, nil
 }
 
+// isRetryable returns true if err was caused by a failure of the command that
+// might not happen when it is rerun.
+func isRetryable(err error) bool {
+	var ee *exec.ExitCodeError
+
+	return errors.As(err, &ee) || errors.Is(err, ErrTaskRunTimeout)
+}
+
+// sleep waits for d or until ctx is canceled. It returns false if ctx was
+// canceled.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// RunInSandbox executes the command of a task like Run() but in a Sandbox
+// that only contains the files of inputs.
+// When the command succeeds, its outputs in the task directory are replaced
+// with the ones from the sandbox. If a declared output was not created, an
+// error is returned. The sandbox is removed afterwards.
+// The sandbox does not prevent that the command reads files of the
+// repository via absolute paths.
+func (t *TaskRunner) RunInSandbox(ctx context.Context, task *Task, inputs *Inputs) (*RunResult, error) {
+	if t.skipRun(ctx) {
+		return nil, ErrTaskRunSkipped
+	}
+
+	sb, err := NewSandbox(task, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("creating sandbox failed: %w", err)
+	}
+	defer func() {
+		if err := sb.Remove(); err != nil {
+			t.LogFn("deleting sandbox directory %q failed: %s\n", sb.Dir(), err)
+		}
+	}()
+
+	result, err := t.Run(ctx, sb.Task())
+	if err != nil {
+		return nil, err
+	}
+
+	if result.ExitCode != 0 {
+		return result, nil
+	}
+
+	if err := sb.CopyOutputs(); err != nil {
+		_ = result.Close()
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (t *TaskRunner) skipRun(ctx context.Context) bool {
+	return (t.skipAfterError && t.SkipRunsIsEnabled()) || ctx.Err() != nil
+}
+
 func (t *TaskRunner) setSkipRuns(val uint32) {
 	atomic.StoreUint32(&t.skipEnabled, val)
 }
@@ -1,9 +1,17 @@
 package baur
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/cfg"
 )
 
 func TestRunningTaskFailsWhenGitWorktreeIsDirty(t *testing.T) {
@@ -11,7 +19,180 @@ func TestRunningTaskFailsWhenGitWorktreeIsDirty(t *testing.T) {
 	tr.GitUntrackedFilesFn = func(_ string) ([]string, error) {
 		return []string{"1"}, nil
 	}
-	_, err := tr.Run(&Task{})
+	_, err := tr.Run(t.Context(), &Task{})
 	var eu *ErrUntrackedGitFilesExist
 	require.ErrorAs(t, err, &eu)
 }
+
+func TestRunTerminatesCommandWhenTimeoutIsExceeded(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	task := Task{
+		ID:        "app.build",
+		Directory: t.TempDir(),
+		Command:   []string{"sleep", "60"},
+		Timeout:   100 * time.Millisecond,
+	}
+
+	_, err := tr.Run(t.Context(), &task)
+	require.ErrorIs(t, err, ErrTaskRunTimeout)
+}
+
+func TestRunSkipsTaskWhenContextIsCanceled(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err := tr.Run(ctx, &Task{Command: []string{"true"}})
+	require.ErrorIs(t, err, ErrTaskRunSkipped)
+}
+
+func TestRunRecordsCombinedOutput(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	task := Task{
+		ID:        "app.build",
+		Directory: t.TempDir(),
+		Command:   []string{"sh", "-c", "echo out; sleep 0.1; echo err >&2"},
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	require.NoError(t, err)
+	require.Equal(t, "out\nerr\n", string(res.Output))
+}
+
+func TestRunRecordsOutputOfAllAttempts(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	task := Task{
+		ID:        "app.build",
+		Directory: t.TempDir(),
+		Command:   []string{"sh", "-c", "echo run; exit 1"},
+		Retries:   1,
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	require.NoError(t, err)
+	require.Equal(t, "run\n\n--- attempt 2/2 ---\nrun\n", string(res.Output))
+}
+
+func TestRunTruncatesLargeOutput(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	task := Task{
+		ID:        "app.build",
+		Directory: t.TempDir(),
+		Command: []string{
+			"sh", "-c",
+			fmt.Sprintf("echo begin; head -c %d /dev/zero; echo end", 2*MaxTaskRunLogSize),
+		},
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	require.NoError(t, err)
+	require.Less(t, len(res.Output), MaxTaskRunLogSize+100)
+	require.True(t, bytes.HasPrefix(res.Output, []byte("begin\n")))
+	require.True(t, bytes.HasSuffix(res.Output, []byte("end\n")))
+	require.Contains(t, string(res.Output), fmt.Sprintf("omitting %d bytes", len("begin\nend\n")+MaxTaskRunLogSize))
+
+	logFile := res.LogFile()
+	require.NotEmpty(t, logFile)
+	fi, err := os.Stat(logFile)
+	require.NoError(t, err)
+	require.Equal(t, int64(len("begin\nend\n")+2*MaxTaskRunLogSize), fi.Size())
+
+	require.NoError(t, res.Close())
+	require.NoFileExists(t, logFile)
+}
+
+func TestRunDoesNotKeepSmallOutputInFile(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	task := Task{
+		ID:        "app.build",
+		Directory: t.TempDir(),
+		Command:   []string{"echo", "hello"},
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	require.NoError(t, err)
+	require.Empty(t, res.LogFile())
+	require.Equal(t, "hello\n", string(res.Output))
+}
+
+func TestRunPassesConfiguredEnvironment(t *testing.T) {
+	t.Setenv("BAUR_TEST_INHERITED", "1")
+	t.Setenv("BAUR_TEST_NOT_INHERITED", "1")
+
+	tr := NewTaskRunner(nil, false)
+	task := Task{
+		ID:        "app.build",
+		Directory: t.TempDir(),
+		Command:   []string{"sh", "-c", "env | grep ^BAUR_TEST_ | sort"},
+		Environment: &cfg.Environment{
+			Inherit: []string{"BAUR_TEST_INHERITED"},
+			Set:     map[string]string{"BAUR_TEST_SET": "x"},
+		},
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	require.NoError(t, err)
+	require.Equal(t, "BAUR_TEST_INHERITED=1\nBAUR_TEST_SET=x\n", string(res.Output))
+}
+
+func TestRunRetriesFailedCommand(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	var retriedAttempts []int
+	tr.RetryFn = func(_ *Task, attempt int, _ error) {
+		retriedAttempts = append(retriedAttempts, attempt)
+	}
+
+	task := Task{
+		ID:         "app.build",
+		Directory:  t.TempDir(),
+		Command:    []string{"sh", "-c", "if [ -e marker ]; then exit 0; fi; touch marker; exit 1"},
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	require.NoError(t, err)
+	require.NoError(t, res.ExpectSuccess())
+	require.Equal(t, 2, res.Attempts)
+	require.Equal(t, []int{1}, retriedAttempts)
+}
+
+func TestRunReturnsFailureWhenRetriesAreExhausted(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	task := Task{
+		ID:        "app.build",
+		Directory: t.TempDir(),
+		Command:   []string{"sh", "-c", "echo run >> runs; exit 3"},
+		Retries:   2,
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	require.NoError(t, err)
+	require.Equal(t, 3, res.ExitCode)
+	require.Equal(t, 3, res.Attempts)
+
+	runs, err := os.ReadFile(filepath.Join(task.Directory, "runs"))
+	require.NoError(t, err)
+	require.Equal(t, "run\nrun\nrun\n", string(runs))
+}
+
+func TestRunRetriesCommandExceedingTimeout(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	var retries int
+	tr.RetryFn = func(_ *Task, _ int, err error) {
+		require.ErrorIs(t, err, ErrTaskRunTimeout)
+		retries++
+	}
+
+	task := Task{
+		ID:        "app.build",
+		Directory: t.TempDir(),
+		Command:   []string{"sleep", "60"},
+		Timeout:   50 * time.Millisecond,
+		Retries:   1,
+	}
+
+	_, err := tr.Run(t.Context(), &task)
+	require.ErrorIs(t, err, ErrTaskRunTimeout)
+	require.Equal(t, 1, retries)
+}
@@ -0,0 +1,233 @@
+package baur
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type schedulerTaskState int
+
+const (
+	schedulerTaskWaiting schedulerTaskState = iota
+	schedulerTaskReady
+	schedulerTaskCompleted
+	schedulerTaskFailed
+	schedulerTaskSkipped
+)
+
+type schedulerNode struct {
+	task  *Task
+	order int
+	state schedulerTaskState
+	// pendingDeps is the number of dependencies that did not complete yet.
+	pendingDeps int
+	dependents  []*schedulerNode
+}
+
+// TaskScheduler determines when tasks can be executed, based on their TaskInfo
+// dependencies.
+// It builds a directed acyclic graph from [Task.TaskInfoDependencies] of the
+// passed tasks. A task is only released for execution when all of its
+// dependencies completed. Dependencies that are not part of the scheduled
+// tasks are considered as completed, callers must schedule dependencies that
+// have not been run yet.
+// When a task fails, all tasks that depend directly or indirectly on it are
+// skipped.
+// Tasks that are released at the same time are returned in the order in that
+// they were passed to [NewTaskScheduler].
+//
+// TaskScheduler is safe for concurrent use.
+type TaskScheduler struct {
+	mu    sync.Mutex
+	nodes map[string]*schedulerNode
+	// ordered contains all nodes in the order of the tasks passed to
+	// NewTaskScheduler
+	ordered []*schedulerNode
+	started bool
+}
+
+// NewTaskScheduler creates a TaskScheduler for tasks.
+// If the TaskInfo dependencies of tasks contain a cycle, an error is returned.
+func NewTaskScheduler(tasks []*Task) (*TaskScheduler, error) {
+	s := TaskScheduler{
+		nodes:   make(map[string]*schedulerNode, len(tasks)),
+		ordered: make([]*schedulerNode, 0, len(tasks)),
+	}
+
+	for i, task := range tasks {
+		if _, exists := s.nodes[task.ID]; exists {
+			return nil, fmt.Errorf("task %q is scheduled multiple times", task.ID)
+		}
+
+		n := schedulerNode{task: task, order: i}
+		s.nodes[task.ID] = &n
+		s.ordered = append(s.ordered, &n)
+	}
+
+	for _, n := range s.ordered {
+		for _, ti := range n.task.TaskInfoDependencies {
+			dep, exists := s.nodes[ti.Task.ID]
+			if !exists {
+				continue
+			}
+
+			if slices.Contains(dep.dependents, n) {
+				continue
+			}
+
+			dep.dependents = append(dep.dependents, n)
+			n.pendingDeps++
+		}
+	}
+
+	if err := s.ensureAcyclic(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// ensureAcyclic returns an error if the dependency graph contains a cycle.
+func (s *TaskScheduler) ensureAcyclic() error {
+	pendingDeps := make(map[*schedulerNode]int, len(s.ordered))
+	queue := make([]*schedulerNode, 0, len(s.ordered))
+
+	for _, n := range s.ordered {
+		pendingDeps[n] = n.pendingDeps
+		if n.pendingDeps == 0 {
+			queue = append(queue, n)
+		}
+	}
+
+	var visited int
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		visited++
+
+		for _, dependent := range n.dependents {
+			pendingDeps[dependent]--
+			if pendingDeps[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	if visited == len(s.ordered) {
+		return nil
+	}
+
+	var cyclic []string
+	for _, n := range s.ordered {
+		if pendingDeps[n] > 0 {
+			cyclic = append(cyclic, n.task.ID)
+		}
+	}
+
+	return fmt.Errorf("TaskInfo dependencies of the following tasks are cyclic: %s", strings.Join(cyclic, ", "))
+}
+
+// Start returns the tasks that have no pending dependencies and can be
+// executed immediately.
+// Start must only be called once.
+func (s *TaskScheduler) Start() []*Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		panic("TaskScheduler.Start() was called multiple times")
+	}
+	s.started = true
+
+	result := make([]*Task, 0, len(s.ordered))
+	for _, n := range s.ordered {
+		if n.pendingDeps == 0 {
+			n.state = schedulerTaskReady
+			result = append(result, n.task)
+		}
+	}
+
+	return result
+}
+
+func (s *TaskScheduler) mustGetReadyNode(task *Task) *schedulerNode {
+	n, exists := s.nodes[task.ID]
+	if !exists {
+		panic(fmt.Sprintf("task %q is not known by the scheduler", task.ID))
+	}
+
+	if n.state != schedulerTaskReady {
+		panic(fmt.Sprintf("task %q was not released by the scheduler or is already finished", task.ID))
+	}
+
+	return n
+}
+
+// Completed marks task as successfully finished.
+// It returns the tasks that have no pending dependencies anymore and can be
+// executed now.
+func (s *TaskScheduler) Completed(task *Task) []*Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.mustGetReadyNode(task)
+	n.state = schedulerTaskCompleted
+
+	var released []*schedulerNode
+	for _, dependent := range n.dependents {
+		if dependent.state != schedulerTaskWaiting {
+			continue
+		}
+
+		dependent.pendingDeps--
+		if dependent.pendingDeps == 0 {
+			dependent.state = schedulerTaskReady
+			released = append(released, dependent)
+		}
+	}
+
+	return nodesToTasks(released)
+}
+
+// Failed marks task as unsuccessfully finished.
+// It returns all tasks that depend directly or indirectly on task. They are
+// marked as skipped and are never released for execution.
+func (s *TaskScheduler) Failed(task *Task) []*Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.mustGetReadyNode(task)
+	n.state = schedulerTaskFailed
+
+	var skipped []*schedulerNode
+	queue := slices.Clone(n.dependents)
+	for len(queue) > 0 {
+		dependent := queue[0]
+		queue = queue[1:]
+
+		if dependent.state != schedulerTaskWaiting {
+			continue
+		}
+
+		dependent.state = schedulerTaskSkipped
+		skipped = append(skipped, dependent)
+		queue = append(queue, dependent.dependents...)
+	}
+
+	return nodesToTasks(skipped)
+}
+
+func nodesToTasks(nodes []*schedulerNode) []*Task {
+	slices.SortFunc(nodes, func(a, b *schedulerNode) int {
+		return a.order - b.order
+	})
+
+	result := make([]*Task, 0, len(nodes))
+	for _, n := range nodes {
+		result = append(result, n.task)
+	}
+
+	return result
+}
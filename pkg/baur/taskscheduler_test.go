@@ -0,0 +1,73 @@
+package baur
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSchedulerTestTask(id string, deps ...*Task) *Task {
+	t := Task{ID: id}
+	for _, dep := range deps {
+		t.TaskInfoDependencies = append(t.TaskInfoDependencies, &TaskInfo{Task: dep})
+	}
+
+	return &t
+}
+
+func TestTaskSchedulerReleasesTasksWhenDependenciesCompleted(t *testing.T) {
+	gen := newSchedulerTestTask("proto.gen")
+	check := newSchedulerTestTask("app.check")
+	build := newSchedulerTestTask("app.build", gen, check)
+	deploy := newSchedulerTestTask("app.deploy", build)
+	lint := newSchedulerTestTask("app.lint")
+
+	s, err := NewTaskScheduler([]*Task{deploy, build, check, gen, lint})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Task{check, gen, lint}, s.Start())
+
+	assert.Empty(t, s.Completed(gen))
+	assert.Empty(t, s.Completed(lint))
+	assert.Equal(t, []*Task{build}, s.Completed(check))
+	assert.Equal(t, []*Task{deploy}, s.Completed(build))
+	assert.Empty(t, s.Completed(deploy))
+}
+
+func TestTaskSchedulerSkipsDependentsOfFailedTasks(t *testing.T) {
+	gen := newSchedulerTestTask("proto.gen")
+	check := newSchedulerTestTask("app.check")
+	build := newSchedulerTestTask("app.build", gen, check)
+	deploy := newSchedulerTestTask("app.deploy", build)
+	other := newSchedulerTestTask("other.build", check)
+
+	s, err := NewTaskScheduler([]*Task{gen, check, build, deploy, other})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Task{gen, check}, s.Start())
+
+	assert.Equal(t, []*Task{build, deploy}, s.Failed(gen))
+	assert.Equal(t, []*Task{other}, s.Completed(check))
+	assert.Empty(t, s.Completed(other))
+}
+
+func TestTaskSchedulerIgnoresUnscheduledDependencies(t *testing.T) {
+	gen := newSchedulerTestTask("proto.gen")
+	build := newSchedulerTestTask("app.build", gen)
+
+	s, err := NewTaskScheduler([]*Task{build})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Task{build}, s.Start())
+}
+
+func TestTaskSchedulerDetectsCycles(t *testing.T) {
+	a := newSchedulerTestTask("app.a")
+	b := newSchedulerTestTask("app.b", a)
+	a.TaskInfoDependencies = append(a.TaskInfoDependencies, &TaskInfo{Task: b})
+	c := newSchedulerTestTask("app.c")
+
+	_, err := NewTaskScheduler([]*Task{a, b, c})
+	require.ErrorContains(t, err, "app.a, app.b")
+}
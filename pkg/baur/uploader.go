@@ -1,26 +1,32 @@
 package baur
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"path/filepath"
 	"reflect"
+	"sync"
 	"time"
+
+	"github.com/simplesurance/baur/v5/internal/output/docker"
+	"github.com/simplesurance/baur/v5/internal/output/s3"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
 )
 
 // S3Uploader is an interface for uploading files to AWS S3 buckets.
 type S3Uploader interface {
-	Upload(filepath, bucket, key string) (string, error)
+	Upload(ctx context.Context, filepath, bucket, key string) (string, error)
 }
 
 // DockerImgUploader is an interface for uploading docker images to a docker registry.
 type DockerImgUploader interface {
-	Upload(image, registryAddr, repository, tag string) (string, error)
+	Upload(ctx context.Context, image, registryAddr, repository, tag string) (string, error)
 }
 
 // FileCopyUploader is an interface for copying files from one directory to another.
 type FileCopyUploader interface {
-	Upload(src, dst string) (string, error)
+	Upload(ctx context.Context, src, dst string) (string, error)
 }
 
 // Uploader uploads outputs, produced by task run, to remote locations.
@@ -28,6 +34,12 @@ type Uploader struct {
 	dockerclient     DockerImgUploader
 	s3client         S3Uploader
 	filecopyUploader FileCopyUploader
+
+	// Retries is the number of times a failed upload is retried.
+	Retries int
+	// RetryDelay is the duration that is waited before a failed upload is
+	// retried.
+	RetryDelay time.Duration
 }
 
 func NewUploader(dockerClient DockerImgUploader, s3client S3Uploader, filecopyUploader FileCopyUploader) *Uploader {
@@ -49,67 +61,237 @@ type UploadResult struct {
 }
 
 // UploadStartFn is a function that is called before the upload operation starts.
-type UploadStartFn func(Output, UploadInfo)
+// url is the URL that the output is uploaded to.
+type UploadStartFn func(o Output, dest UploadInfo, url string)
 
 // UploadResultFn is a function that is called after an upload finishes.
 type UploadResultFn func(Output, *UploadResult)
 
+// UploadRetryFn is a function that is called when a failed upload attempt is
+// retried. err is the error of the failed attempt.
+type UploadRetryFn func(o Output, dest UploadInfo, attempt int, err error)
+
 // Upload uploads an output to remote locations.
-// Output must be a *OutputDockerImage or *OutputFile type storing or more upload locations.
-// Immediately before the upload starts uploadStartCb is called, when the
+// Output must be a *OutputDockerImage, *OutputFile or *OutputFileTree type
+// storing one or more upload locations.
+// For *OutputFileTree the tar archive of the files is uploaded.
+// The uploads to the different locations run concurrently, the callbacks
+// can be called concurrently.
+// Immediately before an upload starts uploadStartCb is called, when the
 // upload finished resultCb is called.
-func (u *Uploader) Upload(output Output, uploadStartCb UploadStartFn, resultCb UploadResultFn) error {
-	switch o := output.(type) {
-	case *OutputDockerImage:
-		if o.UploadDestinations == nil {
-			return errors.New("uploadDestination is nil")
-		}
+// Failed uploads are retried up to u.Retries times, before a retry retryCb is
+// called if it is not nil.
+// When an upload fails, the other running uploads of the output are aborted
+// and the error of the failed upload is returned.
+// When ctx is canceled, running uploads are aborted and an error is returned.
+func (u *Uploader) Upload(
+	ctx context.Context,
+	output Output,
+	uploadStartCb UploadStartFn,
+	resultCb UploadResultFn,
+	retryCb UploadRetryFn,
+) error {
+	jobs, err := u.uploadJobs(output)
+	if err != nil {
+		return err
+	}
 
-		for _, dest := range o.UploadDestinations {
-			uploadStartCb(o, dest)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-			result, err := u.dockerImage(o, dest)
+			uploadStartCb(output, job.dest, job.url)
+
+			result, err := u.withRetries(ctx, output, job.dest, retryCb, func() (*UploadResult, error) {
+				return job.upload(ctx)
+			})
 			if err != nil {
-				return fmt.Errorf("docker upload failed: %w", err)
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+
+				return
 			}
 
-			resultCb(o, result)
+			resultCb(output, result)
+		}()
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+// UploadLog uploads the complete output of the task command to the
+// destination configured in dest and sets runResult.LogURL to its URL.
+// If the output is not larger than MaxTaskRunLogSize or dest contains no
+// destination, nothing is uploaded.
+// Failed uploads are retried up to u.Retries times.
+func (u *Uploader) UploadLog(ctx context.Context, task *Task, runResult *RunResult, dest *cfg.TaskLog) error {
+	path := runResult.LogFile()
+	if path == "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s.%s-%s.log",
+		task.AppName, task.Name, runResult.StartTime.UTC().Format("20060102T150405.000000000Z"),
+	)
+
+	var upload func() (*UploadResult, error)
+	switch {
+	case dest.S3Bucket != "":
+		upload = func() (*UploadResult, error) {
+			s3Dest := UploadInfoS3{&cfg.S3Upload{Bucket: dest.S3Bucket, Key: dest.S3KeyPrefix + name}}
+			return u.s3(ctx, nil, path, &s3Dest)
+		}
+	case dest.FileCopyDir != "":
+		upload = func() (*UploadResult, error) {
+			return u.fileCopy(ctx, nil, path, filepath.Join(dest.FileCopyDir, name))
 		}
+	default:
+		return nil
+	}
 
-	case *OutputFile:
-		for _, dest := range o.UploadsFilecopy {
-			uploadStartCb(o, dest)
+	result, err := u.withRetries(ctx, nil, nil, nil, upload)
+	if err != nil {
+		return fmt.Errorf("uploading log failed: %w", err)
+	}
 
-			result, err := u.fileCopy(o, dest)
-			if err != nil {
-				return fmt.Errorf("filecopy failed: %w", err)
-			}
+	runResult.LogURL = result.URL
 
-			resultCb(o, result)
+	return nil
+}
+
+// uploadJob is the upload of an output to a single destination.
+type uploadJob struct {
+	dest UploadInfo
+	// url is the URL that the output is uploaded to
+	url    string
+	upload func(context.Context) (*UploadResult, error)
+}
+
+// uploadJobs returns the uploads of output to all its destinations.
+func (u *Uploader) uploadJobs(output Output) ([]*uploadJob, error) {
+	switch o := output.(type) {
+	case *OutputDockerImage:
+		if o.UploadDestinations == nil {
+			return nil, errors.New("uploadDestination is nil")
 		}
 
-		for _, dest := range o.UploadsS3 {
-			uploadStartCb(o, dest)
+		jobs := make([]*uploadJob, 0, len(o.UploadDestinations))
+		for _, dest := range o.UploadDestinations {
+			jobs = append(jobs, &uploadJob{
+				dest: dest,
+				url:  docker.DestinationURI(dest.Registry, dest.Repository, dest.Tag),
+				upload: func(ctx context.Context) (*UploadResult, error) {
+					result, err := u.dockerImage(ctx, o, dest)
+					if err != nil {
+						return nil, fmt.Errorf("docker upload failed: %w", err)
+					}
+					return result, nil
+				},
+			})
+		}
 
-			result, err := u.s3(o, dest)
-			if err != nil {
-				return fmt.Errorf("s3 upload failed: %w", err)
-			}
+		return jobs, nil
 
-			resultCb(o, result)
+	case *OutputFile:
+		return u.fileUploadJobs(o, o.absPath, o.UploadsFilecopy, o.UploadsS3), nil
+
+	case *OutputFileTree:
+		archivePath, err := o.ArchivePath()
+		if err != nil {
+			return nil, err
 		}
 
+		return u.fileUploadJobs(o, archivePath, o.UploadsFilecopy, o.UploadsS3), nil
+
 	default:
-		return fmt.Errorf("unsupported output type: %s", reflect.TypeOf(output).Kind())
+		return nil, fmt.Errorf("unsupported output type: %s", reflect.TypeOf(output).Kind())
 	}
+}
 
-	return nil
+// fileUploadJobs returns the uploads of the file at absPath, that belongs to
+// the output o, to the filecopy and S3 destinations.
+func (u *Uploader) fileUploadJobs(
+	o Output,
+	absPath string,
+	filecopyDests []*UploadInfoFileCopy,
+	s3Dests []*UploadInfoS3,
+) []*uploadJob {
+	jobs := make([]*uploadJob, 0, len(filecopyDests)+len(s3Dests))
+
+	for _, dest := range filecopyDests {
+		destFile := filepath.Join(dest.Path, filepath.Base(absPath))
+		jobs = append(jobs, &uploadJob{
+			dest: dest,
+			url:  destFile,
+			upload: func(ctx context.Context) (*UploadResult, error) {
+				result, err := u.fileCopy(ctx, o, absPath, destFile)
+				if err != nil {
+					return nil, fmt.Errorf("filecopy failed: %w", err)
+				}
+				return result, nil
+			},
+		})
+	}
+
+	for _, dest := range s3Dests {
+		jobs = append(jobs, &uploadJob{
+			dest: dest,
+			url:  s3.URL(dest.Bucket, dest.Key),
+			upload: func(ctx context.Context) (*UploadResult, error) {
+				result, err := u.s3(ctx, o, absPath, dest)
+				if err != nil {
+					return nil, fmt.Errorf("s3 upload failed: %w", err)
+				}
+				return result, nil
+			},
+		})
+	}
+
+	return jobs
 }
 
-func (u *Uploader) dockerImage(o *OutputDockerImage, dest *UploadInfoDocker) (*UploadResult, error) {
+// withRetries calls uploadFn until it succeeds, it was called u.Retries+1
+// times or ctx is canceled.
+func (u *Uploader) withRetries(
+	ctx context.Context,
+	o Output,
+	dest UploadInfo,
+	retryCb UploadRetryFn,
+	uploadFn func() (*UploadResult, error),
+) (*UploadResult, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := uploadFn()
+		if err == nil || attempt > u.Retries || ctx.Err() != nil {
+			return result, err
+		}
+
+		if retryCb != nil {
+			retryCb(o, dest, attempt, err)
+		}
+
+		if !sleep(ctx, u.RetryDelay) {
+			return nil, err
+		}
+	}
+}
+
+func (u *Uploader) dockerImage(ctx context.Context, o *OutputDockerImage, dest *UploadInfoDocker) (*UploadResult, error) {
 	startTime := time.Now()
 
 	url, err := u.dockerclient.Upload(
+		ctx,
 		o.ImageID,
 		dest.Registry,
 		dest.Repository,
@@ -128,12 +310,10 @@ func (u *Uploader) dockerImage(o *OutputDockerImage, dest *UploadInfoDocker) (*U
 	}, nil
 }
 
-func (u *Uploader) fileCopy(o *OutputFile, dest *UploadInfoFileCopy) (*UploadResult, error) {
+func (u *Uploader) fileCopy(ctx context.Context, o Output, absPath, destFile string) (*UploadResult, error) {
 	startTime := time.Now()
 
-	destFile := filepath.Join(dest.Path, filepath.Base(o.absPath))
-
-	url, err := u.filecopyUploader.Upload(o.absPath, destFile)
+	url, err := u.filecopyUploader.Upload(ctx, absPath, destFile)
 	if err != nil {
 		return nil, err
 	}
@@ -147,10 +327,10 @@ func (u *Uploader) fileCopy(o *OutputFile, dest *UploadInfoFileCopy) (*UploadRes
 	}, nil
 }
 
-func (u *Uploader) s3(o *OutputFile, dest *UploadInfoS3) (*UploadResult, error) {
+func (u *Uploader) s3(ctx context.Context, o Output, absPath string, dest *UploadInfoS3) (*UploadResult, error) {
 	startTime := time.Now()
 
-	url, err := u.s3client.Upload(o.AbsPath(), dest.Bucket, dest.Key)
+	url, err := u.s3client.Upload(ctx, absPath, dest.Bucket, dest.Key)
 	if err != nil {
 		return nil, err
 	}
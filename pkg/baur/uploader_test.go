@@ -0,0 +1,200 @@
+package baur
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/testutils/fstest"
+	"github.com/simplesurance/baur/v5/pkg/cfg"
+)
+
+type flakyFileCopyUploader struct {
+	failures int
+	calls    int
+}
+
+func (u *flakyFileCopyUploader) Upload(_ context.Context, _, dst string) (string, error) {
+	u.calls++
+	if u.calls <= u.failures {
+		return "", errors.New("connection reset")
+	}
+
+	return dst, nil
+}
+
+// newTestOutputFile creates an OutputFile with destCnt filecopy upload
+// destinations.
+func newTestOutputFile(t *testing.T, destCnt int) *OutputFile {
+	dir := t.TempDir()
+	fstest.WriteToFile(t, []byte("out"), filepath.Join(dir, "out.bin"))
+
+	var dests []*UploadInfoFileCopy
+	for range destCnt {
+		dests = append(dests, &UploadInfoFileCopy{FileCopy: &cfg.FileCopy{Path: t.TempDir()}})
+	}
+
+	return NewOutputFile("out.bin", filepath.Join(dir, "out.bin"), nil, dests)
+}
+
+func TestUploadRetriesFailedUploads(t *testing.T) {
+	fc := flakyFileCopyUploader{failures: 2}
+	uploader := NewUploader(nil, nil, &fc)
+	uploader.Retries = 2
+	uploader.RetryDelay = time.Millisecond
+
+	var retriedAttempts []int
+	var results []*UploadResult
+	err := uploader.Upload(t.Context(), newTestOutputFile(t, 1),
+		func(Output, UploadInfo, string) {},
+		func(_ Output, r *UploadResult) { results = append(results, r) },
+		func(_ Output, _ UploadInfo, attempt int, err error) {
+			assert.ErrorContains(t, err, "connection reset")
+			retriedAttempts = append(retriedAttempts, attempt)
+		},
+	)
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, []int{1, 2}, retriedAttempts)
+	assert.Equal(t, 3, fc.calls)
+}
+
+func TestUploadLogUploadsLargeOutput(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	task := Task{
+		ID:        "app.build",
+		AppName:   "app",
+		Name:      "build",
+		Directory: t.TempDir(),
+		Command:   []string{"head", "-c", strconv.Itoa(2 * MaxTaskRunLogSize), "/dev/zero"},
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = res.Close() })
+
+	fc := flakyFileCopyUploader{failures: 1}
+	uploader := NewUploader(nil, nil, &fc)
+	uploader.Retries = 1
+
+	logDir := t.TempDir()
+	require.NoError(t, uploader.UploadLog(t.Context(), &task, res, &cfg.TaskLog{FileCopyDir: logDir}))
+	assert.Equal(t, 2, fc.calls)
+	assert.Equal(t, logDir, filepath.Dir(res.LogURL))
+	assert.True(t, strings.HasPrefix(filepath.Base(res.LogURL), "app.build-"), res.LogURL)
+}
+
+func TestUploadLogSkipsSmallOutput(t *testing.T) {
+	tr := NewTaskRunner(nil, false)
+	task := Task{
+		ID:        "app.build",
+		AppName:   "app",
+		Name:      "build",
+		Directory: t.TempDir(),
+		Command:   []string{"echo", "hello"},
+	}
+
+	res, err := tr.Run(t.Context(), &task)
+	require.NoError(t, err)
+
+	fc := flakyFileCopyUploader{}
+	uploader := NewUploader(nil, nil, &fc)
+
+	require.NoError(t, uploader.UploadLog(t.Context(), &task, res, &cfg.TaskLog{FileCopyDir: t.TempDir()}))
+	assert.Zero(t, fc.calls)
+	assert.Empty(t, res.LogURL)
+}
+
+func TestUploadFailsWhenRetriesAreExhausted(t *testing.T) {
+	fc := flakyFileCopyUploader{failures: 3}
+	uploader := NewUploader(nil, nil, &fc)
+	uploader.Retries = 1
+
+	err := uploader.Upload(t.Context(), newTestOutputFile(t, 1),
+		func(Output, UploadInfo, string) {},
+		func(Output, *UploadResult) { t.Error("result callback was called for a failed upload") },
+		nil,
+	)
+	require.ErrorContains(t, err, "connection reset")
+	assert.Equal(t, 2, fc.calls)
+}
+
+// blockingFileCopyUploader blocks uploads until waitFor uploads were
+// started or ctx is canceled. Uploads to failDst fail immediately.
+type blockingFileCopyUploader struct {
+	mu         sync.Mutex
+	startedCnt int
+	allStarted chan struct{}
+	waitFor    int
+	failDst    string
+}
+
+func newBlockingFileCopyUploader(waitFor int, failDst string) *blockingFileCopyUploader {
+	return &blockingFileCopyUploader{
+		allStarted: make(chan struct{}),
+		waitFor:    waitFor,
+		failDst:    failDst,
+	}
+}
+
+func (u *blockingFileCopyUploader) Upload(ctx context.Context, _, dst string) (string, error) {
+	if filepath.Dir(dst) == u.failDst {
+		return "", errors.New("upload failed")
+	}
+
+	u.mu.Lock()
+	u.startedCnt++
+	if u.startedCnt == u.waitFor {
+		close(u.allStarted)
+	}
+	u.mu.Unlock()
+
+	select {
+	case <-u.allStarted:
+		return dst, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestUploadUploadsToDestinationsConcurrently(t *testing.T) {
+	const destCnt = 3
+	uploader := NewUploader(nil, nil, newBlockingFileCopyUploader(destCnt, ""))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var results []*UploadResult
+	err := uploader.Upload(ctx, newTestOutputFile(t, destCnt),
+		func(Output, UploadInfo, string) {},
+		func(_ Output, r *UploadResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, r)
+		},
+		nil,
+	)
+	require.NoError(t, err)
+	assert.Len(t, results, destCnt)
+}
+
+func TestUploadAbortsOtherUploadsWhenOneFails(t *testing.T) {
+	output := newTestOutputFile(t, 2)
+	uploader := NewUploader(nil, nil, newBlockingFileCopyUploader(2, output.UploadsFilecopy[1].Path))
+
+	err := uploader.Upload(t.Context(), output,
+		func(Output, UploadInfo, string) {},
+		func(Output, *UploadResult) { t.Error("result callback was called for an aborted upload") },
+		nil,
+	)
+	require.ErrorContains(t, err, "upload failed")
+}
@@ -45,3 +45,17 @@ type UploadInfoFileCopy struct {
 func (f *UploadInfoFileCopy) String() string {
 	return f.Path
 }
+
+// String returns the name of the upload method.
+func (m UploadMethod) String() string {
+	switch m {
+	case UploadMethodS3:
+		return "s3"
+	case UploadMethodFilecopy:
+		return "filecopy"
+	case UploadMethodDocker:
+		return "docker"
+	default:
+		return "undefined"
+	}
+}
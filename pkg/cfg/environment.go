@@ -0,0 +1,137 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Environment describes the environment variables that are passed to the
+// command of a task.
+type Environment struct {
+	Inherit any               `toml:"inherit" comment:"Environment variables of the baur process that are passed to the command.\n true passes all variables, false none, a list passes the variables with the given names.\n Glob patterns are supported in names. If unset, all variables are passed."`
+	Set     map[string]string `toml:"set" comment:"Environment variables that are set for the command, they overwrite inherited ones.\n The variables are tracked as inputs."`
+}
+
+// InheritedEnvVars returns if all environment variables are inherited and
+// the names or name patterns of the inherited environment variables.
+// If all is true, names is nil.
+// Inherit must have been validated before.
+func (e *Environment) InheritedEnvVars() (all bool, names []string) {
+	switch v := e.Inherit.(type) {
+	case nil:
+		return true, nil
+
+	case bool:
+		return v, nil
+
+	case []string:
+		return false, v
+
+	case []any:
+		names := make([]string, 0, len(v))
+		for _, elem := range v {
+			// the type was checked in validate()
+			names = append(names, elem.(string))
+		}
+
+		return false, names
+
+	default:
+		panic(fmt.Sprintf("Environment.Inherit has unsupported type %T, validate() was not called?", e.Inherit))
+	}
+}
+
+// clone returns a deep copy of e.
+func (e *Environment) clone() Environment {
+	result := Environment{Set: maps.Clone(e.Set)}
+
+	switch v := e.Inherit.(type) {
+	case []string:
+		result.Inherit = slices.Clone(v)
+	case []any:
+		result.Inherit = slices.Clone(v)
+	default:
+		result.Inherit = v
+	}
+
+	return result
+}
+
+func (e *Environment) resolve(resolver Resolver) error {
+	for k, v := range e.Set {
+		val, err := resolver.Resolve(v)
+		if err != nil {
+			return fieldErrorWrap(err, "set", k)
+		}
+
+		e.Set[k] = val
+	}
+
+	return nil
+}
+
+func (e *Environment) validate() error {
+	switch v := e.Inherit.(type) {
+	case nil, bool:
+
+	case []string:
+		for _, name := range v {
+			if err := validateEnvVarPattern(name); err != nil {
+				return fieldErrorWrap(err, "inherit")
+			}
+		}
+
+	case []any:
+		for _, elem := range v {
+			name, ok := elem.(string)
+			if !ok {
+				return newFieldError(fmt.Sprintf("element %v is not a string", elem), "inherit")
+			}
+
+			if err := validateEnvVarPattern(name); err != nil {
+				return fieldErrorWrap(err, "inherit")
+			}
+		}
+
+	default:
+		return newFieldError("must be a boolean or a list of environment variable names", "inherit")
+	}
+
+	for k := range e.Set {
+		if err := validateEnvVarName(k); err != nil {
+			return fieldErrorWrap(err, "set")
+		}
+	}
+
+	return nil
+}
+
+func validateEnvVarPattern(pattern string) error {
+	if err := validateEnvVarName(pattern); err != nil {
+		return err
+	}
+
+	// patterns are matched via path.Match when the environment is
+	// created, it reports malformed patterns independent of the name
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("environment variable name pattern %q: %w", pattern, err)
+	}
+
+	return nil
+}
+
+func validateEnvVarName(name string) error {
+	if name == "" {
+		return errors.New("environment variable name can not be empty")
+	}
+
+	if strings.ContainsRune(name, '=') {
+		return fmt.Errorf("environment variable name %q contains invalid character '='", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/cfg/resolver"
+)
+
+func TestEnvironmentValidation(t *testing.T) {
+	testcases := []struct {
+		Name           string
+		Environment    Environment
+		ExpectedErrStr string
+	}{
+		{Name: "unset", Environment: Environment{}},
+		{Name: "inherit bool", Environment: Environment{Inherit: false}},
+		{Name: "inherit names", Environment: Environment{Inherit: []any{"PATH", "GO*"}}},
+		{Name: "set", Environment: Environment{Set: map[string]string{"CGO_ENABLED": "0"}}},
+		{
+			Name:           "inherit string",
+			Environment:    Environment{Inherit: "PATH"},
+			ExpectedErrStr: "must be a boolean or a list",
+		},
+		{
+			Name:           "inherit non-string element",
+			Environment:    Environment{Inherit: []any{"PATH", int64(1)}},
+			ExpectedErrStr: "is not a string",
+		},
+		{
+			Name:           "inherit empty name",
+			Environment:    Environment{Inherit: []string{""}},
+			ExpectedErrStr: "can not be empty",
+		},
+		{
+			Name:           "inherit invalid pattern",
+			Environment:    Environment{Inherit: []any{"PATH", "GO["}},
+			ExpectedErrStr: "syntax error in pattern",
+		},
+		{
+			Name:           "set invalid name",
+			Environment:    Environment{Set: map[string]string{"A=B": "1"}},
+			ExpectedErrStr: "invalid character '='",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			a := ExampleApp("shop")
+			a.Tasks[0].Environment = tc.Environment
+			err := a.Validate()
+			if tc.ExpectedErrStr == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, "Environment")
+			require.ErrorContains(t, err, tc.ExpectedErrStr)
+		})
+	}
+}
+
+func TestEnvironmentIsParsedAndResolved(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), ".app.toml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte(`
+name = "shop"
+
+[[Task]]
+name = "build"
+command = ["make"]
+
+[Task.Environment]
+inherit = ["PATH", "GO*"]
+set = { APP = "{{ .AppName }}" }
+
+[[Task]]
+name = "check"
+command = ["make", "check"]
+
+[Task.Environment]
+inherit = false
+`), 0o644))
+
+	app, err := AppFromFile(cfgPath)
+	require.NoError(t, err)
+	require.NoError(t, app.Validate())
+	require.NoError(t, app.Resolve(resolver.NewGoTemplate("shop", "/", nil)))
+
+	all, names := app.Tasks[0].Environment.InheritedEnvVars()
+	assert.False(t, all)
+	assert.Equal(t, []string{"PATH", "GO*"}, names)
+	assert.Equal(t, map[string]string{"APP": "shop"}, app.Tasks[0].Environment.Set)
+
+	all, names = app.Tasks[1].Environment.InheritedEnvVars()
+	assert.False(t, all)
+	assert.Empty(t, names)
+}
@@ -2,7 +2,7 @@ package cfg
 
 // FileOutput describes where a file output is stored.
 type FileOutput struct {
-	Path     string     `toml:"path" comment:"Path relative to the application directory."`
+	Path     string     `toml:"path" comment:"Path relative to the application directory.\n If it is a directory or a glob pattern, the files are uploaded as tar archive."`
 	FileCopy []FileCopy `comment:"Copy the file to a local directory."`
 	S3Upload []S3Upload `comment:"Upload the file to S3."`
 }
@@ -12,7 +12,7 @@ type InputInclude struct {
 	Files                []FileInputs
 	GolangSources        []GolangSources `comment:"Inputs specified by resolving dependencies of Golang source files or packages."`
 	ExcludedFiles        FileExcludeList
-	TaskInfos            []TaskInfo `comment:"Information about task of the same App"`
+	TaskInfos            []TaskInfo `comment:"Information about another baur task."`
 
 	filepath string
 }
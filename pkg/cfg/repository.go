@@ -22,13 +22,21 @@ type Repository struct {
 
 	Database Database
 	Discover Discover
+	TaskLog  TaskLog
 
 	filePath string
 }
 
 // Database contains database configuration
 type Database struct {
-	PGSQLURL string `toml:"postgresql_url" comment:"PostgreSQL database Connection string (https://www.postgresql.org/docs/current/static/libpq-connect.html#LIBPQ-CONNSTRING)\n The setting is overwritten by the environment variable BAUR_POSTGRESQL_URL."`
+	PGSQLURL string `toml:"postgresql_url" comment:"PostgreSQL database Connection string (https://www.postgresql.org/docs/current/static/libpq-connect.html#LIBPQ-CONNSTRING)\n or the path of an SQLite database file prefixed with sqlite://, e.g. sqlite:///var/lib/baur/baur.db.\n The setting is overwritten by the environment variable BAUR_POSTGRESQL_URL."`
+}
+
+// TaskLog stores the [TaskLog] section of the repository configuration.
+type TaskLog struct {
+	S3Bucket    string `toml:"s3_bucket" comment:"Logs of task runs that are larger than 1 MiB are uploaded to this S3 bucket\n and referenced from the run in the database, instead of storing them in the database."`
+	S3KeyPrefix string `toml:"s3_key_prefix" comment:"Prefix of the S3 object keys of uploaded logs"`
+	FileCopyDir string `toml:"filecopy_dir" comment:"Directory to that logs of task runs that are larger than 1 MiB are copied,\n instead of storing them in the database. Can not be used together with s3_bucket."`
 }
 
 // Discover stores the [Discover] section of the repository configuration.
@@ -98,6 +106,24 @@ func (r *Repository) Validate() error {
 		return fieldErrorWrap(err, "Discover")
 	}
 
+	err = r.TaskLog.validate()
+	if err != nil {
+		return fieldErrorWrap(err, "TaskLog")
+	}
+
+	return nil
+}
+
+// validate validates the TaskLog section.
+func (t *TaskLog) validate() error {
+	if t.S3Bucket != "" && t.FileCopyDir != "" {
+		return newFieldError("can not be set together with s3_bucket", "filecopy_dir")
+	}
+
+	if t.S3KeyPrefix != "" && t.S3Bucket == "" {
+		return newFieldError("can only be set together with s3_bucket", "s3_key_prefix")
+	}
+
 	return nil
 }
 
@@ -40,3 +40,29 @@ func Test_ExampleRepository_WrittenAndReadCfgIsValid(t *testing.T) {
 		t.Error("validating conf from file failed: ", err)
 	}
 }
+
+func TestTaskLogValidation(t *testing.T) {
+	testcases := []struct {
+		Name      string
+		TaskLog   TaskLog
+		ExpectErr bool
+	}{
+		{Name: "unset", TaskLog: TaskLog{}},
+		{Name: "s3", TaskLog: TaskLog{S3Bucket: "logs", S3KeyPrefix: "baur/"}},
+		{Name: "filecopy", TaskLog: TaskLog{FileCopyDir: "/mnt/logs"}},
+		{Name: "s3 and filecopy", TaskLog: TaskLog{S3Bucket: "logs", FileCopyDir: "/mnt/logs"}, ExpectErr: true},
+		{Name: "key prefix without bucket", TaskLog: TaskLog{S3KeyPrefix: "baur/"}, ExpectErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.TaskLog.validate()
+			if tc.ExpectErr && err == nil {
+				t.Error("validation succeeded, expected an error")
+			}
+			if !tc.ExpectErr && err != nil {
+				t.Errorf("validation failed: %s", err)
+			}
+		})
+	}
+}
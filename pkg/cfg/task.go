@@ -1,12 +1,20 @@
 package cfg
 
+import "time"
+
 // cfg Task is a task section
 type Task struct {
-	Name     string   `toml:"name" comment:"Task name"`
-	Command  []string `toml:"command" comment:"Command to execute.\n The first element is the command, the following its arguments."`
-	Includes []string `toml:"includes" comment:"Input or Output includes that the task inherits.\n Includes are specified in the format FILEPATH#INCLUDE_ID>.\n Paths are relative to the application directory."`
-	Input    Input    `toml:"Input" comment:"Inputs are tracked, when they change the task is rerun."`
-	Output   Output   `toml:"Output" comment:"Artifacts produced by the Task.command and their upload destinations."`
+	Name        string      `toml:"name" comment:"Task name"`
+	Command     []string    `toml:"command" comment:"Command to execute.\n The first element is the command, the following its arguments."`
+	Timeout     string      `toml:"timeout" comment:"Maximum duration the command is allowed to run, e.g. \"10m\" or \"1h30m\".\n When it is exceeded, the command is terminated and the task run fails.\n If empty, the command can run indefinitely."`
+	Retries     int         `toml:"retries" comment:"Number of times the command is rerun when it fails or exceeds the timeout."`
+	RetryDelay  string      `toml:"retry_delay" comment:"Duration to wait before a failed command is rerun, e.g. \"10s\"."`
+	Weight      int         `toml:"weight" comment:"Number of slots of the parallelism budget (baur run --parallel-runs) that the task occupies while it runs.\n If unset, the task occupies 1 slot."`
+	MutexGroup  string      `toml:"mutex_group" comment:"Tasks of the same mutex group are never run in parallel."`
+	Includes    []string    `toml:"includes" comment:"Input or Output includes that the task inherits.\n Includes are specified in the format FILEPATH#INCLUDE_ID>.\n Paths are relative to the application directory."`
+	Environment Environment `toml:"Environment" comment:"Environment variables of the command.\n The inherit key configures the environment variables of the baur process that are passed to the command,\n true passes all variables, false none, a list passes the variables with the given names, e.g.:\n   inherit = [\"PATH\", \"HOME\", \"APP_VERSION\"]\n Glob patterns are supported in names. If unset, all variables are passed."`
+	Input       Input       `toml:"Input" comment:"Inputs are tracked, when they change the task is rerun."`
+	Output      Output      `toml:"Output" comment:"Artifacts produced by the Task.command and their upload destinations."`
 
 	// multiple include sections of the same file can be included, use a map
 	// instead of a slice to act as a Set datastructure
@@ -41,10 +49,42 @@ func (t *Task) name() string {
 	return t.Name
 }
 
+func (t *Task) timeout() string {
+	return t.Timeout
+}
+
+// TimeoutDuration returns the parsed Timeout value.
+// If Timeout is empty, 0 is returned.
+func (t *Task) TimeoutDuration() (time.Duration, error) {
+	return parsePositiveDuration(t.Timeout)
+}
+
+func (t *Task) retries() int {
+	return t.Retries
+}
+
+func (t *Task) retryDelay() string {
+	return t.RetryDelay
+}
+
+// RetryDelayDuration returns the parsed RetryDelay value.
+// If RetryDelay is empty, 0 is returned.
+func (t *Task) RetryDelayDuration() (time.Duration, error) {
+	return parsePositiveDuration(t.RetryDelay)
+}
+
+func (t *Task) weight() int {
+	return t.Weight
+}
+
 func (t *Task) includes() *[]string {
 	return &t.Includes
 }
 
+func (t *Task) environment() *Environment {
+	return &t.Environment
+}
+
 func (t *Task) input() *Input {
 	return &t.Input
 }
@@ -62,6 +102,10 @@ func (t *Task) resolve(resolver Resolver) error {
 		}
 	}
 
+	if err := t.Environment.resolve(resolver); err != nil {
+		return fieldErrorWrap(err, "Environment")
+	}
+
 	if err := t.Input.resolve(resolver); err != nil {
 		return fieldErrorWrap(err, "Input")
 	}
@@ -4,11 +4,17 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 type taskDef interface {
 	command() []string
+	timeout() string
+	retries() int
+	retryDelay() string
+	weight() int
 	includes() *[]string
+	environment() *Environment
 	input() *Input
 	name() string
 	output() *Output
@@ -52,6 +58,25 @@ func taskMerge(task taskDef, workingDir string, resolver Resolver, includeDB *In
 	return nil
 }
 
+// parsePositiveDuration parses a duration string, e.g. "10m".
+// An empty string is parsed as 0, other values must be positive.
+func parsePositiveDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if d <= 0 {
+		return 0, errors.New("must be a positive duration")
+	}
+
+	return d, nil
+}
+
 // taskValidate validates the task section
 func taskValidate(t taskDef) error {
 	if len(t.command()) == 0 {
@@ -66,10 +91,30 @@ func taskValidate(t taskDef) error {
 		return newFieldError("dots are not allowed in task names", "name")
 	}
 
+	if _, err := parsePositiveDuration(t.timeout()); err != nil {
+		return fieldErrorWrap(err, "timeout")
+	}
+
+	if t.retries() < 0 {
+		return newFieldError("can not be negative", "retries")
+	}
+
+	if _, err := parsePositiveDuration(t.retryDelay()); err != nil {
+		return fieldErrorWrap(err, "retry_delay")
+	}
+
+	if t.weight() < 0 {
+		return newFieldError("can not be negative", "weight")
+	}
+
 	if err := validateIncludes(*t.includes()); err != nil {
 		return fieldErrorWrap(err, "includes")
 	}
 
+	if err := t.environment().validate(); err != nil {
+		return fieldErrorWrap(err, "Environment")
+	}
+
 	if t.input() == nil {
 		return newFieldError("section is empty", "Input")
 	}
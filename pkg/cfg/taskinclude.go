@@ -8,11 +8,17 @@ import (
 type TaskInclude struct {
 	IncludeID string `toml:"include_id" comment:"identifier of the include"`
 
-	Name     string   `toml:"name" comment:"Task name"`
-	Command  []string `toml:"command" comment:"Command to execute. The first element is the command, the following its arguments.\n If the command element contains no path seperators, its path is looked up via the $PATH environment variable."`
-	Includes []string `toml:"includes" comment:"Input or Output includes that the task inherits.\n Includes are specified in the format <filepath>#<ID>.\n Paths are relative to the include file location."`
-	Input    Input    `toml:"Input" comment:"Specification of task inputs like source files, Makefiles, etc"`
-	Output   Output   `toml:"Output" comment:"Specification of task outputs produced by the Task.command"`
+	Name        string      `toml:"name" comment:"Task name"`
+	Command     []string    `toml:"command" comment:"Command to execute. The first element is the command, the following its arguments.\n If the command element contains no path seperators, its path is looked up via the $PATH environment variable."`
+	Timeout     string      `toml:"timeout" comment:"Maximum duration the command is allowed to run, e.g. \"10m\" or \"1h30m\".\n When it is exceeded, the command is terminated and the task run fails.\n If empty, the command can run indefinitely."`
+	Retries     int         `toml:"retries" comment:"Number of times the command is rerun when it fails or exceeds the timeout."`
+	RetryDelay  string      `toml:"retry_delay" comment:"Duration to wait before a failed command is rerun, e.g. \"10s\"."`
+	Weight      int         `toml:"weight" comment:"Number of slots of the parallelism budget (baur run --parallel-runs) that the task occupies while it runs.\n If unset, the task occupies 1 slot."`
+	MutexGroup  string      `toml:"mutex_group" comment:"Tasks of the same mutex group are never run in parallel."`
+	Includes    []string    `toml:"includes" comment:"Input or Output includes that the task inherits.\n Includes are specified in the format <filepath>#<ID>.\n Paths are relative to the include file location."`
+	Environment Environment `toml:"Environment" comment:"Environment variables of the command.\n The inherit key configures the environment variables of the baur process that are passed to the command,\n true passes all variables, false none, a list passes the variables with the given names, e.g.:\n   inherit = [\"PATH\", \"HOME\", \"APP_VERSION\"]\n Glob patterns are supported in names. If unset, all variables are passed."`
+	Input       Input       `toml:"Input" comment:"Specification of task inputs like source files, Makefiles, etc"`
+	Output      Output      `toml:"Output" comment:"Specification of task outputs produced by the Task.command"`
 
 	cfgFiles map[string]struct{}
 }
@@ -29,10 +35,30 @@ func (t *TaskInclude) name() string {
 	return t.Name
 }
 
+func (t *TaskInclude) timeout() string {
+	return t.Timeout
+}
+
+func (t *TaskInclude) retries() int {
+	return t.Retries
+}
+
+func (t *TaskInclude) retryDelay() string {
+	return t.RetryDelay
+}
+
+func (t *TaskInclude) weight() int {
+	return t.Weight
+}
+
 func (t *TaskInclude) includes() *[]string {
 	return &t.Includes
 }
 
+func (t *TaskInclude) environment() *Environment {
+	return &t.Environment
+}
+
 func (t *TaskInclude) input() *Input {
 	return &t.Input
 }
@@ -60,6 +86,12 @@ func (t *TaskInclude) toTask() *Task {
 	result.Name = t.Name
 	result.Command = make([]string, len(t.Command))
 	copy(result.Command, t.Command)
+	result.Timeout = t.Timeout
+	result.Retries = t.Retries
+	result.RetryDelay = t.RetryDelay
+	result.Weight = t.Weight
+	result.MutexGroup = t.MutexGroup
+	result.Environment = t.Environment.clone()
 
 	result.cfgFiles = make(map[string]struct{}, len(result.cfgFiles))
 	for k, v := range t.cfgFiles {
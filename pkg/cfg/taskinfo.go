@@ -1,12 +1,32 @@
 package cfg
 
+import (
+	"fmt"
+	"strings"
+)
+
 type TaskInfo struct {
-	TaskName   string `toml:"task_name" comment:"name of a task of the same app"`
+	TaskName   string `toml:"task_name" comment:"name of a task of the same app or <APP-NAME>.<TASK-NAME> of a task of another app"`
 	EnvVarName string `toml:"env_var" comment:"name of an environment variable, when the task command is executed, is is set to to a file path.\n The temporary file contains the JSON encoded information about the task."`
 }
 
+// IsTaskID returns true if TaskName is in the format <APP-NAME>.<TASK-NAME>
+// and can reference a task of another app.
+func (t *TaskInfo) IsTaskID() bool {
+	return strings.Contains(t.TaskName, ".")
+}
+
 func (t *TaskInfo) Validate() error {
-	if err := validateTaskOrAppName(t.TaskName); err != nil {
+	if t.IsTaskID() {
+		appName, taskName, _ := strings.Cut(t.TaskName, ".")
+		if err := validateTaskOrAppName(appName); err != nil {
+			return fieldErrorWrap(fmt.Errorf("app name: %w", err), "task_name")
+		}
+
+		if err := validateTaskOrAppName(taskName); err != nil {
+			return fieldErrorWrap(fmt.Errorf("task name: %w", err), "task_name")
+		}
+	} else if err := validateTaskOrAppName(t.TaskName); err != nil {
 		return fieldErrorWrap(err, "task_name")
 	}
 
@@ -0,0 +1,61 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskInfoValidation(t *testing.T) {
+	testcases := []struct {
+		TaskName       string
+		ExpectedErrStr string
+	}{
+		{
+			TaskName: "build",
+		},
+		{
+			TaskName: "otherapp.build",
+		},
+		{
+			TaskName:       "otherapp.",
+			ExpectedErrStr: "task name: can not be empty",
+		},
+		{
+			TaskName:       ".build",
+			ExpectedErrStr: "app name: can not be empty",
+		},
+		{
+			TaskName:       "other.app.build",
+			ExpectedErrStr: "character not allowed",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.TaskName, func(t *testing.T) {
+			ti := TaskInfo{TaskName: tc.TaskName, EnvVarName: "INFO"}
+			err := ti.Validate()
+			if tc.ExpectedErrStr == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, tc.ExpectedErrStr)
+		})
+	}
+}
+
+func TestTaskInfosReferencingOtherAppsAreNotCheckedForCycles(t *testing.T) {
+	tasks := Tasks{
+		{
+			Name: "build",
+			Input: Input{
+				TaskInfos: []TaskInfo{
+					{TaskName: "otherapp.build", EnvVarName: "INFO"},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, tasks.validateTaskInfosAreCycleFree())
+}
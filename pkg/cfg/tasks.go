@@ -61,6 +61,13 @@ func (tasks Tasks) validateTaskInfosAreCycleFree() error {
 func (task *Task) validateTaskInfoAresCycleFree(allTasks map[string]*Task, recursionTracker []string) error {
 	recursionTracker = append(recursionTracker, task.Name)
 	for _, ti := range task.Input.taskInfos() {
+		// references in the <APP-NAME>.<TASK-NAME> format can point to
+		// tasks of other apps, they are validated when the tasks are
+		// loaded
+		if ti.IsTaskID() {
+			continue
+		}
+
 		if slices.Contains(recursionTracker, ti.TaskName) {
 			return newFieldError(
 				"TaskInfo dependency is cyclic",
@@ -46,6 +46,76 @@ func TestTaskNameValidation(t *testing.T) {
 	}
 }
 
+func TestTaskTimeoutValidation(t *testing.T) {
+	testcases := []struct {
+		Timeout        string
+		ExpectedErrStr string
+	}{
+		{Timeout: ""},
+		{Timeout: "90s"},
+		{Timeout: "1h30m"},
+		{Timeout: "10", ExpectedErrStr: "missing unit"},
+		{Timeout: "-5m", ExpectedErrStr: "must be a positive duration"},
+		{Timeout: "0s", ExpectedErrStr: "must be a positive duration"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Timeout, func(t *testing.T) {
+			a := ExampleApp("shop")
+			a.Tasks[0].Timeout = tc.Timeout
+			err := a.Validate()
+			if tc.ExpectedErrStr == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, "timeout")
+			require.ErrorContains(t, err, tc.ExpectedErrStr)
+		})
+	}
+}
+
+func TestTaskRetryValidation(t *testing.T) {
+	testcases := []struct {
+		Name           string
+		Retries        int
+		RetryDelay     string
+		ExpectedErrStr string
+	}{
+		{Name: "unset"},
+		{Name: "retries", Retries: 3},
+		{Name: "retries_and_delay", Retries: 3, RetryDelay: "10s"},
+		{Name: "negative_retries", Retries: -1, ExpectedErrStr: "retries"},
+		{Name: "delay_without_unit", Retries: 1, RetryDelay: "10", ExpectedErrStr: "retry_delay"},
+		{Name: "negative_delay", Retries: 1, RetryDelay: "-1s", ExpectedErrStr: "retry_delay"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			a := ExampleApp("shop")
+			a.Tasks[0].Retries = tc.Retries
+			a.Tasks[0].RetryDelay = tc.RetryDelay
+			err := a.Validate()
+			if tc.ExpectedErrStr == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, tc.ExpectedErrStr)
+		})
+	}
+}
+
+func TestTaskWeightValidation(t *testing.T) {
+	a := ExampleApp("shop")
+	a.Tasks[0].Weight = 4
+	a.Tasks[0].MutexGroup = "db"
+	require.NoError(t, a.Validate())
+
+	a.Tasks[0].Weight = -1
+	require.ErrorContains(t, a.Validate(), "weight")
+}
+
 func TestTaskInfosAreCycleFree_SelfRef(t *testing.T) {
 	tasks := Tasks{
 		{
@@ -19,6 +19,7 @@ const (
 	FieldID
 	FieldInputString
 	FieldInputFilePath
+	FieldResult
 )
 
 func (f Field) String() string {
@@ -35,6 +36,10 @@ func (f Field) String() string {
 		return "FieldID"
 	case FieldInputString:
 		return "FieldInputString"
+	case FieldInputFilePath:
+		return "FieldInputFilePath"
+	case FieldResult:
+		return "FieldResult"
 	default:
 		return "FieldUndefined"
 	}
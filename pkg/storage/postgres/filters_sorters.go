@@ -30,6 +30,8 @@ func columnName(f storage.Field) (string, error) {
 		return "input_string_val", nil
 	case storage.FieldInputFilePath:
 		return "input_file_path", nil
+	case storage.FieldResult:
+		return "result", nil
 
 	default:
 		return "", fmt.Errorf("no postgresql mapping for storage field %s exists", f)
@@ -661,10 +661,29 @@ func insertTaskOutputsIfNotExist(ctx context.Context, db dbConn, taskRunID int,
 	return nil
 }
 
+func insertTaskRunLog(ctx context.Context, db dbConn, taskRunID int, log *storage.TaskRunLog) error {
+	const query = `
+		INSERT INTO task_run_log (task_run_id, log, url)
+		VALUES($1, $2, $3)
+		`
+
+	content := log.Content
+	if content == nil {
+		content = []byte{}
+	}
+
+	_, err := db.Exec(ctx, query, taskRunID, content, log.URL)
+	if err != nil {
+		return newQueryError(query, err, taskRunID, fmt.Sprintf("<%d bytes>", len(content)), log.URL)
+	}
+
+	return nil
+}
+
 func (c *Client) saveTaskRun(ctx context.Context, tx pgx.Tx, taskRun *storage.TaskRunFull) (int, error) {
 	const query = `
-		   INSERT INTO task_run (vcs_id, task_id, total_input_digest, start_timestamp, stop_timestamp, result)
-		   VALUES($1, $2, $3, $4, $5, $6)
+		   INSERT INTO task_run (vcs_id, task_id, total_input_digest, start_timestamp, stop_timestamp, result, exit_code, attempts)
+		   VALUES($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING ID
 		`
 
@@ -687,6 +706,8 @@ func (c *Client) saveTaskRun(ctx context.Context, tx pgx.Tx, taskRun *storage.Ta
 		taskRun.StartTimestamp,
 		taskRun.StopTimestamp,
 		taskRun.Result,
+		taskRun.ExitCode,
+		taskRun.Attempts,
 	}
 
 	err = tx.QueryRow(
@@ -723,6 +744,13 @@ func (c *Client) saveTaskRun(ctx context.Context, tx pgx.Tx, taskRun *storage.Ta
 		return -1, err
 	}
 
+	if taskRun.Log != nil {
+		err = insertTaskRunLog(ctx, tx, taskRunID, taskRun.Log)
+		if err != nil {
+			return -1, err
+		}
+	}
+
 	return taskRunID, nil
 }
 
@@ -0,0 +1,60 @@
+//go:build dbtest
+// +build dbtest
+
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+func TestTaskRunJobQueue(t *testing.T) {
+	client, cleanupFn := newTestClient(t)
+	defer cleanupFn()
+
+	require.NoError(t, client.Init(ctx))
+
+	ids, err := client.EnqueueTaskRunJobs(ctx, []*storage.TaskRunJob{
+		{ApplicationName: "app", TaskName: "build", VCSRevision: "abc", TotalInputDigest: "1"},
+		{ApplicationName: "app", TaskName: "check", VCSRevision: "abc", TotalInputDigest: "2"},
+		{ApplicationName: "app", TaskName: "lint", VCSRevision: "abc", TotalInputDigest: "3"},
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+
+	job, err := client.ClaimTaskRunJob(ctx, "worker1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ids[0], job.ID)
+	assert.Equal(t, "build", job.TaskName)
+	assert.Equal(t, storage.JobStateRunning, job.State)
+	assert.Equal(t, "worker1", job.Worker)
+
+	job, err = client.ClaimTaskRunJob(ctx, "worker2", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ids[1], job.ID)
+
+	canceled, err := client.CancelTaskRunJobs(ctx, ids)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), canceled)
+
+	_, err = client.ClaimTaskRunJob(ctx, "worker1", time.Minute)
+	require.ErrorIs(t, err, storage.ErrNotExist)
+
+	require.NoError(t, client.FinishTaskRunJob(ctx, ids[1], "worker2", storage.JobStateError, 0, "digest mismatch"))
+	require.ErrorIs(t, client.FinishTaskRunJob(ctx, ids[1], "worker2", storage.JobStateSuccess, 0, ""), storage.ErrNotExist)
+	require.Error(t, client.FinishTaskRunJob(ctx, ids[0], "worker1", storage.JobStateRunning, 0, ""))
+
+	jobs, err := client.TaskRunJobs(ctx, ids)
+	require.NoError(t, err)
+	require.Len(t, jobs, 3)
+	assert.Equal(t, storage.JobStateRunning, jobs[0].State)
+	assert.Equal(t, storage.JobStateError, jobs[1].State)
+	assert.Equal(t, "digest mismatch", jobs[1].Error)
+	assert.Equal(t, "worker2", jobs[1].Worker)
+	assert.Equal(t, storage.JobStateCanceled, jobs[2].State)
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+func (c *Client) AcquireTaskRunLease(
+	ctx context.Context,
+	appName, taskName, totalInputDigest, holder string,
+	ttl time.Duration,
+) error {
+	// the expiration time is calculated by the database server, to be
+	// independent of clock differences between the hosts running baur
+	const query = `
+	INSERT INTO task_run_lease (application_name, task_name, total_input_digest, holder, expires_at)
+	VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5))
+	    ON CONFLICT (application_name, task_name, total_input_digest)
+	    DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+	 WHERE task_run_lease.holder = EXCLUDED.holder
+	    OR task_run_lease.expires_at < now()
+	RETURNING holder
+	`
+
+	var h string
+	err := c.db.QueryRow(ctx, query, appName, taskName, totalInputDigest, holder, ttl.Seconds()).Scan(&h)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrExists
+		}
+
+		return newQueryError(query, err, appName, taskName, totalInputDigest, holder, ttl.Seconds())
+	}
+
+	return nil
+}
+
+func (c *Client) RenewTaskRunLease(
+	ctx context.Context,
+	appName, taskName, totalInputDigest, holder string,
+	ttl time.Duration,
+) error {
+	const query = `
+	UPDATE task_run_lease
+	   SET expires_at = now() + make_interval(secs => $5)
+	 WHERE application_name = $1
+	   AND task_name = $2
+	   AND total_input_digest = $3
+	   AND holder = $4
+	`
+
+	t, err := c.db.Exec(ctx, query, appName, taskName, totalInputDigest, holder, ttl.Seconds())
+	if err != nil {
+		return newQueryError(query, err, appName, taskName, totalInputDigest, holder, ttl.Seconds())
+	}
+
+	if t.RowsAffected() == 0 {
+		return storage.ErrNotExist
+	}
+
+	return nil
+}
+
+func (c *Client) ReleaseTaskRunLease(
+	ctx context.Context,
+	appName, taskName, totalInputDigest, holder string,
+) error {
+	const query = `
+	DELETE FROM task_run_lease
+	 WHERE application_name = $1
+	   AND task_name = $2
+	   AND total_input_digest = $3
+	   AND holder = $4
+	`
+
+	t, err := c.db.Exec(ctx, query, appName, taskName, totalInputDigest, holder)
+	if err != nil {
+		return newQueryError(query, err, appName, taskName, totalInputDigest, holder)
+	}
+
+	if t.RowsAffected() == 0 {
+		return storage.ErrNotExist
+	}
+
+	return nil
+}
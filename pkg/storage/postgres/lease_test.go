@@ -0,0 +1,49 @@
+//go:build dbtest
+// +build dbtest
+
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+func TestTaskRunLeaseIsExclusive(t *testing.T) {
+	client, cleanupFn := newTestClient(t)
+	defer cleanupFn()
+
+	require.NoError(t, client.Init(ctx))
+
+	require.NoError(t, client.AcquireTaskRunLease(ctx, "app", "build", "123", "holder1", time.Minute))
+	require.ErrorIs(t, client.AcquireTaskRunLease(ctx, "app", "build", "123", "holder2", time.Minute), storage.ErrExists)
+
+	// leases of other digests are independent
+	require.NoError(t, client.AcquireTaskRunLease(ctx, "app", "build", "456", "holder2", time.Minute))
+
+	// acquiring an own lease renews it
+	require.NoError(t, client.AcquireTaskRunLease(ctx, "app", "build", "123", "holder1", time.Minute))
+	require.NoError(t, client.RenewTaskRunLease(ctx, "app", "build", "123", "holder1", time.Minute))
+	require.ErrorIs(t, client.RenewTaskRunLease(ctx, "app", "build", "123", "holder2", time.Minute), storage.ErrNotExist)
+
+	require.ErrorIs(t, client.ReleaseTaskRunLease(ctx, "app", "build", "123", "holder2"), storage.ErrNotExist)
+	require.NoError(t, client.ReleaseTaskRunLease(ctx, "app", "build", "123", "holder1"))
+
+	require.NoError(t, client.AcquireTaskRunLease(ctx, "app", "build", "123", "holder2", time.Minute))
+}
+
+func TestExpiredTaskRunLeaseCanBeAcquired(t *testing.T) {
+	client, cleanupFn := newTestClient(t)
+	defer cleanupFn()
+
+	require.NoError(t, client.Init(ctx))
+
+	require.NoError(t, client.AcquireTaskRunLease(ctx, "app", "build", "123", "holder1", time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	require.NoError(t, client.AcquireTaskRunLease(ctx, "app", "build", "123", "holder2", time.Minute))
+	require.ErrorIs(t, client.RenewTaskRunLease(ctx, "app", "build", "123", "holder1", time.Minute), storage.ErrNotExist)
+}
@@ -0,0 +1,14 @@
+CREATE TABLE task_run_job (
+	id serial PRIMARY KEY,
+	application_name text NOT NULL,
+	task_name text NOT NULL,
+	vcs_revision text NOT NULL,
+	total_input_digest text NOT NULL,
+	state text NOT NULL,
+	created_at timestamp with time zone NOT NULL DEFAULT now(),
+	worker text NOT NULL DEFAULT '',
+	task_run_id integer REFERENCES task_run(id) ON DELETE SET NULL,
+	error text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_task_run_job_queued ON task_run_job(id) WHERE state = 'queued';
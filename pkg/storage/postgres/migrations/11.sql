@@ -0,0 +1 @@
+ALTER TABLE task_run_job ADD COLUMN lease_expires_at timestamp with time zone NOT NULL DEFAULT now();
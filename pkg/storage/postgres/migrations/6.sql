@@ -0,0 +1 @@
+ALTER TABLE task_run ADD COLUMN exit_code integer NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE task_run ADD COLUMN attempts integer NOT NULL DEFAULT 1;
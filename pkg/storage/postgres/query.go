@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v4"
 
@@ -50,7 +51,9 @@ func (c *Client) LatestTaskRunByDigest(ctx context.Context, appName, taskName, t
 	       task_run.total_input_digest,
 	       task_run.start_timestamp,
 	       task_run.stop_timestamp,
-	       task_run.result
+	       task_run.result,
+	       task_run.exit_code,
+	       task_run.attempts
 	  FROM application
 	  JOIN task ON application.id = task.application_id
 	  JOIN task_run ON task.id = task_run.task_id
@@ -58,6 +61,7 @@ func (c *Client) LatestTaskRunByDigest(ctx context.Context, appName, taskName, t
 	 WHERE application.name = $1
 	   AND task.name = $2
 	   AND task_run.total_input_digest = $3
+	   AND task_run.result = 'success'
 	 ORDER BY task_run.stop_timestamp DESC
 	 LIMIT 1
 	 `
@@ -76,6 +80,8 @@ func (c *Client) LatestTaskRunByDigest(ctx context.Context, appName, taskName, t
 		&result.StartTimestamp,
 		&result.StopTimestamp,
 		&result.Result,
+		&result.ExitCode,
+		&result.Attempts,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -88,6 +94,27 @@ func (c *Client) LatestTaskRunByDigest(ctx context.Context, appName, taskName, t
 	return &result, nil
 }
 
+func (c *Client) TaskRunLog(ctx context.Context, taskRunID int) (*storage.TaskRunLog, error) {
+	const query = `
+	SELECT log, url
+	  FROM task_run_log
+	 WHERE task_run_id = $1
+	 `
+
+	var result storage.TaskRunLog
+
+	err := c.db.QueryRow(ctx, query, taskRunID).Scan(&result.Content, &result.URL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, storage.ErrNotExist
+		}
+
+		return nil, newQueryError(query, err, taskRunID)
+	}
+
+	return &result, nil
+}
+
 func (c *Client) inputStrings(ctx context.Context, taskRunID int) ([]*storage.InputString, error) {
 	const query = `
 	SELECT input_string.string,
@@ -348,7 +375,7 @@ func (c *Client) TaskRuns(
 	cb func(*storage.TaskRunWithID) error,
 ) error {
 	const queryTemplate = `
-	SELECT task_run_id, application_name, task_name, revision, dirty, total_input_digest, start_timestamp, stop_timestamp, result
+	SELECT task_run_id, application_name, task_name, revision, dirty, total_input_digest, start_timestamp, stop_timestamp, result, exit_code, attempts
 	  FROM (
 	       SELECT DISTINCT ON ({distinct_on})
 		      task_run.id AS task_run_id,
@@ -360,6 +387,8 @@ func (c *Client) TaskRuns(
 	              task_run.start_timestamp AS start_timestamp,
 	              task_run.stop_timestamp,
 	              task_run.result,
+	              task_run.exit_code,
+	              task_run.attempts,
 	              {fields}
 	              (EXTRACT(EPOCH FROM (task_run.stop_timestamp - task_run.start_timestamp))::bigint * 1000000000) AS duration
 	         FROM application
@@ -441,6 +470,8 @@ func (c *Client) TaskRuns(
 			&taskRun.StartTimestamp,
 			&taskRun.StopTimestamp,
 			&taskRun.Result,
+			&taskRun.ExitCode,
+			&taskRun.Attempts,
 		)
 		if err != nil {
 			rows.Close()
@@ -464,6 +495,54 @@ func (c *Client) TaskRuns(
 	return nil
 }
 
+func (c *Client) AvgTaskRunDurations(ctx context.Context, appNames []string, stoppedBefore time.Time, maxRuns int) ([]*storage.TaskRunDuration, error) {
+	const query = `
+	SELECT application_name,
+	       task_name,
+	       avg(duration)::double precision
+	  FROM (
+		SELECT application.name AS application_name,
+		       task.name AS task_name,
+		       extract(epoch FROM task_run.stop_timestamp - task_run.start_timestamp) AS duration,
+		       row_number() OVER (PARTITION BY task_run.task_id ORDER BY task_run.id DESC) AS run_nr
+		  FROM application
+		  JOIN task ON application.id = task.application_id
+		  JOIN task_run ON task.id = task_run.task_id
+		 WHERE application.name = ANY($1)
+		   AND task_run.result = $2
+		   AND task_run.stop_timestamp < $3
+	       ) AS runs
+	 WHERE run_nr <= $4
+	 GROUP BY application_name, task_name
+	 `
+	var result []*storage.TaskRunDuration
+
+	args := []any{appNames, string(storage.ResultSuccess), stoppedBefore, maxRuns}
+	rows, err := c.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, newQueryError(query, err, args...)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d storage.TaskRunDuration
+		var secs float64
+
+		if err := rows.Scan(&d.ApplicationName, &d.TaskName, &secs); err != nil {
+			return nil, newQueryError(query, err, args...)
+		}
+
+		d.AvgDuration = time.Duration(secs * float64(time.Second))
+		result = append(result, &d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, newQueryError(query, err, args...)
+	}
+
+	return result, nil
+}
+
 func (c *Client) ReleaseExists(ctx context.Context, name string) (bool, error) {
 	const query = `
 	SELECT COUNT(id)
@@ -490,7 +569,7 @@ func (c *Client) ReleaseTaskRuns(ctx context.Context, releaseName string) ([]*st
 		SELECT application.name,
 		       task.name,
 		       task_run.id,
-		       output.id, output.name,
+		       output.id, output.name, output.type,
 		       upload.uri, upload.method
 		FROM fr
 		JOIN release_task_run ON release_task_run.release_id = fr.id
@@ -513,6 +592,7 @@ func (c *Client) ReleaseTaskRuns(ctx context.Context, releaseName string) ([]*st
 		var r storage.ReleaseTaskRunsResult
 		var outputID sql.NullInt32
 		var outputName sql.NullString
+		var outputType sql.NullString
 		var uri sql.NullString
 		var uploadMethod sql.NullString
 
@@ -522,6 +602,7 @@ func (c *Client) ReleaseTaskRuns(ctx context.Context, releaseName string) ([]*st
 			&r.RunID,
 			&outputID,
 			&outputName,
+			&outputType,
 			&uri,
 			&uploadMethod,
 		)
@@ -534,6 +615,7 @@ func (c *Client) ReleaseTaskRuns(ctx context.Context, releaseName string) ([]*st
 
 		r.OutputID = int(outputID.Int32)
 		r.OutputName = outputName.String
+		r.OutputType = storage.ArtifactType(outputType.String)
 		r.URI = uri.String
 		r.UploadMethod = storage.UploadMethod(uploadMethod.String)
 		result = append(result, &r)
@@ -93,6 +93,80 @@ func TestLatestTaskRunByDigest(t *testing.T) {
 	assert.Equal(t, taskRunDropMonotonicTimevals(&run2.TaskRun), taskRunDropMonotonicTimevals(&latestTaskRun.TaskRun))
 }
 
+func TestLatestTaskRunByDigestIgnoresFailedRuns(t *testing.T) {
+	client, cleanupFn := newTestClient(t)
+	defer cleanupFn()
+
+	require.NoError(t, client.Init(ctx))
+
+	successfulRun := storage.TaskRunFull{
+		TaskRun: storage.TaskRun{
+			ApplicationName:  "baurHimself",
+			TaskName:         "build",
+			VCSRevision:      "1",
+			StartTimestamp:   time.Now(),
+			StopTimestamp:    time.Now().Add(5 * time.Minute),
+			Result:           storage.ResultSuccess,
+			TotalInputDigest: "1234567890",
+		},
+	}
+
+	failedRun := successfulRun
+	failedRun.StopTimestamp = failedRun.StopTimestamp.Add(time.Second)
+	failedRun.Result = storage.ResultFailure
+	failedRun.ExitCode = 2
+	failedRun.Attempts = 3
+
+	successfulID, err := client.SaveTaskRun(ctx, &successfulRun)
+	require.NoError(t, err)
+
+	failedID, err := client.SaveTaskRun(ctx, &failedRun)
+	require.NoError(t, err)
+
+	latestTaskRun, err := client.LatestTaskRunByDigest(ctx, failedRun.ApplicationName, failedRun.TaskName, failedRun.TotalInputDigest)
+	require.NoError(t, err)
+	assert.Equal(t, successfulID, latestTaskRun.ID, "wrong record id")
+
+	failedTaskRun, err := client.TaskRun(ctx, failedID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.ResultFailure, failedTaskRun.Result)
+	assert.Equal(t, 2, failedTaskRun.ExitCode)
+	assert.Equal(t, 3, failedTaskRun.Attempts)
+}
+
+func TestTaskRunLog(t *testing.T) {
+	client, cleanupFn := newTestClient(t)
+	defer cleanupFn()
+
+	require.NoError(t, client.Init(ctx))
+
+	run := storage.TaskRunFull{
+		TaskRun: storage.TaskRun{
+			ApplicationName:  "baurHimself",
+			TaskName:         "build",
+			VCSRevision:      "1",
+			StartTimestamp:   time.Now(),
+			StopTimestamp:    time.Now().Add(5 * time.Minute),
+			Result:           storage.ResultSuccess,
+			TotalInputDigest: "1234567890",
+		},
+	}
+
+	idWithoutLog, err := client.SaveTaskRun(ctx, &run)
+	require.NoError(t, err)
+
+	run.Log = &storage.TaskRunLog{Content: []byte("building...\ndone\n")}
+	idWithLog, err := client.SaveTaskRun(ctx, &run)
+	require.NoError(t, err)
+
+	log, err := client.TaskRunLog(ctx, idWithLog)
+	require.NoError(t, err)
+	assert.Equal(t, run.Log.Content, log.Content)
+
+	_, err = client.TaskRunLog(ctx, idWithoutLog)
+	assert.ErrorIs(t, err, storage.ErrNotExist)
+}
+
 func TestLatestTaskRunByDigest_ReturnsErrNotExist(t *testing.T) {
 	client, cleanupFn := newTestClient(t)
 	defer cleanupFn()
@@ -18,9 +18,9 @@ import (
 
 const (
 	// minSchemaVer is the minimum required database schema version
-	minSchemaVer int32 = 4
+	minSchemaVer int32 = 12
 	// maxSchemaVer is the highest database schema version that is compatible
-	maxSchemaVer int32 = 5
+	maxSchemaVer int32 = 12
 )
 
 // migration represents a database schema migration.
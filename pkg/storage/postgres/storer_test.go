@@ -0,0 +1,19 @@
+//go:build dbtest
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/simplesurance/baur/v5/internal/testutils/storagetest"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+func TestStorer(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storer {
+		client, cleanupFn := newTestClient(t)
+		t.Cleanup(cleanupFn)
+
+		return client
+	})
+}
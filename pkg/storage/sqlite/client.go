@@ -0,0 +1,107 @@
+// Package sqlite is a baur-storage implementation storing data in an SQLite
+// database file.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the sqlite database/sql driver
+)
+
+// URLPrefix is the prefix of URLs that refer to SQLite databases, it is
+// followed by the path of the database file, e.g. "sqlite:///var/lib/baur.db".
+const URLPrefix = "sqlite://"
+
+// Client is an SQLite storage client
+type Client struct {
+	db *sql.DB
+}
+
+// Logger is an interface for logging debug informations
+type Logger interface {
+	Debugln(v ...any)
+}
+
+// dsnParameters configure the connections to the database:
+// - foreign key constraints are only enforced when they are enabled,
+// - the write-ahead log allows to read while another connection writes,
+// - transactions lock the database for writing when they start, instead of
+// failing when a read transaction is upgraded to a write transaction while
+// another connection is writing,
+// - connections wait for locks instead of failing immediately.
+const dsnParameters = "_pragma=foreign_keys(1)" +
+	"&_pragma=journal_mode(WAL)" +
+	"&_pragma=busy_timeout(30000)" +
+	"&_txlock=immediate"
+
+// New opens the SQLite database referenced by url. The database file is
+// created if it does not exist.
+// If logger is nil, logging is disabled.
+func New(ctx context.Context, url string, logger Logger) (*Client, error) {
+	path, found := strings.CutPrefix(url, URLPrefix)
+	if !found {
+		return nil, errors.New("sqlite database URL must start with " + URLPrefix)
+	}
+
+	if path == "" {
+		return nil, errors.New("sqlite database URL does not contain a file path")
+	}
+
+	if logger != nil {
+		logger.Debugln("sqlite: opening database", path)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+dsnParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Client{db: db}, nil
+}
+
+// Close closes the database.
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+// inTx runs fn in a transaction. If fn returns nil, the transaction is
+// committed, otherwise it is rolled back.
+func (c *Client) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+// timestamp converts t to the representation of timestamps in the database,
+// the number of nanoseconds since the unix epoch.
+func timestamp(t time.Time) int64 {
+	return t.UnixNano()
+}
+
+// timeFromTimestamp converts a timestamp from the database to a time.Time.
+func timeFromTimestamp(ts int64) time.Time {
+	return time.Unix(0, ts)
+}
+
+type dbConn interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+}
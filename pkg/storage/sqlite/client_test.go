@@ -0,0 +1,35 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/internal/testutils/storagetest"
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	clt, err := New(t.Context(), URLPrefix+filepath.Join(t.TempDir(), "baur.db"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, clt.Close()) })
+
+	return clt
+}
+
+func TestStorer(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storer {
+		return newTestClient(t)
+	})
+}
+
+func TestNewFailsOnInvalidURL(t *testing.T) {
+	_, err := New(t.Context(), "postgres://localhost/baur", nil)
+	require.Error(t, err)
+
+	_, err = New(t.Context(), URLPrefix, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,212 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+// errPretend is returned by the transaction functions of delete operations
+// that only pretend to delete, to roll back the transaction.
+var errPretend = errors.New("pretend mode, rolling back")
+
+// inDeleteTx runs fn in a transaction, when pretend is true the transaction
+// is rolled back.
+func (c *Client) inDeleteTx(ctx context.Context, pretend bool, fn func(*sql.Tx) error) error {
+	err := c.inTx(ctx, func(tx *sql.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		if pretend {
+			return errPretend
+		}
+
+		return nil
+	})
+	if errors.Is(err, errPretend) {
+		return nil
+	}
+
+	return err
+}
+
+func (c *Client) ReleasesDelete(ctx context.Context, before time.Time, pretend bool) (*storage.ReleasesDeleteResult, error) {
+	var result storage.ReleasesDeleteResult
+
+	err := c.inDeleteTx(ctx, pretend, func(tx *sql.Tx) error {
+		var err error
+
+		result.DeletedReleases, err = deleteOldReleases(ctx, tx, before)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func deleteOldReleases(ctx context.Context, tx *sql.Tx, before time.Time) (int64, error) {
+	const query = `
+	      DELETE FROM release
+	       WHERE created_at < $1
+	`
+
+	return execRowsAffected(ctx, tx, query, timestamp(before))
+}
+
+func (c *Client) TaskRunsDelete(ctx context.Context, before time.Time, pretend bool) (*storage.TaskRunsDeleteResult, error) {
+	var result *storage.TaskRunsDeleteResult
+
+	err := c.inDeleteTx(ctx, pretend, func(tx *sql.Tx) (err error) {
+		result, err = taskRunsDelete(ctx, tx, before)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// execRowsAffected executes query and returns the number of affected rows.
+func execRowsAffected(ctx context.Context, db dbConn, query string, args ...any) (int64, error) {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, newQueryError(query, err, args...)
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return 0, newQueryError(query, err, args...)
+	}
+
+	return cnt, nil
+}
+
+func taskRunsDelete(ctx context.Context, tx *sql.Tx, before time.Time) (*storage.TaskRunsDeleteResult, error) {
+	const deleteTaskRunsQuery = `
+	      DELETE FROM task_run
+	       WHERE start_timestamp < $1
+	         AND NOT EXISTS (
+			SELECT 1 FROM release_task_run
+			 WHERE task_run.id = release_task_run.task_run_id
+	       )
+	`
+	const deleteTasksQuery = `
+		DELETE FROM task
+		 WHERE NOT EXISTS (
+			SELECT 1 FROM task_run
+			 WHERE task.id = task_run.task_id
+		 )
+	`
+	const deleteAppsQuery = `
+		DELETE FROM application
+		 WHERE id NOT IN (
+			 SELECT task.application_id FROM task
+		 )
+	`
+	const deleteOutputsQuery = `
+		DELETE FROM output
+		 WHERE id NOT IN (
+			 SELECT task_run_output.output_id
+			   FROM task_run_output
+		 )
+	`
+	const deleteUploadsQuery = `
+		DELETE FROM upload
+		 WHERE id NOT IN (
+			 SELECT task_run_output.upload_id
+			   FROM task_run_output
+		 )
+	`
+	const deleteVCSQuery = `
+		DELETE FROM vcs
+		 WHERE NOT EXISTS (
+			 SELECT 1 FROM task_run
+			  WHERE vcs.id = task_run.vcs_id
+		 )
+	`
+
+	var result storage.TaskRunsDeleteResult
+	var err error
+
+	result.DeletedTaskRuns, err = execRowsAffected(ctx, tx, deleteTaskRunsQuery, timestamp(before))
+	if err != nil {
+		return nil, err
+	}
+
+	result.DeletedTasks, err = execRowsAffected(ctx, tx, deleteTasksQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	result.DeletedApps, err = execRowsAffected(ctx, tx, deleteAppsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	result.DeletedOutputs, err = execRowsAffected(ctx, tx, deleteOutputsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	result.DeletedUploads, err = execRowsAffected(ctx, tx, deleteUploadsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	result.DeletedInputs, err = deleteUnusedInputs(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	result.DeletedVCS, err = execRowsAffected(ctx, tx, deleteVCSQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func deleteUnusedInputs(ctx context.Context, tx *sql.Tx) (int64, error) {
+	queries := []string{
+		`
+		DELETE FROM input_file
+		 WHERE NOT EXISTS (
+			SELECT 1 FROM task_run_file_input
+			 WHERE input_file.id = task_run_file_input.input_file_id
+		 )
+		`,
+		`
+		DELETE FROM input_string
+		 WHERE id NOT IN (
+			 SELECT task_run_string_input.input_string_id
+			   FROM task_run_string_input
+		 )
+		`,
+		`
+		DELETE FROM input_task
+		 WHERE id NOT IN (
+			 SELECT task_run_task_input.input_task_id
+			   FROM task_run_task_input
+		 )
+		`,
+	}
+
+	var cnt int64
+	for _, q := range queries {
+		n, err := execRowsAffected(ctx, tx, q)
+		if err != nil {
+			return 0, err
+		}
+
+		cnt += n
+	}
+
+	return cnt, nil
+}
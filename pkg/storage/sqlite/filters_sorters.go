@@ -0,0 +1,184 @@
+package sqlite
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+// query assembles an SQL-Query described by storage Filters and Sorters
+type query struct {
+	BaseQuery string
+	Filters   []*storage.Filter
+	Sorters   []*storage.Sorter
+	Limit     uint
+}
+
+func columnName(f storage.Field) (string, error) {
+	switch f {
+	case storage.FieldApplicationName:
+		return "application_name", nil
+	case storage.FieldTaskName:
+		return "task_name", nil
+	case storage.FieldDuration:
+		return "duration", nil
+	case storage.FieldStartTime:
+		return "start_timestamp", nil
+	case storage.FieldID:
+		return "task_run_id", nil
+	case storage.FieldInputString:
+		return "input_string_val", nil
+	case storage.FieldInputFilePath:
+		return "input_file_path", nil
+	case storage.FieldResult:
+		return "result", nil
+
+	default:
+		return "", fmt.Errorf("no sqlite mapping for storage field %s exists", f)
+	}
+}
+
+// filterValue converts v to the representation that is stored in the
+// database.
+func filterValue(v any) any {
+	switch val := v.(type) {
+	case time.Time:
+		return timestamp(val)
+	case time.Duration:
+		return int64(val)
+	default:
+		return v
+	}
+}
+
+// compileOp returns the SQL expression for the operator op applied to
+// column and value. The parameters in the expression are numbered
+// consecutively starting at argNr. The query arguments for the parameters
+// are returned.
+func compileOp(column string, op storage.Op, value any, argNr int) (string, []any, error) {
+	switch op {
+	case storage.OpEQ:
+		return fmt.Sprintf("%s = $%d", column, argNr), []any{filterValue(value)}, nil
+	case storage.OpGT:
+		return fmt.Sprintf("%s > $%d", column, argNr), []any{filterValue(value)}, nil
+	case storage.OpLT:
+		return fmt.Sprintf("%s < $%d", column, argNr), []any{filterValue(value)}, nil
+	case storage.OpIN:
+		// SQLite does not support array parameters, a parameter is
+		// added for each element
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice {
+			return "", nil, fmt.Errorf("value of %s filter must be a slice, got %T", op, value)
+		}
+
+		if rv.Len() == 0 {
+			return "FALSE", nil, nil
+		}
+
+		params := make([]string, 0, rv.Len())
+		args := make([]any, 0, rv.Len())
+		for i := range rv.Len() {
+			params = append(params, fmt.Sprintf("$%d", argNr+i))
+			args = append(args, filterValue(rv.Index(i).Interface()))
+		}
+
+		return fmt.Sprintf("%s IN (%s)", column, strings.Join(params, ", ")), args, nil
+
+	default:
+		return "", nil, fmt.Errorf("no sqlite mapping for storage operator %s exists", op)
+	}
+}
+
+func compileSortOrder(o storage.Order, column string) (string, error) {
+	switch o {
+	case storage.OrderAsc:
+		return column + " ASC", nil
+	case storage.OrderDesc:
+		return column + " DESC", nil
+
+	default:
+		return "", fmt.Errorf("no sqlite mapping for storage order direction %s exists", o)
+	}
+}
+
+func (q *query) compileFilterStr() (filterStr string, args []any, err error) {
+	if len(q.Filters) == 0 {
+		return
+	}
+
+	for i, f := range q.Filters {
+		column, err := columnName(f.Field)
+		if err != nil {
+			return "", nil, err
+		}
+
+		opStr, opArgs, err := compileOp(column, f.Operator, f.Value, len(args)+1)
+		if err != nil {
+			return "", nil, err
+		}
+
+		filterStr += opStr
+		args = append(args, opArgs...)
+
+		if i+1 < len(q.Filters) {
+			filterStr += " AND "
+		}
+	}
+
+	return "WHERE " + filterStr, args, err
+}
+
+func (q *query) compileSorterStr() (string, error) {
+	if len(q.Sorters) == 0 {
+		return "", nil
+	}
+
+	var sorterStr string
+	for i, f := range q.Sorters {
+		column, err := columnName(f.Field)
+		if err != nil {
+			return "", err
+		}
+
+		orderStr, err := compileSortOrder(f.Order, column)
+		if err != nil {
+			return "", err
+		}
+
+		sorterStr += orderStr
+
+		if i+1 < len(q.Sorters) {
+			sorterStr += ", "
+		}
+	}
+
+	return "ORDER BY " + sorterStr, nil
+}
+
+func (q *query) compileLimitStr() string {
+	if q.Limit == storage.NoLimit {
+		return ""
+	}
+
+	return fmt.Sprintf("LIMIT %d", q.Limit)
+}
+
+// Compile creates the SQL query string and returns it with the arguments for the query
+func (q *query) Compile() (query string, args []any, err error) {
+	filterStr, args, err := q.compileFilterStr()
+	if err != nil {
+		return "", nil, err
+	}
+
+	orderStr, err := q.compileSorterStr()
+	if err != nil {
+		return "", nil, err
+	}
+
+	limitStr := q.compileLimitStr()
+
+	return fmt.Sprintf("%s %s %s %s", q.BaseQuery, filterStr, orderStr, limitStr), args, nil
+}
@@ -0,0 +1,469 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+// insertRows runs query for each element of args with the element as
+// arguments. The query is prepared once, inserting rows one by one is cheap
+// in SQLite and is not limited by the max. number of query parameters.
+func insertRows(ctx context.Context, db dbConn, query string, args [][]any) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return newQueryError(query, err)
+	}
+	defer stmt.Close()
+
+	for _, a := range args {
+		if _, err := stmt.ExecContext(ctx, a...); err != nil {
+			return newQueryError(query, err, a...)
+		}
+	}
+
+	return nil
+}
+
+// insertRowsReturningIDs runs query for each element of args with the element
+// as arguments and returns the IDs returned by the query.
+func insertRowsReturningIDs(ctx context.Context, db dbConn, query string, args [][]any) ([]int, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, newQueryError(query, err)
+	}
+	defer stmt.Close()
+
+	ids := make([]int, 0, len(args))
+	for _, a := range args {
+		var id int
+
+		if err := stmt.QueryRowContext(ctx, a...).Scan(&id); err != nil {
+			return nil, newQueryError(query, err, a...)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func insertAppIfNotExist(ctx context.Context, db dbConn, appName string) (int, error) {
+	const query = `
+	   INSERT INTO application (name)
+	   VALUES ($1)
+	       ON CONFLICT (name)
+	       DO UPDATE SET id=id
+	RETURNING id
+	`
+
+	var id int
+
+	if err := db.QueryRowContext(ctx, query, appName).Scan(&id); err != nil {
+		return -1, newQueryError(query, err, appName)
+	}
+
+	return id, nil
+}
+
+func insertTaskIfNotExist(ctx context.Context, db dbConn, appName, taskName string) (int, error) {
+	var id int
+
+	appID, err := insertAppIfNotExist(ctx, db, appName)
+	if err != nil {
+		return -1, err
+	}
+
+	const query = `
+	   INSERT INTO task (name, application_id)
+	   VALUES ($1, $2)
+	       ON CONFLICT (name, application_id)
+	       DO UPDATE SET id=id
+	RETURNING id
+	`
+
+	if err := db.QueryRowContext(ctx, query, taskName, appID).Scan(&id); err != nil {
+		return -1, newQueryError(query, err, appName, taskName)
+	}
+
+	return id, nil
+}
+
+func insertVCSIfNotExist(ctx context.Context, db dbConn, revision string, isDirty bool) (int, error) {
+	const query = `
+	   INSERT INTO vcs (revision, dirty)
+	   VALUES ($1, $2)
+	       ON CONFLICT (revision, dirty)
+	       DO UPDATE SET id=id
+	RETURNING id
+	`
+
+	var id int
+
+	if err := db.QueryRowContext(ctx, query, revision, isDirty).Scan(&id); err != nil {
+		return -1, newQueryError(query, err, revision, isDirty)
+	}
+
+	return id, nil
+}
+
+func insertTaskRunInputFiles(ctx context.Context, db dbConn, taskRunID int, inputs []*storage.InputFile) error {
+	const insertInputQuery = `
+	   INSERT INTO input_file (path, digest)
+	   VALUES ($1, $2)
+	       ON CONFLICT (path, digest)
+	       DO UPDATE SET id=id
+	RETURNING id
+	`
+	const insertRelationQuery = `
+	INSERT INTO task_run_file_input (task_run_id, input_file_id)
+	VALUES ($1, $2)
+	`
+
+	args := make([][]any, 0, len(inputs))
+	for _, in := range inputs {
+		args = append(args, []any{in.Path, in.Digest})
+	}
+
+	ids, err := insertRowsReturningIDs(ctx, db, insertInputQuery, args)
+	if err != nil {
+		return err
+	}
+
+	return insertRows(ctx, db, insertRelationQuery, relationArgs(taskRunID, ids))
+}
+
+func insertTaskRunInputStrings(ctx context.Context, db dbConn, taskRunID int, inputs []*storage.InputString) error {
+	const insertInputQuery = `
+	   INSERT INTO input_string (string, digest)
+	   VALUES ($1, $2)
+	       ON CONFLICT (digest)
+	       DO UPDATE SET id=id
+	RETURNING id
+	`
+	const insertRelationQuery = `
+	INSERT INTO task_run_string_input (task_run_id, input_string_id)
+	VALUES ($1, $2)
+	`
+
+	args := make([][]any, 0, len(inputs))
+	for _, in := range inputs {
+		args = append(args, []any{in.String, in.Digest})
+	}
+
+	ids, err := insertRowsReturningIDs(ctx, db, insertInputQuery, args)
+	if err != nil {
+		return err
+	}
+
+	return insertRows(ctx, db, insertRelationQuery, relationArgs(taskRunID, ids))
+}
+
+func insertTaskRunInputEnvVars(ctx context.Context, db dbConn, taskRunID int, inputs []*storage.InputEnvVar) error {
+	const insertInputQuery = `
+	   INSERT INTO input_env_var (name, digest)
+	   VALUES ($1, $2)
+	       ON CONFLICT (digest)
+	       DO UPDATE SET id=id
+	RETURNING id
+	`
+	const insertRelationQuery = `
+	INSERT INTO task_run_env_var_input (task_run_id, input_env_var_id)
+	VALUES ($1, $2)
+	`
+
+	args := make([][]any, 0, len(inputs))
+	for _, in := range inputs {
+		args = append(args, []any{in.Name, in.Digest})
+	}
+
+	ids, err := insertRowsReturningIDs(ctx, db, insertInputQuery, args)
+	if err != nil {
+		return err
+	}
+
+	return insertRows(ctx, db, insertRelationQuery, relationArgs(taskRunID, ids))
+}
+
+func insertTaskRunInputTasks(ctx context.Context, db dbConn, taskRunID int, inputs []*storage.InputTaskInfo) error {
+	const insertInputQuery = `
+	   INSERT INTO input_task (name, digest)
+	   VALUES ($1, $2)
+	       ON CONFLICT (name, digest)
+	       DO UPDATE SET id=id
+	RETURNING id
+	`
+	const insertRelationQuery = `
+	INSERT INTO task_run_task_input (task_run_id, input_task_id)
+	VALUES ($1, $2)
+	`
+
+	args := make([][]any, 0, len(inputs))
+	for _, in := range inputs {
+		args = append(args, []any{in.Name, in.Digest})
+	}
+
+	ids, err := insertRowsReturningIDs(ctx, db, insertInputQuery, args)
+	if err != nil {
+		return err
+	}
+
+	return insertRows(ctx, db, insertRelationQuery, relationArgs(taskRunID, ids))
+}
+
+// relationArgs returns the arguments to insert records that relate the row
+// with the ID firstID to each row with an ID in secondIDs.
+func relationArgs(firstID int, secondIDs []int) [][]any {
+	result := make([][]any, 0, len(secondIDs))
+	for _, id := range secondIDs {
+		result = append(result, []any{firstID, id})
+	}
+
+	return result
+}
+
+func insertOutputIfNotExist(ctx context.Context, db dbConn, output *storage.Output) (int, error) {
+	const query = `
+	   INSERT INTO output (name, type, digest, size_bytes)
+	   VALUES($1, $2, $3, $4)
+	       ON CONFLICT (name, type, digest, size_bytes)
+	       DO UPDATE SET id=id
+	RETURNING id
+	`
+
+	var id int
+
+	queryArgs := []any{
+		output.Name,
+		output.Type,
+		output.Digest,
+		output.SizeBytes,
+	}
+
+	err := db.QueryRowContext(ctx, query, queryArgs...).Scan(&id)
+	if err != nil {
+		return -1, newQueryError(query, err, queryArgs...)
+	}
+
+	return id, nil
+}
+
+func insertTaskOutputs(ctx context.Context, db dbConn, taskRunID int, outputs []*storage.Output) error {
+	const insertUploadQuery = `
+	INSERT into upload (uri, method, start_timestamp, stop_timestamp)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id
+	`
+	const insertRelationQuery = `
+	INSERT INTO task_run_output (task_run_id, output_id, upload_id)
+	VALUES ($1, $2, $3)
+	`
+
+	var relations [][]any
+
+	for _, output := range outputs {
+		outputID, err := insertOutputIfNotExist(ctx, db, output)
+		if err != nil {
+			return err
+		}
+
+		uploadArgs := make([][]any, 0, len(output.Uploads))
+		for _, upload := range output.Uploads {
+			uploadArgs = append(uploadArgs, []any{
+				upload.URI,
+				upload.Method,
+				timestamp(upload.UploadStartTimestamp),
+				timestamp(upload.UploadStopTimestamp),
+			})
+		}
+
+		uploadIDs, err := insertRowsReturningIDs(ctx, db, insertUploadQuery, uploadArgs)
+		if err != nil {
+			return err
+		}
+
+		for _, uploadID := range uploadIDs {
+			relations = append(relations, []any{taskRunID, outputID, uploadID})
+		}
+	}
+
+	return insertRows(ctx, db, insertRelationQuery, relations)
+}
+
+func insertTaskRunLog(ctx context.Context, db dbConn, taskRunID int, log *storage.TaskRunLog) error {
+	const query = `
+		INSERT INTO task_run_log (task_run_id, log, url)
+		VALUES($1, $2, $3)
+		`
+
+	content := log.Content
+	if content == nil {
+		content = []byte{}
+	}
+
+	_, err := db.ExecContext(ctx, query, taskRunID, content, log.URL)
+	if err != nil {
+		return newQueryError(query, err, taskRunID, fmt.Sprintf("<%d bytes>", len(content)), log.URL)
+	}
+
+	return nil
+}
+
+func (*Client) saveTaskRun(ctx context.Context, tx *sql.Tx, taskRun *storage.TaskRunFull) (int, error) {
+	const query = `
+		   INSERT INTO task_run (vcs_id, task_id, total_input_digest, start_timestamp, stop_timestamp, result, exit_code, attempts)
+		   VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ID
+		`
+
+	var taskRunID int
+
+	vcsID, err := insertVCSIfNotExist(ctx, tx, taskRun.VCSRevision, taskRun.VCSIsDirty)
+	if err != nil {
+		return -1, fmt.Errorf("storing vcs record failed: %w", err)
+	}
+
+	taskID, err := insertTaskIfNotExist(ctx, tx, taskRun.ApplicationName, taskRun.TaskName)
+	if err != nil {
+		return -1, fmt.Errorf("storing task record failed: %w", err)
+	}
+
+	queryArgs := []any{
+		vcsID,
+		taskID,
+		taskRun.TotalInputDigest,
+		timestamp(taskRun.StartTimestamp),
+		timestamp(taskRun.StopTimestamp),
+		taskRun.Result,
+		taskRun.ExitCode,
+		taskRun.Attempts,
+	}
+
+	err = tx.QueryRowContext(ctx, query, queryArgs...).Scan(&taskRunID)
+	if err != nil {
+		return -1, newQueryError(query, err, queryArgs...)
+	}
+
+	err = insertTaskRunInputStrings(ctx, tx, taskRunID, taskRun.Inputs.Strings)
+	if err != nil {
+		return -1, err
+	}
+
+	err = insertTaskRunInputFiles(ctx, tx, taskRunID, taskRun.Inputs.Files)
+	if err != nil {
+		return -1, err
+	}
+
+	err = insertTaskRunInputEnvVars(ctx, tx, taskRunID, taskRun.Inputs.EnvironmentVariables)
+	if err != nil {
+		return -1, err
+	}
+
+	err = insertTaskRunInputTasks(ctx, tx, taskRunID, taskRun.Inputs.TaskInfo)
+	if err != nil {
+		return -1, err
+	}
+
+	err = insertTaskOutputs(ctx, tx, taskRunID, taskRun.Outputs)
+	if err != nil {
+		return -1, err
+	}
+
+	if taskRun.Log != nil {
+		err = insertTaskRunLog(ctx, tx, taskRunID, taskRun.Log)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	return taskRunID, nil
+}
+
+func (c *Client) SaveTaskRun(ctx context.Context, taskRun *storage.TaskRunFull) (int, error) {
+	var id int
+
+	return id, c.inTx(ctx, func(tx *sql.Tx) (err error) {
+		id, err = c.saveTaskRun(ctx, tx, taskRun)
+		return err
+	})
+}
+
+func (c *Client) CreateRelease(ctx context.Context, releaseName string, createdAt time.Time, taskRunIDs []int, metadata io.Reader) error {
+	return c.inTx(ctx, func(tx *sql.Tx) error {
+		releaseID, err := c.insertRelease(ctx, tx, releaseName, createdAt, metadata)
+		if err != nil {
+			return err
+		}
+
+		return c.insertReleaseTaskRun(ctx, tx, releaseID, taskRunIDs)
+	})
+}
+
+func (*Client) insertRelease(ctx context.Context, tx *sql.Tx, name string, createdAt time.Time, metadata io.Reader) (int, error) {
+	const existsQuery = `
+	SELECT EXISTS (SELECT 1 FROM release WHERE name = $1)
+	`
+	const query = `
+		INSERT INTO release (name, created_at, metadata)
+	        VALUES($1, $2, $3)
+	     RETURNING id
+	`
+
+	var data []byte
+	var err error
+	var releaseID int
+	var exists bool
+
+	// the transaction holds the write lock of the database, no other
+	// release can be created concurrently
+	if err := tx.QueryRowContext(ctx, existsQuery, name).Scan(&exists); err != nil {
+		return -1, newQueryError(existsQuery, err, name)
+	}
+
+	if exists {
+		return -1, storage.ErrExists
+	}
+
+	if metadata != nil {
+		data, err = io.ReadAll(metadata)
+		if err != nil {
+			return -1, fmt.Errorf("reading metadata failed: %w", err)
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query, name, timestamp(createdAt), data).Scan(&releaseID)
+	if err != nil {
+		if metadata == nil {
+			return -1, newQueryError(query, err, name)
+		}
+
+		return -1, newQueryError(query, err, []any{name, "<OMITTED-RELEASE-METADATA>"}...)
+	}
+
+	return releaseID, nil
+}
+
+func (*Client) insertReleaseTaskRun(ctx context.Context, tx *sql.Tx, releaseID int, taskRunIDs []int) error {
+	const query = `
+		INSERT INTO release_task_run (release_id, task_run_id)
+		VALUES ($1, $2)`
+
+	if len(taskRunIDs) == 0 {
+		return errors.New("no task run IDs were specified")
+	}
+
+	return insertRows(ctx, tx, query, relationArgs(releaseID, taskRunIDs))
+}
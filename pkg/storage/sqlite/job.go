@@ -0,0 +1,214 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+const jobColumns = `
+	       id,
+	       application_name,
+	       task_name,
+	       vcs_revision,
+	       total_input_digest,
+	       state,
+	       worker,
+	       task_run_id,
+	       error
+`
+
+func scanJob(row rowScanner) (*storage.TaskRunJobWithID, error) {
+	var job storage.TaskRunJobWithID
+	var taskRunID sql.NullInt32
+
+	err := row.Scan(
+		&job.ID,
+		&job.ApplicationName,
+		&job.TaskName,
+		&job.VCSRevision,
+		&job.TotalInputDigest,
+		&job.State,
+		&job.Worker,
+		&taskRunID,
+		&job.Error,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.TaskRunID = int(taskRunID.Int32)
+
+	return &job, nil
+}
+
+func (c *Client) EnqueueTaskRunJobs(ctx context.Context, jobs []*storage.TaskRunJob) ([]int, error) {
+	const query = `
+	INSERT INTO task_run_job (application_name, task_name, vcs_revision, total_input_digest, state)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id
+	`
+
+	ids := make([]int, 0, len(jobs))
+
+	err := c.inTx(ctx, func(tx *sql.Tx) error {
+		for _, job := range jobs {
+			var id int
+
+			err := tx.QueryRowContext(ctx, query,
+				job.ApplicationName, job.TaskName, job.VCSRevision, job.TotalInputDigest, storage.JobStateQueued,
+			).Scan(&id)
+			if err != nil {
+				return newQueryError(query, err,
+					job.ApplicationName, job.TaskName, job.VCSRevision, job.TotalInputDigest, storage.JobStateQueued,
+				)
+			}
+
+			ids = append(ids, id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (c *Client) ClaimTaskRunJob(ctx context.Context, worker string, ttl time.Duration) (*storage.TaskRunJobWithID, error) {
+	// SQLite serializes write statements, concurrent workers can not
+	// claim the same job
+	const query = `
+	UPDATE task_run_job
+	   SET state = $1, worker = $2, lease_expires_at = $3
+	 WHERE id = (
+	       SELECT id
+	         FROM task_run_job
+	        WHERE state = $4
+	           OR (state = $1 AND lease_expires_at < $5)
+	        ORDER BY id
+	        LIMIT 1
+	 )
+	RETURNING` + jobColumns
+
+	now := time.Now()
+	args := []any{storage.JobStateRunning, worker, timestamp(now.Add(ttl)), storage.JobStateQueued, timestamp(now)}
+
+	job, err := scanJob(c.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotExist
+		}
+
+		return nil, newQueryError(query, err, args...)
+	}
+
+	return job, nil
+}
+
+func (c *Client) RenewTaskRunJob(ctx context.Context, id int, worker string, ttl time.Duration) error {
+	const query = `
+	UPDATE task_run_job
+	   SET lease_expires_at = $3
+	 WHERE id = $1
+	   AND worker = $2
+	   AND state = $4
+	`
+
+	cnt, err := execRowsAffected(ctx, c.db, query, id, worker, timestamp(time.Now().Add(ttl)), storage.JobStateRunning)
+	if err != nil {
+		return err
+	}
+
+	if cnt == 0 {
+		return fmt.Errorf("job with id %d running on worker %s %w", id, worker, storage.ErrNotExist)
+	}
+
+	return nil
+}
+
+func (c *Client) FinishTaskRunJob(ctx context.Context, id int, worker string, state storage.JobState, taskRunID int, errMsg string) error {
+	const query = `
+	UPDATE task_run_job
+	   SET state = $3, task_run_id = $4, error = $5
+	 WHERE id = $1
+	   AND worker = $2
+	   AND state = $6
+	`
+
+	if !state.IsFinished() {
+		return fmt.Errorf("%q is not a final job state", state)
+	}
+
+	var runID sql.NullInt32
+	if taskRunID != 0 {
+		runID = sql.NullInt32{Int32: int32(taskRunID), Valid: true} //nolint:gosec
+	}
+
+	cnt, err := execRowsAffected(ctx, c.db, query, id, worker, state, runID, errMsg, storage.JobStateRunning)
+	if err != nil {
+		return err
+	}
+
+	if cnt == 0 {
+		return fmt.Errorf("job with id %d running on worker %s %w", id, worker, storage.ErrNotExist)
+	}
+
+	return nil
+}
+
+func (c *Client) CancelTaskRunJobs(ctx context.Context, ids []int) (int64, error) {
+	idsExpr, idsArgs, err := compileOp("id", storage.OpIN, ids, 3)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+	UPDATE task_run_job
+	   SET state = $1
+	 WHERE state = $2
+	   AND ` + idsExpr
+
+	return execRowsAffected(ctx, c.db, query, append([]any{storage.JobStateCanceled, storage.JobStateQueued}, idsArgs...)...)
+}
+
+func (c *Client) TaskRunJobs(ctx context.Context, ids []int) ([]*storage.TaskRunJobWithID, error) {
+	idsExpr, args, err := compileOp("id", storage.OpIN, ids, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT` + jobColumns + `
+	  FROM task_run_job
+	 WHERE ` + idsExpr + `
+	 ORDER BY id
+	`
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, newQueryError(query, err, args...)
+	}
+	defer rows.Close()
+
+	var result []*storage.TaskRunJobWithID
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, newQueryError(query, err, args...)
+		}
+
+		result = append(result, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, newQueryError(query, err, args...)
+	}
+
+	return result, nil
+}
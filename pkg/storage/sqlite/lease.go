@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+// The expiration times of leases are calculated with the clock of the host
+// running baur, the database file is only accessed by processes on the same
+// host.
+
+func (c *Client) AcquireTaskRunLease(
+	ctx context.Context,
+	appName, taskName, totalInputDigest, holder string,
+	ttl time.Duration,
+) error {
+	const query = `
+	INSERT INTO task_run_lease (application_name, task_name, total_input_digest, holder, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	    ON CONFLICT (application_name, task_name, total_input_digest)
+	    DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+	 WHERE task_run_lease.holder = excluded.holder
+	    OR task_run_lease.expires_at < $6
+	RETURNING holder
+	`
+
+	now := time.Now()
+	args := []any{appName, taskName, totalInputDigest, holder, timestamp(now.Add(ttl)), timestamp(now)}
+
+	var h string
+	err := c.db.QueryRowContext(ctx, query, args...).Scan(&h)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrExists
+		}
+
+		return newQueryError(query, err, args...)
+	}
+
+	return nil
+}
+
+func (c *Client) RenewTaskRunLease(
+	ctx context.Context,
+	appName, taskName, totalInputDigest, holder string,
+	ttl time.Duration,
+) error {
+	const query = `
+	UPDATE task_run_lease
+	   SET expires_at = $5
+	 WHERE application_name = $1
+	   AND task_name = $2
+	   AND total_input_digest = $3
+	   AND holder = $4
+	`
+
+	cnt, err := execRowsAffected(ctx, c.db, query, appName, taskName, totalInputDigest, holder, timestamp(time.Now().Add(ttl)))
+	if err != nil {
+		return err
+	}
+
+	if cnt == 0 {
+		return storage.ErrNotExist
+	}
+
+	return nil
+}
+
+func (c *Client) ReleaseTaskRunLease(
+	ctx context.Context,
+	appName, taskName, totalInputDigest, holder string,
+) error {
+	const query = `
+	DELETE FROM task_run_lease
+	 WHERE application_name = $1
+	   AND task_name = $2
+	   AND total_input_digest = $3
+	   AND holder = $4
+	`
+
+	cnt, err := execRowsAffected(ctx, c.db, query, appName, taskName, totalInputDigest, holder)
+	if err != nil {
+		return err
+	}
+
+	if cnt == 0 {
+		return storage.ErrNotExist
+	}
+
+	return nil
+}
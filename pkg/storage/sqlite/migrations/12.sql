@@ -0,0 +1 @@
+ALTER TABLE task_run_log ADD COLUMN url text NOT NULL DEFAULT '';
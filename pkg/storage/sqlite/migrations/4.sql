@@ -0,0 +1,4 @@
+/* SQLite can not add a NOT NULL constraint to an existing column, the column
+   is created with it and a default value for existing rows instead. */
+ALTER TABLE release ADD COLUMN created_at integer NOT NULL DEFAULT 0;
+UPDATE release SET created_at = CAST(unixepoch('subsec') * 1000000000 AS integer);
@@ -0,0 +1,8 @@
+CREATE TABLE task_run_lease (
+	application_name text NOT NULL,
+	task_name text NOT NULL,
+	total_input_digest text NOT NULL,
+	holder text NOT NULL,
+	expires_at integer NOT NULL,
+	PRIMARY KEY (application_name, task_name, total_input_digest)
+);
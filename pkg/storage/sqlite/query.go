@@ -0,0 +1,591 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+func (c *Client) TaskRun(ctx context.Context, id int) (*storage.TaskRunWithID, error) {
+	var taskRun *storage.TaskRunWithID
+
+	idFilter := []*storage.Filter{
+		{
+			Field:    storage.FieldID,
+			Operator: storage.OpEQ,
+			Value:    id,
+		},
+	}
+
+	err := c.TaskRuns(ctx, idFilter, nil, storage.NoLimit, func(tr *storage.TaskRunWithID) error {
+		taskRun = tr
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if taskRun == nil {
+		panic("TaskRuns returned a nil TaskRunWithID and nil error")
+	}
+
+	return taskRun, nil
+}
+
+// rowScanner is implemented by sql.Row and sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTaskRun scans a row with the columns: task_run.id, application.name,
+// task.name, vcs.revision, vcs.dirty, task_run.total_input_digest,
+// task_run.start_timestamp, task_run.stop_timestamp, task_run.result,
+// task_run.exit_code, task_run.attempts
+func scanTaskRun(row rowScanner) (*storage.TaskRunWithID, error) {
+	var result storage.TaskRunWithID
+	var startTimestamp, stopTimestamp int64
+
+	err := row.Scan(
+		&result.ID,
+		&result.ApplicationName,
+		&result.TaskName,
+		&result.VCSRevision,
+		&result.VCSIsDirty,
+		&result.TotalInputDigest,
+		&startTimestamp,
+		&stopTimestamp,
+		&result.Result,
+		&result.ExitCode,
+		&result.Attempts,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result.StartTimestamp = timeFromTimestamp(startTimestamp)
+	result.StopTimestamp = timeFromTimestamp(stopTimestamp)
+
+	return &result, nil
+}
+
+func (c *Client) LatestTaskRunByDigest(ctx context.Context, appName, taskName, totalInputDigest string) (*storage.TaskRunWithID, error) {
+	const query = `
+	SELECT task_run.id,
+	       application.name,
+	       task.name,
+	       vcs.revision,
+	       vcs.dirty,
+	       task_run.total_input_digest,
+	       task_run.start_timestamp,
+	       task_run.stop_timestamp,
+	       task_run.result,
+	       task_run.exit_code,
+	       task_run.attempts
+	  FROM application
+	  JOIN task ON application.id = task.application_id
+	  JOIN task_run ON task.id = task_run.task_id
+	  LEFT OUTER JOIN vcs ON vcs.id = task_run.vcs_id
+	 WHERE application.name = $1
+	   AND task.name = $2
+	   AND task_run.total_input_digest = $3
+	   AND task_run.result = 'success'
+	 ORDER BY task_run.stop_timestamp DESC
+	 LIMIT 1
+	 `
+
+	result, err := scanTaskRun(c.db.QueryRowContext(ctx, query, appName, taskName, totalInputDigest))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotExist
+		}
+
+		return nil, newQueryError(query, err, appName, taskName, totalInputDigest)
+	}
+
+	return result, nil
+}
+
+func (c *Client) TaskRunLog(ctx context.Context, taskRunID int) (*storage.TaskRunLog, error) {
+	const query = `
+	SELECT log, url
+	  FROM task_run_log
+	 WHERE task_run_id = $1
+	 `
+
+	var result storage.TaskRunLog
+
+	err := c.db.QueryRowContext(ctx, query, taskRunID).Scan(&result.Content, &result.URL)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotExist
+		}
+
+		return nil, newQueryError(query, err, taskRunID)
+	}
+
+	return &result, nil
+}
+
+// queryInputs runs query with the argument taskRunID. The query must return
+// 2 string columns, newFn is called with their values for each row.
+func queryInputs[T any](ctx context.Context, db dbConn, query string, taskRunID int, newFn func(string, string) T) ([]T, error) {
+	var result []T
+
+	rows, err := db.QueryContext(ctx, query, taskRunID)
+	if err != nil {
+		return nil, newQueryError(query, err, taskRunID)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a, b string
+
+		if err := rows.Scan(&a, &b); err != nil {
+			return nil, newQueryError(query, err, taskRunID)
+		}
+
+		result = append(result, newFn(a, b))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, newQueryError(query, err, taskRunID)
+	}
+
+	return result, nil
+}
+
+func (c *Client) inputStrings(ctx context.Context, taskRunID int) ([]*storage.InputString, error) {
+	const query = `
+	SELECT input_string.string,
+	       input_string.digest
+	  FROM input_string
+	  JOIN task_run_string_input ON input_string.id = task_run_string_input.input_string_id
+	 WHERE task_run_string_input.task_run_id = $1
+	 `
+
+	return queryInputs(ctx, c.db, query, taskRunID, func(s, digest string) *storage.InputString {
+		return &storage.InputString{String: s, Digest: digest}
+	})
+}
+
+func (c *Client) inputFiles(ctx context.Context, taskRunID int) ([]*storage.InputFile, error) {
+	const query = `
+	SELECT input_file.path,
+	       input_file.digest
+	  FROM input_file
+	  JOIN task_run_file_input ON input_file.id = task_run_file_input.input_file_id
+	 WHERE task_run_file_input.task_run_id = $1
+	 `
+
+	return queryInputs(ctx, c.db, query, taskRunID, func(path, digest string) *storage.InputFile {
+		return &storage.InputFile{Path: path, Digest: digest}
+	})
+}
+
+func (c *Client) inputEnvVars(ctx context.Context, taskRunID int) ([]*storage.InputEnvVar, error) {
+	const query = `
+	SELECT input_env_var.name,
+	       input_env_var.digest
+	  FROM input_env_var
+	  JOIN task_run_env_var_input ON input_env_var.id = task_run_env_var_input.input_env_var_id
+	 WHERE task_run_env_var_input.task_run_id = $1
+	 `
+
+	return queryInputs(ctx, c.db, query, taskRunID, func(name, digest string) *storage.InputEnvVar {
+		return &storage.InputEnvVar{Name: name, Digest: digest}
+	})
+}
+
+func (c *Client) inputTasks(ctx context.Context, taskRunID int) ([]*storage.InputTaskInfo, error) {
+	const query = `
+	SELECT input_task.name,
+	       input_task.digest
+	  FROM input_task
+	  JOIN task_run_task_input ON input_task.id = task_run_task_input.input_task_id
+	 WHERE task_run_task_input.task_run_id = $1
+	 `
+
+	return queryInputs(ctx, c.db, query, taskRunID, func(name, digest string) *storage.InputTaskInfo {
+		return &storage.InputTaskInfo{Name: name, Digest: digest}
+	})
+}
+
+func (c *Client) Inputs(ctx context.Context, taskRunID int) (*storage.Inputs, error) {
+	var result storage.Inputs
+	var err error
+
+	result.Files, err = c.inputFiles(ctx, taskRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Strings, err = c.inputStrings(ctx, taskRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	result.EnvironmentVariables, err = c.inputEnvVars(ctx, taskRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	result.TaskInfo, err = c.inputTasks(ctx, taskRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Files) == 0 && len(result.Strings) == 0 && len(result.EnvironmentVariables) == 0 {
+		return nil, storage.ErrNotExist
+	}
+
+	return &result, nil
+}
+
+func (c *Client) Outputs(ctx context.Context, taskRunID int) ([]*storage.Output, error) {
+	const query = `
+	SELECT output.id,
+	       output.name,
+	       output.type,
+	       output.digest,
+	       output.size_bytes,
+	       upload.uri,
+	       upload.method,
+	       upload.start_timestamp,
+	       upload.stop_timestamp
+	  FROM output
+	  JOIN task_run_output ON task_run_output.output_id = output.id
+	  JOIN upload ON upload.id = task_run_output.upload_id
+	 WHERE task_run_output.task_run_id = $1
+	 `
+
+	resMap := map[int]*storage.Output{}
+
+	rows, err := c.db.QueryContext(ctx, query, taskRunID)
+	if err != nil {
+		return nil, newQueryError(query, err, taskRunID)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var upload storage.Upload
+		var outputID int
+		var uploadStartTimestamp, uploadStopTimestamp int64
+		output := &storage.Output{}
+
+		err := rows.Scan(
+			&outputID,
+			&output.Name,
+			&output.Type,
+			&output.Digest,
+			&output.SizeBytes,
+			&upload.URI,
+			&upload.Method,
+			&uploadStartTimestamp,
+			&uploadStopTimestamp,
+		)
+		if err != nil {
+			return nil, newQueryError(query, err, taskRunID)
+		}
+
+		upload.UploadStartTimestamp = timeFromTimestamp(uploadStartTimestamp)
+		upload.UploadStopTimestamp = timeFromTimestamp(uploadStopTimestamp)
+
+		if rec := resMap[outputID]; rec == nil {
+			resMap[outputID] = output
+		} else {
+			output = rec
+		}
+
+		output.Uploads = append(output.Uploads, &upload)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, newQueryError(query, err, taskRunID)
+	}
+
+	if len(resMap) == 0 {
+		return nil, storage.ErrNotExist
+	}
+
+	result := make([]*storage.Output, 0, len(resMap))
+	for _, output := range resMap {
+		result = append(result, output)
+	}
+
+	return result, nil
+}
+
+func (c *Client) TaskRuns(
+	ctx context.Context,
+	filters []*storage.Filter,
+	sorters []*storage.Sorter,
+	limit uint,
+	cb func(*storage.TaskRunWithID) error,
+) error {
+	// the combination of a task run and an input string or input file
+	// path is unique, the joins do not return duplicate rows
+	const queryTemplate = `
+	SELECT task_run_id, application_name, task_name, revision, dirty, total_input_digest, start_timestamp, stop_timestamp, result, exit_code, attempts
+	  FROM (
+	       SELECT task_run.id AS task_run_id,
+	              application.name AS application_name,
+	              task.name AS task_name,
+	              vcs.revision AS revision,
+	              vcs.dirty AS dirty,
+	              task_run.total_input_digest AS total_input_digest,
+	              task_run.start_timestamp AS start_timestamp,
+	              task_run.stop_timestamp AS stop_timestamp,
+	              task_run.result AS result,
+	              task_run.exit_code AS exit_code,
+	              task_run.attempts AS attempts,
+	              {fields}
+	              (task_run.stop_timestamp - task_run.start_timestamp) AS duration
+	         FROM application
+	         JOIN task ON application.id = task.application_id
+	         JOIN task_run ON task.id = task_run.task_id
+		 {joins}
+	         LEFT OUTER JOIN vcs ON vcs.id = task_run.vcs_id
+	       ) tr
+	  `
+
+	containsInputStringFilter := false
+	containsInputFileFilter := false
+	for _, filter := range filters {
+		if filter.Field == storage.FieldInputString {
+			containsInputStringFilter = true
+		} else if filter.Field == storage.FieldInputFilePath {
+			containsInputFileFilter = true
+		}
+	}
+
+	if containsInputFileFilter && containsInputStringFilter {
+		return errors.New("either a FieldInputString or FieldInputFilePath filter can be specified, not both")
+	}
+
+	var replacer *strings.Replacer
+	if containsInputStringFilter { //nolint: gocritic // ifElseChain: rewrite if-else to switch statement
+		replacer = strings.NewReplacer(
+			"{fields}", "input_string.string AS input_string_val,",
+			"{joins}", "JOIN task_run_string_input ON task_run_string_input.task_run_id = task_run.id\n"+
+				"JOIN input_string ON input_string.id = task_run_string_input.input_string_id",
+		)
+	} else if containsInputFileFilter {
+		replacer = strings.NewReplacer(
+			"{fields}", "input_file.path AS input_file_path,",
+			"{joins}", "JOIN task_run_file_input ON task_run_file_input.task_run_id = task_run.id\n"+
+				"JOIN input_file ON input_file.id = task_run_file_input.input_file_id",
+		)
+	} else {
+		replacer = strings.NewReplacer(
+			"{fields}", "",
+			"{joins}", "")
+	}
+	queryStr := replacer.Replace(queryTemplate)
+
+	var queryReturnedRows bool
+
+	q := query{
+		BaseQuery: queryStr,
+		Filters:   filters,
+		Sorters:   sorters,
+		Limit:     limit,
+	}
+
+	query, args, err := q.Compile()
+	if err != nil {
+		return fmt.Errorf("compiling query string failed: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return newQueryError(query, err, args...)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		queryReturnedRows = true
+
+		taskRun, err := scanTaskRun(rows)
+		if err != nil {
+			return newQueryError(query, err, args...)
+		}
+
+		if err := cb(taskRun); err != nil {
+			return fmt.Errorf("callback failed: %w", err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return newQueryError(query, err, args...)
+	}
+
+	if !queryReturnedRows {
+		return storage.ErrNotExist
+	}
+
+	return nil
+}
+
+func (c *Client) AvgTaskRunDurations(ctx context.Context, appNames []string, stoppedBefore time.Time, maxRuns int) ([]*storage.TaskRunDuration, error) {
+	const queryTmpl = `
+	SELECT application_name,
+	       task_name,
+	       avg(duration)
+	  FROM (
+		SELECT application.name AS application_name,
+		       task.name AS task_name,
+		       task_run.stop_timestamp - task_run.start_timestamp AS duration,
+		       row_number() OVER (PARTITION BY task_run.task_id ORDER BY task_run.id DESC) AS run_nr
+		  FROM application
+		  JOIN task ON application.id = task.application_id
+		  JOIN task_run ON task.id = task_run.task_id
+		 WHERE task_run.result = $1
+		   AND task_run.stop_timestamp < $2
+		   AND %s
+	       ) AS runs
+	 WHERE run_nr <= $3
+	 GROUP BY application_name, task_name
+	 `
+	var result []*storage.TaskRunDuration
+
+	appNamesExpr, appNamesArgs, err := compileOp("application.name", storage.OpIN, appNames, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(queryTmpl, appNamesExpr)
+	args := append([]any{string(storage.ResultSuccess), timestamp(stoppedBefore), maxRuns}, appNamesArgs...)
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, newQueryError(query, err, args...)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d storage.TaskRunDuration
+		var nsecs float64
+
+		if err := rows.Scan(&d.ApplicationName, &d.TaskName, &nsecs); err != nil {
+			return nil, newQueryError(query, err, args...)
+		}
+
+		d.AvgDuration = time.Duration(nsecs)
+		result = append(result, &d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, newQueryError(query, err, args...)
+	}
+
+	return result, nil
+}
+
+func (c *Client) ReleaseExists(ctx context.Context, name string) (bool, error) {
+	const query = `
+	SELECT EXISTS (SELECT 1 FROM release WHERE name = $1)
+	 `
+	var exists bool
+	err := c.db.QueryRowContext(ctx, query, name).Scan(&exists)
+	if err != nil {
+		return false, newQueryError(query, err, name)
+	}
+
+	return exists, nil
+}
+
+func (c *Client) ReleaseTaskRuns(ctx context.Context, releaseName string) ([]*storage.ReleaseTaskRunsResult, error) {
+	const query = `
+		WITH fr AS (
+		    SELECT id
+		    FROM release
+		    WHERE name = $1
+		)
+		SELECT application.name,
+		       task.name,
+		       task_run.id,
+		       output.id, output.name, output.type,
+		       upload.uri, upload.method
+		FROM fr
+		JOIN release_task_run ON release_task_run.release_id = fr.id
+		JOIN task_run ON task_run.id = release_task_run.task_run_id
+		JOIN task ON task.id = task_run.task_id
+		JOIN application ON application.id = task.application_id
+	   LEFT JOIN task_run_output ON task_run_output.task_run_id = release_task_run.task_run_id
+	   LEFT JOIN output ON output.id = task_run_output.output_id
+	   LEFT JOIN upload ON upload.id = task_run_output.upload_id
+	    ORDER BY application.name, task.name, output.name, upload.uri
+		`
+	rows, err := c.db.QueryContext(ctx, query, releaseName)
+	if err != nil {
+		return nil, newQueryError(query, err, releaseName)
+	}
+	defer rows.Close()
+
+	var result []*storage.ReleaseTaskRunsResult
+
+	for rows.Next() {
+		var r storage.ReleaseTaskRunsResult
+		var outputID sql.NullInt32
+		var outputName sql.NullString
+		var outputType sql.NullString
+		var uri sql.NullString
+		var uploadMethod sql.NullString
+
+		err := rows.Scan(
+			&r.AppName,
+			&r.TaskName,
+			&r.RunID,
+			&outputID,
+			&outputName,
+			&outputType,
+			&uri,
+			&uploadMethod,
+		)
+		if err != nil {
+			return nil, newQueryError(query, err, releaseName)
+		}
+
+		r.OutputID = int(outputID.Int32)
+		r.OutputName = outputName.String
+		r.OutputType = storage.ArtifactType(outputType.String)
+		r.URI = uri.String
+		r.UploadMethod = storage.UploadMethod(uploadMethod.String)
+		result = append(result, &r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, newQueryError(query, err, releaseName)
+	}
+
+	if len(result) == 0 {
+		return nil, storage.ErrNotExist
+	}
+
+	return result, nil
+}
+
+func (c *Client) ReleaseMetadata(ctx context.Context, releaseName string) ([]byte, error) {
+	const query = `
+	SELECT metadata
+	  FROM release
+	 WHERE release.name = $1
+	 `
+	var metadata []byte
+
+	err := c.db.QueryRowContext(ctx, query, releaseName).Scan(&metadata)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotExist
+		}
+		return nil, newQueryError(query, err, releaseName)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/simplesurance/baur/v5/pkg/storage"
+)
+
+func TestMustParseMigrations(t *testing.T) {
+	var migrations []*migration
+	assert.NotPanics(t, func() { migrations = mustParseMigrations() })
+
+	assert.NotEmpty(t, migrations)
+
+	assert.Truef(t,
+		sort.SliceIsSorted(migrations, func(i, j int) bool {
+			return migrations[i].version < migrations[j].version
+		}),
+		"returned migrations are not sorted ascending by version: %+v", migrations,
+	)
+
+	assert.Equal(t, maxSchemaVer, migrations[len(migrations)-1].version)
+}
+
+func TestMigrationsFromVer(t *testing.T) {
+	migrations := []*migration{
+		{
+			version: 1,
+		},
+		{
+			version: 5,
+		},
+		{
+			version: 7,
+		},
+	}
+
+	assert.ElementsMatch(t, migrations, migrationsFromVer(0, migrations))
+	assert.ElementsMatch(t, []*migration{{version: 7}}, migrationsFromVer(5, migrations))
+	assert.Empty(t, migrationsFromVer(7, migrations))
+}
+
+func TestIsCompatible_SchemaVersionDoesNotMatch(t *testing.T) {
+	client := newTestClient(t)
+
+	require.NoError(t, client.Init(t.Context()))
+
+	_, err := client.db.ExecContext(t.Context(), "UPDATE migrations set schema_version = 100")
+	require.NoError(t, err)
+
+	err = client.IsCompatible(t.Context())
+	require.ErrorContains(t, err, "database schema version is not compatible")
+}
+
+func TestUpgradeAppliesNewerMigrations(t *testing.T) {
+	client := newTestClient(t)
+
+	migrations := mustParseMigrations()
+	require.NoError(t, client.applyMigrations(t.Context(), migrations[:len(migrations)-1]))
+
+	ver, err := client.SchemaVersion(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, migrations[len(migrations)-2].version, ver)
+
+	require.NoError(t, client.Upgrade(t.Context()))
+	require.NoError(t, client.IsCompatible(t.Context()))
+}
+
+func TestTaskRunsDeleteRemovesAllRecords(t *testing.T) {
+	ctx := t.Context()
+	client := newTestClient(t)
+	require.NoError(t, client.Init(ctx))
+
+	startTime := time.Now().Add(-time.Minute)
+	_, err := client.SaveTaskRun(ctx, &storage.TaskRunFull{
+		TaskRun: storage.TaskRun{
+			ApplicationName:  "baurHimself",
+			TaskName:         "build",
+			VCSRevision:      "1",
+			StartTimestamp:   startTime,
+			StopTimestamp:    time.Now(),
+			Result:           storage.ResultSuccess,
+			TotalInputDigest: "1234567890",
+		},
+		Inputs: storage.Inputs{
+			Files:    []*storage.InputFile{{Path: "main.go", Digest: "45"}},
+			Strings:  []*storage.InputString{{String: "hello", Digest: "46"}},
+			TaskInfo: []*storage.InputTaskInfo{{Name: "shop.build", Digest: "47"}},
+		},
+		Outputs: []*storage.Output{
+			{
+				Name:      "binary",
+				Type:      storage.ArtifactTypeFile,
+				Digest:    "456",
+				SizeBytes: 300,
+				Uploads: []*storage.Upload{
+					{
+						URI:                  "abc",
+						UploadStartTimestamp: time.Now(),
+						UploadStopTimestamp:  time.Now().Add(5 * time.Second),
+						Method:               storage.UploadMethodS3,
+					},
+				},
+			},
+		},
+		Log: &storage.TaskRunLog{Content: []byte("done")},
+	})
+	require.NoError(t, err)
+
+	_, err = client.TaskRunsDelete(ctx, startTime.Add(time.Second), false)
+	require.NoError(t, err)
+
+	rows, err := client.db.QueryContext(ctx, "SELECT name FROM sqlite_schema WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	require.NoError(t, err)
+
+	var tableNames []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		tableNames = append(tableNames, name)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	require.NotEmpty(t, tableNames)
+
+	for _, name := range tableNames {
+		if name == "migrations" {
+			continue
+		}
+
+		var exists bool
+		err := client.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT * FROM "`+name+`" LIMIT 1)`).Scan(&exists)
+		require.NoError(t, err)
+		assert.Falsef(t, exists, "table %s is not empty", name)
+	}
+}
@@ -62,6 +62,9 @@ type ArtifactType string
 const (
 	ArtifactTypeDocker ArtifactType = "docker"
 	ArtifactTypeFile   ArtifactType = "file"
+	// ArtifactTypeFileTree is a tar archive of a directory or of files
+	// matching a glob pattern.
+	ArtifactTypeFileTree ArtifactType = "filetree"
 )
 
 type Output struct {
@@ -88,12 +91,36 @@ type TaskRun struct {
 	StopTimestamp    time.Time
 	TotalInputDigest string
 	Result           Result
+	// ExitCode is the exit code of the task command.
+	ExitCode int
+	// Attempts is the number of times the task command was executed.
+	Attempts int
 }
 
 type TaskRunFull struct {
 	TaskRun
 	Inputs  Inputs
 	Outputs []*Output
+	// Log is the log of the task run. If it is nil, no log is stored.
+	Log *TaskRunLog
+}
+
+// TaskRunLog is the combined stdout and stderr output of all attempts to run
+// the command of a task.
+// Large logs are uploaded by baur, then only the URL is stored.
+type TaskRunLog struct {
+	// Content is the log, it is empty when the log was uploaded.
+	Content []byte
+	// URL is the location that the log was uploaded to, it is empty when
+	// the log is stored in Content.
+	URL string
+}
+
+// TaskRunDuration is the average duration of runs of a task.
+type TaskRunDuration struct {
+	ApplicationName string
+	TaskName        string
+	AvgDuration     time.Duration
 }
 
 type TaskRunWithID struct {
@@ -107,10 +134,60 @@ type ReleaseTaskRunsResult struct {
 	RunID        int
 	OutputID     int
 	OutputName   string
+	OutputType   ArtifactType
 	URI          string
 	UploadMethod UploadMethod
 }
 
+// JobState is the state of a TaskRunJob.
+type JobState string
+
+const (
+	// JobStateQueued is the state of a job that waits to be claimed by
+	// a worker.
+	JobStateQueued JobState = "queued"
+	// JobStateRunning is the state of a job that was claimed by a worker.
+	JobStateRunning JobState = "running"
+	// JobStateSuccess is the state of a job whose task run succeeded and
+	// was recorded.
+	JobStateSuccess JobState = "success"
+	// JobStateFailure is the state of a job whose task command failed.
+	JobStateFailure JobState = "failure"
+	// JobStateError is the state of a job that could not be processed,
+	// e.g. because the inputs of the task differ on the worker.
+	JobStateError JobState = "error"
+	// JobStateCanceled is the state of a queued job that was canceled
+	// before a worker claimed it.
+	JobStateCanceled JobState = "canceled"
+)
+
+// IsFinished returns true if the job reached a final state.
+func (s JobState) IsFinished() bool {
+	return s != JobStateQueued && s != JobStateRunning
+}
+
+// TaskRunJob describes the execution of a task at a VCS revision that is
+// processed by a worker.
+type TaskRunJob struct {
+	ApplicationName  string
+	TaskName         string
+	VCSRevision      string
+	TotalInputDigest string
+}
+
+type TaskRunJobWithID struct {
+	ID int
+	TaskRunJob
+	State JobState
+	// Worker identifies the worker that claimed the job.
+	Worker string
+	// TaskRunID is the ID of the task run that was recorded by the
+	// worker, it is 0 if no run was recorded.
+	TaskRunID int
+	// Error describes why the job failed.
+	Error string
+}
+
 const (
 	NoLimit uint = 0
 )
@@ -149,8 +226,53 @@ type Storer interface {
 	Init(context.Context) error
 
 	SaveTaskRun(context.Context, *TaskRunFull) (id int, err error)
+	// LatestTaskRunByDigest returns the most recent successful task run
+	// of the task with the given total input digest.
+	// Failed task runs are ignored. If no matching record exists,
+	// ErrNotExist is returned.
 	LatestTaskRunByDigest(ctx context.Context, appName, taskName, totalInputDigest string) (*TaskRunWithID, error)
 
+	// AcquireTaskRunLease acquires the lease for executing the task with
+	// the given total input digest for holder. The lease expires after
+	// ttl, unless it is renewed. If the lease is already held by holder, it
+	// is renewed. If another holder has an unexpired lease, ErrExists is
+	// returned.
+	AcquireTaskRunLease(ctx context.Context, appName, taskName, totalInputDigest, holder string, ttl time.Duration) error
+	// RenewTaskRunLease sets the expiration of the lease of holder to ttl
+	// from now. If holder does not hold the lease, ErrNotExist is
+	// returned.
+	RenewTaskRunLease(ctx context.Context, appName, taskName, totalInputDigest, holder string, ttl time.Duration) error
+	// ReleaseTaskRunLease removes the lease of holder. If holder does not
+	// hold the lease, ErrNotExist is returned.
+	ReleaseTaskRunLease(ctx context.Context, appName, taskName, totalInputDigest, holder string) error
+
+	// EnqueueTaskRunJobs adds jobs in state JobStateQueued to the job
+	// queue. The IDs of the jobs are returned in the same order.
+	EnqueueTaskRunJobs(ctx context.Context, jobs []*TaskRunJob) ([]int, error)
+	// ClaimTaskRunJob changes the state of the oldest queued job, or of
+	// the oldest running job whose lease expired, to JobStateRunning and
+	// returns it. The lease of the job expires after ttl, unless it is
+	// renewed. Jobs whose lease expired were claimed by workers that
+	// terminated unexpectedly. Jobs that are claimed concurrently by other
+	// workers are skipped. If no claimable job exists, ErrNotExist is
+	// returned.
+	ClaimTaskRunJob(ctx context.Context, worker string, ttl time.Duration) (*TaskRunJobWithID, error)
+	// RenewTaskRunJob sets the expiration of the lease of the running job
+	// to ttl from now. If the job is not running or was claimed by another
+	// worker, ErrNotExist is returned.
+	RenewTaskRunJob(ctx context.Context, id int, worker string, ttl time.Duration) error
+	// FinishTaskRunJob sets the final state of a job that is run by
+	// worker. taskRunID is the ID of the recorded task run, 0 if none was
+	// recorded. errMsg describes why the job failed, it can be empty.
+	// If the job is not running or was claimed by another worker,
+	// ErrNotExist is returned.
+	FinishTaskRunJob(ctx context.Context, id int, worker string, state JobState, taskRunID int, errMsg string) error
+	// CancelTaskRunJobs sets the state of the passed jobs that are still
+	// queued to JobStateCanceled. It returns the number of canceled jobs.
+	CancelTaskRunJobs(ctx context.Context, ids []int) (int64, error)
+	// TaskRunJobs returns the jobs with the passed IDs.
+	TaskRunJobs(ctx context.Context, ids []int) ([]*TaskRunJobWithID, error)
+
 	TaskRun(ctx context.Context, id int) (*TaskRunWithID, error)
 	// TaskRuns queries the storage for runs that match the filters.
 	// A limit value of 0 will return all results.
@@ -165,11 +287,20 @@ type Storer interface {
 		callback func(*TaskRunWithID) error,
 	) error
 	TaskRunsDelete(ctx context.Context, before time.Time, pretend bool) (*TaskRunsDeleteResult, error)
+	// AvgTaskRunDurations returns for each task of the applications
+	// appNames the average duration of its last maxRuns successful runs
+	// that stopped before stoppedBefore. The last runs are the ones that
+	// were recorded last. Tasks without such runs are not contained in
+	// the result.
+	AvgTaskRunDurations(ctx context.Context, appNames []string, stoppedBefore time.Time, maxRuns int) ([]*TaskRunDuration, error)
 
 	// Inputs returns the inputs of a task run. If no records were found,
 	// the method returns ErrNotExist.
 	Inputs(ctx context.Context, taskRunID int) (*Inputs, error)
 	Outputs(ctx context.Context, taskRunID int) ([]*Output, error)
+	// TaskRunLog returns the log of a task run. If no log was recorded,
+	// the method returns ErrNotExist.
+	TaskRunLog(ctx context.Context, taskRunID int) (*TaskRunLog, error)
 
 	// CreateRelease creates a new release called releaseName, that
 	// consists of the passed task runs. Metadata is arbitrary data stored
@@ -179,7 +310,8 @@ type Storer interface {
 	// ReleaseTaskRuns the task runs and their outputs for the release
 	// named releaseName.
 	// If a task has no outputs the [ReleaseTaskRunsResult.OutputID]
-	// [ReleaseTaskRunsResult.OutputName], [ReleaseTaskRunsResult.URI],
+	// [ReleaseTaskRunsResult.OutputName], [ReleaseTaskRunsResult.OutputType],
+	// [ReleaseTaskRunsResult.URI],
 	// [ReleaseTaskRunsResult.UploadMethod] fields are unset.
 	// If the release does not exist, ErrNotExist is returned.
 	ReleaseTaskRuns(ctx context.Context, releaseName string) ([]*ReleaseTaskRunsResult, error)